@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/ed25519"
 	"crypto/rand"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -290,6 +291,27 @@ func (s SigninPayload) Validate() error {
 	return nil
 }
 
+var (
+	signinHooksMutex sync.RWMutex
+	signinHooks      []func(address string)
+)
+
+// OnSignin registers a function called with the wallet address after every successful sign-in
+// (and with the vault address too when a delegate.cash delegate signs in)
+func OnSignin(hook func(address string)) {
+	signinHooksMutex.Lock()
+	defer signinHooksMutex.Unlock()
+	signinHooks = append(signinHooks, hook)
+}
+
+func runSigninHooks(address string) {
+	signinHooksMutex.RLock()
+	defer signinHooksMutex.RUnlock()
+	for _, hook := range signinHooks {
+		hook(address)
+	}
+}
+
 // SigninHandler godoc
 // @Summary     Sign In with signed nonce value, currently JWT token returned should be valid for 24 hours
 // @Description Every call the to API after this signin should present the JWT Bearer token for authenticated access.
@@ -352,6 +374,11 @@ func SigninHandler(jwtProvider *JwtHmacProvider) http.HandlerFunc {
 			}
 		}
 
+		runSigninHooks(address)
+		if !strings.EqualFold(Authuser.Address, address) {
+			runSigninHooks(Authuser.Address)
+		}
+
 		wc_analytics.SendCustomEvent(Authuser.Address, "CONNECT_WALLET_SIGNIN")
 
 		signedToken, err := jwtProvider.CreateStandard(Authuser.Address)
@@ -416,6 +443,21 @@ func GetUserFromReqContext(r *http.Request) Authuser {
 
 var apiTrackerCnt = make(map[string]int32)
 
+// IsAdminApiKey checks the bearer token is one of the WalletChat admin API keys (comma separated ADMIN_API_KEY_LIST),
+// keys are compared whole and in constant time
+func IsAdminApiKey(tokenString string) bool {
+	if len(tokenString) < 16 {
+		return false
+	}
+	for _, apiKey := range strings.Split(os.Getenv("ADMIN_API_KEY_LIST"), ",") {
+		apiKey = strings.TrimSpace(apiKey)
+		if apiKey != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(tokenString)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
 func AuthMiddleware(jwtProvider *JwtHmacProvider) func(next http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -448,7 +490,7 @@ func AuthMiddleware(jwtProvider *JwtHmacProvider) func(next http.Handler) http.H
 				return
 			}
 
-			if strings.Contains(os.Getenv("ADMIN_API_KEY_LIST"), tokenString) {
+			if IsAdminApiKey(tokenString) {
 				var authAdmin Authuser
 				authAdmin.Address = tokenString[0:16]
 				authAdmin.Nonce = "none"
@@ -0,0 +1,23 @@
+package auth
+
+import "testing"
+
+func TestIsAdminApiKey(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY_LIST", "key0123456789abcdef, other-key-0123456789abcdefghij")
+	cases := []struct {
+		token string
+		want  bool
+	}{
+		{"key0123456789abcdef", true},
+		{"other-key-0123456789abcdefghij", true},
+		{"key0123456789abcde", false},              //a prefix of a key
+		{"0123456789abcdef, other", false},         //a span across the separator
+		{"other-key-0123456789abcdefghijk", false}, //longer than the key
+		{"", false},
+	}
+	for _, c := range cases {
+		if got := IsAdminApiKey(c.token); got != c.want {
+			t.Errorf("IsAdminApiKey(%q) = %v, want %v", c.token, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,639 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"regexp"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/onchain"
+	"rest-go-demo/poap"
+	"rest-go-demo/ttlcache"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// access condition types, stored in Communityaccesscondition.Type (empty is erc721 for rows from before types existed)
+const (
+	AccessTypeErc721  = "erc721"  //hold at least Count NFTs of the contract (any token, Tokenid, a token id range and/or a trait)
+	AccessTypeErc1155 = "erc1155" //hold at least Count of the contract (any token, Tokenid, a token id range and/or a trait)
+	AccessTypeErc20   = "erc20"   //hold at least Count tokens, in whole token units (decimals allowed, e.g. 0.5)
+	AccessTypePoap    = "poap"    //hold the POAP of event Nftaddr, or of any event in a comma separated list (poap_ prefix optional)
+)
+
+const (
+	accessAllowedTtl    = 15 * time.Minute
+	accessDeniedTtl     = 2 * time.Minute //short so people who just bought in don't wait long
+	maxAccessGroups     = 10
+	maxAccessConditions = 10 //per group
+	accessRecheckPause  = 200 * time.Millisecond
+	tokenTraitsTtl      = time.Hour //metadata rarely changes, reveals are the exception
+	maxTraitLookups     = 50        //uncached metadata fetches per wallet check, big wallets finish over several checks
+	accessCacheEntries  = 50000
+	tokenTraitsEntries  = 50000
+)
+
+var (
+	errCommunityAccessDenied = errors.New("you don't meet this community's access conditions")
+	errTraitLookupsExceeded  = errors.New("too many tokens to check the traits of, please retry later")
+)
+
+var (
+	evmAddressPattern = regexp.MustCompile(`^0x[0-9a-fA-F]{40}$`)
+	digitsPattern     = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// AccessConditionsRequest is the full rule set: every condition in a group must pass, any passing group grants access
+type AccessConditionsRequest struct {
+	Groups [][]entity.Communityaccesscondition `json:"groups"`
+}
+
+// AccessConditionResult is one evaluated condition, Checked is false when an earlier result already decided its group
+type AccessConditionResult struct {
+	entity.Communityaccesscondition
+	Checked bool   `json:"checked"`
+	Passed  bool   `json:"passed"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AccessCheck is the result of evaluating a community's conditions for one wallet
+type AccessCheck struct {
+	Slug    string                    `json:"slug"`
+	Channel string                    `json:"channel,omitempty"`
+	Gated   bool                      `json:"gated"`
+	Allowed bool                      `json:"allowed"`
+	Error   string                    `json:"error,omitempty"` //set when a provider failed and no group passed, the result is not cached
+	Groups  [][]AccessConditionResult `json:"groups"`
+	Checked time.Time                 `json:"checked"`
+}
+
+var (
+	accessCache      = ttlcache.New[AccessCheck](accessCacheEntries)
+	tokenTraitsCache = ttlcache.New[map[string]string](tokenTraitsEntries)
+)
+
+func accessCacheKey(slug string, walletaddr string) string {
+	return slug + "|" + strings.ToLower(walletaddr)
+}
+
+// channel results share the community prefix so invalidateAccessCache drops them too
+func channelAccessCacheKey(slug string, channel string, walletaddr string) string {
+	if channel == "" {
+		return accessCacheKey(slug, walletaddr)
+	}
+	return accessCacheKey(slug, walletaddr) + "#" + channel
+}
+
+// invalidateAccessCache drops every cached result for the community (conditions changed)
+func invalidateAccessCache(slug string) {
+	accessCache.DeletePrefix(slug + "|")
+}
+
+func getAccessConditions(slug string) []entity.Communityaccesscondition {
+	return getChannelAccessConditions(slug, "")
+}
+
+// getChannelAccessConditions returns the conditions of one channel, the empty channel is the community itself
+func getChannelAccessConditions(slug string, channel string) []entity.Communityaccesscondition {
+	var conditions []entity.Communityaccesscondition
+	database.Connector.Where("slug = ?", slug).Where("channel = ?", channel).Order("groupid asc, id asc").Find(&conditions)
+	return conditions
+}
+
+// IsCommunityGated checks the community has any access conditions
+func IsCommunityGated(slug string) bool {
+	return isChannelGated(slug, "")
+}
+
+func isChannelGated(slug string, channel string) bool {
+	var conditions []entity.Communityaccesscondition
+	return database.Connector.Where("slug = ?", slug).Where("channel = ?", channel).Limit(1).Find(&conditions).RowsAffected > 0
+}
+
+func accessConditionType(condition entity.Communityaccesscondition) string {
+	if condition.Type == "" {
+		return AccessTypeErc721
+	}
+	return condition.Type
+}
+
+func accessConditionChainList(condition entity.Communityaccesscondition) []string {
+	if condition.Chain == "" {
+		return holderChains("") //same order as the holder checks of NFT chats
+	}
+	return []string{condition.Chain}
+}
+
+// nftHolding counts the NFTs (ERC-1155 amounts) of a contract the wallet holds, optionally only one token id
+func nftHolding(contractAddr string, walletAddr string, chain string, tokenId string) (*big.Int, error) {
+	return onchain.NftBalance(chain, contractAddr, walletAddr, tokenId)
+}
+
+// cachedTokenTraits returns the token's traits and whether they came from the cache, tokens without metadata
+// are cached as having no traits
+func cachedTokenTraits(chain string, contractAddr string, tokenId string) (map[string]string, bool, error) {
+	key := chain + "|" + strings.ToLower(contractAddr) + "|" + tokenId
+	if traits, found := tokenTraitsCache.Get(key); found {
+		return traits, true, nil
+	}
+
+	traits, err := onchain.TokenTraits(chain, contractAddr, tokenId)
+	if errors.Is(err, onchain.ErrNotFound) {
+		traits, err = map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	tokenTraitsCache.Set(key, traits, tokenTraitsTtl)
+	return traits, false, nil
+}
+
+// tokenIdInRange checks an inclusive range, empty ends are open
+func tokenIdInRange(tokenId string, minimum string, maximum string) bool {
+	id, ok := new(big.Int).SetString(tokenId, 10)
+	if !ok {
+		return false
+	}
+	if low, ok := new(big.Int).SetString(minimum, 10); ok && id.Cmp(low) < 0 {
+		return false
+	}
+	if high, ok := new(big.Int).SetString(maximum, 10); ok && id.Cmp(high) > 0 {
+		return false
+	}
+	return true
+}
+
+func isSelectiveCondition(condition entity.Communityaccesscondition) bool {
+	return condition.Tokenidmin != "" || condition.Tokenidmax != "" || condition.Traittype != ""
+}
+
+// selectiveNftHolding counts the wallet's NFTs of the contract in the token id range and/or with the trait,
+// it stops as soon as the minimum is reached
+func selectiveNftHolding(condition entity.Communityaccesscondition, walletAddr string, chain string, minimum *big.Int) (*big.Int, error) {
+	nfts, err := onchain.OwnedNfts(chain, walletAddr, []string{condition.Nftaddr})
+	if err != nil {
+		return nil, err
+	}
+
+	total := big.NewInt(0)
+	lookups := 0
+	for _, nft := range nfts {
+		if !strings.EqualFold(nft.Contract, condition.Nftaddr) || !tokenIdInRange(nft.Tokenid, condition.Tokenidmin, condition.Tokenidmax) {
+			continue
+		}
+		if condition.Traittype != "" {
+			if lookups >= maxTraitLookups {
+				//the traits fetched so far are cached, so a retry gets further instead of denying on a partial count
+				return nil, errTraitLookupsExceeded
+			}
+			traits, cached, err := cachedTokenTraits(chain, condition.Nftaddr, nft.Tokenid)
+			if !cached {
+				lookups++
+			}
+			if err != nil {
+				return nil, err
+			}
+			if !strings.EqualFold(traits[strings.ToLower(condition.Traittype)], condition.Traitvalue) {
+				continue
+			}
+		}
+		amount, ok := new(big.Int).SetString(nft.Amount, 10)
+		if !ok || amount.Sign() < 0 {
+			amount = big.NewInt(1)
+		}
+		total.Add(total, amount)
+		if total.Cmp(minimum) >= 0 {
+			break
+		}
+	}
+	return total, nil
+}
+
+// erc20Holding is the wallet's balance of the token in whole token units
+func erc20Holding(contractAddr string, walletAddr string, chain string) (*big.Float, error) {
+	balance, err := onchain.GetTokenBalance(chain, contractAddr, walletAddr)
+	if err != nil {
+		return nil, err
+	}
+	return balance.Units(), nil
+}
+
+// holdingMeets checks one wallet (not its delegates) against a count based condition
+func holdingMeets(condition entity.Communityaccesscondition, walletAddr string, chain string) (bool, error) {
+	count := strings.TrimSpace(condition.Count)
+	if count == "" {
+		count = "1" //rows from before counts were stored
+	}
+	switch accessConditionType(condition) {
+	case AccessTypeErc20:
+		minimum, ok := new(big.Float).SetString(count)
+		if !ok {
+			return false, fmt.Errorf("access condition %d has an invalid count %q", condition.Id, condition.Count)
+		}
+		balance, err := erc20Holding(condition.Nftaddr, walletAddr, chain)
+		if err != nil {
+			return false, err
+		}
+		return balance.Cmp(minimum) >= 0, nil
+	default:
+		minimum, ok := new(big.Int).SetString(count, 10)
+		if !ok {
+			return false, fmt.Errorf("access condition %d has an invalid count %q", condition.Id, condition.Count)
+		}
+		if isSelectiveCondition(condition) {
+			holding, err := selectiveNftHolding(condition, walletAddr, chain, minimum)
+			if err != nil {
+				return false, err
+			}
+			return holding.Cmp(minimum) >= 0, nil
+		}
+		holding, err := nftHolding(condition.Nftaddr, walletAddr, chain, condition.Tokenid)
+		if err != nil {
+			return false, err
+		}
+		return holding.Cmp(minimum) >= 0, nil
+	}
+}
+
+// evaluateAccessCondition checks a single condition, for the wallet and the vaults that delegated to it (delegate.cash)
+func evaluateAccessCondition(condition entity.Communityaccesscondition, walletAddr string) (bool, error) {
+	if accessConditionType(condition) == AccessTypePoap {
+		eventIds, _ := poapEventIds(condition.Nftaddr)
+		return holdsAnyPoap(eventIds, walletAddr)
+	}
+
+	//the common "own any NFT of the collection" case uses the same holder check as NFT chats
+	simpleNft := accessConditionType(condition) == AccessTypeErc721 && condition.Tokenid == "" && !isSelectiveCondition(condition) &&
+		(condition.Count == "" || condition.Count == "0" || condition.Count == "1")
+
+	if simpleNft {
+		return ownsOnChains(condition.Nftaddr, walletAddr, accessConditionChainList(condition))
+	}
+
+	var lastErr error
+	wallets := append([]string{walletAddr}, newWalletVaults(walletAddr).get()...)
+	for _, chain := range accessConditionChainList(condition) {
+		for _, wallet := range wallets {
+			passed, err := holdingMeets(condition, wallet, chain)
+			if err != nil {
+				lastErr = err
+				continue
+			}
+			if passed {
+				return true, nil
+			}
+		}
+	}
+	return false, lastErr
+}
+
+// EvaluateCommunityAccess evaluates the conditions without the cache, groups short-circuit to save provider calls
+func EvaluateCommunityAccess(slug string, walletaddr string) AccessCheck {
+	return evaluateChannelAccess(slug, "", walletaddr)
+}
+
+// evaluateChannelAccess only looks at the channel's own conditions, the community ones are checked separately
+func evaluateChannelAccess(slug string, channel string, walletaddr string) AccessCheck {
+	check := AccessCheck{Slug: slug, Channel: channel, Groups: [][]AccessConditionResult{}, Checked: time.Now()}
+	conditions := getChannelAccessConditions(slug, channel)
+	if len(conditions) == 0 {
+		check.Allowed = true
+		return check
+	}
+	check.Gated = true
+
+	//conditions come sorted by group
+	for i := 0; i < len(conditions); {
+		var group []AccessConditionResult
+		groupid := conditions[i].Groupid
+		for ; i < len(conditions) && conditions[i].Groupid == groupid; i++ {
+			group = append(group, AccessConditionResult{Communityaccesscondition: conditions[i]})
+		}
+
+		groupPassed := !check.Allowed
+		for j := range group {
+			if !groupPassed {
+				break
+			}
+			passed, err := evaluateAccessCondition(group[j].Communityaccesscondition, walletaddr)
+			group[j].Checked = true
+			group[j].Passed = passed
+			if err != nil {
+				group[j].Error = err.Error()
+				check.Error = "could not check all conditions, please try again"
+			}
+			groupPassed = passed
+		}
+		if groupPassed {
+			check.Allowed = true
+		}
+		check.Groups = append(check.Groups, group)
+	}
+	if check.Allowed {
+		check.Error = ""
+	}
+	return check
+}
+
+// GetCommunityAccess returns the cached result, evaluating when missing or expired
+func GetCommunityAccess(slug string, walletaddr string) AccessCheck {
+	return getChannelAccess(slug, "", walletaddr)
+}
+
+func getChannelAccess(slug string, channel string, walletaddr string) AccessCheck {
+	key := channelAccessCacheKey(slug, channel, walletaddr)
+	if check, found := accessCache.Get(key); found {
+		return check
+	}
+
+	check := evaluateChannelAccess(slug, channel, walletaddr)
+	if check.Error == "" {
+		ttl := accessDeniedTtl
+		if check.Allowed {
+			ttl = accessAllowedTtl
+		}
+		accessCache.Set(key, check, ttl)
+	}
+	return check
+}
+
+// communityAccessAllowed - staff always pass, members keep access while a provider is down (the recheck job catches up)
+func communityAccessAllowed(slug string, walletaddr string) bool {
+	if !IsCommunityGated(slug) || isCommunityStaff(slug, walletaddr) {
+		return true
+	}
+	check := GetCommunityAccess(slug, walletaddr)
+	if check.Allowed {
+		return true
+	}
+	return check.Error != "" && IsCommunityMember(slug, walletaddr)
+}
+
+// canReadCommunity - open ungated communities are public to signed in users, otherwise members (or staff) meeting the conditions
+func canReadCommunity(slug string, walletaddr string) bool {
+	if IsBannedFromCommunity(slug, walletaddr) {
+		return false
+	}
+	if GetCommunityJoinMode(slug) != entity.JoinModeOpen && !IsCommunityMember(slug, walletaddr) && !isCommunityStaff(slug, walletaddr) {
+		return false
+	}
+	return communityAccessAllowed(slug, walletaddr)
+}
+
+// RecheckGatedCommunities removes members that no longer meet the conditions (sold the NFT etc), run periodically
+// provider errors never remove anyone
+func RecheckGatedCommunities() {
+	var slugs []string
+	database.Connector.Model(&entity.Communityaccesscondition{}).Where("channel = ?", "").Pluck("DISTINCT slug", &slugs)
+	for _, slug := range slugs {
+		RecheckCommunityAccess(slug)
+	}
+}
+
+// RecheckCommunityAccess re-evaluates every active member of one community
+func RecheckCommunityAccess(slug string) {
+	var members []entity.Communitymember
+	database.Connector.Where("slug = ?", slug).Where("status = ?", entity.MemberStatusActive).Find(&members)
+
+	removed := 0
+	for _, member := range members {
+		if isCommunityStaff(slug, member.Walletaddr) {
+			continue
+		}
+		check := EvaluateCommunityAccess(slug, member.Walletaddr)
+		accessCache.Delete(accessCacheKey(slug, member.Walletaddr))
+		if !check.Allowed && check.Error == "" {
+			deactivateCommunityMember(member, entity.MemberStatusLeft, settingsAuditSourceSystem)
+			removed++
+		}
+		time.Sleep(accessRecheckPause) //stay within provider rate limits
+	}
+	if removed > 0 {
+		log.Println("Access recheck removed members: ", slug, removed)
+	}
+}
+
+// groupAccessConditions turns the stored rows (sorted by group) back into the API shape
+func groupAccessConditions(conditions []entity.Communityaccesscondition) AccessConditionsRequest {
+	result := AccessConditionsRequest{Groups: [][]entity.Communityaccesscondition{}}
+	for i, condition := range conditions {
+		if i == 0 || condition.Groupid != conditions[i-1].Groupid {
+			result.Groups = append(result.Groups, []entity.Communityaccesscondition{})
+		}
+		result.Groups[len(result.Groups)-1] = append(result.Groups[len(result.Groups)-1], condition)
+	}
+	return result
+}
+
+// parseAccessConditions validates the request body and returns the rows to store, or a message for the first problem
+func parseAccessConditions(requestBody []byte, slug string, channel string) ([]entity.Communityaccesscondition, string) {
+	var request AccessConditionsRequest
+	if err := json.Unmarshal(requestBody, &request); err != nil {
+		return nil, "body must be {\"groups\": [[condition, ...], ...]}"
+	}
+	if len(request.Groups) > maxAccessGroups {
+		return nil, fmt.Sprintf("at most %d groups", maxAccessGroups)
+	}
+
+	var conditions []entity.Communityaccesscondition
+	for groupid, group := range request.Groups {
+		if len(group) == 0 || len(group) > maxAccessConditions {
+			return nil, fmt.Sprintf("groups need 1 to %d conditions", maxAccessConditions)
+		}
+		for _, condition := range group {
+			if problem := validateAccessCondition(&condition); problem != "" {
+				return nil, fmt.Sprintf("group %d: %s", groupid, problem)
+			}
+			condition.Id = 0
+			condition.Slug = slug
+			condition.Channel = channel
+			condition.Groupid = groupid
+			conditions = append(conditions, condition)
+		}
+	}
+	return conditions, ""
+}
+
+// replaceAccessConditions swaps all conditions of the community (empty channel) or of one channel
+func replaceAccessConditions(slug string, channel string, conditions []entity.Communityaccesscondition) {
+	database.Connector.Where("slug = ?", slug).Where("channel = ?", channel).Delete(&entity.Communityaccesscondition{})
+	for i := range conditions {
+		database.Connector.Create(&conditions[i])
+	}
+	invalidateAccessCache(slug)
+}
+
+// validateAccessCondition normalizes one condition from the API, returns a message for the first problem
+func validateAccessCondition(condition *entity.Communityaccesscondition) string {
+	condition.Type = strings.ToLower(strings.TrimSpace(condition.Type))
+	if condition.Type == "" {
+		condition.Type = AccessTypeErc721
+	}
+	condition.Chain = onchain.NormalizeChain(condition.Chain)
+	condition.Nftaddr = strings.TrimSpace(condition.Nftaddr)
+	condition.Tokenid = strings.TrimSpace(condition.Tokenid)
+	condition.Count = strings.TrimSpace(condition.Count)
+	condition.Tokenidmin = strings.TrimSpace(condition.Tokenidmin)
+	condition.Tokenidmax = strings.TrimSpace(condition.Tokenidmax)
+	condition.Traittype = strings.ToLower(strings.TrimSpace(condition.Traittype))
+	condition.Traitvalue = strings.TrimSpace(condition.Traitvalue)
+
+	switch condition.Type {
+	case AccessTypePoap:
+		eventIds, ok := poapEventIds(condition.Nftaddr)
+		if !ok {
+			return "poap address must be the event id, or a comma separated list of event ids"
+		}
+		if len(eventIds) > maxPoapGateEvents {
+			return fmt.Sprintf("at most %d poap events per condition", maxPoapGateEvents)
+		}
+		var chatAddrs []string
+		for _, eventId := range eventIds {
+			chatAddrs = append(chatAddrs, poap.ChatAddress(eventId))
+		}
+		condition.Nftaddr = strings.Join(chatAddrs, ",")
+		condition.Chain = ""
+		condition.Tokenid = ""
+		condition.Count = "1"
+		if isSelectiveCondition(*condition) {
+			return "token id ranges and traits are only for erc721 and erc1155"
+		}
+		return ""
+	case AccessTypeErc721, AccessTypeErc1155, AccessTypeErc20:
+	default:
+		return "type must be erc721, erc1155, erc20 or poap"
+	}
+
+	if !evmAddressPattern.MatchString(condition.Nftaddr) {
+		return "address must be a 0x contract address"
+	}
+	condition.Nftaddr = strings.ToLower(condition.Nftaddr)
+	if condition.Chain != "" && !onchain.IsEvmChain(condition.Chain) {
+		return "unsupported chain " + condition.Chain
+	}
+	if condition.Count == "" {
+		condition.Count = "1"
+	}
+	if condition.Type == AccessTypeErc20 {
+		if minimum, ok := new(big.Float).SetString(condition.Count); !ok || minimum.Sign() <= 0 {
+			return "count must be a positive token amount"
+		}
+		if condition.Tokenid != "" || isSelectiveCondition(*condition) {
+			return "token ids and traits are not used for erc20"
+		}
+		return ""
+	}
+	if count, err := strconv.Atoi(condition.Count); err != nil || count < 1 {
+		return "count must be a whole number of at least 1"
+	}
+	if condition.Tokenid != "" && !digitsPattern.MatchString(condition.Tokenid) {
+		return "token_id must be a number"
+	}
+	if condition.Tokenidmin != "" || condition.Tokenidmax != "" {
+		if condition.Tokenid != "" {
+			return "use either token_id or a token id range"
+		}
+		if (condition.Tokenidmin != "" && !digitsPattern.MatchString(condition.Tokenidmin)) ||
+			(condition.Tokenidmax != "" && !digitsPattern.MatchString(condition.Tokenidmax)) {
+			return "token_id_min and token_id_max must be numbers"
+		}
+		if condition.Tokenidmin != "" && condition.Tokenidmax != "" && !tokenIdInRange(condition.Tokenidmin, "", condition.Tokenidmax) {
+			return "token_id_min must not be above token_id_max"
+		}
+	}
+	if (condition.Traittype == "") != (condition.Traitvalue == "") {
+		return "trait_type and trait_value go together"
+	}
+	return ""
+}
+
+// ============================================================================
+
+// GetCommunityConditions godoc
+// @Summary     Get the access conditions of a community
+// @Description Every condition in a group must pass, any passing group grants access. No groups means anyone can join.
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug path     string true "community slug"
+// @Success     200  {object} AccessConditionsRequest
+// @Router      /v1/community/{slug}/conditions [get]
+func GetCommunityConditions(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+
+	result := groupAccessConditions(getAccessConditions(slug))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(result)
+}
+
+// SetCommunityConditions godoc
+// @Summary     Replace the access conditions of a community (admins only)
+// @Description groups is a list of AND groups, access is granted when any group passes (OR). An empty list removes gating.
+// @Description Condition types: erc721/erc1155 (count NFTs, optional token_id, token_id_min/token_id_max range or trait_type/trait_value), erc20 (count in whole tokens), poap (address is the event id, or a comma separated list of event ids: any of them)
+// @Description chain is ethereum, polygon, bsc, arbitrum, base, optimism or avalanche, empty checks ethereum then polygon
+// @Description Existing members are re-checked in the background
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string                  true "community slug"
+// @Param       message body     AccessConditionsRequest true "condition groups"
+// @Success     200     {object} AccessConditionsRequest
+// @Router      /v1/community/{slug}/conditions [put]
+func SetCommunityConditions(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isCommunityAdmin(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	conditions, problem := parseAccessConditions(requestBody, slug, "")
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+
+	replaceAccessConditions(slug, "", conditions)
+	go RecheckCommunityAccess(slug)
+	log.Println("Community conditions changed: ", slug, len(conditions), "by", Authuser.Address)
+
+	GetCommunityConditions(w, r)
+}
+
+// GetCommunityAccessCheck godoc
+// @Summary     Check if the signed in wallet meets a community's access conditions
+// @Description Results are cached for a few minutes, refresh=true re-checks now (e.g. right after buying the NFT)
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string true  "community slug"
+// @Param       refresh query    bool   false "skip the cache"
+// @Success     200     {object} AccessCheck
+// @Router      /v1/community/{slug}/access [get]
+func GetCommunityAccessCheck(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if r.URL.Query().Get("refresh") == "true" {
+		accessCache.Delete(accessCacheKey(slug, Authuser.Address))
+	}
+	check := GetCommunityAccess(slug, Authuser.Address)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(check)
+}
@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"errors"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"testing"
+)
+
+func TestRecheckKeepsMembersWhileTheProviderFails(t *testing.T) {
+	fake := useFakeChain(t)
+	useTestDB(t, &entity.Communityaccesscondition{}, &entity.Communityadmin{}, &entity.Communitymember{},
+		&entity.Bookmarkitem{}, &entity.Userunjoined{}, &entity.Settings{})
+	accessCache.Clear()
+	t.Cleanup(accessCache.Clear)
+	const slug = "holders"
+	database.Connector.Create(&entity.Communityaccesscondition{Slug: slug, Nftaddr: fixtureEthereum, Type: AccessTypeErc721, Chain: "ethereum"})
+	for _, wallet := range []string{fixtureHolder, fixtureDelegate} {
+		database.Connector.Create(&entity.Communitymember{Slug: slug, Walletaddr: wallet, Status: entity.MemberStatusActive})
+	}
+	activeMembers := func() int {
+		count := 0
+		database.Connector.Model(&entity.Communitymember{}).Where("slug = ?", slug).Where("status = ?", entity.MemberStatusActive).Count(&count)
+		return count
+	}
+
+	fake.Fail = errors.New("provider down")
+	if check := EvaluateCommunityAccess(slug, fixtureHolder); check.Allowed || check.Error == "" {
+		t.Errorf("check while the provider fails = %+v, want inconclusive", check)
+	}
+	RecheckCommunityAccess(slug)
+	if n := activeMembers(); n != 2 {
+		t.Fatalf("%d active members after a recheck during an outage, want both kept", n)
+	}
+
+	fake.Fail = nil
+	RecheckCommunityAccess(slug)
+	var member entity.Communitymember
+	database.Connector.Where("slug = ?", slug).Where("walletaddr = ?", fixtureDelegate).Find(&member)
+	if n := activeMembers(); n != 1 || member.Status != entity.MemberStatusLeft {
+		t.Errorf("%d active members and the non-holder is %q, want only the holder kept", n, member.Status)
+	}
+}
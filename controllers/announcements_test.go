@@ -0,0 +1,55 @@
+package controllers
+
+import (
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"testing"
+)
+
+func TestCheckReplyTo(t *testing.T) {
+	useTestDB(t, &entity.Groupchatitem{}, &entity.Communityadmin{})
+	const (
+		slug   = "gm"
+		admin  = "0x1111111111111111111111111111111111111111"
+		member = "0x2222222222222222222222222222222222222222"
+	)
+	database.Connector.Create(&entity.Communityadmin{Slug: slug, Adminaddr: admin, Accesslevel: entity.CommunityRoleAdmin})
+	announcement := entity.Groupchatitem{Nftaddr: slug, Fromaddr: admin, Type: entity.Announcement, Message: "v2 is live"}
+	message := entity.Groupchatitem{Nftaddr: slug, Fromaddr: member, Type: entity.Message, Message: "gm"}
+	elsewhere := entity.Groupchatitem{Nftaddr: "other", Fromaddr: member, Type: entity.Message, Message: "gm"}
+	for _, chat := range []*entity.Groupchatitem{&announcement, &message, &elsewhere} {
+		database.Connector.Create(chat)
+	}
+
+	cases := []struct {
+		name    string
+		wallet  string
+		replyto int
+		want    error
+	}{
+		{"not a reply", member, 0, nil},
+		{"member replies to a message", member, message.Id, nil},
+		{"member replies to an announcement", member, announcement.Id, errAnnouncementReply},
+		{"admin replies to an announcement", admin, announcement.Id, nil},
+		{"reply to another chat's message", member, elsewhere.Id, errReplyNotFound},
+		{"reply to a missing message", member, 9999, errReplyNotFound},
+	}
+	for _, c := range cases {
+		chat := entity.Groupchatitem{Nftaddr: slug, Fromaddr: c.wallet, Replyto: c.replyto}
+		if err := checkReplyTo(chat, c.wallet); err != c.want {
+			t.Errorf("%s: error %v, want %v", c.name, err, c.want)
+		}
+	}
+}
+
+func TestWalletsBlocking(t *testing.T) {
+	useTestDB(t, &entity.Blockeduser{})
+	const sender = "0x1111111111111111111111111111111111111111"
+	database.Connector.Create(&entity.Blockeduser{Owneraddress: "0xAAAA000000000000000000000000000000000000", Blockedaddress: "0x1111111111111111111111111111111111111111"})
+	database.Connector.Create(&entity.Blockeduser{Owneraddress: "0xbbbb000000000000000000000000000000000000", Blockedaddress: "0x3333333333333333333333333333333333333333"})
+
+	blocking := walletsBlocking(sender)
+	if len(blocking) != 1 || !blocking["0xaaaa000000000000000000000000000000000000"] {
+		t.Errorf("walletsBlocking = %v, want only the wallet that blocked the sender (lowercased)", blocking)
+	}
+}
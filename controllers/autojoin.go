@@ -0,0 +1,284 @@
+package controllers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	autoJoinInterval       = 24 * time.Hour      //scheduled refresh of active wallets
+	autoJoinActiveWindow   = 30 * 24 * time.Hour //wallets not seen for longer are only synced again when they come back
+	autoJoinActivityStep   = time.Hour           //inbox loads update Lastactive at most this often
+	autoJoinBatch          = 50                  //wallets per worker run
+	autoJoinClaimTimeout   = 15 * time.Minute
+	autoJoinBackfillSpread = time.Minute //each backfill batch waits this much longer, so sign-ins still go first
+)
+
+// what queued a sync, stored in Autojoinsync.Source
+const (
+	autoJoinSourceSignin   = "signin"
+	autoJoinSourceInbox    = "inbox"
+	autoJoinSourceSchedule = "schedule"
+	autoJoinSourceUser     = "user"
+	autoJoinSourceBackfill = "backfill"
+)
+
+// AutoJoinBackfillResult is the number of wallets queued by a backfill
+type AutoJoinBackfillResult struct {
+	Queued int `json:"queued"`
+}
+
+// only EVM wallets (and ENS names) hold the NFTs and POAPs auto-join looks for, same rule the inbox used
+func autoJoinEligible(walletaddr string) bool {
+	return strings.HasPrefix(walletaddr, "0x") || strings.HasSuffix(walletaddr, ".eth")
+}
+
+func getAutoJoinSync(walletaddr string) (entity.Autojoinsync, bool) {
+	var sync entity.Autojoinsync
+	found := database.Connector.Where("walletaddr = ?", walletaddr).Find(&sync).RowsAffected > 0
+	return sync, found
+}
+
+// queueAutoJoin makes the wallet's sync due at the given time, an earlier pending time or a running sync is kept
+func queueAutoJoin(walletaddr string, source string, at time.Time) {
+	if !autoJoinEligible(walletaddr) {
+		return
+	}
+	sync, found := getAutoJoinSync(walletaddr)
+	if !found {
+		sync = entity.Autojoinsync{
+			Walletaddr: walletaddr,
+			Status:     entity.AutoJoinStatusPending,
+			Nextsync:   at,
+			Lastactive: time.Now(),
+			Source:     source,
+		}
+		database.Connector.Create(&sync) //a concurrent create for the same wallet fails on the unique index, either one is fine
+		return
+	}
+	if sync.Status == entity.AutoJoinStatusRunning || (sync.Status == entity.AutoJoinStatusPending && !sync.Nextsync.After(at)) {
+		return
+	}
+	database.Connector.Model(&entity.Autojoinsync{}).Where("id = ?", sync.Id).Where("status <> ?", entity.AutoJoinStatusRunning).
+		Updates(map[string]interface{}{"status": entity.AutoJoinStatusPending, "nextsync": at, "source": source})
+}
+
+// QueueAutoJoinOnSignin is a sign-in hook, the wallet is synced on the worker's next run
+func QueueAutoJoinOnSignin(walletaddr string) {
+	queueAutoJoin(walletaddr, autoJoinSourceSignin, time.Now())
+	database.Connector.Model(&entity.Autojoinsync{}).Where("walletaddr = ?", walletaddr).Update("lastactive", time.Now())
+}
+
+// noteAutoJoinActivity keeps the wallet in the scheduled refresh, wallets seen for the first time are queued
+func noteAutoJoinActivity(walletaddr string) {
+	if !autoJoinEligible(walletaddr) {
+		return
+	}
+	if _, found := getAutoJoinSync(walletaddr); !found {
+		queueAutoJoin(walletaddr, autoJoinSourceInbox, time.Now())
+		return
+	}
+	database.Connector.Model(&entity.Autojoinsync{}).Where("walletaddr = ?", walletaddr).
+		Where("lastactive < ?", time.Now().Add(-autoJoinActivityStep)).Update("lastactive", time.Now())
+}
+
+// RunAutoJoinWorker syncs the wallets that are due, run on a schedule. State is in the DB so any replica can run it,
+// a wallet is only synced by the replica that claimed it.
+func RunAutoJoinWorker() {
+	//without an on-chain provider every wallet would look empty and be marked done, leave them pending
+	if len(holderChains("")) == 0 {
+		return
+	}
+	now := time.Now()
+	//syncs stuck in "running" (process restarted mid-sync) go back to pending
+	database.Connector.Model(&entity.Autojoinsync{}).Where("status = ?", entity.AutoJoinStatusRunning).
+		Where("claimed < ?", now.Add(-autoJoinClaimTimeout)).Update("status", entity.AutoJoinStatusPending)
+	//scheduled refresh of wallets that were active recently
+	database.Connector.Model(&entity.Autojoinsync{}).Where("status = ?", entity.AutoJoinStatusDone).
+		Where("nextsync <= ?", now).Where("lastactive >= ?", now.Add(-autoJoinActiveWindow)).
+		Updates(map[string]interface{}{"status": entity.AutoJoinStatusPending, "source": autoJoinSourceSchedule})
+
+	var due []entity.Autojoinsync
+	database.Connector.Where("status = ?", entity.AutoJoinStatusPending).Where("nextsync <= ?", now).
+		Order("nextsync asc").Limit(autoJoinBatch).Find(&due)
+	synced := 0
+	for _, sync := range due {
+		claimed := database.Connector.Model(&entity.Autojoinsync{}).Where("id = ?", sync.Id).Where("status = ?", entity.AutoJoinStatusPending).
+			Updates(map[string]interface{}{"status": entity.AutoJoinStatusRunning, "claimed": time.Now()}).RowsAffected
+		if claimed == 0 {
+			continue //another replica took it
+		}
+		syncAutoJoin(sync)
+		synced++
+	}
+	if synced > 0 {
+		log.Println("Auto-join worker synced wallets: ", synced)
+	}
+}
+
+// syncAutoJoin joins the NFT and POAP chats of everything the wallet (and its delegating vaults) holds,
+// chats the user left (Userunjoined) are never joined again
+func syncAutoJoin(sync entity.Autojoinsync) {
+	started := time.Now()
+	autoJoinCommunitiesOnChains(sync.Walletaddr, holderChains(""))
+	AutoJoinPoapChats(sync.Walletaddr)
+	fixUpBookmarkChains(sync.Walletaddr)
+
+	finished := time.Now()
+	database.Connector.Model(&entity.Autojoinsync{}).Where("id = ?", sync.Id).Updates(map[string]interface{}{
+		"status":     entity.AutoJoinStatusDone,
+		"lastsynced": finished,
+		"nextsync":   finished.Add(autoJoinInterval),
+		"duration":   int(finished.Sub(started) / time.Millisecond),
+	})
+}
+
+// fixUpBookmarkChains fills the chain of NFT bookmarks from before bookmarks stored it
+func fixUpBookmarkChains(walletaddr string) {
+	var bookmarks []entity.Bookmarkitem
+	database.Connector.Where("walletaddr = ?", walletaddr).Where("chain = ?", "").Where("nftaddr LIKE ?", "0x%").Find(&bookmarks)
+	for _, bookmark := range bookmarks {
+		if chain := contractChain(bookmark.Nftaddr); chain != "" {
+			database.Connector.Model(&entity.Bookmarkitem{}).Where("walletaddr = ?", bookmark.Walletaddr).Where("nftaddr = ?", bookmark.Nftaddr).Update("chain", chain)
+		}
+	}
+}
+
+// backfillAutoJoin queues every known wallet, in batches spread over the next worker runs
+func backfillAutoJoin() int {
+	var wallets []string
+	database.Connector.Model(&entity.Settings{}).Pluck("DISTINCT walletaddr", &wallets)
+	var bookmarkWallets []string
+	database.Connector.Model(&entity.Bookmarkitem{}).Pluck("DISTINCT walletaddr", &bookmarkWallets)
+
+	seen := map[string]bool{}
+	queued := 0
+	for _, walletaddr := range append(wallets, bookmarkWallets...) {
+		if seen[strings.ToLower(walletaddr)] || !autoJoinEligible(walletaddr) {
+			continue
+		}
+		seen[strings.ToLower(walletaddr)] = true
+		queueAutoJoin(walletaddr, autoJoinSourceBackfill, time.Now().Add(time.Duration(queued/autoJoinBatch)*autoJoinBackfillSpread))
+		queued++
+	}
+	return queued
+}
+
+// ============================================================================
+
+// GetAutoJoinStatus godoc
+// @Summary     When the signed in wallet's NFT and POAP chats were last auto-joined
+// @Description Wallets are synced in the background on sign-in and about once a day while active
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} entity.Autojoinsync
+// @Router      /v1/autojoin [get]
+func GetAutoJoinStatus(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+
+	sync, found := getAutoJoinSync(Authuser.Address)
+	if !found {
+		sync.Walletaddr = Authuser.Address
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(sync)
+}
+
+// RequestAutoJoin godoc
+// @Summary     Auto-join the signed in wallet's NFT and POAP chats again
+// @Description Queues a sync (e.g. right after buying an NFT), it runs in the background within a minute or two.
+// @Description Chats the user left are not joined again.
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Success     202 {object} entity.Autojoinsync
+// @Router      /v1/autojoin [post]
+func RequestAutoJoin(w http.ResponseWriter, r *http.Request) {
+	writeAutoJoinQueued(w, auth.GetUserFromReqContext(r).Address)
+}
+
+// BackfillAutoJoin godoc
+// @Summary     Queue auto-join syncs for every wallet, or one wallet
+// @Description Only for WalletChat, requires an admin API key as the Bearer token.
+// @Description Each sync also fills in the chain of old NFT bookmarks.
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Param       wallet query    string false "only this wallet"
+// @Success     202    {object} AutoJoinBackfillResult
+// @Router      /v1/autojoin/backfill [post]
+func BackfillAutoJoin(w http.ResponseWriter, r *http.Request) {
+	if !isAdminApiRequest(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	result := AutoJoinBackfillResult{}
+	if wallet := r.URL.Query().Get("wallet"); wallet != "" {
+		if !autoJoinEligible(wallet) {
+			http.Error(w, "auto-join is only for EVM wallets", http.StatusBadRequest)
+			return
+		}
+		queueAutoJoin(wallet, autoJoinSourceBackfill, time.Now())
+		result.Queued = 1
+	} else {
+		result.Queued = backfillAutoJoin()
+		log.Println("Auto-join backfill queued wallets: ", result.Queued)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(result)
+}
+
+// writeAutoJoinQueued queues a sync for the wallet and answers with its state
+func writeAutoJoinQueued(w http.ResponseWriter, walletaddr string) {
+	if !autoJoinEligible(walletaddr) {
+		http.Error(w, "auto-join is only for EVM wallets", http.StatusBadRequest)
+		return
+	}
+	queueAutoJoin(walletaddr, autoJoinSourceUser, time.Now())
+	sync, _ := getAutoJoinSync(walletaddr)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(sync)
+}
+
+// AutoJoinCommunities godoc
+// @Summary     Auto-join a wallet's NFT and POAP chats again (deprecated, use POST /v1/autojoin)
+// @Description Kept for older clients, queues a background sync of the wallet like POST /v1/autojoin.
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Param       wallet path     string true "wallet address"
+// @Success     202    {object} entity.Autojoinsync
+// @Router      /v1/rejoin_all/{wallet} [get]
+func AutoJoinCommunities(w http.ResponseWriter, r *http.Request) {
+	writeAutoJoinQueued(w, mux.Vars(r)["wallet"])
+}
+
+// FixUpBookmarks godoc
+// @Summary     Fill in the chain of the signed in wallet's old NFT bookmarks (deprecated, use POST /v1/autojoin)
+// @Description Kept for older clients, queues a background sync of the signed in wallet, which also fixes its bookmarks.
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Success     202 {object} entity.Autojoinsync
+// @Router      /v1/backfill_all_bookmarks [get]
+func FixUpBookmarks(w http.ResponseWriter, r *http.Request) {
+	writeAutoJoinQueued(w, auth.GetUserFromReqContext(r).Address)
+}
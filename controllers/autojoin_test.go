@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"rest-go-demo/auth"
+	"rest-go-demo/entity"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func TestDeprecatedAutoJoinRoutesQueueSyncs(t *testing.T) {
+	useTestDB(t, &entity.Autojoinsync{})
+	const (
+		wallet   = "0x1111111111111111111111111111111111111111"
+		signedIn = "0x2222222222222222222222222222222222222222"
+	)
+
+	r := mux.SetURLVars(httptest.NewRequest("GET", "/v1/rejoin_all/"+wallet, nil), map[string]string{"wallet": wallet})
+	w := httptest.NewRecorder()
+	AutoJoinCommunities(w, r)
+	if sync, found := getAutoJoinSync(wallet); w.Code != http.StatusAccepted || !found || sync.Status != entity.AutoJoinStatusPending {
+		t.Errorf("/rejoin_all: %d %+v", w.Code, sync)
+	}
+
+	r = httptest.NewRequest("GET", "/v1/backfill_all_bookmarks", nil)
+	r = r.WithContext(context.WithValue(r.Context(), "Authuser", auth.Authuser{Address: signedIn}))
+	w = httptest.NewRecorder()
+	FixUpBookmarks(w, r)
+	if sync, found := getAutoJoinSync(signedIn); w.Code != http.StatusAccepted || !found || sync.Status != entity.AutoJoinStatusPending {
+		t.Errorf("/backfill_all_bookmarks: %d %+v", w.Code, sync)
+	}
+
+	r = mux.SetURLVars(httptest.NewRequest("GET", "/v1/rejoin_all/example.near", nil), map[string]string{"wallet": "example.near"})
+	w = httptest.NewRecorder()
+	AutoJoinCommunities(w, r)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("/rejoin_all for a NEAR wallet: %d", w.Code)
+	}
+}
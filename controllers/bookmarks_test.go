@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"testing"
+)
+
+func createBookmark(t *testing.T, bookmark entity.Bookmarkitem) *httptest.ResponseRecorder {
+	t.Helper()
+	body, _ := json.Marshal(bookmark)
+	r := httptest.NewRequest("POST", "/v1/create_bookmark", bytes.NewBuffer(body))
+	r = r.WithContext(context.WithValue(r.Context(), "Authuser", auth.Authuser{Address: bookmark.Walletaddr}))
+	w := httptest.NewRecorder()
+	CreateBookmarkItem(w, r)
+	return w
+}
+
+func TestCreateBookmarkKeepsStoredChains(t *testing.T) {
+	useFakeChain(t)
+	useTestDB(t, &entity.Bookmarkitem{}, &entity.Communityitem{}, &entity.Communitymember{}, &entity.Communityadmin{},
+		&entity.Communitypolicy{}, &entity.Communityaccesscondition{}, &entity.Userunjoined{}, &entity.Settings{},
+		&entity.Groupchatitem{}, &entity.Addrnameitem{})
+	const wallet = "0x1111111111111111111111111111111111111111"
+	database.Connector.Create(&entity.Communityitem{Slug: "gm", Name: "GM"})
+
+	//a bookmark fetched from the inbox is sent back as is, with the chain the server stored
+	w := createBookmark(t, entity.Bookmarkitem{Walletaddr: wallet, Nftaddr: "gm", Chain: "none"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("community bookmark: %d %s", w.Code, w.Body.String())
+	}
+	if member, found := getCommunityMember("gm", wallet); !found || member.Status != entity.MemberStatusActive {
+		t.Errorf("the wallet didn't join the community: %+v", member)
+	}
+
+	w = createBookmark(t, entity.Bookmarkitem{Walletaddr: wallet, Nftaddr: "poap_1234", Chain: "xdai"})
+	var poapBookmark entity.Bookmarkitem
+	json.Unmarshal(w.Body.Bytes(), &poapBookmark)
+	if w.Code != http.StatusCreated || poapBookmark.Chain != "xdai" {
+		t.Errorf("POAP bookmark: %d %+v", w.Code, poapBookmark)
+	}
+
+	if w := createBookmark(t, entity.Bookmarkitem{Walletaddr: wallet, Nftaddr: fixtureEthereum, Chain: "dogechain"}); w.Code != http.StatusBadRequest {
+		t.Errorf("an NFT bookmark on an unsupported chain: %d", w.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/onchain"
+	"strings"
+)
+
+// validChain normalizes a chain sent by a client, empty stays empty (try every enabled chain)
+func validChain(chain string) (string, bool) {
+	if strings.TrimSpace(chain) == "" {
+		return "", true
+	}
+	chain = onchain.NormalizeChain(chain)
+	return chain, onchain.IsChain(chain)
+}
+
+// chainParam reads the optional ?chain= of a request, writing a 400 when it is not an enabled chain
+func chainParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	chain, ok := validChain(r.URL.Query().Get("chain"))
+	if !ok {
+		http.Error(w, "unsupported chain", http.StatusBadRequest)
+	}
+	return chain, ok
+}
+
+func isNearWallet(walletAddr string) bool {
+	return strings.HasSuffix(walletAddr, ".near") || strings.HasSuffix(walletAddr, ".testnet") ||
+		(len(walletAddr) == 64 && !strings.HasPrefix(walletAddr, "0x"))
+}
+
+// holderChains are the EVM chains a contract is checked on, the given one or the registry in order
+// (skipping chains no configured provider can answer for)
+func holderChains(chain string) []string {
+	if chain != "" {
+		return []string{chain}
+	}
+	chains := []string{}
+	for _, evmChain := range onchain.EvmChains() {
+		if onchain.Supports(evmChain) {
+			chains = append(chains, evmChain)
+		}
+	}
+	return chains
+}
+
+// isGroupHolder is the holder check of NFT group chats: EVM contracts on the chain (every enabled chain when empty),
+// NEAR and Tezos by wallet format, POAPs by event
+func isGroupHolder(nftaddr string, walletAddr string, chain string) bool {
+	if strings.HasPrefix(nftaddr, "0x") {
+		evmChains := []string{}
+		for _, holderChain := range holderChains(chain) {
+			if onchain.IsEvmChain(holderChain) {
+				evmChains = append(evmChains, holderChain)
+			}
+		}
+		return isOwnerOnChains(nftaddr, walletAddr, evmChains)
+	}
+	if isNearWallet(walletAddr) {
+		return IsOwnerOfNFT(nftaddr, walletAddr, onchain.ChainNear)
+	}
+	if strings.HasPrefix(walletAddr, "tz") {
+		return IsOwnerOfNFT(nftaddr, walletAddr, onchain.ChainTezos)
+	}
+	if strings.HasPrefix(nftaddr, "poap_") {
+		split := strings.Split(nftaddr, "_")
+		return IsOwnerOfPOAP(split[1], walletAddr)
+	}
+	return false
+}
+
+// groupChain is the chain to check an NFT group chat on: the given one, else the chain stored with the
+// wallet's bookmark of the chat, empty (every enabled chain) when neither is known
+func groupChain(nftaddr string, walletAddr string, chain string) string {
+	if chain != "" || !strings.HasPrefix(nftaddr, "0x") {
+		return chain
+	}
+	var bookmark entity.Bookmarkitem
+	database.Connector.Where("nftaddr = ?", nftaddr).Where("walletaddr = ?", walletAddr).Find(&bookmark)
+	if onchain.IsEvmChain(bookmark.Chain) {
+		return bookmark.Chain
+	}
+	return ""
+}
+
+// contractChain finds the first enabled EVM chain the NFT contract is deployed on, empty when none
+func contractChain(nftaddr string) string {
+	for _, chain := range holderChains("") {
+		if IsOnChain(nftaddr, chain) {
+			return chain
+		}
+	}
+	return ""
+}
+
+// GetChains godoc
+// @Summary     Chains NFT chats, bookmarks and access conditions can use
+// @Description EVM chains are listed first, in the order holder checks try them when no chain is given
+// @Tags        NFT
+// @Produce     json
+// @Success     200 {array} onchain.Chain
+// @Router      /chains [get]
+func GetChains(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(onchain.Chains())
+}
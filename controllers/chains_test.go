@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"errors"
+	"reflect"
+	"rest-go-demo/onchain"
+	"testing"
+
+	delegatecash "rest-go-demo/contracts"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+const (
+	fixtureHolder     = "0x1111111111111111111111111111111111111111"
+	fixtureDelegate   = "0x2222222222222222222222222222222222222222"
+	fixtureEthereum   = "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa" //erc721 on ethereum
+	fixturePolygon    = "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb" //erc1155 on polygon
+	fixtureNotHeld    = "0xdddddddddddddddddddddddddddddddddddddddd"
+	fixtureNearWallet = "example.near"
+)
+
+// useFakeChain answers holder checks from onchain/fixtures/holders.json with no delegations,
+// everything is put back when the test ends
+func useFakeChain(t *testing.T) *onchain.FakeProvider {
+	t.Helper()
+	fixtures, err := onchain.LoadFixtures("../onchain/fixtures/holders.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := onchain.NewFakeProvider(fixtures)
+	onchain.SetProvider(fake)
+	lookup := delegationsByDelegate
+	delegationsByDelegate = func(string) []delegatecash.IDelegationRegistryDelegationInfo { return nil }
+	ownershipCache.Clear()
+	tokenHolderCache.Clear()
+	t.Cleanup(func() {
+		onchain.SetProviders()
+		delegationsByDelegate = lookup
+		ownershipCache.Clear()
+		tokenHolderCache.Clear()
+	})
+	return fake
+}
+
+func TestHolderChains(t *testing.T) {
+	useFakeChain(t)
+	if got := holderChains("polygon"); !reflect.DeepEqual(got, []string{"polygon"}) {
+		t.Errorf("holderChains(polygon) = %v", got)
+	}
+	if got := holderChains(""); !reflect.DeepEqual(got, onchain.EvmChains()) {
+		t.Errorf("holderChains() = %v, want every enabled EVM chain %v", got, onchain.EvmChains())
+	}
+
+	//only chains a configured provider supports are tried
+	onchain.SetProviders(onchain.NewRpcProvider(map[string]string{"base": "http://localhost:8545"}))
+	if got := holderChains(""); !reflect.DeepEqual(got, []string{"base"}) {
+		t.Errorf("holderChains() with a base RPC only = %v", got)
+	}
+	onchain.SetProviders()
+	if got := holderChains(""); len(got) != 0 {
+		t.Errorf("holderChains() without providers = %v, want none", got)
+	}
+}
+
+func TestIsOwnerOfNFT(t *testing.T) {
+	useFakeChain(t)
+	cases := []struct {
+		contract string
+		wallet   string
+		chain    string
+		want     bool
+	}{
+		{fixtureEthereum, fixtureHolder, "ethereum", true},
+		{fixturePolygon, fixtureHolder, "polygon", true},
+		{fixturePolygon, fixtureHolder, "ethereum", false}, //held on another chain
+		{fixtureNotHeld, fixtureHolder, "ethereum", false},
+		{fixtureEthereum, fixtureDelegate, "ethereum", false},
+	}
+	for _, c := range cases {
+		if got := IsOwnerOfNFT(c.contract, c.wallet, c.chain); got != c.want {
+			t.Errorf("IsOwnerOfNFT(%s, %s, %s) = %v, want %v", c.contract, c.wallet, c.chain, got, c.want)
+		}
+	}
+}
+
+func TestIsOwnerOfNFTDelegatedVault(t *testing.T) {
+	useFakeChain(t)
+	delegationsByDelegate = func(wallet string) []delegatecash.IDelegationRegistryDelegationInfo {
+		if wallet != fixtureDelegate {
+			return nil
+		}
+		return []delegatecash.IDelegationRegistryDelegationInfo{{Type: 1, Vault: common.HexToAddress(fixtureHolder), Delegate: common.HexToAddress(fixtureDelegate)}}
+	}
+	if !IsOwnerOfNFT(fixtureEthereum, fixtureDelegate, "ethereum") {
+		t.Error("the delegate of a holding vault is not a holder")
+	}
+	if IsOwnerOfNFT(fixtureNotHeld, fixtureDelegate, "ethereum") {
+		t.Error("the delegate holds a contract the vault doesn't")
+	}
+}
+
+func TestIsOwnerOfNFTErrorsNotCached(t *testing.T) {
+	fake := useFakeChain(t)
+	fake.Fail = errors.New("provider down")
+	if IsOwnerOfNFT(fixtureEthereum, fixtureHolder, "ethereum") {
+		t.Error("holder while the provider fails")
+	}
+	fake.Fail = nil
+	if !IsOwnerOfNFT(fixtureEthereum, fixtureHolder, "ethereum") {
+		t.Error("a provider failure was cached as not a holder")
+	}
+	calls := len(fake.CallLog())
+	IsOwnerOfNFT(fixtureEthereum, fixtureHolder, "ethereum")
+	if len(fake.CallLog()) != calls {
+		t.Error("a cached holder check called the provider again")
+	}
+}
+
+func TestIsGroupHolder(t *testing.T) {
+	useFakeChain(t)
+	if !isGroupHolder(fixturePolygon, fixtureHolder, "") {
+		t.Error("a polygon holder is not found when no chain is given")
+	}
+	if isGroupHolder(fixturePolygon, fixtureHolder, "ethereum") {
+		t.Error("the chain of the group chat is ignored")
+	}
+	if !isGroupHolder("example.mintbase1.near", fixtureNearWallet, "") {
+		t.Error("a NEAR holder is not found by wallet format")
+	}
+	if !isGroupHolder("KT1Example1111111111111111111111111", "tz1Example1111111111111111111111111", "") {
+		t.Error("a Tezos holder is not found by wallet format")
+	}
+}
+
+func TestIsGroupHolderLooksUpDelegationsOnce(t *testing.T) {
+	useFakeChain(t)
+	lookups := 0
+	delegationsByDelegate = func(string) []delegatecash.IDelegationRegistryDelegationInfo {
+		lookups++
+		return nil
+	}
+	if isGroupHolder(fixtureNotHeld, fixtureDelegate, "") {
+		t.Error("holder of a contract nobody holds")
+	}
+	if lookups != 1 {
+		t.Errorf("delegations looked up %d times over %d chains, want once", lookups, len(holderChains("")))
+	}
+}
@@ -0,0 +1,565 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxCommunityChannels = 50
+	maxChannelNameLength = 32 //runes
+)
+
+var (
+	errChannelNotFound     = errors.New("channel not found")
+	errChannelExists       = errors.New("a channel with this name already exists")
+	errChannelLimit        = errors.New("too many channels in this community")
+	errChannelReadonly     = errors.New("only admins and moderators can post in this channel")
+	errChannelAccessDenied = errors.New("you don't meet this channel's access conditions")
+	errChannelDefault      = errors.New("the general channel can't be deleted or gated, use the community settings")
+)
+
+// ChannelInfo is a channel as the signed in wallet sees it
+type ChannelInfo struct {
+	entity.Communitychannel
+	Gated   bool `json:"gated"`   //has its own access conditions, on top of the community's
+	Allowed bool `json:"allowed"` //the wallet can read and post (unless read only)
+	Unread  int  `json:"unread"`
+}
+
+// ChannelRequest creates or updates a channel, name is only used on create
+type ChannelRequest struct {
+	Name        string `json:"name"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Readonly    *bool  `json:"read_only"`
+}
+
+// ChannelOrderRequest lists every channel name of the community in the new order
+type ChannelOrderRequest struct {
+	Channels []string `json:"channels"`
+}
+
+func writeChannelError(w http.ResponseWriter, err error) {
+	status := http.StatusForbidden
+	switch err {
+	case errChannelNotFound:
+		status = http.StatusNotFound
+	case errChannelExists, errChannelLimit:
+		status = http.StatusConflict
+	case errChannelDefault:
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// channelKey is what Groupchatitem.Channel stores, the general channel is the empty string (all messages from before channels)
+func channelKey(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == entity.DefaultChannel {
+		return ""
+	}
+	return name
+}
+
+// channelNameFromTitle makes a URL safe channel name, same rules as community slugs but shorter
+func channelNameFromTitle(title string) (string, bool) {
+	hasText := false
+	for _, r := range title {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			hasText = true
+			break
+		}
+	}
+	if !hasText {
+		return "", false
+	}
+	name := []rune(slugifyCommunityName(title))
+	if len(name) > maxChannelNameLength {
+		name = name[:maxChannelNameLength]
+	}
+	return strings.TrimRight(string(name), "-"), true
+}
+
+func defaultCommunityChannel(slug string) entity.Communitychannel {
+	return entity.Communitychannel{Slug: slug, Name: entity.DefaultChannel, Title: "General"}
+}
+
+// getCommunityChannels returns the channels in display order, general is included even when it has no row yet
+func getCommunityChannels(slug string) []entity.Communitychannel {
+	var channels []entity.Communitychannel
+	database.Connector.Where("slug = ?", slug).Order("position asc, id asc").Find(&channels)
+	for _, channel := range channels {
+		if channel.Name == entity.DefaultChannel {
+			return channels
+		}
+	}
+	return append([]entity.Communitychannel{defaultCommunityChannel(slug)}, channels...)
+}
+
+// getCommunityChannel finds a channel by name or key, the general channel always exists
+func getCommunityChannel(slug string, name string) (entity.Communitychannel, bool) {
+	key := channelKey(name)
+	if key == "" {
+		key = entity.DefaultChannel
+	}
+	var channel entity.Communitychannel
+	if database.Connector.Where("slug = ?", slug).Where("name = ?", key).Find(&channel).RowsAffected > 0 {
+		return channel, true
+	}
+	if key == entity.DefaultChannel {
+		return defaultCommunityChannel(slug), true
+	}
+	return channel, false
+}
+
+// channelAccessAllowed checks the channel's own conditions only, same rules as communityAccessAllowed
+func channelAccessAllowed(slug string, key string, walletaddr string) bool {
+	if key == "" || !isChannelGated(slug, key) || isCommunityStaff(slug, walletaddr) {
+		return true
+	}
+	check := getChannelAccess(slug, key, walletaddr)
+	if check.Allowed {
+		return true
+	}
+	return check.Error != "" && IsCommunityMember(slug, walletaddr)
+}
+
+// canReadChannel - the community must be readable, the channel must exist and its conditions must pass
+func canReadChannel(slug string, key string, walletaddr string) bool {
+	if _, found := getCommunityChannel(slug, key); !found {
+		return false
+	}
+	return canReadCommunity(slug, walletaddr) && channelAccessAllowed(slug, key, walletaddr)
+}
+
+// checkChannelPost is the channel part of posting, the community checks (membership, conditions, mutes) run separately
+func checkChannelPost(slug string, key string, walletaddr string) error {
+	channel, found := getCommunityChannel(slug, key)
+	if !found {
+		return errChannelNotFound
+	}
+	if channel.Readonly && !isCommunityStaff(slug, walletaddr) {
+		return errChannelReadonly
+	}
+	if !channelAccessAllowed(slug, key, walletaddr) {
+		return errChannelAccessDenied
+	}
+	return nil
+}
+
+// markChannelRead moves the read time of the wallet in one channel to now
+func markChannelRead(slug string, key string, walletaddr string) {
+	var chatReadTime entity.Groupchatreadtime
+	dbQuery := database.Connector.Where("fromaddr = ?", walletaddr).Where("nftaddr = ?", slug).Where("channel = ?", key).Find(&chatReadTime)
+	if dbQuery.RowsAffected == 0 {
+		chatReadTime.Fromaddr = walletaddr
+		chatReadTime.Nftaddr = slug
+		chatReadTime.Channel = key
+		chatReadTime.Readtimestamp_dtm = time.Now()
+		database.Connector.Create(&chatReadTime)
+		return
+	}
+	database.Connector.Model(&entity.Groupchatreadtime{}).
+		Where("fromaddr = ?", walletaddr).
+		Where("nftaddr = ?", slug).
+		Where("channel = ?", key).
+		Update("readtimestamp_dtm", time.Now())
+}
+
+// channelUnread counts messages after the wallet's read time, everything when it never opened the channel
+func channelUnread(slug string, key string, walletaddr string) int {
+	var count int
+	query := database.Connector.Model(&entity.Groupchatitem{}).Where("nftaddr = ?", slug).Where("channel = ?", key)
+	var chatReadTime entity.Groupchatreadtime
+	if database.Connector.Where("fromaddr = ?", walletaddr).Where("nftaddr = ?", slug).Where("channel = ?", key).Find(&chatReadTime).RowsAffected > 0 {
+		query = query.Where("timestamp_dtm > ?", chatReadTime.Readtimestamp_dtm)
+	}
+	query.Count(&count)
+	return count
+}
+
+// readableChannelKeys lists the channel keys the wallet passes the channel conditions of
+func readableChannelKeys(slug string, walletaddr string) []string {
+	keys := []string{}
+	for _, channel := range getCommunityChannels(slug) {
+		key := channelKey(channel.Name)
+		if channelAccessAllowed(slug, key, walletaddr) {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// communityInboxSummary rolls the channels up into one inbox entry: the latest message and the unread count
+// over every channel the wallet can read
+func communityInboxSummary(slug string, walletaddr string) (entity.Groupchatitem, bool, int) {
+	keys := readableChannelKeys(slug, walletaddr)
+	unread := 0
+	for _, key := range keys {
+		unread += channelUnread(slug, key, walletaddr)
+	}
+
+	var gchat []entity.Groupchatitem
+	dbQuery := database.Connector.Where("nftaddr = ?", slug).Where("channel IN (?)", keys).Last(&gchat)
+	if dbQuery.RowsAffected == 0 || len(gchat) == 0 {
+		return entity.Groupchatitem{}, false, unread
+	}
+	return gchat[0], true, unread
+}
+
+// channelInfos adds the per wallet state to the channels
+func channelInfos(slug string, walletaddr string) []ChannelInfo {
+	infos := []ChannelInfo{}
+	for _, channel := range getCommunityChannels(slug) {
+		key := channelKey(channel.Name)
+		info := ChannelInfo{Communitychannel: channel, Gated: key != "" && isChannelGated(slug, key)}
+		info.Allowed = channelAccessAllowed(slug, key, walletaddr)
+		if info.Allowed {
+			info.Unread = channelUnread(slug, key, walletaddr)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// ensureChannelRow creates the row of the general channel the first time an admin changes it
+func ensureChannelRow(channel *entity.Communitychannel, walletaddr string) {
+	if channel.Id != 0 {
+		return
+	}
+	channel.Createdby = strings.ToLower(walletaddr)
+	channel.Timestamp = time.Now()
+	database.Connector.Create(channel)
+}
+
+// ============================================================================
+
+// GetCommunityChannels godoc
+// @Summary     Get the channels of a community in display order, with unread counts
+// @Description Every community has the general channel. Channels with their own access conditions show allowed=false
+// @Description for wallets that don't meet them. Messages of a channel: GET /v1/community/{slug}/{address}?channel=name
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug path    string true "community slug"
+// @Success     200  {array} ChannelInfo
+// @Router      /v1/community/{slug}/channels [get]
+func GetCommunityChannels(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !canReadCommunity(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(channelInfos(slug, Authuser.Address))
+}
+
+// CreateCommunityChannel godoc
+// @Summary     Add a channel to a community (admins only)
+// @Description The name is made URL safe from name (or title), new channels go last
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string         true "community slug"
+// @Param       message body     ChannelRequest true "channel"
+// @Success     201     {object} entity.Communitychannel
+// @Failure     409
+// @Router      /v1/community/{slug}/channels [post]
+func CreateCommunityChannel(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var channelRequest ChannelRequest
+	if err := json.Unmarshal(requestBody, &channelRequest); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !communityExists(slug) {
+		writeCommunityError(w, errCommunityNotFound)
+		return
+	}
+	if !isCommunityAdmin(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+
+	if channelRequest.Name == "" {
+		channelRequest.Name = channelRequest.Title
+	}
+	name, ok := channelNameFromTitle(channelRequest.Name)
+	if !ok {
+		http.Error(w, "name needs at least one letter or digit", http.StatusBadRequest)
+		return
+	}
+	if _, found := getCommunityChannel(slug, name); found {
+		writeChannelError(w, errChannelExists)
+		return
+	}
+	channels := getCommunityChannels(slug)
+	if len(channels) >= maxCommunityChannels {
+		writeChannelError(w, errChannelLimit)
+		return
+	}
+
+	channel := entity.Communitychannel{
+		Slug:        slug,
+		Name:        name,
+		Title:       strings.TrimSpace(channelRequest.Title),
+		Description: strings.TrimSpace(channelRequest.Description),
+		Position:    channels[len(channels)-1].Position + 1,
+		Createdby:   strings.ToLower(Authuser.Address),
+		Timestamp:   time.Now(),
+	}
+	if channel.Title == "" {
+		channel.Title = name
+	}
+	if channelRequest.Readonly != nil {
+		channel.Readonly = *channelRequest.Readonly
+	}
+	if err := database.Connector.Create(&channel).Error; err != nil {
+		log.Println("CreateCommunityChannel error: ", err)
+		writeChannelError(w, errChannelExists)
+		return
+	}
+	logModeration(entity.Moderationlog{Groupaddr: slug, Actor: Authuser.Address, Action: entity.ModActionChannel, Reason: "created " + name})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(channel)
+}
+
+// UpdateCommunityChannel godoc
+// @Summary     Change the title, description or read only flag of a channel (admins only)
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string         true "community slug"
+// @Param       channel path     string         true "channel name"
+// @Param       message body     ChannelRequest true "fields to change, name is ignored"
+// @Success     200     {object} entity.Communitychannel
+// @Router      /v1/community/{slug}/channels/{channel} [put]
+func UpdateCommunityChannel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var channelRequest ChannelRequest
+	if err := json.Unmarshal(requestBody, &channelRequest); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !isCommunityAdmin(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+	channel, found := getCommunityChannel(slug, vars["channel"])
+	if !found {
+		writeChannelError(w, errChannelNotFound)
+		return
+	}
+
+	if title := strings.TrimSpace(channelRequest.Title); title != "" {
+		channel.Title = title
+	}
+	channel.Description = strings.TrimSpace(channelRequest.Description)
+	if channelRequest.Readonly != nil {
+		channel.Readonly = *channelRequest.Readonly
+	}
+	ensureChannelRow(&channel, Authuser.Address)
+	database.Connector.Save(&channel)
+	logModeration(entity.Moderationlog{Groupaddr: slug, Actor: Authuser.Address, Action: entity.ModActionChannel, Reason: "updated " + channel.Name})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(channel)
+}
+
+// DeleteCommunityChannel godoc
+// @Summary     Delete a channel and its messages (admins only)
+// @Description The general channel can't be deleted
+// @Tags        GroupChat
+// @Security    BearerAuth
+// @Param       slug    path string true "community slug"
+// @Param       channel path string true "channel name"
+// @Success     204
+// @Router      /v1/community/{slug}/channels/{channel} [delete]
+func DeleteCommunityChannel(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isCommunityAdmin(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+	key := channelKey(vars["channel"])
+	if key == "" {
+		writeChannelError(w, errChannelDefault)
+		return
+	}
+	channel, found := getCommunityChannel(slug, key)
+	if !found {
+		writeChannelError(w, errChannelNotFound)
+		return
+	}
+
+	var messageids []int
+	database.Connector.Model(&entity.Groupchatitem{}).Where("nftaddr = ?", slug).Where("channel = ?", key).Pluck("id", &messageids)
+	if len(messageids) > 0 {
+		database.Connector.Where("groupaddr = ?", slug).Where("messageid IN (?)", messageids).Delete(&entity.Pinnedmessage{})
+	}
+	database.Connector.Where("nftaddr = ?", slug).Where("channel = ?", key).Delete(&entity.Groupchatitem{})
+	database.Connector.Where("nftaddr = ?", slug).Where("channel = ?", key).Delete(&entity.Groupchatreadtime{})
+	database.Connector.Where("slug = ?", slug).Where("channel = ?", key).Delete(&entity.Communityaccesscondition{})
+	database.Connector.Delete(&channel)
+	invalidateAccessCache(slug)
+	logModeration(entity.Moderationlog{Groupaddr: slug, Actor: Authuser.Address, Action: entity.ModActionChannel, Reason: fmt.Sprintf("deleted %s (%d messages)", channel.Name, len(messageids))})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderCommunityChannels godoc
+// @Summary     Change the order of the channels (admins only)
+// @Description channels must contain every channel name of the community exactly once, general included
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path    string              true "community slug"
+// @Param       message body    ChannelOrderRequest true "channel names in the new order"
+// @Success     200     {array} ChannelInfo
+// @Router      /v1/community/{slug}/channels [put]
+func ReorderCommunityChannels(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var orderRequest ChannelOrderRequest
+	if err := json.Unmarshal(requestBody, &orderRequest); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !isCommunityAdmin(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+
+	byName := map[string]entity.Communitychannel{}
+	for _, channel := range getCommunityChannels(slug) {
+		byName[channel.Name] = channel
+	}
+	seen := map[string]bool{}
+	for _, name := range orderRequest.Channels {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if _, found := byName[name]; !found || seen[name] {
+			http.Error(w, "channels must list every channel exactly once", http.StatusBadRequest)
+			return
+		}
+		seen[name] = true
+	}
+	if len(seen) != len(byName) {
+		http.Error(w, "channels must list every channel exactly once", http.StatusBadRequest)
+		return
+	}
+
+	for i, name := range orderRequest.Channels {
+		channel := byName[strings.ToLower(strings.TrimSpace(name))]
+		ensureChannelRow(&channel, Authuser.Address)
+		database.Connector.Model(&entity.Communitychannel{}).Where("id = ?", channel.Id).Update("position", i+1)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(channelInfos(slug, Authuser.Address))
+}
+
+// GetChannelConditions godoc
+// @Summary     Get the access conditions of a channel, checked on top of the community's
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string true "community slug"
+// @Param       channel path     string true "channel name"
+// @Success     200     {object} AccessConditionsRequest
+// @Router      /v1/community/{slug}/channels/{channel}/conditions [get]
+func GetChannelConditions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+
+	channel, found := getCommunityChannel(slug, vars["channel"])
+	if !found {
+		writeChannelError(w, errChannelNotFound)
+		return
+	}
+	result := groupAccessConditions(getChannelAccessConditions(slug, channelKey(channel.Name)))
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(result)
+}
+
+// SetChannelConditions godoc
+// @Summary     Replace the access conditions of a channel (admins only)
+// @Description Same format as PUT /v1/community/{slug}/conditions. Wallets need the community's and the channel's conditions.
+// @Description The general channel uses the community conditions.
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string                  true "community slug"
+// @Param       channel path     string                  true "channel name"
+// @Param       message body     AccessConditionsRequest true "condition groups"
+// @Success     200     {object} AccessConditionsRequest
+// @Router      /v1/community/{slug}/channels/{channel}/conditions [put]
+func SetChannelConditions(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isCommunityAdmin(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+	key := channelKey(vars["channel"])
+	if key == "" {
+		writeChannelError(w, errChannelDefault)
+		return
+	}
+	if _, found := getCommunityChannel(slug, key); !found {
+		writeChannelError(w, errChannelNotFound)
+		return
+	}
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	conditions, problem := parseAccessConditions(requestBody, slug, key)
+	if problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
+	}
+	replaceAccessConditions(slug, key, conditions)
+	log.Println("Channel conditions changed: ", slug, key, len(conditions), "by", Authuser.Address)
+
+	GetChannelConditions(w, r)
+}
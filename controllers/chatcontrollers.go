@@ -6,6 +6,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -21,11 +22,13 @@ import (
 	"rest-go-demo/database"
 	"rest-go-demo/email"
 	"rest-go-demo/entity"
+	"rest-go-demo/names"
+	"rest-go-demo/onchain"
 	"rest-go-demo/referrals"
 	"rest-go-demo/vanaencrypt"
 	"rest-go-demo/vanatransact"
 	"rest-go-demo/wc_analytics"
-	"sync"
+	"rest-go-demo/webhooks"
 
 	"strconv"
 	"strings"
@@ -33,10 +36,7 @@ import (
 
 	_ "rest-go-demo/docs"
 
-	goaway "github.com/TwiN/go-away"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/ethclient"
-	ens "github.com/wealdtech/go-ens/v3"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/credentials"
@@ -48,7 +48,6 @@ import (
 )
 
 var telegramUpdateOffset = 0
-var throttleInboxCounterPerUser = make(map[string]int64) //only access via mutex function!
 
 // Retrieve the environment variable value with an array-like data as a comma-separated string
 var tgSupportWalletsCsvString = ""
@@ -60,8 +59,6 @@ var tgSupportChatIdsArray []string
 var tgSupportAdminsCsvString = ""
 var tgSupportAdminsArray []string
 
-var tgSupportBlockedUsers []string
-
 var giveawayMessageCounter = 0
 
 func stringInSlice(a string, list []string) bool {
@@ -112,21 +109,6 @@ func GetLastMsgToOwner(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(chatReturn)
 }
 
-var mu sync.Mutex
-
-func incrementAndCheck(key string) bool {
-	// Step 1: Acquire the lock at the beginning
-	mu.Lock()
-	// Step 2: Defer the unlock until the end, ensuring it happens even if there's an early return
-	defer mu.Unlock()
-
-	// Critical section: Increment the counter for the specified key
-	throttleInboxCounterPerUser[key]++
-
-	// Check if the incremented value is divisible by 25
-	return throttleInboxCounterPerUser[key]%25 == 0
-}
-
 // GetInboxByOwner godoc
 // @Summary     Get Inbox Summary With Last Message
 // @Description Get Each 1-on-1 Conversation, NFT and Community Chat For Display in Inbox
@@ -181,6 +163,10 @@ func GetInboxByOwner(w http.ResponseWriter, r *http.Request) {
 		// //get name for return val
 		var addrname entity.Addrnameitem
 		database.Connector.Where("address = ?", chatmember.Address).Find(&addrname)
+		if addrname.Name == "" {
+			addrname.Name = names.CachedPrimaryName(chatmember.Address) //ENS etc primary name, filled in for the next load
+			addrname.Verified = addrname.Name != ""                     //primary names resolve back to the address
+		}
 
 		//database view - local code replaced 7/14
 		var vchatitem entity.V_chatitem
@@ -200,6 +186,7 @@ func GetInboxByOwner(w http.ResponseWriter, r *http.Request) {
 			itemToInsert.Contexttype = entity.DM
 			itemToInsert.Type = entity.Message
 			itemToInsert.Sendername = addrname.Name
+			itemToInsert.Senderverified = addrname.Verified
 			itemToInsert.Encryptsymkey = vchatitem.Encryptsymkey
 			itemToInsert.Litaccesscond = vchatitem.Litaccesscond
 			//fmt.Printf("encrypted symmetric LIT key: %#v %#v %#v\n", vchatitem.Encryptsymkey, vchatitem.Toaddr, vchatitem.Fromaddr)
@@ -230,28 +217,31 @@ func GetInboxByOwner(w http.ResponseWriter, r *http.Request) {
 	var bookmarks []entity.Bookmarkitem
 	database.Connector.Where("walletaddr = ?", key).Find(&bookmarks)
 
-	//TODO: need to throttle these 2 calls to auto-join?
-	//should auto-join them to the community chat
-	if strings.HasPrefix(key, "0x") || strings.HasSuffix(key, ".eth") {
-		//golang maps are not concurrently accessible, must use locks if read/writing in threads
-		updateUserInfo := incrementAndCheck(key)
-		if updateUserInfo {
-			AutoJoinCommunitiesByChainWithDelegates(key, "ethereum") //Moralis uses "eth" instead of "ethereum" but we change this at lower level
-			//AutoJoinCommunitiesByChainWithDelegates(key, "polygon")
-			AutoJoinPoapChats(key)
-		}
-	}
+	//NFT/POAP chats are auto-joined by the background worker (autojoin.go), the inbox only marks the wallet active
+	go noteAutoJoinActivity(key)
+	poapEvents := inboxPoapEvents(bookmarks)
 
 	//now add last message from group chat this bookmark is for
 	var gchat []entity.Groupchatitem //even though I use this in a Last() function I need to store as an array, or subsequenct DB queries fail!
 	for idx := 0; idx < len(bookmarks); idx++ {
 		//fmt.Printf("bookmarks: %#v\n", bookmarks[i])
 		//fmt.Printf("\nnftaddr: %#v\n", bookmarks[idx].Nftaddr)
-		dbQuery := database.Connector.Where("nftaddr = ?", bookmarks[idx].Nftaddr).Last(&gchat)
+		//communities roll their channels up into one entry (only channels the user can read)
+		isCommunity := !strings.HasPrefix(bookmarks[idx].Nftaddr, "0x") && !strings.HasPrefix(bookmarks[idx].Nftaddr, "poap_")
+		var communityUnread int
+		hasMessages := false
+		if isCommunity {
+			var communityLast entity.Groupchatitem
+			communityLast, hasMessages, communityUnread = communityInboxSummary(bookmarks[idx].Nftaddr, key)
+			gchat = []entity.Groupchatitem{communityLast}
+		} else {
+			dbQuery := database.Connector.Where("nftaddr = ?", bookmarks[idx].Nftaddr).Last(&gchat)
+			hasMessages = dbQuery.RowsAffected > 0
+		}
 		//fmt.Printf("dbQuery: %#v\n", dbQuery.Error)
 
 		var returnItem entity.Chatiteminbox
-		if dbQuery.RowsAffected == 0 {
+		if !hasMessages {
 			//if this chat is new/empty just return the basic info
 			returnItem.Nftaddr = bookmarks[idx].Nftaddr
 			returnItem.Contexttype = entity.Community
@@ -262,6 +252,10 @@ func GetInboxByOwner(w http.ResponseWriter, r *http.Request) {
 			if strings.HasPrefix(returnItem.Nftaddr, "poap_") {
 				returnItem.Contexttype = entity.Nft
 				returnItem.Chain = bookmarks[idx].Chain
+				returnItem.Poapevent = inboxPoapEvent(poapEvents, returnItem.Nftaddr)
+				if returnItem.Poapevent != nil {
+					returnItem.Name = returnItem.Poapevent.Name
+				}
 			}
 			userInbox = append(userInbox, returnItem)
 			continue
@@ -274,9 +268,11 @@ func GetInboxByOwner(w http.ResponseWriter, r *http.Request) {
 		//var chatCnt []entity.Groupchatitem
 		var chatCount int
 		var chatReadTime entity.Groupchatreadtime
-		dbQuery = database.Connector.Where("fromaddr = ?", key).Where("nftaddr = ?", groupchat.Nftaddr).Find(&chatReadTime)
+		dbQuery := database.Connector.Where("fromaddr = ?", key).Where("nftaddr = ?", groupchat.Nftaddr).Find(&chatReadTime)
 		//if no respsonse to this query, its the first time a user is reading the chat history, send it all
-		if dbQuery.RowsAffected == 0 {
+		if isCommunity {
+			chatCount = communityUnread
+		} else if dbQuery.RowsAffected == 0 {
 			//fmt.Printf("sending all values! \n")
 			database.Connector.Model(&entity.Groupchatitem{}).Where("nftaddr = ?", groupchat.Nftaddr).Count(&chatCount)
 			//database.Connector.Where("nftaddr = ?", groupchat.Nftaddr).Find(&chatCnt)
@@ -297,7 +293,8 @@ func GetInboxByOwner(w http.ResponseWriter, r *http.Request) {
 		returnItem.Type = groupchat.Type
 		returnItem.Chain = bookmarks[idx].Chain
 		//retrofit old messages prior to setting Type
-		if returnItem.Type != entity.Message && returnItem.Type != entity.Welcome {
+		if returnItem.Type != entity.Message && returnItem.Type != entity.Welcome &&
+			returnItem.Type != entity.MemberJoined && returnItem.Type != entity.MemberLeft {
 			returnItem.Type = entity.Message
 		}
 		returnItem.Contexttype = entity.Community
@@ -321,6 +318,10 @@ func GetInboxByOwner(w http.ResponseWriter, r *http.Request) {
 		}
 		if strings.HasPrefix(returnItem.Nftaddr, "poap_") {
 			returnItem.Contexttype = entity.Nft
+			returnItem.Poapevent = inboxPoapEvent(poapEvents, returnItem.Nftaddr)
+			if returnItem.Name == "" && returnItem.Poapevent != nil {
+				returnItem.Name = returnItem.Poapevent.Name
+			}
 		}
 
 		returnItem.Sendername = ""
@@ -429,6 +430,14 @@ func GetUnreadMsgCntTotalByType(w http.ResponseWriter, r *http.Request) {
 	var gchat []entity.Groupchatitem //even though I use this in a Last() function I need to store as an array, or subsequenct DB queries fail!
 	if msgtype == entity.Nft || msgtype == entity.Community || msgtype == entity.All {
 		for idx := 0; idx < len(bookmarks); idx++ {
+			//community channels are rolled up, counted only for the community types
+			if !strings.HasPrefix(bookmarks[idx].Nftaddr, "0x") && !strings.HasPrefix(bookmarks[idx].Nftaddr, "poap_") {
+				if msgtype == entity.Community || msgtype == entity.All {
+					_, _, unread := communityInboxSummary(bookmarks[idx].Nftaddr, key)
+					msgCntTotal += unread
+				}
+				continue
+			}
 			dbQuery := database.Connector.Where("nftaddr = ?", bookmarks[idx].Nftaddr).Last(&gchat)
 			if dbQuery.RowsAffected == 0 {
 				continue
@@ -492,6 +501,12 @@ func LocalGetUnread(address string) entity.Unreadcountitem {
 	//now add last message from group chat this bookmark is for
 	var gchat []entity.Groupchatitem //even though I use this in a Last() function I need to store as an array, or subsequenct DB queries fail!
 	for idx := 0; idx < len(bookmarks); idx++ {
+		//community channels are rolled up like in the inbox
+		if !strings.HasPrefix(bookmarks[idx].Nftaddr, "0x") && !strings.HasPrefix(bookmarks[idx].Nftaddr, "poap_") {
+			_, _, unread := communityInboxSummary(bookmarks[idx].Nftaddr, address)
+			config.Community += unread
+			continue
+		}
 		dbQuery := database.Connector.Where("nftaddr = ?", bookmarks[idx].Nftaddr).Last(&gchat)
 		if dbQuery.RowsAffected == 0 {
 			continue
@@ -1023,6 +1038,7 @@ func GetChatNftAllItemsFromAddrAndNFT(w http.ResponseWriter, r *http.Request) {
 			chat = append(chat, chatmember)
 		}
 	}
+	chat = readableHolderThreadItems(chat, Authuser.Address)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -1142,15 +1158,26 @@ func CreateChatitem(w http.ResponseWriter, r *http.Request) {
 			w.WriteHeader(http.StatusCreated)
 			json.NewEncoder(w).Encode(chat)
 
+			//message content is not sent to integrators (DMs are usually encrypted anyway)
+			webhooks.EmitForWallet(entity.WebhookMessageCreated, chat.Toaddr, map[string]interface{}{
+				"id":        chat.Id,
+				"fromaddr":  chat.Fromaddr,
+				"toaddr":    chat.Toaddr,
+				"nftaddr":   chat.Nftaddr,
+				"nftid":     chat.Nftid,
+				"timestamp": chat.Timestamp,
+			})
+
 			//fmt.Println("message check for support", tgSupportWalletsCsvString, tgSupporChatIdsCsvString, chat.Toaddr)
 			if strings.Contains(tgSupportWalletsCsvString, chat.Toaddr) {
 				//first find the index - we could have this be the initial check, but might be slow if the list gets long
 				index := findStrIndexInArray(chat.Toaddr, tgSupportWalletArray)
 				chat_id := tgSupportChatIdsArray[index]
 
-				blockedUserIndex := findStrIndexInArray(strings.ToLower(chat.Fromaddr), tgSupportBlockedUsers)
-
-				if blockedUserIndex < 0 {
+				//messages from support-blocked wallets are kept in the DB (quarantined) but never forwarded to the TG group
+				if IsSupportBlocked(chat.Fromaddr) {
+					fmt.Println("support blocked user message quarantined: ", chat.Fromaddr, chat.Toaddr)
+				} else {
 					//fmt.Println("sending to TG group message")
 					var fromAddrSettings entity.Settings
 					database.Connector.Where("walletaddr = ?", chat.Fromaddr).Find(&fromAddrSettings)
@@ -1212,12 +1239,10 @@ func CreateChatitem(w http.ResponseWriter, r *http.Request) {
 			// 			var fromAddrname entity.Addrnameitem
 			// 			database.Connector.Where("address = ?", walletaddr).Find(&fromAddrname)
 
+			// 			rendered, _ := email.RenderTwitter(settings.Locale, settings.Signupsite, settings.Email, email.TwitterData{ToAddress: walletaddr, ToName: fromAddrname.Name})
 			// 			from := mail.NewEmail("WalletChat Prize Notifications", "contact@walletchat.fun")
-			// 			subject := "Prize Notfication For WalletChat!"
 			// 			to := mail.NewEmail(fromAddrname.Name, settings.Email)
-			// 			plainTextContent := "You have a message from" + fromAddrname.Name + " : \r\n" + chat.Message + "\r\n Please login via the app at https://app.walletchat.fun to read!"
-			// 			htmlContent := email.NotificationEmailTwitter(walletaddr, fromAddrname.Name, settings.Email)
-			// 			message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
+			// 			message := mail.NewSingleEmail(from, rendered.Subject, to, rendered.Text, rendered.Html)
 			// 			client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
 			// 			response, err := client.Send(message)
 			// 			if err != nil {
@@ -1234,29 +1259,38 @@ func CreateChatitem(w http.ResponseWriter, r *http.Request) {
 			var dbResult = database.Connector.Where("walletaddr = ?", chat.Toaddr).Find(&settings)
 			wc_analytics.SendCustomEventWithSignupSite(Authuser.Address, "SEND_MESSAGE", settings.Signupsite)
 			if dbResult.RowsAffected > 0 && strings.EqualFold("true", settings.Verified) {
-				if strings.Contains(settings.Email, "@") && strings.EqualFold(settings.Notifydm, "true") {
+				if strings.Contains(settings.Email, "@") && strings.EqualFold(settings.Notifydm, "true") &&
+					ShouldNotify(chat.Toaddr, entity.DM, chat.Fromaddr, NotifyChannelEmail, false) {
 					var fromAddrname entity.Addrnameitem
 					database.Connector.Where("address = ?", chat.Fromaddr).Find(&fromAddrname)
 					var toAddrname entity.Addrnameitem
 					database.Connector.Where("address = ?", chat.Toaddr).Find(&toAddrname)
 
-					from := mail.NewEmail("WalletChat Notifications", "contact@walletchat.fun")
-					subject := "Message Waiting In WalletChat"
-					to := mail.NewEmail(toAddrname.Name, settings.Email)
-					plainTextContent := "You have a message from" + fromAddrname.Name + " : \r\n" + chat.Message + "\r\n Please login via the app at https://app.walletchat.fun to read!"
-					htmlContent := email.NotificationEmailDM(toAddrname.Address, fromAddrname.Address, toAddrname.Name, fromAddrname.Name, settings.Email, chat.Message)
-					message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-					client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
-					response, err := client.Send(message)
+					rendered, err := email.RenderDM(settings.Locale, settings.Signupsite, settings.Email, email.DMData{
+						ToAddress:   chat.Toaddr,
+						ToName:      toAddrname.Name,
+						FromAddress: chat.Fromaddr,
+						FromName:    fromAddrname.Name,
+						Message:     chat.Message,
+					})
 					if err != nil {
 						log.Println(err)
 					} else {
-						_ = response
+						from := mail.NewEmail(rendered.Brand.FromName, "contact@walletchat.fun")
+						to := mail.NewEmail(toAddrname.Name, settings.Email)
+						message := mail.NewSingleEmail(from, rendered.Subject, to, rendered.Text, rendered.Html)
+						client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
+						response, err := client.Send(message)
+						if err != nil {
+							log.Println(err)
+						} else {
+							_ = response
+						}
 					}
 				}
 			}
 			if dbResult.RowsAffected > 0 && settings.Telegramid != "" {
-				if strings.EqualFold(settings.Notifydm, "true") {
+				if strings.EqualFold(settings.Notifydm, "true") && ShouldNotify(chat.Toaddr, entity.DM, chat.Fromaddr, NotifyChannelTelegram, false) {
 					var fromAddrname entity.Addrnameitem
 					database.Connector.Where("address = ?", chat.Fromaddr).Find(&fromAddrname)
 
@@ -1264,6 +1298,7 @@ func CreateChatitem(w http.ResponseWriter, r *http.Request) {
 					SendTelegramMessage(message, settings.Telegramid)
 				}
 			}
+			go pushNewDirectMessage(chat)
 		}
 	} else {
 		fmt.Println("create_chatitem - JWT Address: ", Authuser.Address)
@@ -1283,12 +1318,20 @@ func SendNotificationEmails() {
 			var dbQuery = database.Connector.Where("address = ?", settings[i].Walletaddr).Find(&addrnameDB)
 
 			if dbQuery.RowsAffected > 0 && strings.EqualFold(settings[i].Notify24, "true") && strings.EqualFold("true", settings[i].Verified) {
-				from := mail.NewEmail("WalletChat Notifications", "contact@walletchat.fun")
-				subject := "Message Waiting In WalletChat"
+				rendered, err := email.RenderDaily(settings[i].Locale, settings[i].Signupsite, settings[i].Email, email.DailyData{
+					ToAddress:   addrnameDB.Address,
+					ToName:      addrnameDB.Name,
+					Dms:         config.Dm,
+					Nfts:        config.Nft,
+					Communities: config.Community,
+				})
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+				from := mail.NewEmail(rendered.Brand.FromName, "contact@walletchat.fun")
 				to := mail.NewEmail(addrnameDB.Name, settings[i].Email)
-				plainTextContent := "You have " + strconv.Itoa(config.Dm) + " unread DM(s), " + strconv.Itoa(config.Nft) + " unread NFT group chat messages, and " + strconv.Itoa(config.Community) + " unread custom community chat messages waiting in WalletChat. Please login via the app at https://app.walletchat.fun to read!"
-				htmlContent := email.NotificationEmail24(addrnameDB.Address, addrnameDB.Name, strconv.Itoa(config.Dm), strconv.Itoa(config.Nft), strconv.Itoa(config.Community), settings[i].Email)
-				message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
+				message := mail.NewSingleEmail(from, rendered.Subject, to, rendered.Text, rendered.Html)
 				client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
 				response, err := client.Send(message)
 				if err != nil {
@@ -1401,12 +1444,31 @@ func UpdateTelegramNotifications() {
 				origMsgSender := origSenderSettings.Walletaddr
 				//fmt.Println("GD Admin Replied To Message from / with:", origMsgSender, updatedNotifsData.Result[i].Message)
 
-				//see if its a block user request
-				if strings.EqualFold(updatedNotifsData.Result[i].Message.Text, "BLOCK_USER") {
-					fmt.Println("TG Admin Blocked User: ", origMsgSender)
-					tgSupportBlockedUsers = append(tgSupportBlockedUsers, strings.ToLower(origMsgSender))
+				adminId := strconv.FormatInt(updatedNotifsData.Result[i].Message.From.ID, 10)
+				replyChatId := strconv.Itoa(updatedNotifsData.Result[i].Message.ReplyToMessage.Chat.ID)
+
+				//see if its a block/unblock user request (stored in DB so it survives restarts and is shared across replicas)
+				if isTelegramCommand(updatedNotifsData.Result[i].Message.Text, tgCmdBlockUser) {
+					expires, reason := parseBlockUserCommand(updatedNotifsData.Result[i].Message.Text)
+					fmt.Println("TG Admin Blocked User: ", origMsgSender, adminId, reason)
+					if err := BlockSupportUser(origMsgSender, reason, adminId, expires); err != nil {
+						fmt.Println("TG Admin Block User error: ", err)
+						SendTelegramMessage("Could not block user: "+err.Error(), replyChatId)
+					} else {
+						SendTelegramMessage("Blocked Ticket #("+settingsIdMsgSender+") from support", replyChatId)
+					}
 					telegramUpdateOffset = updatedNotifsData.Result[i].UpdateID + 1
-					return
+					continue
+				}
+				if isTelegramCommand(updatedNotifsData.Result[i].Message.Text, tgCmdUnblockUser) {
+					fmt.Println("TG Admin Unblocked User: ", origMsgSender, adminId)
+					if UnblockSupportUser(origMsgSender) > 0 {
+						SendTelegramMessage("Unblocked Ticket #("+settingsIdMsgSender+")", replyChatId)
+					} else {
+						SendTelegramMessage("Ticket #("+settingsIdMsgSender+") was not blocked", replyChatId)
+					}
+					telegramUpdateOffset = updatedNotifsData.Result[i].UpdateID + 1
+					continue
 				}
 
 				//find corresponding support wallet for given chat_id
@@ -1425,6 +1487,22 @@ func UpdateTelegramNotifications() {
 				}
 			}
 
+		} else if findStrIndexInArray(strconv.FormatInt(updatedNotifsData.Result[i].Message.From.ID, 10), tgSupportAdminsArray) > -1 &&
+			(isTelegramCommand(updatedNotifsData.Result[i].Message.Text, tgCmdListBlocked) || isTelegramCommand(updatedNotifsData.Result[i].Message.Text, tgCmdUnblockUser)) {
+			//admin commands which are not replies to a ticket
+			adminChatId := strconv.FormatInt(updatedNotifsData.Result[i].Message.Chat.ID, 10)
+			if isTelegramCommand(updatedNotifsData.Result[i].Message.Text, tgCmdListBlocked) {
+				SendTelegramMessage(formatSupportBlockedList(GetSupportBlockedUsers()), adminChatId)
+			} else {
+				//UNBLOCK_USER <wallet address>
+				args := strings.Fields(updatedNotifsData.Result[i].Message.Text)
+				if len(args) > 1 && UnblockSupportUser(args[1]) > 0 {
+					fmt.Println("TG Admin Unblocked User: ", args[1], updatedNotifsData.Result[i].Message.From.ID)
+					SendTelegramMessage("Unblocked "+args[1], adminChatId)
+				} else {
+					SendTelegramMessage("Usage: reply UNBLOCK_USER to a ticket, or UNBLOCK_USER <wallet address> for a blocked wallet", adminChatId)
+				}
+			}
 		} else {
 			//fmt.Println("Results For Telegram Check", updatedNotifsData.Result[i])
 			verifCode := updatedNotifsData.Result[i].Message.Text
@@ -1437,6 +1515,7 @@ func UpdateTelegramNotifications() {
 				fmt.Println("Updating Telegram Chat ID for WalletAddr/chatID: ", settings[0].Walletaddr, strconv.FormatInt(chatId, 10))
 				database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", settings[0].Walletaddr).Update("telegramid", strconv.FormatInt(chatId, 10))
 				database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", settings[0].Walletaddr).Update("telegramcode", "")
+				auditSettingsField(settings[0].Walletaddr, settingsAuditSourceSystem, settingsAuditSourceSystem, "telegram_linked", strconv.FormatBool(settings[0].Telegramid != ""), "true")
 
 				var message string = "You have successfully setup notifications in WalletChat for: " + settings[0].Walletaddr
 				SendTelegramMessage(message, strconv.FormatInt(chatId, 10))
@@ -1473,37 +1552,42 @@ func CreateGroupChatitem(w http.ResponseWriter, r *http.Request) {
 
 	Authuser := auth.GetUserFromReqContext(r)
 
-	//ensure user holds the NFT first
-	isHolder := false
-	if strings.HasPrefix(chat.Nftaddr, "0x") {
-		//TODO: we should send in chain along with message
-		isHolder = IsOwnerOfNFT(chat.Nftaddr, chat.Fromaddr, "ethereum")
-		if !isHolder {
-			isHolder = IsOwnerOfNFT(chat.Nftaddr, chat.Fromaddr, "polygon")
-		}
-	} else if !isHolder && (strings.HasSuffix(chat.Fromaddr, ".near") || strings.HasSuffix(chat.Fromaddr, ".testnet")) ||
-		(len(chat.Fromaddr) == 64 && !strings.HasPrefix(chat.Fromaddr, "0x")) { //NEAR check
-		isHolder = IsOwnerOfNFT(chat.Nftaddr, chat.Fromaddr, "near")
-	} else if !isHolder && strings.HasPrefix(chat.Fromaddr, "tz") { //Tezos check
-		isHolder = IsOwnerOfNFT(chat.Nftaddr, chat.Fromaddr, "tezos")
-	} else if !isHolder && strings.HasPrefix(chat.Nftaddr, "poap_") {
-		split := strings.Split(chat.Nftaddr, "_")
-		isHolder = IsOwnerOfPOAP(split[1], chat.Fromaddr)
+	//the chain the contract is on, empty tries every enabled chain
+	chain, validChainName := validChain(chat.Chain)
+	if !validChainName {
+		http.Error(w, "unsupported chain", http.StatusBadRequest)
+		return
 	}
+	chat.Chain = chain
+
+	//ensure user holds the NFT first
+	isHolder := isGroupHolder(chat.Nftaddr, chat.Fromaddr, groupChain(chat.Nftaddr, chat.Fromaddr, chat.Chain))
 
 	if strings.EqualFold(chat.Fromaddr, Authuser.Address) && isHolder {
-		//public chats are not encrpyted and we implement a basic censor
-		cleanMessage := goaway.Censor(chat.Message)
-		chat.Message = cleanMessage
+		if err := checkReplyTo(chat, Authuser.Address); err != nil {
+			writeReplyError(w, err)
+			return
+		}
+		if !checkGroupPost(w, chat.Nftaddr, Authuser.Address) {
+			return
+		}
+		//public chats are not encrpyted, they go through the group's filter rules instead
+		filtered := filterGroupMessage(chat.Nftaddr, chat.Message)
+		if filtered.Blocked {
+			writeBlockedMessage(w, filtered)
+			return
+		}
+		chat.Message = filtered.Message
 
 		database.Connector.Create(&chat)
+		if filtered.Flagged {
+			flagFilteredMessage(chat, filtered)
+		}
 
 		wc_analytics.SendCustomEvent(Authuser.Address, "SEND_MESSAGE_NFTGROUP")
+		go notifyGroupMessage(chat, entity.Nft)
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(chat)
+		writeFilteredMessage(w, chat, filtered)
 	} else {
 		w.WriteHeader(http.StatusForbidden)
 	}
@@ -1511,7 +1595,7 @@ func CreateGroupChatitem(w http.ResponseWriter, r *http.Request) {
 
 // ChangeCommunityConditions godoc
 // @Summary     Change community access conditions
-// @Description Change community access conditions
+// @Description Replaces all conditions with this single one, use PUT /v1/community/{slug}/conditions for AND/OR groups
 // @Tags        GroupChat
 // @Accept      json
 // @Produce     json
@@ -1529,42 +1613,26 @@ func ChangeCommunityConditions(w http.ResponseWriter, r *http.Request) {
 	Authuser := auth.GetUserFromReqContext(r)
 
 	//ensure the caller is an admin for the group
-	var adminForCommunity []entity.Communityadmin
-	dbQuery := database.Connector.Where("adminaddr = ?", Authuser.Address).Find(&adminForCommunity)
-	isAdmin := false
-	for i := 0; i < int(dbQuery.RowsAffected); i++ {
-		if adminForCommunity[i].Slug == accessCondition.Slug {
-			isAdmin = true
-			break
-		}
-	}
-	if !isAdmin {
+	if !isCommunityAdmin(accessCondition.Slug, Authuser.Address) {
 		fmt.Println("ChangeCommunityConditions not an admin", accessCondition.Slug)
 		w.WriteHeader(http.StatusForbidden)
 		return
 	}
 
-	var accessConditionToUpdate entity.Communityaccesscondition
-	dbQuery = database.Connector.Where("slug = ?", accessCondition.Slug).Find(&accessConditionToUpdate)
-	resultCnt := 0
-	if dbQuery.RowsAffected == 0 {
-		dbQuery = database.Connector.Create(&accessCondition)
-		resultCnt += int(dbQuery.RowsAffected)
-	} else {
-		dbQuery = database.Connector.Model(&entity.Communityaccesscondition{}).
-			Where("slug = ?", accessCondition.Slug).
-			Update("nftaddr", accessCondition.Nftaddr)
-		//fmt.Println("ChangeCommunityConditions 1", accessCondition, dbQuery.RowsAffected)
-		resultCnt += int(dbQuery.RowsAffected)
-
-		dbQuery = database.Connector.Model(&entity.Communityaccesscondition{}).
-			Where("slug = ?", accessCondition.Slug).
-			Update("count", accessCondition.Count)
-		//fmt.Println("ChangeCommunityConditions 2", accessCondition, dbQuery.RowsAffected)
-		resultCnt += int(dbQuery.RowsAffected)
+	if problem := validateAccessCondition(&accessCondition); problem != "" {
+		http.Error(w, problem, http.StatusBadRequest)
+		return
 	}
+	accessCondition.Id = 0
+	accessCondition.Groupid = 0
+	accessCondition.Channel = "" //channel conditions go through /v1/community/{slug}/channels/{channel}/conditions
+
+	database.Connector.Where("slug = ?", accessCondition.Slug).Where("channel = ?", "").Delete(&entity.Communityaccesscondition{})
+	dbQuery := database.Connector.Create(&accessCondition)
+	invalidateAccessCache(accessCondition.Slug)
+	go RecheckCommunityAccess(accessCondition.Slug)
 
-	if resultCnt > 0 {
+	if dbQuery.RowsAffected > 0 {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
 	} else {
@@ -1592,32 +1660,43 @@ func CreateCommunity(w http.ResponseWriter, r *http.Request) {
 
 	Authuser := auth.GetUserFromReqContext(r)
 
-	//don't want groups named "new" as that is the path for creating new groups
-	if strings.EqualFold(communityInfo.Name, "new") {
-		fmt.Println("blacklisted community name", communityInfo.Name)
-		w.WriteHeader(http.StatusForbidden)
+	if strings.TrimSpace(communityInfo.Name) == "" {
+		http.Error(w, "name is required", http.StatusBadRequest)
 		return
 	}
 	fmt.Println("input data create community: ", communityInfo)
 
-	var addrname entity.Addrnameitem
-	addrname.Name = communityInfo.Name //communityInfo.Title
-	//auto-generate the slug (unique URL safe group name)
-	slug := url.QueryEscape(addrname.Name)
-	fmt.Println("Create Community Slug: ", slug)
-	addrname.Address = slug //Slug
+	slug, err := createCommunity(communityInfo, Authuser.Address)
+	if errors.Is(err, errCommunitySlugTaken) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err == nil {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(slug)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusCreated)
+	} else {
+		w.WriteHeader(http.StatusForbidden)
+	}
+}
 
-	var mappings []entity.Addrnameitem
-	dbQuery := database.Connector.Where("address = ?", addrname.Address).Find(&mappings)
-	//currently, community chat is in the addrname mapping table in the DB
-	for i := 0; i < 100; i++ {
-		if dbQuery.RowsAffected == 0 {
-			database.Connector.Create(&addrname)
-			break
-		}
-		addrname.Address = addrname.Address + "_" + strconv.Itoa(i)
-		dbQuery = database.Connector.Where("address = ?", addrname.Address).Find(&mappings)
+// createCommunity stores a new community with its name, image and socials, the creator becomes its admin
+func createCommunity(communityInfo entity.Createcommunityitem, creator string) (string, error) {
+	//auto-generate the slug (unique URL safe group name), reserved words like "new" get a suffix
+	community, err := createCommunityRecord(communityInfo.Name, creator)
+	if err != nil {
+		fmt.Println("CreateCommunity failed to pick a slug", err)
+		return "", errCommunitySlugTaken
 	}
+	slug := community.Slug
+	fmt.Println("Create Community Slug: ", slug)
+
+	//name lookups for community chats still go through the addrname mapping table
+	var addrname entity.Addrnameitem
+	addrname.Name = communityInfo.Name
+	addrname.Address = slug
+	database.Connector.Create(&addrname)
 
 	if communityInfo.Image != "" {
 		var imageaddr entity.Imageitem
@@ -1636,21 +1715,15 @@ func CreateCommunity(w http.ResponseWriter, r *http.Request) {
 
 	//currently the community creator is admin (lots of room for progress)
 	var groupadmin entity.Communityadmin
-	groupadmin.Adminaddr = Authuser.Address
+	groupadmin.Adminaddr = creator
 	groupadmin.Slug = addrname.Address //slug
-	groupadmin.Accesslevel = "admin"   //eventually an enum - admin/moderator/?
-	dbQuery = database.Connector.Create(&groupadmin)
-	//TODO: do we need to limit the people that can create community chat groups?
-	//Authuser := auth.GetUserFromReqContext(r)
-	//if strings.EqualFold(chat.Fromaddr, Authuser.Address) {
-	if dbQuery.RowsAffected != 0 {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(slug)
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.WriteHeader(http.StatusCreated)
-	} else {
-		w.WriteHeader(http.StatusForbidden)
+	groupadmin.Accesslevel = entity.CommunityRoleAdmin
+	dbQuery := database.Connector.Create(&groupadmin)
+	activateCommunityMember(groupadmin.Slug, creator, entity.CommunityRoleAdmin, "", creator)
+	if dbQuery.RowsAffected == 0 {
+		return slug, fmt.Errorf("could not add the community admin: %v", dbQuery.Error)
 	}
+	return slug, nil
 }
 
 // UpdateCommunity godoc
@@ -1680,17 +1753,18 @@ func UpdateCommunity(w http.ResponseWriter, r *http.Request) {
 
 	fmt.Println("input data update community: ", communityInfo)
 
-	var groupadmin entity.Communityadmin
-	database.Connector.Where("slug = ?", communityInfo.Slug).Find(&groupadmin)
-
-	if groupadmin.Accesslevel == "admin" && strings.EqualFold(groupadmin.Adminaddr, Authuser.Address) {
+	if isCommunityAdmin(communityInfo.Slug, Authuser.Address) {
 		var mappings []entity.Addrnameitem
 		database.Connector.Where("address = ?", communityInfo.Slug).Find(&mappings)
 
-		//for update we modify the common name if its different
+		//for update we modify the common name if its different (the slug stays, see PUT /v1/community/{slug}/slug)
 		database.Connector.Model(&entity.Addrnameitem{}).
 			Where("address = ?", communityInfo.Slug).
 			Update("name", communityInfo.Name)
+		database.Connector.Model(&entity.Communityitem{}).
+			Where("slug = ?", communityInfo.Slug).
+			Update("name", communityInfo.Name)
+		invalidateDirectoryCache()
 
 		//delete all communitysocials and just add back in what is passsed in, this allows for deletion
 		var socialsToDelete []entity.Communitysocial
@@ -1743,7 +1817,7 @@ func CreateCommunityChatItem(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//set type (could hack this in GET side but this is probably cleaner?)
-	if chat.Type != entity.Welcome {
+	if chat.Type != entity.Welcome && chat.Type != entity.Announcement {
 		chat.Type = entity.Message
 	}
 
@@ -1752,17 +1826,46 @@ func CreateCommunityChatItem(w http.ResponseWriter, r *http.Request) {
 
 	Authuser := auth.GetUserFromReqContext(r)
 	if strings.EqualFold(chat.Fromaddr, Authuser.Address) {
-		cleanMessage := goaway.Censor(chat.Message)
-		chat.Message = cleanMessage
+		if !canPostInCommunity(chat.Nftaddr, Authuser.Address) || !communityAccessAllowed(chat.Nftaddr, Authuser.Address) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if chat.Type == entity.Announcement && !isCommunityAdmin(chat.Nftaddr, Authuser.Address) {
+			http.Error(w, "only community admins can post announcements", http.StatusForbidden)
+			return
+		}
+		if err := checkReplyTo(chat, Authuser.Address); err != nil {
+			writeReplyError(w, err)
+			return
+		}
+		chat.Channel = channelKey(chat.Channel)
+		if err := checkChannelPost(chat.Nftaddr, chat.Channel, Authuser.Address); err != nil {
+			writeChannelError(w, err)
+			return
+		}
+		if !checkGroupPost(w, chat.Nftaddr, Authuser.Address) {
+			return
+		}
+		filtered := filterGroupMessage(chat.Nftaddr, chat.Message)
+		if filtered.Blocked {
+			writeBlockedMessage(w, filtered)
+			return
+		}
+		chat.Message = filtered.Message
 
 		database.Connector.Create(&chat)
+		if filtered.Flagged {
+			flagFilteredMessage(chat, filtered)
+		}
 
 		wc_analytics.SendCustomEvent(Authuser.Address, "SEND_MESSAGE_COMMUNITY")
+		if chat.Type == entity.Announcement {
+			go notifyCommunityAnnouncement(chat)
+		} else {
+			go notifyGroupMessage(chat, entity.Community)
+		}
 
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(chat)
+		writeFilteredMessage(w, chat, filtered)
 	} else {
 		w.WriteHeader(http.StatusForbidden)
 	}
@@ -1787,23 +1890,44 @@ func CreateBookmarkItem(w http.ResponseWriter, r *http.Request) {
 
 	if strings.EqualFold(bookmark.Walletaddr, Authuser.Address) {
 		//fmt.Printf("Bookmark Item: %#v\n", chat)
+		//only NFT contracts take the client's chain, communities and POAPs get theirs below ("none", "xdai")
+		requestedChain := ""
+		if strings.HasPrefix(bookmark.Nftaddr, "0x") {
+			chain, validChainName := validChain(bookmark.Chain)
+			if !validChainName {
+				http.Error(w, "unsupported chain", http.StatusBadRequest)
+				return
+			}
+			requestedChain = chain
+		}
 		bookmark.Chain = "none"
 
 		if strings.HasPrefix(bookmark.Nftaddr, "poap_") {
 			bookmark.Chain = "xdai"
-		} else {
-			var result = IsOnChain(bookmark.Nftaddr, "ethereum")
-			if result {
-				bookmark.Chain = "ethereum"
-			} else {
-				var result = IsOnChain(bookmark.Nftaddr, "polygon")
-				if result {
-					bookmark.Chain = "polygon"
-				}
+		} else if strings.HasPrefix(bookmark.Nftaddr, "0x") && requestedChain != "" {
+			if IsOnChain(bookmark.Nftaddr, requestedChain) {
+				bookmark.Chain = requestedChain
+			}
+		} else if strings.HasPrefix(bookmark.Nftaddr, "0x") {
+			if chain := contractChain(bookmark.Nftaddr); chain != "" {
+				bookmark.Chain = chain
 			}
 		}
 
-		database.Connector.Create(&bookmark)
+		if bookmark.Chain == "none" && communityExists(bookmark.Nftaddr) {
+			//joining a community goes through its join policy and bans
+			member, err := joinCommunity(bookmark.Nftaddr, Authuser.Address, "")
+			if err != nil {
+				writeCommunityError(w, err)
+				return
+			}
+			if member.Status == entity.MemberStatusPending {
+				writeCommunityMember(w, http.StatusAccepted, member)
+				return
+			}
+		} else {
+			database.Connector.Create(&bookmark)
+		}
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
 		w.WriteHeader(http.StatusCreated)
@@ -1831,9 +1955,19 @@ func DeleteBookmarkItem(w http.ResponseWriter, r *http.Request) {
 	Authuser := auth.GetUserFromReqContext(r)
 
 	if strings.EqualFold(bookmark.Walletaddr, Authuser.Address) {
+		var returnval bool
+		if member, found := getCommunityMember(bookmark.Nftaddr, Authuser.Address); found && member.Status == entity.MemberStatusActive {
+			if isCommunityAdmin(bookmark.Nftaddr, Authuser.Address) && countCommunityAdmins(bookmark.Nftaddr) <= 1 {
+				writeCommunityError(w, errCommunityLastAdmin)
+				return
+			}
+			setCommunityRole(bookmark.Nftaddr, Authuser.Address, entity.CommunityRoleMember)
+			member.Role = entity.CommunityRoleMember
+			deactivateCommunityMember(member, entity.MemberStatusLeft, Authuser.Address)
+			returnval = true
+		}
 		var success = database.Connector.Where("nftaddr = ?", bookmark.Nftaddr).Where("walletaddr = ?", bookmark.Walletaddr).Delete(bookmark)
 
-		var returnval bool
 		if success.RowsAffected > 0 {
 			returnval = true
 		}
@@ -2315,7 +2449,8 @@ func TrackEventGA4(w http.ResponseWriter, r *http.Request) {
 
 // CreateAddrNameItem godoc
 // @Summary     give a common name to a user address, or NFT collection
-// @Description Give a common name (Kevin.eth, BillyTheKid, etc) to an Address (*** Will return 403 if address doesn't own ENS name! ***)
+// @Description Give a common name (Kevin.eth, BillyTheKid, etc) to an Address (*** Will return 403 if address doesn't own the ENS/BNB/ARB/BTC name or the name is reserved! ***)
+// @Description Names backed by a web3 name are returned as verified, 409 if the name (or a lookalike) is used by another wallet
 // @Description Accepts ADMIN_API_KEY for integrated sign-in and creating new WC users (see top of page for examples)
 // @Tags        Common
 // @Accept      json
@@ -2351,30 +2486,16 @@ func CreateAddrNameItem(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	//ensure if user is trying to use .eth that they own it
-	if strings.HasSuffix(addrname.Name, ".eth") {
-		client, err := ethclient.Dial("https://mainnet.infura.io/v3/" + os.Getenv("INFURA_V3"))
-		if err != nil {
-			fmt.Println(err)
-		}
-
-		// Resolve a name to an address.
-		address, err := ens.Resolve(client, addrname.Name)
-		if err != nil {
-			fmt.Println(err)
-		}
-		fmt.Printf("Address of %s is %s\n", addrname.Name, address.Hex())
-
-		if !strings.EqualFold(address.Hex(), addrname.Address) {
-			fmt.Printf("Addresses do not match! %s is %s\n", addrname.Address, address.Hex())
-			w.WriteHeader(http.StatusForbidden)
+	Authuser := auth.GetUserFromReqContext(r)
+	if strings.EqualFold(Authuser.Address, addrname.Address) || isAdmin {
+		//web3 names (.eth, .bnb, .btc...) must be owned by the address and get verified,
+		//other names can't be reserved or imitate another wallet's name
+		if err := applyNamePolicy(&addrname, isAdmin); err != nil {
+			fmt.Printf("name rejected: %s <-> %s: %v\n", addrname.Address, addrname.Name, err)
+			writeNamePolicyError(w, err)
 			return
 		}
-	}
-	//end ensuring .eth name is owned by sender
 
-	Authuser := auth.GetUserFromReqContext(r)
-	if strings.EqualFold(Authuser.Address, addrname.Address) || isAdmin {
 		//create or update in one function is easier
 		var addrnameDB entity.Addrnameitem
 		var dbQuery = database.Connector.Where("address = ?", addrname.Address).Find(&addrnameDB)
@@ -2433,7 +2554,7 @@ func CreateAddrNameItem(w http.ResponseWriter, r *http.Request) {
 		} else {
 			var result = database.Connector.Model(&entity.Addrnameitem{}).
 				Where("address = ?", addrname.Address).
-				Update("name", addrname.Name)
+				Updates(namePolicyUpdates(addrname))
 			affectedRows = int(result.RowsAffected)
 			fmt.Printf("updating addr->name item: %s <-> %s\n", addrname.Address, addrname.Name)
 
@@ -2485,6 +2606,11 @@ func OuraCreateAddrNameItem(w http.ResponseWriter, r *http.Request) {
 		fmt.Println("unmarshal error: ", err)
 	}
 
+	if err := applyNamePolicy(&addrNameItem, false); err != nil {
+		writeNamePolicyError(w, err)
+		return
+	}
+
 	var addrnameDB entity.Addrnameitem
 	var dbQuery = database.Connector.Where("address = ?", addrNameItem.Address).Find(&addrnameDB)
 	if dbQuery.RowsAffected == 0 {
@@ -2492,7 +2618,7 @@ func OuraCreateAddrNameItem(w http.ResponseWriter, r *http.Request) {
 	} else {
 		database.Connector.Model(&entity.Addrnameitem{}).
 			Where("address = ?", addrNameItem.Address).
-			Update("name", addrNameItem.Name)
+			Updates(namePolicyUpdates(addrNameItem))
 
 		fmt.Printf("updating addr->name item: %s <-> %s\n", addrNameItem.Address, addrNameItem.Name)
 	}
@@ -2560,6 +2686,7 @@ func OuraCreateAddrNameItem(w http.ResponseWriter, r *http.Request) {
 // @Security    BearerAuth
 // @Param       address     path    string true "NFT Address"
 // @Param       useraddress path    string true "FROM: wallet address"
+// @Param       chain       query   string false "chain of the NFT contract, every enabled chain is tried when empty"
 // @Success     200         {array} entity.Groupchatitem
 // @Router      /v1/get_groupchatitems/{address}/{useraddress} [get]
 func GetGroupChatItemsByAddr(w http.ResponseWriter, r *http.Request) {
@@ -2568,27 +2695,15 @@ func GetGroupChatItemsByAddr(w http.ResponseWriter, r *http.Request) {
 	//fromaddr := vars["useraddress"]
 	Authuser := auth.GetUserFromReqContext(r)
 	fromaddr := Authuser.Address
+	chain, ok := chainParam(w, r)
+	if !ok {
+		return
+	}
 
 	var chat []entity.Groupchatitem
 
-	//TODO this will use up API calls fast if we are polling all the time
-	//ensure user holds the NFT first
-	isHolder := false
-	if strings.HasPrefix(nftaddr, "0x") {
-		//TODO: we should send in chain along with message
-		isHolder = IsOwnerOfNFT(nftaddr, fromaddr, "ethereum")
-		if !isHolder {
-			isHolder = IsOwnerOfNFT(nftaddr, fromaddr, "polygon")
-		}
-	} else if !isHolder && (strings.HasSuffix(fromaddr, ".near") || strings.HasSuffix(fromaddr, ".testnet")) ||
-		(len(fromaddr) == 64 && !strings.HasPrefix(fromaddr, "0x")) { //NEAR check
-		isHolder = IsOwnerOfNFT(nftaddr, fromaddr, "near")
-	} else if !isHolder && strings.HasPrefix(fromaddr, "tz") { //Tezos check
-		isHolder = IsOwnerOfNFT(nftaddr, fromaddr, "tezos")
-	} else if !isHolder && strings.HasPrefix(nftaddr, "poap_") {
-		split := strings.Split(nftaddr, "_")
-		isHolder = IsOwnerOfPOAP(split[1], fromaddr)
-	}
+	//ensure user holds the NFT first (cached, see ownershipcache.go)
+	isHolder := isGroupHolder(nftaddr, fromaddr, groupChain(nftaddr, fromaddr, chain))
 
 	//if user is not a holder, can't get the messages
 	if isHolder {
@@ -2834,22 +2949,14 @@ func IsModerator(w http.ResponseWriter, r *http.Request) {
 // 	}
 // }
 
-var letters = []rune("abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ")
-
 func InitRandom() {
 	rand.Seed(time.Now().UnixNano())
 }
-func randSeq(n int) string {
-	b := make([]rune, n)
-	for i := range b {
-		b[i] = letters[rand.Intn(len(letters))]
-	}
-	return string(b)
-}
 
 // UpdateSettings godoc
 // @Summary     Settings hold a user address and the email address for notifications if they opt-in
 // @Description Update settings, email address, daily notifications and per DM notifications
+// @Description Deprecated: empty fields are ignored so nothing can be cleared, use PATCH /v2/settings
 // @Tags        Common
 // @Accept      json
 // @Produce     json
@@ -2876,55 +2983,47 @@ func UpdateSettings(w http.ResponseWriter, r *http.Request) {
 		var dbResults = database.Connector.Where("walletaddr = ?", addr).Find(&settings)
 
 		if dbResults.RowsAffected == 0 {
+			//verification state is only ever set by the server (email code, Telegram bot, Twitter check)
+			settingsRX.Verified = "false"
+			settingsRX.Telegramid = ""
+			settingsRX.Twitterverified = "false"
 			dbResults = database.Connector.Create(&settingsRX)
 			log.Println("Created New Settings")
 
 			//create Telegram Link/Login Code
 			if settingsRX.Telegramhandle != "" {
-				var telegramVerificationCode = randSeq(20)
-				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("telegramcode", telegramVerificationCode)
-				if dbResults.RowsAffected == 0 {
-					log.Println("Did not update verification code item for: ", addr)
+				telegramVerificationCode, err := randomCode(20) //crypto/rand, the code proves the Telegram account belongs to the wallet
+				if err != nil {
+					log.Println("Could not create a Telegram code for: ", addr, err)
+				} else {
+					dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("telegramcode", telegramVerificationCode)
+					if dbResults.RowsAffected == 0 {
+						log.Println("Did not update verification code item for: ", addr)
+					}
 				}
 			}
 
 			//send verification email
 			if strings.Contains(settingsRX.Email, "@") {
-				var toAddrname entity.Addrnameitem
-				dbResults = database.Connector.Where("address = ?", settingsRX.Walletaddr).Find(&toAddrname)
-				if dbResults.RowsAffected == 0 {
-					log.Println("Did not find addrname item for: ", settingsRX.Walletaddr)
-				}
-				var verificationCode = randSeq(10)
-				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("verified", verificationCode)
-				if dbResults.RowsAffected == 0 {
-					log.Println("Did not update verification code item for: ", addr)
-				}
-				from := mail.NewEmail("WalletChat Notifications", "contact@walletchat.fun")
-				subject := "Please Verify Email for " + settingsRX.Signupsite
-				to := mail.NewEmail(toAddrname.Name, settingsRX.Email)
 				if settingsRX.Signupsite == "" {
 					settingsRX.Signupsite = settingsRX.Domain //from the main webapp, domain and signup site is the same
 				}
-				plainTextContent := "Please verify your email entered at " + settingsRX.Signupsite + " by clicking here: " + settingsRX.Domain + "/verify-email?email=" + settings.Email + "&code=" + verificationCode
-				htmlContent := email.NotificationEmailVerify(toAddrname.Address, toAddrname.Name, "Email Verification", settingsRX.Email, verificationCode, settingsRX.Signupsite, settingsRX.Domain)
-				message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-				client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
-				response, err := client.Send(message)
-				if err != nil {
-					log.Println(err)
-				} else {
-					_ = response
+				if err := issueEmailVerification(addr, settingsRX.Email, settingsRX.Signupsite, settingsRX.Domain, settingsRX.Locale); err != nil {
+					log.Println("Verification email not sent: ", addr, err)
 				}
 			}
 			wc_analytics.SendCustomEvent(settingsRX.Walletaddr, "UPDATE_SETTINGS")
 		} else {
 			if settingsRX.Telegramhandle != "" {
 				//create Telegram Link/Login Code
-				var telegramVerificationCode = randSeq(20)
-				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("telegramcode", telegramVerificationCode)
-				if dbResults.RowsAffected == 0 {
-					fmt.Println("Did not update verification code item for: ", addr)
+				telegramVerificationCode, err := randomCode(20) //crypto/rand, the code proves the Telegram account belongs to the wallet
+				if err != nil {
+					log.Println("Could not create a Telegram code for: ", addr, err)
+				} else {
+					dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("telegramcode", telegramVerificationCode)
+					if dbResults.RowsAffected == 0 {
+						fmt.Println("Did not update verification code item for: ", addr)
+					}
 				}
 
 				log.Println("Updating Telegram Handle ", settingsRX.Telegramhandle)
@@ -2932,41 +3031,35 @@ func UpdateSettings(w http.ResponseWriter, r *http.Request) {
 				//technically we don't need the handle since the chatId is really what is used, but this helps with login flow and double checks
 				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("telegramhandle", settingsRX.Telegramhandle)
 			}
-			if settingsRX.Email != "" {
+			emailChanged := !strings.EqualFold(strings.TrimSpace(settingsRX.Email), strings.TrimSpace(settings.Email))
+			if settingsRX.Email != "" && (emailChanged || !strings.EqualFold(settings.Verified, "true")) {
 				fmt.Println("Updating Email", settingsRX.Email)
 				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("email", settingsRX.Email)
+				if emailChanged {
+					notifyEmailChanged(settings, settingsRX.Email)
+				}
 				//send verification email
 				if strings.Contains(settingsRX.Email, "@") {
-					var toAddrname entity.Addrnameitem
-					database.Connector.Where("address = ?", settingsRX.Walletaddr).Find(&toAddrname)
-
-					var verificationCode = randSeq(10)
-					database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("verified", verificationCode)
-
-					from := mail.NewEmail("WalletChat Notifications", "contact@walletchat.fun")
 					if settingsRX.Signupsite == "" {
 						settingsRX.Signupsite = settingsRX.Domain //from the main webapp, domain and signup site is the same
 					}
 					if settingsRX.Signupsite != "" {
 						settings.Signupsite = settingsRX.Signupsite //use the received one over past saved signup site.
 					}
-					subject := "Please Verify Email for " + settings.Signupsite
-					to := mail.NewEmail(toAddrname.Name, settingsRX.Email)
-					plainTextContent := "Please verify your email entered at " + settings.Signupsite + " by clicking here: " + settings.Domain + "/verify-email?email=" + settings.Email + "&code=" + verificationCode
-					htmlContent := email.NotificationEmailVerify(toAddrname.Address, toAddrname.Name, "Email Verification", settingsRX.Email, verificationCode, settingsRX.Signupsite, settingsRX.Domain)
-					message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-					client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
-					response, err := client.Send(message)
-					if err != nil {
-						log.Println(err)
-					} else {
-						_ = response
+					if settingsRX.Domain != "" {
+						settings.Domain = settingsRX.Domain
+					}
+					if settingsRX.Locale != "" {
+						settings.Locale = settingsRX.Locale
+					}
+					if err := issueEmailVerification(addr, settingsRX.Email, settings.Signupsite, settings.Domain, settings.Locale); err != nil {
+						log.Println("Verification email not sent: ", addr, err)
 					}
 				}
 			}
 			if settingsRX.Verified != "" {
-				log.Println("Updating Verifed Email Status", settingsRX.Verified)
-				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("verified", settingsRX.Verified)
+				//only VerifyEmail can mark an email as verified
+				log.Println("Ignoring client Verified value for: ", addr)
 			}
 			if settingsRX.Notifydm != "" {
 				log.Println("Updating Daily Notifications", settingsRX.Notifydm)
@@ -2976,6 +3069,10 @@ func UpdateSettings(w http.ResponseWriter, r *http.Request) {
 				log.Println("Updating Daily Notifications", settingsRX.Notify24)
 				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("notify24", settingsRX.Notify24)
 			}
+			if settingsRX.Locale != "" {
+				log.Println("Updating Email Language", settingsRX.Locale)
+				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("locale", email.NormalizeLocale(settingsRX.Locale))
+			}
 			if settingsRX.Signupsite != "" {
 				log.Println("Updating Signup Site", settingsRX.Signupsite)
 				//strip HTTPS prefix and trailing /
@@ -3002,6 +3099,10 @@ func UpdateSettings(w http.ResponseWriter, r *http.Request) {
 				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Update("twitterverified", "false")
 			}
 		}
+		var updated entity.Settings
+		database.Connector.Where("walletaddr = ?", addr).Find(&updated)
+		recordSettingsAudit(settings, updated, Authuser.Address, settingsAuditSourceV1)
+
 		w.WriteHeader(http.StatusOK)
 		w.Header().Set("Content-Type", "application/json")
 		w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -3013,45 +3114,6 @@ func UpdateSettings(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// UpdateSettings godoc
-// @Summary     Link a user can click in email to verify email address, will have unique code
-// @Description Users will get an email when signing-up to verify email, to ensure we do not send spam
-// @Tags        Common
-// @Accept      json
-// @Produce     json
-// @Security    BearerAuth
-// @Param       message body    entity.Settings true "update struct"
-// @Success     200     {array} entity.Settings
-// @Router      /v1/verify_email/{email}/{verification_code} [GET]
-func VerifyEmail(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	email := vars["email"]
-	code := vars["code"]
-	log.Println("Verify Email: ", email)
-	var settingsRX []entity.Settings
-	var dbResults = database.Connector.Where("email = ?", email).Find(&settingsRX)
-	if dbResults.RowsAffected == 0 {
-		w.WriteHeader(http.StatusForbidden)
-	} else {
-		dbResults.RowsAffected = 0
-		for i := 0; i < len(settingsRX); i++ {
-			if settingsRX[i].Verified == code {
-				log.Println("Updating Verifed Email Status", settingsRX[i].Verified)
-				dbResults = database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", settingsRX[i].Walletaddr).Update("verified", "true")
-				break
-			}
-		}
-		if dbResults.RowsAffected == 0 {
-			w.WriteHeader(http.StatusForbidden)
-		} else {
-			w.WriteHeader(http.StatusOK)
-			w.Header().Set("Content-Type", "application/json")
-			w.Header().Set("X-Content-Type-Options", "nosniff")
-			json.NewEncoder(w).Encode(dbResults.RowsAffected)
-		}
-	}
-}
-
 // DeleteSettings godoc
 // @Summary     Delete Settings Info
 // @Description TODO: not yet used
@@ -3069,14 +3131,17 @@ func DeleteSettings(w http.ResponseWriter, r *http.Request) {
 	key := Authuser.Address
 
 	var settings entity.Settings
-
-	database.Connector.Where("walletaddr = ?", key).Delete(&settings)
+	dbResults := database.Connector.Where("walletaddr = ?", key).Find(&settings)
+	if dbResults.RowsAffected > 0 {
+		database.Connector.Where("walletaddr = ?", key).Delete(&entity.Settings{})
+		recordSettingsAudit(settings, entity.Settings{Walletaddr: settings.Walletaddr}, key, settingsAuditSourceV1)
+	}
 	w.WriteHeader(http.StatusNoContent)
 }
 
 // GetSettings godoc
 // @Summary     Get Settings Info
-// @Description TODO: not yet used
+// @Description Deprecated: returns the raw row, use GET /v2/settings which has typed fields and no secrets
 // @Tags        Unused/Legacy
 // @Accept      json
 // @Produce     json
@@ -3093,7 +3158,7 @@ func GetSettings(w http.ResponseWriter, r *http.Request) {
 	var settings []entity.Settings
 	database.Connector.Where("walletaddr = ?", key).Find(&settings)
 
-	//if there is a verification code, make sure to clear it out
+	//rows from before verification tokens may still hold a verification code, make sure to clear it out
 	//or this would be a vulnerability that people could verify other email addresses
 	if len(settings) > 0 && len(settings[0].Verified) > 9 {
 		settings[0].Verified = "false"
@@ -3104,299 +3169,59 @@ func GetSettings(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(settings)
 }
 
-func Erc20TokenOverlap(w http.ResponseWriter, r *http.Request) {
+// ResolveName godoc
+// @Summary     Generic Resolve Name Service
+// @Description Resolve .ETH, .BNB, .ARB, .BTC (and Unstoppable Domains when configured) names to an address.
+// @Description Every naming system returns the same shape, avatar and expiry are included when known.
+// @Description 404 if the name doesn't exist, 400 for unsupported names, 502 when the naming service failed
+// @Tags        Common
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       name path     string true "ENS/BNB/ARB/BTC Name"
+// @Success     200  {object} names.Record
+// @Router      /v1/resolve_name/{name} [get]
+func ResolveName(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
-	tokenAddress := vars["contract_address"]
-
-	url := "https://api.dune.com/api/v1/query/3615247/execute"
+	nameToResolve := vars["name"]
 
-	// Create the POST request payload
-	payload := map[string]interface{}{
-		"query_parameters": map[string]string{
-			"token_address": tokenAddress,
-		},
-		"performance": "medium",
-	}
-	jsonData, err := json.Marshal(payload)
+	record, err := names.Resolve(nameToResolve)
 	if err != nil {
-		http.Error(w, "Error preparing request", http.StatusInternalServerError)
+		writeNameError(w, nameToResolve, err)
 		return
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		http.Error(w, "Error creating request", http.StatusInternalServerError)
-		return
-	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(record)
+}
 
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-Dune-API-Key", os.Getenv("DUNE_API_KEY"))
-	req.Header.Add("Content-Type", "application/json")
+// CreateComments godoc
+// @Summary     Comments are used within an NFT community chat
+// @Description Comments are meant to be public, someday having an up/downvote method for auto-moderation
+// @Tags        NFT
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       message body    entity.Comments true "create struct"
+// @Success     200     {array} entity.Comments
+// @Router      /v1/create_comments [post]
+func CreateComments(w http.ResponseWriter, r *http.Request) {
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var comment entity.Comments
+	json.Unmarshal(requestBody, &comment)
 
-	// Send the POST request
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		http.Error(w, "Error sending request", http.StatusInternalServerError)
-		return
+	Authuser := auth.GetUserFromReqContext(r)
+	if strings.EqualFold(Authuser.Address, comment.Fromaddr) {
+		database.Connector.Create(&comment)
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(comment)
+	} else {
+		w.WriteHeader(http.StatusForbidden)
 	}
-	defer res.Body.Close()
-
-	// Read and parse the response
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		http.Error(w, "Error reading response", http.StatusInternalServerError)
-		return
-	}
-
-	var response struct {
-		ExecutionID string `json:"execution_id"`
-		State       string `json:"state"`
-	}
-	if err := json.Unmarshal(body, &response); err != nil {
-		http.Error(w, "Error parsing response", http.StatusInternalServerError)
-		return
-	}
-
-	// Check the status of the execution periodically
-	for {
-		statusURL := "https://api.dune.com/api/v1/execution/" + response.ExecutionID + "/results"
-		statusReq, err := http.NewRequest("GET", statusURL, nil)
-		if err != nil {
-			http.Error(w, "Error creating status request", http.StatusInternalServerError)
-			return
-		}
-		statusReq.Header.Add("X-Dune-API-Key", os.Getenv("DUNE_API_KEY"))
-
-		statusRes, err := http.DefaultClient.Do(statusReq)
-		if err != nil {
-			http.Error(w, "Error sending status request", http.StatusInternalServerError)
-			return
-		}
-
-		statusBody, err := ioutil.ReadAll(statusRes.Body)
-		statusRes.Body.Close()
-		if err != nil {
-			http.Error(w, "Error reading status response", http.StatusInternalServerError)
-			return
-		}
-
-		var statusResponse struct {
-			IsExecutionFinished bool `json:"is_execution_finished"`
-		}
-		json.Unmarshal(statusBody, &statusResponse)
-
-		fmt.Println("requestID current status: ", response.ExecutionID, statusResponse)
-
-		if statusResponse.IsExecutionFinished {
-			// Once finished, respond with the final results
-			json.NewEncoder(w).Encode(string(statusBody))
-			break
-		}
-		time.Sleep(30 * time.Second)
-	}
-}
-
-func SolTokenOverlap(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	tokenAddress := vars["contract_address"]
-
-	url := "https://api.dune.com/api/v1/query/3623869/execute"
-
-	// Create the POST request payload
-	payload := map[string]interface{}{
-		"query_parameters": map[string]string{
-			"token_address": tokenAddress,
-		},
-		"performance": "medium",
-	}
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		http.Error(w, "Error preparing request", http.StatusInternalServerError)
-		return
-	}
-
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
-	if err != nil {
-		http.Error(w, "Error creating request", http.StatusInternalServerError)
-		return
-	}
-
-	req.Header.Add("Accept", "application/json")
-	req.Header.Add("X-Dune-API-Key", os.Getenv("DUNE_API_KEY"))
-	req.Header.Add("Content-Type", "application/json")
-
-	// Send the POST request
-	res, err := http.DefaultClient.Do(req)
-	if err != nil {
-		http.Error(w, "Error sending request", http.StatusInternalServerError)
-		return
-	}
-	defer res.Body.Close()
-
-	// Read and parse the response
-	body, err := ioutil.ReadAll(res.Body)
-	if err != nil {
-		http.Error(w, "Error reading response", http.StatusInternalServerError)
-		return
-	}
-
-	var response struct {
-		ExecutionID string `json:"execution_id"`
-		State       string `json:"state"`
-	}
-	if err := json.Unmarshal(body, &response); err != nil {
-		http.Error(w, "Error parsing response", http.StatusInternalServerError)
-		return
-	}
-
-	// Check the status of the execution periodically
-	for {
-		statusURL := "https://api.dune.com/api/v1/execution/" + response.ExecutionID + "/results"
-		statusReq, err := http.NewRequest("GET", statusURL, nil)
-		if err != nil {
-			http.Error(w, "Error creating status request", http.StatusInternalServerError)
-			return
-		}
-		statusReq.Header.Add("X-Dune-API-Key", os.Getenv("DUNE_API_KEY"))
-
-		statusRes, err := http.DefaultClient.Do(statusReq)
-		if err != nil {
-			http.Error(w, "Error sending status request", http.StatusInternalServerError)
-			return
-		}
-
-		statusBody, err := ioutil.ReadAll(statusRes.Body)
-		statusRes.Body.Close()
-		if err != nil {
-			http.Error(w, "Error reading status response", http.StatusInternalServerError)
-			return
-		}
-
-		var statusResponse struct {
-			IsExecutionFinished bool `json:"is_execution_finished"`
-		}
-		json.Unmarshal(statusBody, &statusResponse)
-
-		fmt.Println("requestID current status: ", response.ExecutionID, statusResponse)
-
-		if statusResponse.IsExecutionFinished {
-			// Once finished, respond with the final results
-			json.NewEncoder(w).Encode(string(statusBody))
-			break
-		}
-		time.Sleep(30 * time.Second)
-	}
-}
-
-// GetSettings godoc
-// @Summary     Generic Resolve Name Service
-// @Description Resolve .ETH, .BNB, .ARB names
-// @Tags
-// @Accept   json
-// @Produce     json
-// @Security    BearerAuth
-// @Param    address path    string true "ENS/BNB/ARB/TEZ/NEAR/BTC Name"
-// @Success  200     {array} entity.Settings
-// @Router   /v1/resolve_name/{name} [get]
-func ResolveName(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	nameToResolve := vars["name"]
-	// Authuser := auth.GetUserFromReqContext(r)
-	// key := Authuser.Address
-	fmt.Println("ENS: ", nameToResolve)
-
-	if strings.HasSuffix(nameToResolve, ".eth") {
-		url := "https://deep-index.moralis.io/api/v2/resolve/ens/" + nameToResolve
-
-		req, _ := http.NewRequest("GET", url, nil)
-
-		req.Header.Add("Accept", "application/json")
-		req.Header.Add("X-API-Key", os.Getenv("MORALIS_NFT_API_KEY"))
-
-		res, _ := http.DefaultClient.Do(req)
-
-		defer res.Body.Close()
-		body, _ := ioutil.ReadAll(res.Body)
-
-		var parsed any
-		json.Unmarshal(body, &parsed)
-
-		json.NewEncoder(w).Encode(parsed)
-	} else if strings.HasSuffix(nameToResolve, ".btc") {
-		url := "https://stacks-node-api.mainnet.stacks.co/v1/names/" + nameToResolve
-
-		req, _ := http.NewRequest("GET", url, nil)
-		req.Header.Add("Accept", "application/json")
-		res, _ := http.DefaultClient.Do(req)
-
-		defer res.Body.Close()
-		body, _ := ioutil.ReadAll(res.Body)
-
-		var parsed any
-		json.Unmarshal(body, &parsed)
-
-		json.NewEncoder(w).Encode(parsed)
-	} else if strings.HasSuffix(nameToResolve, ".bnb") || strings.HasSuffix(nameToResolve, ".arb") {
-		//https://docs.space.id/developer-guide/web3-name-sdk/sid-api
-		chain := "bnb"
-		if strings.HasSuffix(nameToResolve, ".arb") {
-			chain = "arb1"
-		}
-		url := "https://api.prd.space.id/v1/getAddress?tld=" + chain + "&domain=" + nameToResolve
-
-		// Create a new request using http
-		req, _ := http.NewRequest("GET", url, nil)
-		req.Header.Add("Content-Type", "application/json")
-
-		// Send req using http Client
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Println("Error on response.\n[ERROR] -", err)
-		}
-		defer resp.Body.Close()
-
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Println("Error while reading the response bytes:", err)
-		}
-
-		var parsed any
-		json.Unmarshal(body, &parsed)
-
-		json.NewEncoder(w).Encode(parsed)
-	}
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-}
-
-// CreateComments godoc
-// @Summary     Comments are used within an NFT community chat
-// @Description Comments are meant to be public, someday having an up/downvote method for auto-moderation
-// @Tags        NFT
-// @Accept      json
-// @Produce     json
-// @Security    BearerAuth
-// @Param       message body    entity.Comments true "create struct"
-// @Success     200     {array} entity.Comments
-// @Router      /v1/create_comments [post]
-func CreateComments(w http.ResponseWriter, r *http.Request) {
-	requestBody, _ := ioutil.ReadAll(r.Body)
-	var comment entity.Comments
-	json.Unmarshal(requestBody, &comment)
-
-	Authuser := auth.GetUserFromReqContext(r)
-	if strings.EqualFold(Authuser.Address, comment.Fromaddr) {
-		database.Connector.Create(&comment)
-		w.Header().Set("Content-Type", "application/json")
-		w.Header().Set("X-Content-Type-Options", "nosniff")
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(comment)
-	} else {
-		w.WriteHeader(http.StatusForbidden)
-	}
-}
+}
 
 func DebugPrint(w http.ResponseWriter, r *http.Request) {
 	requestBody, _ := ioutil.ReadAll(r.Body)
@@ -3539,10 +3364,11 @@ func GetOpenseaCollectionSlug(contractAddr string) string {
 	req.Header.Add("X-API-KEY", osKey)
 
 	// Send req using http Client
-	client := &http.Client{}
+	client := onchain.HttpClient
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println("OSea API CALL - Error on response.\n[ERROR] -", err)
+		return ""
 	}
 	defer resp.Body.Close()
 
@@ -3579,10 +3405,12 @@ func GetOpenseaCollectionStats(w http.ResponseWriter, r *http.Request) {
 	req.Header.Add("X-API-KEY", osKey)
 
 	// Send req using http Client
-	client := &http.Client{}
+	client := onchain.HttpClient
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println("OSea API CALL - Error on response.\n[ERROR] -", err)
+		http.Error(w, "upstream request failed", http.StatusBadGateway)
+		return
 	}
 	defer resp.Body.Close()
 
@@ -3609,10 +3437,12 @@ func GetOpenseaAssetContract(w http.ResponseWriter, r *http.Request) {
 	req.Header.Add("X-API-KEY", osKey)
 
 	// Send req using http Client
-	client := &http.Client{}
+	client := onchain.HttpClient
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println("OSea API CALL - Error on response.\n[ERROR] -", err)
+		http.Error(w, "upstream request failed", http.StatusBadGateway)
+		return
 	}
 	defer resp.Body.Close()
 
@@ -3643,10 +3473,12 @@ func GetOpenseaAsset(w http.ResponseWriter, r *http.Request) {
 	req.Header.Add("X-API-KEY", osKey)
 
 	// Send req using http Client
-	client := &http.Client{}
+	client := onchain.HttpClient
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println("OSea API CALL asset - Error on response.\n[ERROR] -", err)
+		http.Error(w, "upstream request failed", http.StatusBadGateway)
+		return
 	}
 	defer resp.Body.Close()
 
@@ -3674,10 +3506,12 @@ func GetOpenseaAssetOwner(w http.ResponseWriter, r *http.Request) {
 	req.Header.Add("X-API-KEY", osKey)
 
 	// Send req using http Client
-	client := &http.Client{}
+	client := onchain.HttpClient
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println("OSea API CALL asset - Error on response.\n[ERROR] -", err)
+		http.Error(w, "upstream request failed", http.StatusBadGateway)
+		return
 	}
 	defer resp.Body.Close()
 
@@ -3706,10 +3540,12 @@ func GetOpenseaAssetOwnerENS(w http.ResponseWriter, r *http.Request) {
 	req.Header.Add("X-API-KEY", osKey)
 
 	// Send req using http Client
-	client := &http.Client{}
+	client := onchain.HttpClient
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println("OSea API CALL asset - Error on response.\n[ERROR] -", err)
+		http.Error(w, "upstream request failed", http.StatusBadGateway)
+		return
 	}
 	defer resp.Body.Close()
 
@@ -3762,10 +3598,11 @@ func GetTwitterHandle(slug string) string {
 	req.Header.Add("X-API-KEY", osKey)
 
 	// Send req using http Client
-	client := &http.Client{}
+	client := onchain.HttpClient
 	resp, err := client.Do(req)
 	if err != nil {
 		log.Println("Error on response.\n[ERROR] -", err)
+		return ""
 	}
 	defer resp.Body.Close()
 
@@ -3925,43 +3762,33 @@ func FormatTwitterData(data TwitterTweetsData) []TweetType {
 // @Accept      json
 // @Produce     json
 // @Security    BearerAuth
-// @Param       address path    string true "Wallet Address"
-// @Param       address path    string true "Wallet Address"
+// @Description Open communities auto-join the caller unless they left or were banned, private ones only return messages to members
+// @Description The member list is paginated separately: GET /v1/community/{community}/members
+// @Param       community path    string true "community slug"
+// @Param       address   path    string true "Wallet Address (must match the JWT)"
+// @Param       channel   query   string false "channel name, general when not set"
 // @Success     200       {array} LandingPageItems
 // @Router      /v1/community/{community}/{address} [get]
 func GetCommunityChat(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	community := vars["community"]
-	key := vars["address"]
+	Authuser := auth.GetUserFromReqContext(r)
+	if !strings.EqualFold(vars["address"], Authuser.Address) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	//old slug of a renamed community, send the client to the current one
+	if current := ResolveCommunitySlug(community); current != community {
+		http.Redirect(w, r, strings.Replace(r.URL.Path, "/community/"+community+"/", "/community/"+current+"/", 1), http.StatusPermanentRedirect)
+		return
+	}
+	key := strings.ToLower(Authuser.Address)
 	var landingData LandingPageItems
 
-	//for now, the walletchat living room is all users by default
-	var memberCount []entity.Bookmarkitem
-	database.Connector.Where("nftaddr = ?", community).Find(&memberCount)
-	landingData.MemberCount = len(memberCount)
-
-	//need to re-architect this - will be very slow
-	// for i := 0; i < landingData.MemberCount; i++ {
-	// 	var localMember CommunityMember
-
-	// 	localMember.Address = memberCount[i].Walletaddr
-	// 	var localAddrName entity.Addrnameitem
-	// 	database.Connector.Where("address = ?", localMember.Address).Find(&localAddrName)
-	// 	localMember.Name = localAddrName.Name
-
-	// 	var localImage entity.Imageitem
-	// 	database.Connector.Where("addr = ?", localMember.Address).Find(&localImage)
-	// 	localMember.Image = localImage.Base64data
-
-	// 	localMember.Admin = false
-	// 	var isAdmin entity.Communityadmin
-	// 	database.Connector.Where("slug = ?", community).Find(&isAdmin)
-	// 	if strings.EqualFold(localMember.Address, isAdmin.Adminaddr) {
-	// 		localMember.Admin = true
-	// 	}
-
-	// 	landingData.Members = append(landingData.Members, localMember)
-	// }
+	database.Connector.Model(&entity.Communitymember{}).
+		Where("slug = ?", community).
+		Where("status = ?", entity.MemberStatusActive).
+		Count(&landingData.MemberCount)
 
 	//name (this might be better moved to a different table someday)
 	var addrname entity.Addrnameitem
@@ -3973,59 +3800,59 @@ func GetCommunityChat(w http.ResponseWriter, r *http.Request) {
 	database.Connector.Where("addr = ?", community).Find(&imgaddr)
 	landingData.Logo = imgaddr.Base64data
 
-	//WalletChat is verified of course
-	landingData.Verified = true
+	//verified badge is given out by WalletChat (PUT /v1/directory/{slug}/verified)
+	landingData.Verified = IsCommunityVerified(community)
 
-	//auto-join new users to WalletChat community (they can leave later) - might need to break this out
-	var bookmarks []entity.Bookmarkitem
-	var dbQuery = database.Connector.Where("nftaddr = ?", community).Where("walletaddr = ?", key).Find(&bookmarks)
-	if dbQuery.RowsAffected == 0 {
-		var bookmark entity.Bookmarkitem
-		bookmark.Nftaddr = community
-		bookmark.Walletaddr = key
-		bookmark.Chain = "none"
+	//auto-join new users to open communities like WalletChat HQ (they can leave later, and won't be re-joined)
+	landingData.JoinMode = GetCommunityJoinMode(community)
+	member, found := getCommunityMember(community, key)
+	landingData.Gated = IsCommunityGated(community)
+	accessAllowed := communityAccessAllowed(community, key)
+	if !found && accessAllowed && landingData.JoinMode == entity.JoinModeOpen && !hasUnjoined(community, key) && communityExists(community) {
+		member = activateCommunityMember(community, key, "", "", key)
+		landingData.MemberCount++
+	}
+	landingData.Joined = member.Status == entity.MemberStatusActive
+	landingData.Pending = member.Status == entity.MemberStatusPending
+	if landingData.Joined {
+		landingData.Role = member.Role
+	}
 
-		database.Connector.Create(&bookmark)
-
-		//by default everyone is joined to Walletchat
-		landingData.Joined = true
-		//create the welcome message, save it
-		var newgroupchatuser entity.Groupchatitem
-		newgroupchatuser.Type = entity.Welcome
-		newgroupchatuser.Contexttype = entity.Community
-		newgroupchatuser.Fromaddr = key
-		newgroupchatuser.Nftaddr = community
-		newgroupchatuser.Message = "Welcome " + key + " to " + landingData.Name + "!"
-		newgroupchatuser.Timestamp_dtm = time.Now()
-		newgroupchatuser.Timestamp = time.Now().Format("2006-01-02T15:04:05.000Z")
-
-		//add it to the database
-		database.Connector.Create(&newgroupchatuser)
-	} else {
-		//We don't have a way for users to get back to WC HQ if they leave (shouldn't need to use above block to re-welcome them)
-		landingData.Joined = true
+	//social links are public, messages of private communities are for members only
+	var socialMediaMatches []entity.Communitysocial
+	database.Connector.Where("community = ?", community).Find(&socialMediaMatches)
+	if banActive(member) || !accessAllowed || (landingData.JoinMode != entity.JoinModeOpen && !landingData.Joined) {
+		for i := 0; i < len(socialMediaMatches); i++ {
+			landingData.Social = append(landingData.Social, SocialMsg{Type: socialMediaMatches[i].Type, Username: socialMediaMatches[i].Name})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		json.NewEncoder(w).Encode(landingData)
+		return
+	}
+
+	//?channel=name shows one channel, general when not set
+	channel := channelKey(r.URL.Query().Get("channel"))
+	if _, found := getCommunityChannel(community, channel); !found {
+		writeChannelError(w, errChannelNotFound)
+		return
+	}
+	if !channelAccessAllowed(community, channel, key) {
+		writeChannelError(w, errChannelAccessDenied)
+		return
+	}
+	landingData.Channel = channel
+	if landingData.Channel == "" {
+		landingData.Channel = entity.DefaultChannel
 	}
 
 	//check messages read for this user address because this GetCommunityChat is being called
 	//separately each time (I thought it would be filled from bookmarks)
 	var groupchat []entity.Groupchatitem
 	database.Connector.Where("nftaddr = ?", community).Where("fromaddr = ?", key).Find(&groupchat)
-	//redoing some things already done in getGroupChatItemsByAddr
-	var chatReadTime entity.Groupchatreadtime
-	dbQuery = database.Connector.Where("fromaddr = ?", key).Where("nftaddr = ?", community).Find(&chatReadTime)
-	if dbQuery.RowsAffected == 0 {
-		//add the first read element to the group timestamp table cross reference
-		chatReadTime.Fromaddr = key
-		chatReadTime.Nftaddr = community
-		chatReadTime.Readtimestamp_dtm = time.Now()
-
-		database.Connector.Create(&chatReadTime)
-	} else {
-		//database.Connector.Where("timestamp > ?", chatReadTime.Readtimestamp_dtm).Where("nftaddr = ?", nftaddr).Find(&chat) //mana requests all data for now
-		//set timestamp when this was last grabbed
-		currtime := time.Now()
-		database.Connector.Model(&entity.Groupchatreadtime{}).Where("fromaddr = ?", key).Where("nftaddr = ?", community).Update("readtimestamp_dtm", currtime)
-	}
+	//redoing some things already done in getGroupChatItemsByAddr, read state is per channel
+	markChannelRead(community, channel, key)
+	landingData.Channels = channelInfos(community, key)
 
 	var hasMessaged bool
 	if len(groupchat) > 0 {
@@ -4036,12 +3863,16 @@ func GetCommunityChat(w http.ResponseWriter, r *http.Request) {
 	landingData.Messaged = hasMessaged
 
 	//grab all the data for walletchat group
-	database.Connector.Where("nftaddr = ?", community).Order("id desc").Limit(100).Find(&groupchat)
+	database.Connector.Where("nftaddr = ?", community).Where("channel = ?", channel).Order("id desc").Limit(100).Find(&groupchat)
 	landingData.Messages = groupchat
+	landingData.Pinned = []entity.Groupchatitem{}
+	for _, pinned := range getPinnedMessages(community) {
+		if pinned.Channel == channel {
+			landingData.Pinned = append(landingData.Pinned, pinned)
+		}
+	}
 
 	//get social media info
-	var socialMediaMatches []entity.Communitysocial
-	database.Connector.Where("community = ?", community).Find(&socialMediaMatches)
 	for i := 0; i < len(socialMediaMatches); i++ {
 		if socialMediaMatches[i].Type == "twitter" {
 			//fmt.Println("adding Twitter social: ", socialmedia.Name)
@@ -4073,6 +3904,7 @@ func GetCommunityChat(w http.ResponseWriter, r *http.Request) {
 // @Param       community path    string true "community slug"
 // @Param       time      path    string true "Timestamp of last message in current community chat"
 // @Param       count     path    string true "Number of Messages To Get (1-1000)"
+// @Param       channel   query   string false "channel name, general when not set"
 // @Success     200     {array} LandingPageItems
 // @Router      /v1/community/{community}/{time}/{count} [get]
 func GetCommunityChatAfterTime(w http.ResponseWriter, r *http.Request) {
@@ -4085,8 +3917,15 @@ func GetCommunityChatAfterTime(w http.ResponseWriter, r *http.Request) {
 		count = 100
 	}
 
+	Authuser := auth.GetUserFromReqContext(r)
+	channel := channelKey(r.URL.Query().Get("channel"))
+	if !canReadChannel(community, channel, Authuser.Address) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	var messages []entity.Groupchatitem
-	database.Connector.Where("nftaddr = ?", community).Order("id desc").Where("timestamp_dtm < ?", time).Limit(count).Find(&messages)
+	database.Connector.Where("nftaddr = ?", community).Where("channel = ?", channel).Order("id desc").Where("timestamp_dtm < ?", time).Limit(count).Find(&messages)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -4102,6 +3941,7 @@ func GetCommunityChatAfterTime(w http.ResponseWriter, r *http.Request) {
 // @Security    BearerAuth
 // @Param       community path    string true "community slug"
 // @Param       pagenum   path    string true "page number to get (1-N)"
+// @Param       channel   query   string false "channel name, general when not set"
 // @Success     200     {array}   LandingPageItems
 // @Router      /v1/community_pagenum/{community}/{pagenum} [get]
 func GetCommunityChatPage(w http.ResponseWriter, r *http.Request) {
@@ -4113,12 +3953,19 @@ func GetCommunityChatPage(w http.ResponseWriter, r *http.Request) {
 		pageNumber = 1
 	}
 
+	Authuser := auth.GetUserFromReqContext(r)
+	channel := channelKey(r.URL.Query().Get("channel"))
+	if !canReadChannel(community, channel, Authuser.Address) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	itemsPerPage := 100
 	// Calculate the offset based on the page number
 	offset := (pageNumber - 1) * itemsPerPage
 
 	var messages []entity.Groupchatitem
-	database.Connector.Where("nftaddr = ?", community).Order("id desc").Limit(itemsPerPage).Offset(offset).Find(&messages)
+	database.Connector.Where("nftaddr = ?", community).Where("channel = ?", channel).Order("id desc").Limit(itemsPerPage).Offset(offset).Find(&messages)
 
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("X-Content-Type-Options", "nosniff")
@@ -4134,6 +3981,7 @@ func GetCommunityChatPage(w http.ResponseWriter, r *http.Request) {
 // @Security BearerAuth
 // @Param       contract path    string true "NFT Contract Address"
 // @Param       wallet   path    string true "Wallet Address"
+// @Param       chain    query   string false "chain of the contract, every enabled chain is tried when empty"
 // @Success     200      {array} LandingPageItems
 // @Router      /v1/is_owner/{contract}/{wallet} [get]
 func IsOwner(w http.ResponseWriter, r *http.Request) {
@@ -4141,14 +3989,16 @@ func IsOwner(w http.ResponseWriter, r *http.Request) {
 	contract := vars["contract"]
 	wallet := vars["wallet"]
 
+	chain, ok := chainParam(w, r)
+	if !ok {
+		return
+	}
+
 	result := false
 	if strings.HasPrefix(wallet, "tz") {
-		result = IsOwnerOfNFT(contract, wallet, "tezos")
+		result = IsOwnerOfNFT(contract, wallet, onchain.ChainTezos)
 	} else {
-		result = IsOwnerOfNFT(contract, wallet, "ethereum")
-		if !result {
-			result = IsOwnerOfNFT(contract, wallet, "polygon")
-		}
+		result = isOwnerOnChains(contract, wallet, holderChains(chain))
 	}
 
 	w.Header().Set("Content-Type", "application/json")
@@ -4157,39 +4007,12 @@ func IsOwner(w http.ResponseWriter, r *http.Request) {
 }
 
 // internal
-func GetOwnerNFTs(walletAddr string, chain string) MoralisOwnerOf {
-
-	//url := "https://eth-mainnet.alchemyapi.io/v2/${process.env.REACT_APP_ALCHEMY_API_KEY}/getOwnersForToken" + contractAddr
-	//url := "https://api.nftport.xyz/v0/accounts/" + walletAddr + "?chain=" + chain
-	url := "https://deep-index.moralis.io/api/v2.2/" + walletAddr + "/nft?chain=" + chain + "&format=decimal&normalizeMetadata=false"
-
-	req, _ := http.NewRequest("GET", url, nil)
-
-	req.Header.Add("Content-Type", "application/json")
-	//req.Header.Add("Authorization", os.Getenv("NFTPORT_API_KEY"))
-	req.Header.Add("X-API-Key", os.Getenv("MORALIS_NFT_API_KEY"))
-
-	// Send req using http Client
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error on response.\n[ERROR] -", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
+func GetOwnerNFTs(walletAddr string, chain string) []onchain.Nft {
+	nfts, err := onchain.OwnedNfts(chain, walletAddr, nil)
 	if err != nil {
-		log.Println("Error while reading the response bytes:", err)
-	}
-
-	var result MoralisOwnerOf
-	if err := json.Unmarshal(body, &result); err != nil { // Parse []byte to the go struct pointer
-		fmt.Println("Can not unmarshal JSON - GetOwnerNFTs", body)
+		fmt.Println("Error getting NFTs - GetOwnerNFTs: ", walletAddr, chain, err)
 	}
-
-	//fmt.Printf("IsOwner: %#v\n", result.Total)
-
-	return result
+	return nfts
 }
 
 //internal use (NFTPORT version)
@@ -4225,227 +4048,51 @@ func GetOwnerNFTs(walletAddr string, chain string) MoralisOwnerOf {
 // 	return result.Total > 0
 // }
 
+// IsOwnerOfNFT - cached for a few minutes, see ownershipcache.go
 func IsOwnerOfNFT(contractAddr string, walletAddr string, chain string) bool {
-
-	result := IsOwnerOfNftLocal(contractAddr, walletAddr, chain)
-	//fmt.Println("IsOwnerOfNFT params / holder: ", contractAddr, walletAddr, chain, result)
-
-	if !result && (chain == "ethereum" || chain == "polygon") {
-		delegates := auth.GetDelegationsByDelegate(walletAddr)
-		//fmt.Println("Wallet Delegates in OwnerOfNFT: ", delegates)
-		for _, delegateWallet := range delegates {
-			result = IsOwnerOfNftLocal(contractAddr, delegateWallet.Vault.Hex(), chain)
-			if result {
-				break
-			} //if we find an NFT, can stop here
-		}
-	}
-
-	return result
+	owner, _ := cachedNftOwnership(contractAddr, walletAddr, chain, newWalletVaults(walletAddr))
+	return owner
 }
 
 // internal - called from wrapper which checks DelegateCash as well
 func IsOwnerOfNftLocal(contractAddr string, walletAddr string, chain string) bool {
-	//For now if we use Moralis, ethereum needs to be "eth"
-	if chain == "ethereum" {
-		chain = "eth"
-	}
-
-	if chain == "near" {
-		//fmt.Println("Near Chain ", walletAddr, contractAddr)
-		url := "https://near-mainnet.api.pagoda.co/eapi/v1/accounts/" + walletAddr + "/NFT/" + contractAddr
-
-		req, _ := http.NewRequest("GET", url, nil)
-
-		req.Header.Add("accept", "application/json")
-		req.Header.Add("X-API-Key", os.Getenv("PAGODA_NFT_API_KEY"))
-
-		// Send req using http Client
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Println("Error on response.\n[ERROR] -", err)
-		}
-		defer resp.Body.Close()
-
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Println("Error while reading the response bytes:", err)
-		}
-
-		var result NearOwnerOf
-		if err := json.Unmarshal(body, &result); err != nil { // Parse []byte to the go struct pointer
-			fmt.Println("Can not unmarshal JSON - Near IsOwnerOfNFT", body)
-		}
-		//fmt.Printf("IsOwner: %#v\n", result.Nfts)
-
-		return len(result.Nfts) > 0
-	}
-	if chain == "tezos" {
-		//fmt.Println("Tezos Chain ", walletAddr, contractAddr)
-		url := "https://api.tzkt.io/v1/tokens/balances?" + walletAddr + "&token.contract=" + contractAddr
-
-		req, _ := http.NewRequest("GET", url, nil)
-
-		req.Header.Add("accept", "application/json")
-
-		// Send req using http Client
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Println("Error on response.\n[ERROR] -", err)
-		}
-		defer resp.Body.Close()
-
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Println("Error while reading the response bytes:", err)
-		}
-
-		var result []TezosOwnerOf
-		if err := json.Unmarshal(body, &result); err != nil { // Parse []byte to the go struct pointer
-			fmt.Println("Can not unmarshal JSON - Tezos IsOwnerOfNFT", body)
-		}
-		//fmt.Printf("IsOwner: %#v\n", result.Total)
-
-		return len(result) > 0
-	} else {
-		url := "https://deep-index.moralis.io/api/v2.2/" + walletAddr + "/nft?chain=" + chain + "&format=decimal&token_addresses=" + contractAddr + "&normalizeMetadata=false"
-		//url := "https://deep-index.moralis.io/api/v2.2/0x57ca1B13510D82a6286a225a217798e079BD0767/nft?chain=eth&format=decimal&token_addresses=0x34d85c9cdeb23fa97cb08333b511ac86e1c4e258&normalizeMetadata=false"
-
-		req, _ := http.NewRequest("GET", url, nil)
-
-		req.Header.Add("accept", "application/json")
-		//req.Header.Add("Authorization", os.Getenv("NFTPORT_API_KEY"))
-		req.Header.Add("X-API-Key", os.Getenv("MORALIS_NFT_API_KEY"))
-
-		// Send req using http Client
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			fmt.Println("Error on response.\n[ERROR] -", err)
-		}
-		defer resp.Body.Close()
-
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			fmt.Println("Error while reading the response bytes:", err)
-		}
-
-		var result MoralisOwnerOf
-		if err := json.Unmarshal(body, &result); err != nil { // Parse []byte to the go struct pointer
-			fmt.Println("Can not unmarshal JSON - IsOwnerOfNFT", body)
-		}
-		//fmt.Println("Moralis Returned Data - IsOwnerOfNFT", result)
-
-		return len(result.Result) > 0
-	}
-}
-
-func IsOwnerOfPOAP(eventId string, walletAddr string) bool {
-	url := "https://api.poap.tech/actions/scan/" + walletAddr + "/" + eventId
-
-	req, _ := http.NewRequest("GET", url, nil)
-
-	req.Header.Add("accept", "application/json")
-	req.Header.Add("X-API-Key", os.Getenv("POAP_API_KEY"))
-
-	// Send req using http Client
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	owner, err := isOwnerOfNftLocal(contractAddr, walletAddr, chain)
 	if err != nil {
-		log.Println("Error on response.\n[ERROR] -", err)
+		fmt.Println("Error checking NFT owner - IsOwnerOfNFT: ", contractAddr, walletAddr, chain, err)
 	}
-
-	return resp.StatusCode != 404
+	return owner
 }
 
 func IsOnChain(contractAddr string, chain string) bool {
-	//For now if we use Moralis, ethereum needs to be "eth"
-	if chain == "ethereum" {
-		chain = "eth"
-	}
-
-	//url := "https://api.nftport.xyz/v0/nfts/" + contractAddr + "?chain=" + chain
-	url := "https://deep-index.moralis.io/api/v2.2/nft/" + contractAddr + "?chain=" + chain + "&format=decimal&normalizeMetadata=false"
-
-	req, _ := http.NewRequest("GET", url, nil)
-
-	req.Header.Add("Content-Type", "application/json")
-	//req.Header.Add("Authorization", os.Getenv("NFTPORT_API_KEY"))
-	req.Header.Add("X-API-Key", os.Getenv("MORALIS_NFT_API_KEY"))
-
-	// Send req using http Client
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error on response.\n[ERROR] -", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Error while reading the response bytes:", err)
-	}
-
-	var result MoralisContractInfoNFT
-	if err := json.Unmarshal(body, &result); err != nil { // Parse []byte to the go struct pointer
-		fmt.Println("Can not unmarshal JSON - IsOnChain", body)
-	}
-
-	//fmt.Printf("Chain Response: %#v\n", result.Response)
-
-	var returnVal = false
-	if len(result.Result) > 0 {
-		returnVal = true
-	}
-	return returnVal
-}
-
-// this was just used to fix up users info after adding new column
-// not intended for extenal calls
-func FixUpBookmarks(w http.ResponseWriter, r *http.Request) {
-	var bookmarks []entity.Bookmarkitem
-	database.Connector.Find(&bookmarks)
-
-	for _, bookmark := range bookmarks {
-		if strings.HasPrefix(bookmark.Nftaddr, "0x") {
-			var result = IsOnChain(bookmark.Nftaddr, "ethereum")
-			if result {
-				database.Connector.Model(&entity.Bookmarkitem{}).Where("walletaddr = ?", bookmark.Walletaddr).Where("nftaddr = ?", bookmark.Nftaddr).Update("chain", "ethereum")
-			} else {
-				var result = IsOnChain(bookmark.Nftaddr, "polygon")
-				if result {
-					database.Connector.Model(&entity.Bookmarkitem{}).Where("walletaddr = ?", bookmark.Walletaddr).Where("nftaddr = ?", bookmark.Nftaddr).Update("chain", "polygon")
-				}
-			}
-		}
+	_, err := onchain.GetCollection(chain, contractAddr)
+	if err != nil && !errors.Is(err, onchain.ErrNotFound) {
+		fmt.Println("Error getting collection - IsOnChain: ", contractAddr, chain, err)
 	}
-}
-
-func AutoJoinCommunities(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	walletAddr := vars["wallet"]
-	AutoJoinCommunitiesByChainWithDelegates(walletAddr, "ethereum")
-	//AutoJoinCommunitiesByChainWithDelegates(walletAddr, "polygon")
-	AutoJoinPoapChats(walletAddr)
+	return err == nil
 }
 
 // internal use only
 func AutoJoinCommunitiesByChainWithDelegates(walletAddr string, chain string) {
-	AutoJoinCommunitiesByChain(walletAddr, "", chain, walletAddr)
+	autoJoinCommunitiesOnChains(walletAddr, []string{chain})
+}
 
+// internal use only - delegations are looked up once for all the chains
+func autoJoinCommunitiesOnChains(walletAddr string, chains []string) {
 	//Check DelegateCash for NFTs owned
 	delegates := auth.GetDelegationsByDelegate(walletAddr)
-	for _, delegateWallet := range delegates {
-		fmt.Println("Wallet Delegate Found: ", delegateWallet)
-		//DelegateCash type 1 is a full wallet delegation
-		//if so, lets allow delegate to to be part of all NFTs in Vault/Cold wallet
-		if delegateWallet.Type == 1 {
-			fmt.Println("Wallet Full Delegate: ", delegateWallet.Vault.Hex())
-			AutoJoinCommunitiesByChain(delegateWallet.Vault.Hex(), "", chain, walletAddr)
-		} else {
-			AutoJoinCommunitiesByChain(delegateWallet.Vault.Hex(), delegateWallet.Contract.Hex(), chain, walletAddr)
+	for _, chain := range chains {
+		AutoJoinCommunitiesByChain(walletAddr, "", chain, walletAddr)
+
+		for _, delegateWallet := range delegates {
+			fmt.Println("Wallet Delegate Found: ", delegateWallet)
+			//DelegateCash type 1 is a full wallet delegation
+			//if so, lets allow delegate to to be part of all NFTs in Vault/Cold wallet
+			if delegateWallet.Type == 1 {
+				fmt.Println("Wallet Full Delegate: ", delegateWallet.Vault.Hex())
+				AutoJoinCommunitiesByChain(delegateWallet.Vault.Hex(), "", chain, walletAddr)
+			} else {
+				AutoJoinCommunitiesByChain(delegateWallet.Vault.Hex(), delegateWallet.Contract.Hex(), chain, walletAddr)
+			}
 		}
 	}
 }
@@ -4453,67 +4100,38 @@ func AutoJoinCommunitiesByChainWithDelegates(walletAddr string, chain string) {
 // internal use only
 // database.Connector.Where("walletaddr = ?", delegateAddr).Where("chain = ?", chain).Delete(&entity.Bookmarkitem{})
 func AutoJoinCommunitiesByChain(walletAddr string, nftAddr string, chain string, delegateAddr string) {
-	//For now if we use Moralis, ethereum needs to be "eth"
-	if chain == "ethereum" {
-		chain = "eth"
-	}
-
-	url := "https://deep-index.moralis.io/api/v2.2/" + walletAddr + "/nft?chain=" + chain + "&format=decimal&normalizeMetadata=false"
+	var contracts []string
 	if nftAddr != "" {
 		fmt.Println("Auto join by Contract: ", nftAddr)
-		url = "https://deep-index.moralis.io/api/v2.2/" + walletAddr + "/nft?chain=" + chain + "&format=decimal&normalizeMetadata=false&token_addresses%5B0%5D=" + nftAddr
+		contracts = []string{nftAddr}
 	}
 
-	req, _ := http.NewRequest("GET", url, nil)
-
-	req.Header.Add("Content-Type", "application/json")
-	//req.Header.Add("Authorization", os.Getenv("NFTPORT_API_KEY"))
-	req.Header.Add("X-API-Key", os.Getenv("MORALIS_NFT_API_KEY"))
-
-	// Send req using http Client
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	nfts, err := onchain.OwnedNfts(chain, walletAddr, contracts)
 	if err != nil {
-		log.Println("Error on response.\n[ERROR] -", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		log.Println("Error while reading the response bytes:", err)
-	}
-
-	var result MoralisOwnerOf
-	if err := json.Unmarshal(body, &result); err != nil { // Parse []byte to the go struct pointer
-		fmt.Println("Can not unmarshal JSON - AutoJoinCommunitiesByChain", body)
+		fmt.Println("Error getting NFTs - AutoJoinCommunitiesByChain: ", walletAddr, chain, err)
+		return
 	}
 
-	//fmt.Printf("IsOwner: %#v\n", result.Total)
-	for _, nft := range result.Result {
+	for _, nft := range nfts {
 		//TODO: could be optimized, good enough for now
 		var bookmarkExists entity.Bookmarkitem
-		var dbResult = database.Connector.Where("nftaddr = ?", nft.TokenAddress).Where("walletaddr = ?", delegateAddr).Find(&bookmarkExists)
+		var dbResult = database.Connector.Where("nftaddr = ?", nft.Contract).Where("walletaddr = ?", delegateAddr).Find(&bookmarkExists)
 
 		if dbResult.RowsAffected == 0 {
 			//check if the user already manually unjoined, if so don't auto rejoin them
 			var userUnjoined entity.Userunjoined
-			var dbUnjoined = database.Connector.Where("nftaddr = ?", nft.TokenAddress).Where("walletaddr = ?", delegateAddr).Find(&userUnjoined)
+			var dbUnjoined = database.Connector.Where("nftaddr = ?", nft.Contract).Where("walletaddr = ?", delegateAddr).Find(&userUnjoined)
 			userAlreadyUnjoined := false
 			if dbUnjoined.RowsAffected > 0 {
 				userAlreadyUnjoined = userUnjoined.Unjoined
 			}
 
 			if !userAlreadyUnjoined {
-				fmt.Println("Found new NFT: " + nft.TokenAddress)
+				fmt.Println("Found new NFT: " + nft.Contract)
 				var bookmark entity.Bookmarkitem
 
-				bookmark.Nftaddr = nft.TokenAddress
+				bookmark.Nftaddr = nft.Contract
 				bookmark.Walletaddr = delegateAddr //for normal cases delegate=walletAddr
-
-				//For now if we use Moralis, ethereum needs to be "eth" (NFTPort on client side and LIT uses "ethereum")
-				if chain == "eth" {
-					chain = "ethereum"
-				}
 				bookmark.Chain = chain
 
 				database.Connector.Create(&bookmark)
@@ -4522,88 +4140,6 @@ func AutoJoinCommunitiesByChain(walletAddr string, nftAddr string, chain string,
 	}
 }
 
-// internal use only
-func AutoJoinPoapChats(walletAddr string) {
-	//https://documentation.poap.tech/reference/getactionsscan-5
-	var poapInfo []POAPInfoByAddress = getPoapInfoByAddress(walletAddr)
-	//fmt.Printf("AutoJoinPoapChats: %#v\n", poapInfo)
-	for _, poap := range poapInfo {
-		var bookmarkExists entity.Bookmarkitem
-
-		var poapAddr = "poap_" + strconv.Itoa(poap.Event.ID)
-		//fmt.Printf("POAP Event: %#v\n", poapAddr)
-		var dbResult = database.Connector.Where("nftaddr = ?", poapAddr).Where("walletaddr = ?", walletAddr).Find(&bookmarkExists)
-
-		if dbResult.RowsAffected == 0 {
-			//check if the user already manually unjoined, if so don't auto rejoin them
-			var userUnjoined entity.Userunjoined
-			var dbUnjoined = database.Connector.Where("nftaddr = ?", poapAddr).Where("walletaddr = ?", walletAddr).Find(&userUnjoined)
-			userAlreadyUnjoined := false
-			if dbUnjoined.RowsAffected > 0 {
-				userAlreadyUnjoined = userUnjoined.Unjoined
-			}
-
-			if !userAlreadyUnjoined {
-				fmt.Printf("POAP is new for user: %#v\n", walletAddr)
-				var bookmark entity.Bookmarkitem
-
-				bookmark.Nftaddr = poapAddr
-				bookmark.Walletaddr = walletAddr
-				bookmark.Chain = poap.Chain
-
-				database.Connector.Create(&bookmark)
-			}
-		}
-	}
-}
-
-func GetPoapsByAddr(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	walletAddr := vars["wallet"]
-
-	result := getPoapInfoByAddress(walletAddr)
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("X-Content-Type-Options", "nosniff")
-	json.NewEncoder(w).Encode(result)
-}
-
-// internal use only
-func getPoapInfoByAddress(walletAddr string) []POAPInfoByAddress {
-	var result []POAPInfoByAddress
-	url := "https://api.poap.tech/actions/scan/" + walletAddr
-
-	if strings.HasPrefix(walletAddr, "tz") {
-		return result
-	}
-
-	// Create a new request using http
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Add("X-API-KEY", os.Getenv("POAP_API_KEY"))
-
-	// Send req using http Client
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Println("Error on response.\n[ERROR] -", err)
-	} else {
-		defer resp.Body.Close()
-
-		body, err := ioutil.ReadAll(resp.Body)
-		if err != nil {
-			log.Println("Error while reading the response bytes:", err)
-		}
-
-		if err := json.Unmarshal(body, &result); err != nil { // Parse []byte to the go struct pointer
-			fmt.Println("Can not unmarshal JSON - getPoapInfoByAddress", resp.Body, walletAddr)
-		}
-	}
-
-	//fmt.Printf("returning: %#v\n", result)
-
-	return result
-}
-
 type WalletGuardStruct struct {
 	DomainName        string `json:"domainName"`
 	RecommendedAction string `json:"recommendedAction"`
@@ -4642,43 +4178,19 @@ func WalletGuardCheck(w http.ResponseWriter, r *http.Request) {
 	recommendedActions := make([]string, len(urls))
 
 	for i, url := range urls {
-		// Create the URL for the WalletGuard API
-		apiUrl := "https://api.walletguard.app/v1/scan?url=" + url
-
-		// Create the HTTP request
-		req, err := http.NewRequest("GET", apiUrl, nil)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
-		req.Header.Add("accept", "application/json")
-		req.Header.Add("X-API-KEY", os.Getenv("WALLET_GUARD_API_KEY"))
-
-		// Send the HTTP request
-		res, err := http.DefaultClient.Do(req)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		// WalletGuard lookups are cached and shared with the group chat phishing filter
+		recommendedAction, err := walletGuardAction(r.Context(), url)
+		if err == errWalletGuardDisabled {
+			//no API key configured, WalletGuard has no recommendation
+			recommendedAction, err = "", nil
 		}
-		defer res.Body.Close()
-
-		// Read and parse the response body
-		responseBody, err := ioutil.ReadAll(res.Body)
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		// Unmarshal the JSON response to get the RecommendedAction
-		var walletGuardResponse WalletGuardResponse
-		if err := json.Unmarshal(responseBody, &walletGuardResponse); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-
 		// Store the RecommendedAction in the slice
-		recommendedActions[i] = walletGuardResponse.RecommendedAction
+		recommendedActions[i] = recommendedAction
 	}
 
 	// Now, recommendedActions contains the RecommendedAction for each URL
@@ -4795,7 +4307,11 @@ rnvdxUhpAAEtJZme5+pnS6Fr4Zi8mUBPt9kC/mHTtbPQoLsX+FeBs/u+rpXe4xBr
 		addrNameItem.Name = newUserTemp.Nickname
 		var dbQuery = database.Connector.Where("address = ?", newUserTemp.Wallet).Find(&addrnameDB)
 		if dbQuery.RowsAffected == 0 {
-			database.Connector.Create(&addrNameItem)
+			if err := applyNamePolicy(&addrNameItem, false); err != nil {
+				fmt.Println("nickname not used: ", newUserTemp.Nickname, err) //registration goes on without a name
+			} else {
+				database.Connector.Create(&addrNameItem)
+			}
 		}
 
 		//this isn't great long-term, but for smaller table it allows new usernames to show up in table instead of waiting for once daily update
@@ -5159,28 +4675,6 @@ func FetchOuraData() {
 	}
 }
 
-type POAPInfoByAddress struct {
-	Event struct {
-		ID          int    `json:"id"`
-		FancyID     string `json:"fancy_id"`
-		Name        string `json:"name"`
-		EventURL    string `json:"event_url"`
-		ImageURL    string `json:"image_url"`
-		Country     string `json:"country"`
-		City        string `json:"city"`
-		Description string `json:"description"`
-		Year        int    `json:"year"`
-		StartDate   string `json:"start_date"`
-		EndDate     string `json:"end_date"`
-		ExpiryDate  string `json:"expiry_date"`
-		Supply      int    `json:"supply"`
-	} `json:"event"`
-	TokenID string `json:"tokenId"`
-	Owner   string `json:"owner"`
-	Chain   string `json:"chain"`
-	Created string `json:"created"`
-}
-
 type NFTPortOwnerOf struct {
 	Response string `json:"response"`
 	Nfts     []struct {
@@ -5431,20 +4925,30 @@ type SocialMsg struct {
 }
 
 type CommunityMember struct {
-	Name    string `json:"name"`
-	Address string `json:"address"`
-	Image   string `json:"image"`
-	Admin   bool   `json:"admin"`
+	Name     string     `json:"name"`
+	Address  string     `json:"address"`
+	Image    string     `json:"image,omitempty"`
+	Admin    bool       `json:"admin"`
+	Role     string     `json:"role"`   //admin, moderator, member
+	Status   string     `json:"status"` //active, pending, banned
+	Joinedat *time.Time `json:"joined_at,omitempty"`
 }
 type LandingPageItems struct {
 	Name        string                 `json:"name"`
 	MemberCount int                    `json:"member_count"`
 	Members     []CommunityMember      `json:"members"`
 	Logo        string                 `json:"logo"`         // logo url, stored in backend
-	Verified    bool                   `json:"is_verified"`  // is this group verified? WalletChat's group is verified by default
+	Verified    bool                   `json:"is_verified"`  // is this group verified? set by WalletChat, see GET /directory
 	Joined      bool                   `json:"joined"`       //number of members of the group
+	Pending     bool                   `json:"pending"`      //join request waiting for approval
+	Gated       bool                   `json:"gated"`        //has access conditions, see /v1/community/{slug}/access
+	Role        string                 `json:"role"`         //admin, moderator, member - empty when not joined
+	JoinMode    string                 `json:"join_mode"`    //open, approval, invite
 	Messaged    bool                   `json:"has_messaged"` // has user messaged in this group chat before? if not show "Say hi" button
+	Channel     string                 `json:"channel"`      //channel of Messages/Pinned, ?channel=name (default general)
+	Channels    []ChannelInfo          `json:"channels"`     //all channels with unread counts
 	Messages    []entity.Groupchatitem `json:"messages"`
+	Pinned      []entity.Groupchatitem `json:"pinned"` //pinned messages in pin order
 	Tweets      []TweetType            `json:"tweets"` // follow format of GET /get_twitter/{nftAddr}
 	Social      []SocialMsg            `json:"social"`
 }
@@ -0,0 +1,486 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/ttlcache"
+	"strings"
+	"sync"
+	"time"
+
+	goaway "github.com/TwiN/go-away"
+	"github.com/gorilla/mux"
+)
+
+const (
+	filterSystemReporter = "system" //Messagereport.Reporter for messages flagged by the filter
+	filterMaxListEntries = 500
+	filterLinkMask       = "[link removed]"
+
+	walletGuardCacheTtl     = 6 * time.Hour
+	walletGuardCacheEntries = 20000 //links are user input, the least recently checked are dropped beyond this
+	walletGuardTimeout      = 5 * time.Second
+	walletGuardMaxLinks     = 5 //links screened per message, checked at once so a message waits walletGuardTimeout at most
+)
+
+// filter rule names reported back to the sender
+const (
+	filterRuleProfanity = "profanity"
+	filterRuleLink      = "link"
+	filterRulePhishing  = "phishing"
+)
+
+// http(s):// and www. links, bare domains are left alone to keep false positives (file.txt, e.g.) down
+var filterLinkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"'` + "`" + `]+`)
+
+// ChatFilterSettings is the API view of entity.Chatfilter with the lists as arrays
+type ChatFilterSettings struct {
+	Profanityaction string   `json:"profanity_action"` //off, block, mask, flag
+	Customwordsonly bool     `json:"custom_words_only"`
+	Customwords     []string `json:"custom_words"`
+	Allowedwords    []string `json:"allowed_words"`
+	Linkmode        string   `json:"link_mode"` //deny, allow
+	Linkaction      string   `json:"link_action"`
+	Linkallow       []string `json:"link_allow"`
+	Linkdeny        []string `json:"link_deny"`
+	Phishingaction  string   `json:"phishing_action"`
+}
+
+// FilterHit is one rule that matched a message
+type FilterHit struct {
+	Rule   string `json:"rule"`
+	Action string `json:"action"`
+	Match  string `json:"match"`
+}
+
+// FilterResult tells the sender what the filter did with their message
+type FilterResult struct {
+	Blocked bool        `json:"blocked"`
+	Flagged bool        `json:"flagged"`
+	Masked  bool        `json:"masked"`
+	Message string      `json:"message"` //the message as posted
+	Hits    []FilterHit `json:"hits"`
+}
+
+// GroupMessageResponse is a posted group chat message plus what the filter did, the message fields stay at the top level
+type GroupMessageResponse struct {
+	entity.Groupchatitem
+	Filter *FilterResult `json:"filter,omitempty"`
+}
+
+var (
+	walletGuardCache  = ttlcache.New[string](walletGuardCacheEntries)
+	walletGuardClient = &http.Client{Timeout: walletGuardTimeout}
+
+	errWalletGuardDisabled = errors.New("WALLET_GUARD_API_KEY not set")
+)
+
+// defaultChatFilter is what groups without settings get, it matches the old goaway.Censor behavior plus phishing screening
+func defaultChatFilter(groupaddr string) entity.Chatfilter {
+	return entity.Chatfilter{
+		Groupaddr:       groupaddr,
+		Profanityaction: entity.FilterActionMask,
+		Linkmode:        entity.LinkModeDeny,
+		Linkaction:      entity.FilterActionBlock,
+		Phishingaction:  entity.FilterActionBlock,
+	}
+}
+
+func getChatFilter(groupaddr string) entity.Chatfilter {
+	var filter entity.Chatfilter
+	if database.Connector.Where("groupaddr = ?", groupaddr).Find(&filter).RowsAffected == 0 {
+		return defaultChatFilter(groupaddr)
+	}
+	return filter
+}
+
+func splitFilterList(list string) []string {
+	entries := []string{}
+	for _, entry := range strings.Split(list, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// normalizeFilterList lower cases, trims and de-duplicates, domains also lose any scheme/path/www.
+func normalizeFilterList(entries []string, domains bool) ([]string, error) {
+	seen := map[string]bool{}
+	normalized := []string{}
+	for _, entry := range entries {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if domains {
+			entry = linkHost(entry)
+		}
+		if entry == "" || seen[entry] {
+			continue
+		}
+		if strings.Contains(entry, ",") {
+			return nil, errors.New("list entries can't contain commas: " + entry)
+		}
+		seen[entry] = true
+		normalized = append(normalized, entry)
+	}
+	if len(normalized) > filterMaxListEntries {
+		return nil, errors.New("lists are limited to 500 entries")
+	}
+	return normalized, nil
+}
+
+func validFilterAction(action string) bool {
+	switch action {
+	case entity.FilterActionOff, entity.FilterActionBlock, entity.FilterActionMask, entity.FilterActionFlag:
+		return true
+	}
+	return false
+}
+
+func chatFilterView(filter entity.Chatfilter) ChatFilterSettings {
+	return ChatFilterSettings{
+		Profanityaction: filter.Profanityaction,
+		Customwordsonly: filter.Customwordsonly,
+		Customwords:     splitFilterList(filter.Customwords),
+		Allowedwords:    splitFilterList(filter.Allowedwords),
+		Linkmode:        filter.Linkmode,
+		Linkaction:      filter.Linkaction,
+		Linkallow:       splitFilterList(filter.Linkallow),
+		Linkdeny:        splitFilterList(filter.Linkdeny),
+		Phishingaction:  filter.Phishingaction,
+	}
+}
+
+// linkHost returns the lower case host of a link without www., "" if it doesn't parse
+func linkHost(link string) string {
+	link = strings.ToLower(strings.TrimSpace(link))
+	if !strings.Contains(link, "://") {
+		link = "https://" + link
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(parsed.Hostname(), "www.")
+}
+
+// domainListed matches the host or any of its parent domains (evil.example.com is listed by example.com)
+func domainListed(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+func profanityDetector(filter entity.Chatfilter) *goaway.ProfanityDetector {
+	custom := splitFilterList(filter.Customwords)
+	allowed := splitFilterList(filter.Allowedwords)
+	if filter.Customwordsonly {
+		return goaway.NewProfanityDetector().WithCustomDictionary(custom, allowed, []string{})
+	}
+	profanities := append(append([]string{}, goaway.DefaultProfanities...), custom...)
+	falsePositives := append(append([]string{}, goaway.DefaultFalsePositives...), allowed...)
+	return goaway.NewProfanityDetector().WithCustomDictionary(profanities, falsePositives, goaway.DefaultFalseNegatives)
+}
+
+// walletGuardAction returns the WalletGuard recommended action (NONE, WARN, BLOCK) for a link, cached since
+// the same links get posted over and over, errors are not cached
+func walletGuardAction(ctx context.Context, link string) (string, error) {
+	apiKey := os.Getenv("WALLET_GUARD_API_KEY")
+	if apiKey == "" {
+		return "", errWalletGuardDisabled
+	}
+
+	if action, found := walletGuardCache.Get(link); found {
+		return action, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", "https://api.walletguard.app/v1/scan?url="+url.QueryEscape(link), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Add("accept", "application/json")
+	req.Header.Add("X-API-KEY", apiKey)
+
+	res, err := walletGuardClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", errors.New("WalletGuard returned " + res.Status)
+	}
+
+	responseBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", err
+	}
+	var walletGuardResponse WalletGuardResponse
+	if err := json.Unmarshal(responseBody, &walletGuardResponse); err != nil {
+		return "", err
+	}
+
+	walletGuardCache.Set(link, walletGuardResponse.RecommendedAction, walletGuardCacheTtl)
+	return walletGuardResponse.RecommendedAction, nil
+}
+
+// applyFilterAction records the hit and applies block/flag, or swaps in the masked message
+func applyFilterAction(result *FilterResult, rule string, action string, match string, masked string) {
+	result.Hits = append(result.Hits, FilterHit{Rule: rule, Action: action, Match: match})
+	switch action {
+	case entity.FilterActionBlock:
+		result.Blocked = true
+	case entity.FilterActionFlag:
+		result.Flagged = true
+	case entity.FilterActionMask:
+		result.Masked = true
+		result.Message = masked
+	}
+}
+
+// filterGroupMessage runs a public group chat message through the group's filter rules:
+// profanity (built in and custom words), link allow/deny lists and WalletGuard phishing screening
+func filterGroupMessage(groupaddr string, message string) FilterResult {
+	filter := getChatFilter(groupaddr)
+	result := FilterResult{Message: message, Hits: []FilterHit{}}
+
+	if filter.Profanityaction != "" && filter.Profanityaction != entity.FilterActionOff {
+		detector := profanityDetector(filter)
+		if match := detector.ExtractProfanity(result.Message); match != "" {
+			applyFilterAction(&result, filterRuleProfanity, filter.Profanityaction, match, detector.Censor(result.Message))
+		}
+	}
+
+	allow := splitFilterList(filter.Linkallow)
+	deny := splitFilterList(filter.Linkdeny)
+	screen := []string{}
+	for _, link := range filterLinkPattern.FindAllString(result.Message, -1) {
+		link = strings.TrimRight(link, ".,;:!?)]}")
+		host := linkHost(link)
+		if host == "" {
+			continue
+		}
+
+		if filter.Linkaction != "" && filter.Linkaction != entity.FilterActionOff {
+			listed := false
+			if filter.Linkmode == entity.LinkModeAllow {
+				listed = !domainListed(host, allow)
+			} else {
+				listed = domainListed(host, deny)
+			}
+			if listed {
+				applyFilterAction(&result, filterRuleLink, filter.Linkaction, link, strings.Replace(result.Message, link, filterLinkMask, -1))
+				continue
+			}
+		}
+
+		if filter.Phishingaction == "" || filter.Phishingaction == entity.FilterActionOff || domainListed(host, allow) {
+			continue
+		}
+		if len(screen) < walletGuardMaxLinks && !stringInSlice(link, screen) {
+			screen = append(screen, link)
+		}
+	}
+
+	for i, recommended := range walletGuardActions(screen) {
+		link := screen[i]
+		switch strings.ToUpper(recommended) {
+		case "BLOCK":
+			applyFilterAction(&result, filterRulePhishing, filter.Phishingaction, link, strings.Replace(result.Message, link, filterLinkMask, -1))
+		case "WARN":
+			applyFilterAction(&result, filterRulePhishing, entity.FilterActionFlag, link, result.Message)
+		}
+	}
+	return result
+}
+
+// walletGuardActions screens the links at the same time under one walletGuardTimeout deadline,
+// a link whose check failed gets an empty action
+func walletGuardActions(links []string) []string {
+	actions := make([]string, len(links))
+	if len(links) == 0 {
+		return actions
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), walletGuardTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func(i int, link string) {
+			defer wg.Done()
+			recommended, err := walletGuardAction(ctx, link)
+			if err != nil {
+				//fail open, a WalletGuard outage shouldn't stop the chat
+				if err != errWalletGuardDisabled {
+					log.Println("filterGroupMessage WalletGuard error: ", err)
+				}
+				return
+			}
+			actions[i] = recommended
+		}(i, link)
+	}
+	wg.Wait()
+	return actions
+}
+
+// flagFilteredMessage puts a posted message that hit a flag rule in the moderation queue
+func flagFilteredMessage(chat entity.Groupchatitem, result FilterResult) {
+	reasons := []string{}
+	for _, hit := range result.Hits {
+		if hit.Action == entity.FilterActionFlag {
+			reasons = append(reasons, hit.Rule+": "+hit.Match)
+		}
+	}
+	report := entity.Messagereport{
+		Groupaddr: chat.Nftaddr,
+		Messageid: chat.Id,
+		Reporter:  filterSystemReporter,
+		Author:    strings.ToLower(chat.Fromaddr),
+		Message:   chat.Message,
+		Reason:    strings.Join(reasons, ", "),
+		Status:    entity.ReportStatusOpen,
+		Timestamp: time.Now(),
+	}
+	if err := database.Connector.Create(&report).Error; err != nil {
+		log.Println("flagFilteredMessage error: ", err)
+	}
+}
+
+// writeFilteredMessage sends the posted message (201) with the filter result when any rule matched
+func writeFilteredMessage(w http.ResponseWriter, chat entity.Groupchatitem, result FilterResult) {
+	response := GroupMessageResponse{Groupchatitem: chat}
+	if len(result.Hits) > 0 {
+		response.Filter = &result
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeBlockedMessage tells the sender which rules rejected the message (422)
+func writeBlockedMessage(w http.ResponseWriter, result FilterResult) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(result)
+}
+
+// ============================================================================
+
+// GetChatFilter godoc
+// @Summary     Get the message filter settings of a community/NFT group chat (moderators)
+// @Tags        Moderation
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group path     string true "community slug or NFT/POAP address"
+// @Success     200   {object} ChatFilterSettings
+// @Router      /v1/moderation/{group}/filters [get]
+func GetChatFilter(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(chatFilterView(getChatFilter(groupaddr)))
+}
+
+// SetChatFilter godoc
+// @Summary     Replace the message filter settings of a community/NFT group chat (moderators)
+// @Description Each rule action is off, block (reject the message), mask (replace the match) or flag (post it and add it to the moderation queue)
+// @Description link_mode deny only acts on link_deny domains, allow acts on every domain not in link_allow
+// @Description Phishing screening uses WalletGuard, links in link_allow are not screened
+// @Tags        Moderation
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group   path     string             true "community slug or NFT/POAP address"
+// @Param       message body     ChatFilterSettings true "filter settings"
+// @Success     200     {object} ChatFilterSettings
+// @Router      /v1/moderation/{group}/filters [put]
+func SetChatFilter(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var settings ChatFilterSettings
+	if err := json.Unmarshal(requestBody, &settings); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+
+	for _, action := range []string{settings.Profanityaction, settings.Linkaction, settings.Phishingaction} {
+		if !validFilterAction(action) {
+			http.Error(w, "actions must be off, block, mask or flag", http.StatusBadRequest)
+			return
+		}
+	}
+	if settings.Linkmode != entity.LinkModeDeny && settings.Linkmode != entity.LinkModeAllow {
+		http.Error(w, "link_mode must be deny or allow", http.StatusBadRequest)
+		return
+	}
+	customwords, err := normalizeFilterList(settings.Customwords, false)
+	if err == nil {
+		settings.Allowedwords, err = normalizeFilterList(settings.Allowedwords, false)
+	}
+	if err == nil {
+		settings.Linkallow, err = normalizeFilterList(settings.Linkallow, true)
+	}
+	if err == nil {
+		settings.Linkdeny, err = normalizeFilterList(settings.Linkdeny, true)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var filter entity.Chatfilter
+	database.Connector.Where("groupaddr = ?", groupaddr).Find(&filter)
+	filter.Groupaddr = groupaddr
+	filter.Profanityaction = settings.Profanityaction
+	filter.Customwordsonly = settings.Customwordsonly
+	filter.Customwords = strings.Join(customwords, ",")
+	filter.Allowedwords = strings.Join(settings.Allowedwords, ",")
+	filter.Linkmode = settings.Linkmode
+	filter.Linkaction = settings.Linkaction
+	filter.Linkallow = strings.Join(settings.Linkallow, ",")
+	filter.Linkdeny = strings.Join(settings.Linkdeny, ",")
+	filter.Phishingaction = settings.Phishingaction
+	filter.Updatedby = strings.ToLower(Authuser.Address)
+	filter.Timestamp = time.Now()
+	if filter.Id == 0 {
+		database.Connector.Create(&filter)
+	} else {
+		database.Connector.Save(&filter)
+	}
+	logModeration(entity.Moderationlog{
+		Groupaddr: groupaddr,
+		Actor:     Authuser.Address,
+		Action:    entity.ModActionFilters,
+		Reason:    "profanity " + filter.Profanityaction + ", links " + filter.Linkmode + "/" + filter.Linkaction + ", phishing " + filter.Phishingaction,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(chatFilterView(filter))
+}
@@ -0,0 +1,1012 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/webhooks"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	communityInviteCodeLength = 12
+	communityMembersPageSize  = 50
+	communityMembersMaxPage   = 200
+)
+
+var (
+	errCommunityNotFound    = errors.New("community not found")
+	errCommunityBanned      = errors.New("banned from this community")
+	errCommunityInviteOnly  = errors.New("this community is invite only")
+	errCommunityInvite      = errors.New("invite link is invalid, expired or used up")
+	errCommunityLastAdmin   = errors.New("a community needs at least one admin")
+	errCommunityNotAllowed  = errors.New("not allowed for your role")
+	errCommunityNotAMember  = errors.New("not a member of this community")
+	errCommunityInvalidRole = errors.New("role must be admin, moderator or member")
+	errReplyNotFound        = errors.New("reply_to is not a message of this chat")
+	errAnnouncementReply    = errors.New("only community admins can reply to announcements")
+)
+
+// CommunityJoinRequest is the optional body of POST /community/{slug}/join
+type CommunityJoinRequest struct {
+	Invitecode string `json:"invite_code"`
+}
+
+// CommunityBanRequest bans a member, duration_hours 0 means permanent
+type CommunityBanRequest struct {
+	Reason        string `json:"reason"`
+	Durationhours int    `json:"duration_hours"`
+}
+
+// CommunityRoleRequest changes a member role
+type CommunityRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// CommunityInviteRequest creates an invite link, 0 means unlimited uses / never expires
+type CommunityInviteRequest struct {
+	Maxuses        int `json:"max_uses"`
+	Expiresinhours int `json:"expires_in_hours"`
+}
+
+// CommunityPolicyRequest changes who can join
+type CommunityPolicyRequest struct {
+	Joinmode string `json:"join_mode"`
+}
+
+// CommunityMemberPage is one page of GET /community/{slug}/members
+type CommunityMemberPage struct {
+	Members []CommunityMember `json:"members"`
+	Total   int               `json:"total"`
+	Page    int               `json:"page"`
+	Limit   int               `json:"limit"`
+}
+
+// communityExists - communities are still in the addrname mapping table, created ones also have an admin row
+func communityExists(slug string) bool {
+	if slug == "" || strings.HasPrefix(strings.ToLower(slug), "0x") {
+		return false
+	}
+	var communities []entity.Communityitem
+	if database.Connector.Where("slug = ?", slug).Limit(1).Find(&communities).RowsAffected > 0 {
+		return true
+	}
+	var admins []entity.Communityadmin
+	if database.Connector.Where("slug = ?", slug).Limit(1).Find(&admins).RowsAffected > 0 {
+		return true
+	}
+	var addrname entity.Addrnameitem
+	return database.Connector.Where("address = ?", slug).Find(&addrname).RowsAffected > 0
+}
+
+// communityStaffRole returns admin, moderator or "" from the Communityadmin rows (a wallet can have several)
+func communityStaffRole(slug string, walletaddr string) string {
+	var rows []entity.Communityadmin
+	database.Connector.Where("slug = ?", slug).Where("adminaddr = ?", strings.ToLower(walletaddr)).Find(&rows)
+	role := ""
+	for _, row := range rows {
+		if strings.EqualFold(row.Accesslevel, entity.CommunityRoleAdmin) {
+			return entity.CommunityRoleAdmin
+		}
+		if strings.EqualFold(row.Accesslevel, entity.CommunityRoleModerator) {
+			role = entity.CommunityRoleModerator
+		}
+	}
+	return role
+}
+
+func isCommunityAdmin(slug string, walletaddr string) bool {
+	return communityStaffRole(slug, walletaddr) == entity.CommunityRoleAdmin
+}
+
+func isCommunityStaff(slug string, walletaddr string) bool {
+	return communityStaffRole(slug, walletaddr) != ""
+}
+
+func countCommunityAdmins(slug string) int {
+	count := 0
+	database.Connector.Model(&entity.Communityadmin{}).
+		Where("slug = ?", slug).
+		Where("accesslevel = ?", entity.CommunityRoleAdmin).
+		Count(&count)
+	return count
+}
+
+// GetCommunityJoinMode returns open, approval or invite
+func GetCommunityJoinMode(slug string) string {
+	var policy entity.Communitypolicy
+	if database.Connector.Where("slug = ?", slug).Find(&policy).RowsAffected == 0 || policy.Joinmode == "" {
+		return entity.JoinModeOpen
+	}
+	return policy.Joinmode
+}
+
+func getCommunityMember(slug string, walletaddr string) (entity.Communitymember, bool) {
+	var member entity.Communitymember
+	dbQuery := database.Connector.Where("slug = ?", slug).Where("walletaddr = ?", strings.ToLower(walletaddr)).Find(&member)
+	return member, dbQuery.RowsAffected > 0
+}
+
+// banActive treats an expired temporary ban as no ban
+func banActive(member entity.Communitymember) bool {
+	return member.Status == entity.MemberStatusBanned && (member.Banneduntil == nil || member.Banneduntil.After(time.Now()))
+}
+
+// IsCommunityMember checks the wallet is an active (not banned, not pending) member
+func IsCommunityMember(slug string, walletaddr string) bool {
+	member, found := getCommunityMember(slug, walletaddr)
+	return found && member.Status == entity.MemberStatusActive
+}
+
+// IsBannedFromCommunity checks for an active ban
+func IsBannedFromCommunity(slug string, walletaddr string) bool {
+	member, found := getCommunityMember(slug, walletaddr)
+	return found && banActive(member)
+}
+
+// hasUnjoined checks the wallet manually left the group chat, so it must not be auto-joined again
+func hasUnjoined(groupaddr string, walletaddr string) bool {
+	var unjoined entity.Userunjoined
+	dbQuery := database.Connector.Where("nftaddr = ?", groupaddr).
+		Where("walletaddr = ?", strings.ToLower(walletaddr)).
+		Where("unjoined = ?", true).
+		Find(&unjoined)
+	return dbQuery.RowsAffected > 0
+}
+
+// canPostInCommunity - open communities keep allowing anyone but banned wallets, private ones need an active membership
+func canPostInCommunity(slug string, walletaddr string) bool {
+	member, found := getCommunityMember(slug, walletaddr)
+	if found && banActive(member) {
+		return false
+	}
+	if GetCommunityJoinMode(slug) == entity.JoinModeOpen {
+		return true
+	}
+	return found && member.Status == entity.MemberStatusActive
+}
+
+func saveCommunityMember(member *entity.Communitymember) {
+	member.Timestamp = time.Now()
+	if member.Id == 0 {
+		database.Connector.Create(member)
+	} else {
+		database.Connector.Save(member)
+	}
+}
+
+// activateCommunityMember makes the wallet an active member: member row, sidebar bookmark, welcome message and webhook
+func activateCommunityMember(slug string, walletaddr string, role string, inviteCode string, actor string) entity.Communitymember {
+	walletaddr = strings.ToLower(walletaddr)
+	member, _ := getCommunityMember(slug, walletaddr)
+	wasActive := member.Status == entity.MemberStatusActive
+
+	now := time.Now()
+	member.Slug = slug
+	member.Walletaddr = walletaddr
+	if role != "" {
+		member.Role = role
+	} else if member.Role == "" {
+		member.Role = entity.CommunityRoleMember
+	}
+	member.Status = entity.MemberStatusActive
+	member.Invitecode = inviteCode
+	member.Actor = strings.ToLower(actor)
+	member.Banreason = ""
+	member.Banneduntil = nil
+	if member.Joinedat == nil || !wasActive {
+		member.Joinedat = &now
+	}
+	saveCommunityMember(&member)
+	if wasActive {
+		return member
+	}
+
+	database.Connector.Model(&entity.Userunjoined{}).
+		Where("walletaddr = ?", walletaddr).
+		Where("nftaddr = ?", slug).
+		Update("unjoined", false)
+
+	//members from before member rows existed already have the bookmark and were welcomed
+	var bookmarks []entity.Bookmarkitem
+	if database.Connector.Where("nftaddr = ?", slug).Where("walletaddr = ?", walletaddr).Find(&bookmarks).RowsAffected > 0 {
+		return member
+	}
+	bookmark := entity.Bookmarkitem{Walletaddr: walletaddr, Nftaddr: slug, Chain: "none"}
+	database.Connector.Create(&bookmark)
+
+	var addrname entity.Addrnameitem
+	database.Connector.Where("address = ?", slug).Find(&addrname)
+	welcome := entity.Groupchatitem{
+		Type:          entity.Welcome,
+		Contexttype:   entity.Community,
+		Fromaddr:      walletaddr,
+		Nftaddr:       slug,
+		Message:       "Welcome " + walletaddr + " to " + addrname.Name + "!",
+		Timestamp_dtm: now,
+		Timestamp:     now.Format("2006-01-02T15:04:05.000Z"),
+	}
+	database.Connector.Create(&welcome)
+
+	webhooks.EmitForWallet(entity.WebhookCommunityMemberJoined, walletaddr, map[string]string{
+		"community":  slug,
+		"walletaddr": walletaddr,
+	})
+	return member
+}
+
+// deactivateCommunityMember sets left/banned/pending-rejected, removes the sidebar bookmark and remembers the user is out
+// so auto-join doesn't add them back
+func deactivateCommunityMember(member entity.Communitymember, status string, actor string) entity.Communitymember {
+	wasActive := member.Status == entity.MemberStatusActive
+	member.Status = status
+	member.Actor = strings.ToLower(actor)
+	saveCommunityMember(&member)
+
+	database.Connector.Where("nftaddr = ?", member.Slug).Where("walletaddr = ?", member.Walletaddr).Delete(&entity.Bookmarkitem{})
+	var unjoined entity.Userunjoined
+	if database.Connector.Where("nftaddr = ?", member.Slug).Where("walletaddr = ?", member.Walletaddr).Find(&unjoined).RowsAffected > 0 {
+		database.Connector.Model(&entity.Userunjoined{}).
+			Where("walletaddr = ?", member.Walletaddr).
+			Where("nftaddr = ?", member.Slug).
+			Update("unjoined", true)
+	} else {
+		unjoined = entity.Userunjoined{Walletaddr: member.Walletaddr, Nftaddr: member.Slug, Unjoined: true}
+		database.Connector.Create(&unjoined)
+	}
+
+	if wasActive {
+		webhooks.EmitForWallet(entity.WebhookCommunityMemberLeft, member.Walletaddr, map[string]string{
+			"community":  member.Slug,
+			"walletaddr": member.Walletaddr,
+			"status":     status,
+		})
+	}
+	return member
+}
+
+// setCommunityRole keeps Communityadmin (used for admin checks everywhere) in sync with the member row
+func setCommunityRole(slug string, walletaddr string, role string) {
+	walletaddr = strings.ToLower(walletaddr)
+	database.Connector.Where("slug = ?", slug).Where("adminaddr = ?", walletaddr).Delete(&entity.Communityadmin{})
+	if role == entity.CommunityRoleAdmin || role == entity.CommunityRoleModerator {
+		staff := entity.Communityadmin{Slug: slug, Adminaddr: walletaddr, Accesslevel: role}
+		database.Connector.Create(&staff)
+	}
+	database.Connector.Model(&entity.Communitymember{}).
+		Where("slug = ?", slug).
+		Where("walletaddr = ?", walletaddr).
+		Update("role", role)
+}
+
+// claimCommunityInvite uses up one invite, the conditional update makes max_uses hold under concurrent joins
+func claimCommunityInvite(slug string, code string) bool {
+	dbResult := database.Connector.Model(&entity.Communityinvite{}).
+		Where("slug = ?", slug).
+		Where("code = ?", code).
+		Where("revoked = ?", false).
+		Where("maxuses = 0 OR uses < maxuses").
+		Where("expires IS NULL OR expires > ?", time.Now()).
+		UpdateColumn("uses", gorm.Expr("uses + ?", 1))
+	return dbResult.RowsAffected == 1
+}
+
+// joinCommunity applies the join policy, bans and invite codes
+func joinCommunity(slug string, walletaddr string, inviteCode string) (entity.Communitymember, error) {
+	if !communityExists(slug) {
+		return entity.Communitymember{}, errCommunityNotFound
+	}
+	member, found := getCommunityMember(slug, walletaddr)
+	if found && banActive(member) {
+		return member, errCommunityBanned
+	}
+	if found && member.Status == entity.MemberStatusActive {
+		return member, nil
+	}
+	//invites and approval skip the join policy, not the token gate
+	if !communityAccessAllowed(slug, walletaddr) {
+		return member, errCommunityAccessDenied
+	}
+
+	if inviteCode != "" {
+		if !claimCommunityInvite(slug, inviteCode) {
+			return member, errCommunityInvite
+		}
+		return activateCommunityMember(slug, walletaddr, "", inviteCode, walletaddr), nil
+	}
+
+	switch GetCommunityJoinMode(slug) {
+	case entity.JoinModeInvite:
+		return member, errCommunityInviteOnly
+	case entity.JoinModeApproval:
+		member.Slug = slug
+		member.Walletaddr = strings.ToLower(walletaddr)
+		if member.Role == "" {
+			member.Role = entity.CommunityRoleMember
+		}
+		member.Status = entity.MemberStatusPending
+		member.Actor = ""
+		saveCommunityMember(&member)
+		return member, nil
+	}
+	return activateCommunityMember(slug, walletaddr, "", "", walletaddr), nil
+}
+
+// BackfillCommunityMembers creates member rows for community bookmarks and admins from before memberships existed
+func BackfillCommunityMembers() {
+	var bookmarks []entity.Bookmarkitem
+	database.Connector.Raw(`SELECT b.* FROM bookmarkitems b
+		LEFT JOIN communitymembers m ON m.slug = b.nftaddr AND m.walletaddr = b.walletaddr
+		WHERE b.chain = ? AND m.id IS NULL`, "none").Scan(&bookmarks)
+
+	created := 0
+	for _, bookmark := range bookmarks {
+		if !communityExists(bookmark.Nftaddr) {
+			continue
+		}
+		role := communityStaffRole(bookmark.Nftaddr, bookmark.Walletaddr)
+		if role == "" {
+			role = entity.CommunityRoleMember
+		}
+		member := entity.Communitymember{
+			Slug:       bookmark.Nftaddr,
+			Walletaddr: strings.ToLower(bookmark.Walletaddr),
+			Role:       role,
+			Status:     entity.MemberStatusActive,
+		}
+		saveCommunityMember(&member)
+		created++
+	}
+
+	var admins []entity.Communityadmin
+	database.Connector.Find(&admins)
+	for _, admin := range admins {
+		if _, found := getCommunityMember(admin.Slug, admin.Adminaddr); found {
+			continue
+		}
+		member := entity.Communitymember{
+			Slug:       admin.Slug,
+			Walletaddr: strings.ToLower(admin.Adminaddr),
+			Role:       strings.ToLower(admin.Accesslevel),
+			Status:     entity.MemberStatusActive,
+		}
+		saveCommunityMember(&member)
+		created++
+	}
+	log.Println("Backfilled community members: ", created)
+}
+
+func writeCommunityError(w http.ResponseWriter, err error) {
+	status := http.StatusForbidden
+	switch err {
+	case errCommunityNotFound, errCommunityNotAMember:
+		status = http.StatusNotFound
+	case errCommunityLastAdmin:
+		status = http.StatusConflict
+	case errCommunityInvalidRole:
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func writeCommunityMember(w http.ResponseWriter, status int, member entity.Communitymember) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(member)
+}
+
+// communityRoleRank orders the staff roles, members (and everyone else) are 0
+func communityRoleRank(role string) int {
+	switch role {
+	case entity.CommunityRoleAdmin:
+		return 2
+	case entity.CommunityRoleModerator:
+		return 1
+	}
+	return 0
+}
+
+// memberActionTarget loads the target of a staff action and checks the caller outranks them. The target's rank comes
+// from its Communityadmin rows, so staff without a member row can't be acted on by their peers either.
+func memberActionTarget(slug string, actor string, target string) (entity.Communitymember, error) {
+	actorRole := communityStaffRole(slug, actor)
+	if actorRole == "" || strings.EqualFold(actor, target) {
+		return entity.Communitymember{}, errCommunityNotAllowed
+	}
+	if communityRoleRank(communityStaffRole(slug, target)) >= communityRoleRank(actorRole) {
+		return entity.Communitymember{}, errCommunityNotAllowed
+	}
+	member, found := getCommunityMember(slug, target)
+	if !found {
+		return member, errCommunityNotAMember
+	}
+	return member, nil
+}
+
+// ============================================================================
+
+// JoinCommunity godoc
+// @Summary     Join a community
+// @Description Open communities join right away, approval communities create a pending request (202), invite communities need invite_code
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string               true  "community slug"
+// @Param       message body     CommunityJoinRequest false "invite code"
+// @Success     200     {object} entity.Communitymember
+// @Success     202     {object} entity.Communitymember
+// @Router      /v1/community/{slug}/join [post]
+func JoinCommunity(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var joinRequest CommunityJoinRequest
+	json.Unmarshal(requestBody, &joinRequest)
+
+	member, err := joinCommunity(slug, Authuser.Address, strings.TrimSpace(joinRequest.Invitecode))
+	if err != nil {
+		writeCommunityError(w, err)
+		return
+	}
+	if member.Status == entity.MemberStatusPending {
+		writeCommunityMember(w, http.StatusAccepted, member)
+		return
+	}
+	writeCommunityMember(w, http.StatusOK, member)
+}
+
+// LeaveCommunity godoc
+// @Summary     Leave a community (or cancel a pending join request)
+// @Description The last admin can't leave, make someone else admin first
+// @Tags        GroupChat
+// @Security    BearerAuth
+// @Param       slug path string true "community slug"
+// @Success     204
+// @Router      /v1/community/{slug}/leave [post]
+func LeaveCommunity(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	member, found := getCommunityMember(slug, Authuser.Address)
+	if !found || (member.Status != entity.MemberStatusActive && member.Status != entity.MemberStatusPending) {
+		writeCommunityError(w, errCommunityNotAMember)
+		return
+	}
+	if isCommunityAdmin(slug, Authuser.Address) && countCommunityAdmins(slug) <= 1 {
+		writeCommunityError(w, errCommunityLastAdmin)
+		return
+	}
+	setCommunityRole(slug, Authuser.Address, entity.CommunityRoleMember)
+	member.Role = entity.CommunityRoleMember
+	deactivateCommunityMember(member, entity.MemberStatusLeft, Authuser.Address)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetCommunityMembers godoc
+// @Summary     Paginated community member list, admins first
+// @Description status defaults to active, only admins/moderators can list pending or banned members
+// @Description Members of private communities are only visible to members
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug   path     string true  "community slug"
+// @Param       status query    string false "active, pending or banned"
+// @Param       role   query    string false "admin, moderator or member"
+// @Param       page   query    int    false "page number, starting at 1"
+// @Param       limit  query    int    false "page size (default 50, max 200)"
+// @Success     200    {object} CommunityMemberPage
+// @Router      /v1/community/{slug}/members [get]
+func GetCommunityMembers(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+	query := r.URL.Query()
+
+	status := query.Get("status")
+	if status == "" {
+		status = entity.MemberStatusActive
+	}
+	switch status {
+	case entity.MemberStatusActive:
+		if GetCommunityJoinMode(slug) != entity.JoinModeOpen && !IsCommunityMember(slug, Authuser.Address) {
+			writeCommunityError(w, errCommunityNotAMember)
+			return
+		}
+	case entity.MemberStatusPending, entity.MemberStatusBanned:
+		if !isCommunityStaff(slug, Authuser.Address) {
+			writeCommunityError(w, errCommunityNotAllowed)
+			return
+		}
+	default:
+		http.Error(w, "status must be active, pending or banned", http.StatusBadRequest)
+		return
+	}
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > communityMembersMaxPage {
+		limit = communityMembersPageSize
+	}
+
+	dbQuery := database.Connector.Model(&entity.Communitymember{}).Where("slug = ?", slug).Where("status = ?", status)
+	if role := query.Get("role"); role != "" {
+		dbQuery = dbQuery.Where("role = ?", role)
+	}
+	var result CommunityMemberPage
+	dbQuery.Count(&result.Total)
+
+	var members []entity.Communitymember
+	dbQuery.Order("role = 'admin' desc, role = 'moderator' desc, id asc").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&members)
+
+	//one query for all the names instead of one per member
+	addresses := []string{}
+	for _, member := range members {
+		addresses = append(addresses, member.Walletaddr)
+	}
+	names := map[string]string{}
+	if len(addresses) > 0 {
+		var addrnames []entity.Addrnameitem
+		database.Connector.Where("address IN (?)", addresses).Find(&addrnames)
+		for _, addrname := range addrnames {
+			names[strings.ToLower(addrname.Address)] = addrname.Name
+		}
+	}
+
+	result.Members = []CommunityMember{}
+	for _, member := range members {
+		result.Members = append(result.Members, CommunityMember{
+			Name:     names[member.Walletaddr],
+			Address:  member.Walletaddr,
+			Admin:    member.Role == entity.CommunityRoleAdmin,
+			Role:     member.Role,
+			Status:   member.Status,
+			Joinedat: member.Joinedat,
+		})
+	}
+	result.Page = page
+	result.Limit = limit
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(result)
+}
+
+// KickCommunityMember godoc
+// @Summary     Remove a member (they can join again)
+// @Description Admins can kick moderators and members, moderators can kick members
+// @Tags        GroupChat
+// @Security    BearerAuth
+// @Param       slug    path string true "community slug"
+// @Param       address path string true "member wallet address"
+// @Success     204
+// @Router      /v1/community/{slug}/members/{address}/kick [post]
+func KickCommunityMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	Authuser := auth.GetUserFromReqContext(r)
+
+	member, err := memberActionTarget(vars["slug"], Authuser.Address, vars["address"])
+	if err != nil {
+		writeCommunityError(w, err)
+		return
+	}
+	if member.Status != entity.MemberStatusActive {
+		writeCommunityError(w, errCommunityNotAMember)
+		return
+	}
+	setCommunityRole(member.Slug, member.Walletaddr, entity.CommunityRoleMember)
+	member.Role = entity.CommunityRoleMember
+	deactivateCommunityMember(member, entity.MemberStatusLeft, Authuser.Address)
+	logModeration(entity.Moderationlog{Groupaddr: member.Slug, Actor: Authuser.Address, Action: entity.ModActionKick, Targetaddr: member.Walletaddr})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// BanCommunityMember godoc
+// @Summary     Ban a wallet from a community, optionally for a number of hours
+// @Description The wallet does not need to be a member, banning also removes any staff role
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string              true  "community slug"
+// @Param       address path     string              true  "wallet address"
+// @Param       message body     CommunityBanRequest false "reason and duration"
+// @Success     200     {object} entity.Communitymember
+// @Router      /v1/community/{slug}/members/{address}/ban [post]
+func BanCommunityMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	target := strings.ToLower(vars["address"])
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var banRequest CommunityBanRequest
+	json.Unmarshal(requestBody, &banRequest)
+
+	member, err := memberActionTarget(slug, Authuser.Address, target)
+	if err == errCommunityNotAMember && communityExists(slug) {
+		member = entity.Communitymember{Slug: slug, Walletaddr: target, Role: entity.CommunityRoleMember}
+	} else if err != nil {
+		writeCommunityError(w, err)
+		return
+	}
+
+	setCommunityRole(slug, target, entity.CommunityRoleMember)
+	member.Role = entity.CommunityRoleMember
+	member.Banreason = banRequest.Reason
+	member.Banneduntil = nil
+	if banRequest.Durationhours > 0 {
+		until := time.Now().Add(time.Duration(banRequest.Durationhours) * time.Hour)
+		member.Banneduntil = &until
+	}
+	member = deactivateCommunityMember(member, entity.MemberStatusBanned, Authuser.Address)
+	log.Println("Community ban: ", slug, target, "by", Authuser.Address)
+	logModeration(entity.Moderationlog{
+		Groupaddr:  slug,
+		Actor:      Authuser.Address,
+		Action:     entity.ModActionBan,
+		Targetaddr: target,
+		Reason:     banRequest.Reason,
+		Until:      member.Banneduntil,
+	})
+	writeCommunityMember(w, http.StatusOK, member)
+}
+
+// UnbanCommunityMember godoc
+// @Summary     Lift a ban, the wallet can join again
+// @Tags        GroupChat
+// @Security    BearerAuth
+// @Param       slug    path string true "community slug"
+// @Param       address path string true "wallet address"
+// @Success     204
+// @Router      /v1/community/{slug}/members/{address}/ban [delete]
+func UnbanCommunityMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	Authuser := auth.GetUserFromReqContext(r)
+
+	member, err := memberActionTarget(vars["slug"], Authuser.Address, vars["address"])
+	if err != nil {
+		writeCommunityError(w, err)
+		return
+	}
+	if member.Status != entity.MemberStatusBanned {
+		writeCommunityError(w, errCommunityNotAMember)
+		return
+	}
+	member.Status = entity.MemberStatusLeft
+	member.Actor = strings.ToLower(Authuser.Address)
+	member.Banreason = ""
+	member.Banneduntil = nil
+	saveCommunityMember(&member)
+	logModeration(entity.Moderationlog{Groupaddr: member.Slug, Actor: Authuser.Address, Action: entity.ModActionUnban, Targetaddr: member.Walletaddr})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ApproveCommunityMember godoc
+// @Summary     Approve a pending join request
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string true "community slug"
+// @Param       address path     string true "wallet address"
+// @Success     200     {object} entity.Communitymember
+// @Router      /v1/community/{slug}/members/{address}/approve [post]
+func ApproveCommunityMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	Authuser := auth.GetUserFromReqContext(r)
+
+	member, err := memberActionTarget(vars["slug"], Authuser.Address, vars["address"])
+	if err != nil {
+		writeCommunityError(w, err)
+		return
+	}
+	if member.Status != entity.MemberStatusPending {
+		writeCommunityError(w, errCommunityNotAMember)
+		return
+	}
+	if !communityAccessAllowed(member.Slug, member.Walletaddr) {
+		writeCommunityError(w, errCommunityAccessDenied)
+		return
+	}
+	member = activateCommunityMember(member.Slug, member.Walletaddr, "", "", Authuser.Address)
+	logModeration(entity.Moderationlog{Groupaddr: member.Slug, Actor: Authuser.Address, Action: entity.ModActionApprove, Targetaddr: member.Walletaddr})
+	writeCommunityMember(w, http.StatusOK, member)
+}
+
+// RejectCommunityMember godoc
+// @Summary     Reject a pending join request (they may ask again, ban to stop that)
+// @Tags        GroupChat
+// @Security    BearerAuth
+// @Param       slug    path string true "community slug"
+// @Param       address path string true "wallet address"
+// @Success     204
+// @Router      /v1/community/{slug}/members/{address}/reject [post]
+func RejectCommunityMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	Authuser := auth.GetUserFromReqContext(r)
+
+	member, err := memberActionTarget(vars["slug"], Authuser.Address, vars["address"])
+	if err != nil {
+		writeCommunityError(w, err)
+		return
+	}
+	if member.Status != entity.MemberStatusPending {
+		writeCommunityError(w, errCommunityNotAMember)
+		return
+	}
+	member.Status = entity.MemberStatusLeft
+	member.Actor = strings.ToLower(Authuser.Address)
+	saveCommunityMember(&member)
+	logModeration(entity.Moderationlog{Groupaddr: member.Slug, Actor: Authuser.Address, Action: entity.ModActionReject, Targetaddr: member.Walletaddr})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetCommunityMemberRole godoc
+// @Summary     Make an active member admin, moderator or a regular member again (admins only)
+// @Description A community can have any number of admins and moderators, but always keeps at least one admin
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string               true "community slug"
+// @Param       address path     string               true "member wallet address"
+// @Param       message body     CommunityRoleRequest true "new role"
+// @Success     200     {object} entity.Communitymember
+// @Router      /v1/community/{slug}/members/{address}/role [put]
+func SetCommunityMemberRole(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	target := strings.ToLower(vars["address"])
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var roleRequest CommunityRoleRequest
+	json.Unmarshal(requestBody, &roleRequest)
+	role := strings.ToLower(roleRequest.Role)
+	if role != entity.CommunityRoleAdmin && role != entity.CommunityRoleModerator && role != entity.CommunityRoleMember {
+		writeCommunityError(w, errCommunityInvalidRole)
+		return
+	}
+
+	if !isCommunityAdmin(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+	member, found := getCommunityMember(slug, target)
+	if !found || member.Status != entity.MemberStatusActive {
+		writeCommunityError(w, errCommunityNotAMember)
+		return
+	}
+	if role != entity.CommunityRoleAdmin && isCommunityAdmin(slug, target) && countCommunityAdmins(slug) <= 1 {
+		writeCommunityError(w, errCommunityLastAdmin)
+		return
+	}
+
+	setCommunityRole(slug, target, role)
+	member.Role = role
+	log.Println("Community role: ", slug, target, role, "by", Authuser.Address)
+	logModeration(entity.Moderationlog{Groupaddr: slug, Actor: Authuser.Address, Action: entity.ModActionRole, Targetaddr: target, Reason: role})
+	writeCommunityMember(w, http.StatusOK, member)
+}
+
+// CreateCommunityInvite godoc
+// @Summary     Create an invite link for a community (admins and moderators)
+// @Description Invite links let people join private communities without approval
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string                 true "community slug"
+// @Param       message body     CommunityInviteRequest true "limits"
+// @Success     201     {object} entity.Communityinvite
+// @Router      /v1/community/{slug}/invites [post]
+func CreateCommunityInvite(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isCommunityStaff(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var inviteRequest CommunityInviteRequest
+	json.Unmarshal(requestBody, &inviteRequest)
+	if inviteRequest.Maxuses < 0 || inviteRequest.Expiresinhours < 0 {
+		http.Error(w, "max_uses and expires_in_hours can't be negative", http.StatusBadRequest)
+		return
+	}
+
+	code, err := randomCode(communityInviteCodeLength)
+	if err != nil {
+		log.Println("CreateCommunityInvite error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	invite := entity.Communityinvite{
+		Slug:      slug,
+		Code:      code,
+		Createdby: strings.ToLower(Authuser.Address),
+		Maxuses:   inviteRequest.Maxuses,
+		Timestamp: time.Now(),
+	}
+	if inviteRequest.Expiresinhours > 0 {
+		expires := time.Now().Add(time.Duration(inviteRequest.Expiresinhours) * time.Hour)
+		invite.Expires = &expires
+	}
+	database.Connector.Create(&invite)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(invite)
+}
+
+// GetCommunityInvites godoc
+// @Summary     List invite links of a community (admins and moderators)
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug path    string true "community slug"
+// @Success     200  {array} entity.Communityinvite
+// @Router      /v1/community/{slug}/invites [get]
+func GetCommunityInvites(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isCommunityStaff(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+
+	var invites []entity.Communityinvite
+	database.Connector.Where("slug = ?", slug).Order("id desc").Find(&invites)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(invites)
+}
+
+// RevokeCommunityInvite godoc
+// @Summary     Revoke an invite link, admins can revoke any, moderators their own
+// @Tags        GroupChat
+// @Security    BearerAuth
+// @Param       slug path string true "community slug"
+// @Param       code path string true "invite code"
+// @Success     204
+// @Router      /v1/community/{slug}/invites/{code} [delete]
+func RevokeCommunityInvite(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	slug := vars["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	role := communityStaffRole(slug, Authuser.Address)
+	dbQuery := database.Connector.Model(&entity.Communityinvite{}).Where("slug = ?", slug).Where("code = ?", vars["code"])
+	if role == entity.CommunityRoleModerator {
+		dbQuery = dbQuery.Where("createdby = ?", strings.ToLower(Authuser.Address))
+	} else if role != entity.CommunityRoleAdmin {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+	if dbQuery.Update("revoked", true).RowsAffected == 0 {
+		http.Error(w, "invite not found", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SetCommunityPolicy godoc
+// @Summary     Change who can join a community (admins only)
+// @Description join_mode is open (anyone), approval (private, admins/moderators approve requests) or invite (private, invite links only)
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string                 true "community slug"
+// @Param       message body     CommunityPolicyRequest true "join policy"
+// @Success     200     {object} entity.Communitypolicy
+// @Router      /v1/community/{slug}/policy [put]
+func SetCommunityPolicy(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isCommunityAdmin(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var policyRequest CommunityPolicyRequest
+	json.Unmarshal(requestBody, &policyRequest)
+	joinMode := strings.ToLower(policyRequest.Joinmode)
+	if joinMode != entity.JoinModeOpen && joinMode != entity.JoinModeApproval && joinMode != entity.JoinModeInvite {
+		http.Error(w, "join_mode must be open, approval or invite", http.StatusBadRequest)
+		return
+	}
+
+	var policy entity.Communitypolicy
+	database.Connector.Where("slug = ?", slug).Find(&policy)
+	policy.Slug = slug
+	policy.Joinmode = joinMode
+	policy.Updatedby = strings.ToLower(Authuser.Address)
+	policy.Timestamp = time.Now()
+	if policy.Id == 0 {
+		database.Connector.Create(&policy)
+	} else {
+		database.Connector.Save(&policy)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(policy)
+}
+
+// checkReplyTo checks a reply's parent is a message of the same group chat, only admins reply to announcements
+func checkReplyTo(chat entity.Groupchatitem, walletaddr string) error {
+	if chat.Replyto == 0 {
+		return nil
+	}
+	parent, found := getGroupMessage(chat.Nftaddr, chat.Replyto)
+	if !found {
+		return errReplyNotFound
+	}
+	if parent.Type == entity.Announcement && !isCommunityAdmin(chat.Nftaddr, walletaddr) {
+		return errAnnouncementReply
+	}
+	return nil
+}
+
+func writeReplyError(w http.ResponseWriter, err error) {
+	status := http.StatusBadRequest
+	if err == errAnnouncementReply {
+		status = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// GetCommunityAnnouncements godoc
+// @Summary     Get the announcements of a community, newest first
+// @Description Announcements are community messages with type "announcement", only admins can post them (POST /v1/community)
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug path    string true "community slug"
+// @Success     200  {array} entity.Groupchatitem
+// @Router      /v1/community/{slug}/announcements [get]
+func GetCommunityAnnouncements(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !canReadCommunity(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+	announcements := []entity.Groupchatitem{}
+	database.Connector.Where("nftaddr = ?", slug).
+		Where("channel IN (?)", readableChannelKeys(slug, Authuser.Address)).
+		Where("type = ?", entity.Announcement).
+		Order("id desc").
+		Limit(communityMembersPageSize).
+		Find(&announcements)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(announcements)
+}
@@ -0,0 +1,73 @@
+package controllers
+
+import (
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+// useTestDB swaps the MySQL connector for an in-memory SQLite database with the given tables
+func useTestDB(t *testing.T, tables ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1) //every connection to :memory: is a database of its own
+	if err := db.AutoMigrate(tables...).Error; err != nil {
+		t.Fatal(err)
+	}
+	saved := database.Connector
+	database.Connector = db
+	t.Cleanup(func() {
+		database.Connector = saved
+		db.Close()
+	})
+}
+
+func TestMemberActionTargetRanks(t *testing.T) {
+	useTestDB(t, &entity.Communityadmin{}, &entity.Communitymember{})
+	const (
+		slug      = "gm"
+		admin     = "0x1111111111111111111111111111111111111111"
+		coAdmin   = "0x2222222222222222222222222222222222222222" //admin rows only, never joined as a member
+		moderator = "0x3333333333333333333333333333333333333333"
+		staffOnly = "0x4444444444444444444444444444444444444444" //moderator rows only
+		member    = "0x5555555555555555555555555555555555555555"
+		outsider  = "0x6666666666666666666666666666666666666666"
+	)
+	database.Connector.Create(&entity.Communityadmin{Slug: slug, Adminaddr: admin, Accesslevel: entity.CommunityRoleAdmin})
+	database.Connector.Create(&entity.Communityadmin{Slug: slug, Adminaddr: coAdmin, Accesslevel: entity.CommunityRoleAdmin})
+	database.Connector.Create(&entity.Communityadmin{Slug: slug, Adminaddr: moderator, Accesslevel: entity.CommunityRoleModerator})
+	database.Connector.Create(&entity.Communityadmin{Slug: slug, Adminaddr: staffOnly, Accesslevel: entity.CommunityRoleModerator})
+	for _, wallet := range []string{admin, moderator, member} {
+		database.Connector.Create(&entity.Communitymember{Slug: slug, Walletaddr: wallet, Status: entity.MemberStatusActive})
+	}
+
+	cases := []struct {
+		name   string
+		actor  string
+		target string
+		want   error
+	}{
+		{"admin on member", admin, member, nil},
+		{"admin on moderator", admin, moderator, nil},
+		{"admin on admin without a member row", admin, coAdmin, errCommunityNotAllowed},
+		{"admin on moderator without a member row", admin, staffOnly, errCommunityNotAMember},
+		{"moderator on member", moderator, member, nil},
+		{"moderator on moderator", moderator, staffOnly, errCommunityNotAllowed},
+		{"moderator on admin", moderator, admin, errCommunityNotAllowed},
+		{"moderator on admin without a member row", moderator, coAdmin, errCommunityNotAllowed},
+		{"moderator on a wallet that never joined", moderator, outsider, errCommunityNotAMember},
+		{"member", member, outsider, errCommunityNotAllowed},
+		{"self", admin, admin, errCommunityNotAllowed},
+	}
+	for _, c := range cases {
+		if _, err := memberActionTarget(slug, c.actor, c.target); err != c.want {
+			t.Errorf("%s: error %v, want %v", c.name, err, c.want)
+		}
+	}
+}
@@ -0,0 +1,317 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gorilla/mux"
+	"golang.org/x/text/runes"
+	"golang.org/x/text/transform"
+	"golang.org/x/text/unicode/norm"
+)
+
+const (
+	communitySlugMaxLength = 48 //runes
+	communitySlugAttempts  = 5  //retries when another request takes the same slug first
+)
+
+var errCommunitySlugTaken = errors.New("community slug is already taken")
+
+// slugs that would clash with app/API paths or mean something else in URLs
+var reservedCommunitySlugs = map[string]bool{
+	"new": true, "create": true, "edit": true, "settings": true, "admin": true, "api": true, "v1": true, "v2": true,
+	"docs": true, "directory": true, "community": true, "communities": true, "members": true, "invites": true,
+	"join": true, "leave": true, "policy": true, "conditions": true, "access": true, "announcements": true,
+	"profile": true, "moderation": true, "pins": true, "slug": true, "search": true, "explore": true,
+	"inbox": true, "dm": true, "nft": true, "poap": true, "me": true, "null": true, "undefined": true,
+}
+
+// CommunitySlugRequest changes the slug of a community
+type CommunitySlugRequest struct {
+	Slug string `json:"slug"`
+}
+
+// CommunitySlugResponse maps a (possibly old) slug to the current one
+type CommunitySlugResponse struct {
+	Slug       string `json:"slug"`
+	Requested  string `json:"requested"`
+	Redirected bool   `json:"redirected"`
+}
+
+// slugifyCommunityName makes a lower case, URL safe slug: accents are dropped (Café -> cafe), other letters and
+// digits are kept in any script, everything else becomes a single "-"
+func slugifyCommunityName(name string) string {
+	stripMarks := transform.Chain(norm.NFKD, runes.Remove(runes.In(unicode.Mn)), norm.NFC)
+	if stripped, _, err := transform.String(stripMarks, name); err == nil {
+		name = stripped
+	}
+
+	var slug strings.Builder
+	length := 0
+	dash := false
+	for _, r := range strings.ToLower(name) {
+		if length >= communitySlugMaxLength {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && length > 0 {
+				slug.WriteRune('-')
+				length++
+			}
+			slug.WriteRune(r)
+			length++
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	result := strings.Trim(slug.String(), "-")
+
+	//0x... and poap_ are NFT/POAP chat addresses
+	if result == "" {
+		result = "community"
+	} else if strings.HasPrefix(result, "0x") {
+		result = "c-" + result
+	}
+	return result
+}
+
+// communitySlugTaken checks every place a slug can already be in use, including wallet/NFT names
+// in the shared Addrnameitem table and the old slugs of renamed communities
+func communitySlugTaken(slug string) bool {
+	if reservedCommunitySlugs[slug] {
+		return true
+	}
+	var communities []entity.Communityitem
+	if database.Connector.Where("slug = ?", slug).Limit(1).Find(&communities).RowsAffected > 0 {
+		return true
+	}
+	var aliases []entity.Communityalias
+	if database.Connector.Where("oldslug = ?", slug).Limit(1).Find(&aliases).RowsAffected > 0 {
+		return true
+	}
+	var admins []entity.Communityadmin
+	if database.Connector.Where("slug = ?", slug).Limit(1).Find(&admins).RowsAffected > 0 {
+		return true
+	}
+	var addrnames []entity.Addrnameitem
+	return database.Connector.Where("address = ?", slug).Limit(1).Find(&addrnames).RowsAffected > 0
+}
+
+// uniqueCommunitySlug returns base, or base-2, base-3... for the first one that is free
+func uniqueCommunitySlug(base string) string {
+	slug := base
+	for i := 2; communitySlugTaken(slug); i++ {
+		suffix := "-" + strconv.Itoa(i)
+		trimmed := []rune(base)
+		if len(trimmed)+len(suffix) > communitySlugMaxLength {
+			trimmed = trimmed[:communitySlugMaxLength-len(suffix)]
+		}
+		slug = strings.TrimRight(string(trimmed), "-") + suffix
+	}
+	return slug
+}
+
+// createCommunityRecord picks a free slug for the name and stores the community,
+// the unique index makes a concurrent create with the same slug fail so it retries with the next one
+func createCommunityRecord(name string, creator string) (entity.Communityitem, error) {
+	base := slugifyCommunityName(name)
+	var err error
+	for attempt := 0; attempt < communitySlugAttempts; attempt++ {
+		community := entity.Communityitem{
+			Slug:      uniqueCommunitySlug(base),
+			Name:      name,
+			Createdby: strings.ToLower(creator),
+			Timestamp: time.Now(),
+		}
+		if err = database.Connector.Create(&community).Error; err == nil {
+			return community, nil
+		}
+		log.Println("createCommunityRecord retry: ", community.Slug, err)
+	}
+	return entity.Communityitem{}, err
+}
+
+// ResolveCommunitySlug follows the alias of a renamed community, returns the slug itself when it is current
+func ResolveCommunitySlug(slug string) string {
+	var alias entity.Communityalias
+	if database.Connector.Where("oldslug = ?", slug).Find(&alias).RowsAffected > 0 {
+		return alias.Slug
+	}
+	return slug
+}
+
+// every table that stores a community slug, as table/column
+var communitySlugColumns = [][2]string{
+	{"communityitems", "slug"},
+	{"addrnameitems", "address"},
+	{"imageitems", "addr"},
+	{"communitysocials", "community"},
+	{"communityadmins", "slug"},
+	{"communitymembers", "slug"},
+	{"communityinvites", "slug"},
+	{"communitypolicies", "slug"},
+	{"communityprofiles", "slug"},
+	{"communityaccessconditions", "slug"},
+	{"communitychannels", "slug"},
+	{"groupchatitems", "nftaddr"},
+	{"groupchatreadtimes", "nftaddr"},
+	{"bookmarkitems", "nftaddr"},
+	{"userunjoineds", "nftaddr"},
+	{"moderationlogs", "groupaddr"},
+	{"groupmutes", "groupaddr"},
+	{"groupmoderations", "groupaddr"},
+	{"pinnedmessages", "groupaddr"},
+	{"messagereports", "groupaddr"},
+	{"moderators", "groupaddr"},
+	{"chatfilters", "groupaddr"},
+}
+
+// renameCommunitySlug moves everything keyed by the old slug to the new one in one transaction and leaves an alias
+// behind, aliases that pointed to the old slug are moved along so there are never redirect chains
+func renameCommunitySlug(oldSlug string, newSlug string, actor string) error {
+	tx := database.Connector.Begin()
+	for _, column := range communitySlugColumns {
+		if err := tx.Exec("UPDATE "+column[0]+" SET "+column[1]+" = ? WHERE "+column[1]+" = ?", newSlug, oldSlug).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	if err := tx.Model(&entity.Notificationpreference{}).
+		Where("contexttype = ?", entity.Community).
+		Where("target = ?", strings.ToLower(oldSlug)).
+		UpdateColumn("target", strings.ToLower(newSlug)).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	tx.Where("oldslug = ?", newSlug).Delete(&entity.Communityalias{})
+	tx.Model(&entity.Communityalias{}).Where("slug = ?", oldSlug).UpdateColumn("slug", newSlug)
+	alias := entity.Communityalias{Oldslug: oldSlug, Slug: newSlug, Changedby: strings.ToLower(actor), Timestamp: time.Now()}
+	if err := tx.Create(&alias).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	invalidateAccessCache(oldSlug)
+	invalidateDirectoryCache()
+	return nil
+}
+
+// BackfillCommunities creates Community rows for communities from before the table existed (their slugs stay as they are)
+func BackfillCommunities() {
+	var slugs []string
+	database.Connector.Model(&entity.Communityadmin{}).Pluck("DISTINCT slug", &slugs)
+
+	created := 0
+	for _, slug := range slugs {
+		if slug == "" || strings.HasPrefix(strings.ToLower(slug), "0x") {
+			continue
+		}
+		var existing []entity.Communityitem
+		if database.Connector.Where("slug = ?", slug).Limit(1).Find(&existing).RowsAffected > 0 {
+			continue
+		}
+		var addrname entity.Addrnameitem
+		database.Connector.Where("address = ?", slug).Find(&addrname)
+		community := entity.Communityitem{Slug: slug, Name: addrname.Name, Timestamp: time.Now()}
+		if database.Connector.Create(&community).Error == nil {
+			created++
+		}
+	}
+	log.Println("Backfilled communities: ", created)
+}
+
+// ============================================================================
+
+// GetCommunitySlug godoc
+// @Summary     Resolve a community slug, old slugs of renamed communities return the current one
+// @Tags        GroupChat
+// @Produce     json
+// @Param       slug path     string true "community slug (current or old)"
+// @Success     200  {object} CommunitySlugResponse
+// @Failure     404
+// @Router      /community_slug/{slug} [get]
+func GetCommunitySlug(w http.ResponseWriter, r *http.Request) {
+	requested := mux.Vars(r)["slug"]
+	slug := ResolveCommunitySlug(requested)
+	if !communityExists(slug) {
+		writeCommunityError(w, errCommunityNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(CommunitySlugResponse{Slug: slug, Requested: requested, Redirected: slug != requested})
+}
+
+// ChangeCommunitySlug godoc
+// @Summary     Change the slug (URL) of a community, admins only
+// @Description The requested slug is slugified (lower case, dashes), the old slug keeps redirecting to the new one
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string               true "current community slug"
+// @Param       message body     CommunitySlugRequest true "new slug"
+// @Success     200     {object} CommunitySlugResponse
+// @Failure     409
+// @Router      /v1/community/{slug}/slug [put]
+func ChangeCommunitySlug(w http.ResponseWriter, r *http.Request) {
+	oldSlug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var slugRequest CommunitySlugRequest
+	if err := json.Unmarshal(requestBody, &slugRequest); err != nil || strings.TrimSpace(slugRequest.Slug) == "" {
+		http.Error(w, "slug is required", http.StatusBadRequest)
+		return
+	}
+	if !communityExists(oldSlug) {
+		writeCommunityError(w, errCommunityNotFound)
+		return
+	}
+	if !isCommunityAdmin(oldSlug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+
+	newSlug := slugifyCommunityName(slugRequest.Slug)
+	if newSlug == oldSlug {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		json.NewEncoder(w).Encode(CommunitySlugResponse{Slug: newSlug, Requested: slugRequest.Slug})
+		return
+	}
+	//a community may take back one of its own old slugs
+	var ownAlias entity.Communityalias
+	isOwnAlias := database.Connector.Where("oldslug = ?", newSlug).Where("slug = ?", oldSlug).Find(&ownAlias).RowsAffected > 0
+	if (!isOwnAlias && communitySlugTaken(newSlug)) || reservedCommunitySlugs[newSlug] {
+		http.Error(w, errCommunitySlugTaken.Error(), http.StatusConflict)
+		return
+	}
+
+	if err := renameCommunitySlug(oldSlug, newSlug, Authuser.Address); err != nil {
+		log.Println("ChangeCommunitySlug error: ", err)
+		http.Error(w, errCommunitySlugTaken.Error(), http.StatusConflict)
+		return
+	}
+	log.Println("Community slug changed: ", oldSlug, "->", newSlug, "by", Authuser.Address)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(CommunitySlugResponse{Slug: newSlug, Requested: slugRequest.Slug})
+}
@@ -0,0 +1,530 @@
+package controllers
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	directoryCacheTtl   = 5 * time.Minute
+	directoryPageSize   = 50
+	directoryMaxPage    = 200
+	directoryMaxTags    = 10
+	directoryMaxTagSize = 32
+)
+
+// directory entry types
+const (
+	directoryTypeCommunity = "community"
+	directoryTypeNft       = "nft"
+	directoryTypePoap      = "poap"
+)
+
+// DirectoryCategories are the categories admins can pick for their community
+var DirectoryCategories = []string{"art", "dao", "defi", "developers", "education", "gaming", "music", "nft", "social", "support", "other"}
+
+// DirectoryEntry is one community or NFT/POAP group chat in the public directory
+type DirectoryEntry struct {
+	Type         string     `json:"type"` //community, nft, poap
+	Slug         string     `json:"slug"` //community slug or NFT contract / poap_ event, same as nftaddr in messages
+	Name         string     `json:"name"`
+	Category     string     `json:"category"`
+	Tags         []string   `json:"tags"`
+	Verified     bool       `json:"is_verified"`
+	JoinMode     string     `json:"join_mode,omitempty"` //communities only
+	Gated        bool       `json:"gated"`
+	MemberCount  int        `json:"member_count"`
+	MessageCount int        `json:"message_count"`
+	LastActivity *time.Time `json:"last_activity"`
+}
+
+// DirectoryPage is one page of GET /directory
+type DirectoryPage struct {
+	Entries []DirectoryEntry `json:"entries"`
+	Total   int              `json:"total"`
+	Page    int              `json:"page"`
+	Limit   int              `json:"limit"`
+}
+
+// CommunityProfileRequest sets how a community/NFT group chat is listed in the directory
+type CommunityProfileRequest struct {
+	Category string   `json:"category"`
+	Tags     []string `json:"tags"`
+	Unlisted bool     `json:"unlisted"`
+}
+
+// CommunityVerifiedRequest sets the verified badge (WalletChat admin API key only)
+type CommunityVerifiedRequest struct {
+	Verified bool `json:"verified"`
+}
+
+// the directory is built from a few GROUP BY queries over the whole message table, so it is cached
+var (
+	directoryCache      []DirectoryEntry
+	directoryCacheBuilt time.Time
+	directoryCacheMu    sync.Mutex
+)
+
+func directoryType(slug string) string {
+	lower := strings.ToLower(slug)
+	if strings.HasPrefix(lower, "0x") {
+		return directoryTypeNft
+	}
+	if strings.HasPrefix(lower, "poap_") {
+		return directoryTypePoap
+	}
+	return directoryTypeCommunity
+}
+
+func getCommunityProfile(slug string) (entity.Communityprofile, bool) {
+	var profile entity.Communityprofile
+	dbQuery := database.Connector.Where("slug = ?", slug).Find(&profile)
+	return profile, dbQuery.RowsAffected > 0
+}
+
+// IsCommunityVerified - the verified badge is set by WalletChat per community/NFT chat
+func IsCommunityVerified(slug string) bool {
+	profile, found := getCommunityProfile(slug)
+	return found && profile.Verified
+}
+
+// SeedVerifiedCommunities creates the profile of WalletChat's own community with the verified badge,
+// it was always shown as verified before badges were stored. An existing profile is left as is
+func SeedVerifiedCommunities() {
+	var profile entity.Communityprofile
+	seeded := entity.Communityprofile{Verified: true, Verifiedby: "walletchat", Updatedby: "walletchat", Timestamp: time.Now()}
+	if err := database.Connector.Where("slug = ?", "walletchat").
+		Attrs(seeded).
+		FirstOrCreate(&profile, entity.Communityprofile{Slug: "walletchat"}).Error; err != nil {
+		log.Println("SeedVerifiedCommunities error: ", err)
+	}
+}
+
+// isAdminApiRequest checks the request uses a WalletChat admin API key instead of a wallet JWT
+func isAdminApiRequest(r *http.Request) bool {
+	apiKey := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(apiKey, prefix) {
+		return false
+	}
+	return auth.IsAdminApiKey(apiKey[len(prefix):])
+}
+
+// canEditCommunityProfile - community admins, NFT/POAP chats have no admins so their moderators
+func canEditCommunityProfile(slug string, walletaddr string) bool {
+	role := moderatorRole(slug, walletaddr)
+	if directoryType(slug) == directoryTypeCommunity {
+		return role == entity.CommunityRoleAdmin
+	}
+	return role != ""
+}
+
+func invalidateDirectoryCache() {
+	directoryCacheMu.Lock()
+	directoryCacheBuilt = time.Time{}
+	directoryCacheMu.Unlock()
+}
+
+// buildDirectory lists communities created with CreateCommunity (they have Communityadmin rows)
+// and NFT/POAP chats that have messages, with member counts from Bookmarkitem
+func buildDirectory() []DirectoryEntry {
+	type messageStats struct {
+		Nftaddr      string
+		Messages     int
+		Lastactivity time.Time
+	}
+	var stats []messageStats
+	database.Connector.Raw(`SELECT nftaddr, COUNT(*) AS messages, MAX(timestamp_dtm) AS lastactivity
+		FROM groupchatitems WHERE type <> ? GROUP BY nftaddr`, entity.Welcome).Scan(&stats)
+
+	type memberStats struct {
+		Nftaddr string
+		Members int
+	}
+	var members []memberStats
+	database.Connector.Raw(`SELECT nftaddr, COUNT(*) AS members FROM bookmarkitems GROUP BY nftaddr`).Scan(&members)
+	memberCounts := map[string]int{}
+	for _, count := range members {
+		memberCounts[strings.ToLower(count.Nftaddr)] += count.Members
+	}
+
+	entries := map[string]*DirectoryEntry{}
+	addEntry := func(slug string) *DirectoryEntry {
+		key := strings.ToLower(slug)
+		if entry, found := entries[key]; found {
+			return entry
+		}
+		entry := &DirectoryEntry{
+			Type:        directoryType(slug),
+			Slug:        slug,
+			Tags:        []string{},
+			MemberCount: memberCounts[key],
+		}
+		entries[key] = entry
+		return entry
+	}
+
+	var slugs []string
+	database.Connector.Model(&entity.Communityadmin{}).Pluck("DISTINCT slug", &slugs)
+	for _, slug := range slugs {
+		if directoryType(slug) == directoryTypeCommunity {
+			addEntry(slug)
+		}
+	}
+	for _, stat := range stats {
+		entry, found := entries[strings.ToLower(stat.Nftaddr)]
+		if !found {
+			//communities only come from CreateCommunity, anything else with messages is an NFT/POAP chat
+			if directoryType(stat.Nftaddr) == directoryTypeCommunity {
+				continue
+			}
+			entry = addEntry(stat.Nftaddr)
+		}
+		lastActivity := stat.Lastactivity
+		entry.MessageCount += stat.Messages
+		if entry.LastActivity == nil || lastActivity.After(*entry.LastActivity) {
+			entry.LastActivity = &lastActivity
+		}
+	}
+
+	var profiles []entity.Communityprofile
+	database.Connector.Find(&profiles)
+	unlisted := map[string]bool{}
+	for _, profile := range profiles {
+		entry, found := entries[strings.ToLower(profile.Slug)]
+		if !found {
+			continue
+		}
+		if profile.Unlisted {
+			unlisted[strings.ToLower(profile.Slug)] = true
+			continue
+		}
+		entry.Category = profile.Category
+		entry.Tags = splitFilterList(profile.Tags)
+		entry.Verified = profile.Verified
+	}
+
+	var policies []entity.Communitypolicy
+	database.Connector.Find(&policies)
+	joinModes := map[string]string{}
+	for _, policy := range policies {
+		joinModes[strings.ToLower(policy.Slug)] = policy.Joinmode
+	}
+	var gated []string
+	database.Connector.Model(&entity.Communityaccesscondition{}).Where("channel = ?", "").Pluck("DISTINCT slug", &gated)
+	gatedSlugs := map[string]bool{}
+	for _, slug := range gated {
+		gatedSlugs[strings.ToLower(slug)] = true
+	}
+
+	addresses := []string{}
+	for _, entry := range entries {
+		addresses = append(addresses, entry.Slug)
+	}
+	names := map[string]string{}
+	if len(addresses) > 0 {
+		var addrnames []entity.Addrnameitem
+		database.Connector.Where("address IN (?)", addresses).Find(&addrnames)
+		for _, addrname := range addrnames {
+			names[strings.ToLower(addrname.Address)] = addrname.Name
+		}
+	}
+
+	directory := []DirectoryEntry{}
+	for key, entry := range entries {
+		if unlisted[key] {
+			continue
+		}
+		entry.Name = names[key]
+		if entry.Name == "" {
+			entry.Name = entry.Slug
+		}
+		entry.Gated = gatedSlugs[key]
+		if entry.Type == directoryTypeCommunity {
+			entry.JoinMode = joinModes[key]
+			if entry.JoinMode == "" {
+				entry.JoinMode = entity.JoinModeOpen
+			}
+		}
+		directory = append(directory, *entry)
+	}
+	return directory
+}
+
+// getDirectory returns the cached directory, rebuilding it when it is older than directoryCacheTtl
+func getDirectory() []DirectoryEntry {
+	directoryCacheMu.Lock()
+	defer directoryCacheMu.Unlock()
+	if directoryCache == nil || time.Since(directoryCacheBuilt) > directoryCacheTtl {
+		directoryCache = buildDirectory()
+		directoryCacheBuilt = time.Now()
+		log.Println("Directory rebuilt: ", len(directoryCache))
+	}
+	return directoryCache
+}
+
+func directoryEntryMatches(entry DirectoryEntry, query string, entryType string, category string, tag string, verifiedOnly bool) bool {
+	if entryType != "" && entry.Type != entryType {
+		return false
+	}
+	if category != "" && entry.Category != category {
+		return false
+	}
+	if verifiedOnly && !entry.Verified {
+		return false
+	}
+	if tag != "" {
+		hasTag := false
+		for _, entryTag := range entry.Tags {
+			if entryTag == tag {
+				hasTag = true
+				break
+			}
+		}
+		if !hasTag {
+			return false
+		}
+	}
+	return query == "" ||
+		strings.Contains(strings.ToLower(entry.Name), query) ||
+		strings.Contains(strings.ToLower(entry.Slug), query)
+}
+
+func lastActivityUnix(entry DirectoryEntry) int64 {
+	if entry.LastActivity == nil {
+		return 0
+	}
+	return entry.LastActivity.Unix()
+}
+
+// ============================================================================
+
+// GetDirectory godoc
+// @Summary     Public directory of communities and active NFT/POAP group chats
+// @Description Search by name, filter by type/category/tag and sort by members (joined via bookmark), recent activity or message volume
+// @Description The directory is rebuilt every 5 minutes
+// @Tags        GroupChat
+// @Produce     json
+// @Param       q        query    string false "search in name and slug"
+// @Param       type     query    string false "community, nft or poap"
+// @Param       category query    string false "category (see /directory/categories)"
+// @Param       tag      query    string false "tag"
+// @Param       verified query    bool   false "only verified"
+// @Param       sort     query    string false "members (default), activity, messages or name"
+// @Param       page     query    int    false "page number, starts at 1"
+// @Param       limit    query    int    false "page size (default 50, max 200)"
+// @Success     200      {object} DirectoryPage
+// @Router      /directory [get]
+func GetDirectory(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	search := strings.ToLower(strings.TrimSpace(query.Get("q")))
+	entryType := strings.ToLower(query.Get("type"))
+	category := strings.ToLower(query.Get("category"))
+	tag := strings.ToLower(strings.TrimSpace(query.Get("tag")))
+	verifiedOnly := strings.EqualFold(query.Get("verified"), "true")
+
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = "members"
+	}
+	if sortBy != "members" && sortBy != "activity" && sortBy != "messages" && sortBy != "name" {
+		http.Error(w, "sort must be members, activity, messages or name", http.StatusBadRequest)
+		return
+	}
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > directoryMaxPage {
+		limit = directoryPageSize
+	}
+
+	matches := []DirectoryEntry{}
+	for _, entry := range getDirectory() {
+		if directoryEntryMatches(entry, search, entryType, category, tag, verifiedOnly) {
+			matches = append(matches, entry)
+		}
+	}
+
+	//verified first on ties, then name so pages are stable
+	sort.SliceStable(matches, func(i, j int) bool {
+		a, b := matches[i], matches[j]
+		switch sortBy {
+		case "activity":
+			if lastActivityUnix(a) != lastActivityUnix(b) {
+				return lastActivityUnix(a) > lastActivityUnix(b)
+			}
+		case "messages":
+			if a.MessageCount != b.MessageCount {
+				return a.MessageCount > b.MessageCount
+			}
+		case "members":
+			if a.MemberCount != b.MemberCount {
+				return a.MemberCount > b.MemberCount
+			}
+		}
+		if a.Verified != b.Verified {
+			return a.Verified
+		}
+		return strings.ToLower(a.Name) < strings.ToLower(b.Name)
+	})
+
+	result := DirectoryPage{Entries: []DirectoryEntry{}, Total: len(matches), Page: page, Limit: limit}
+	start := (page - 1) * limit
+	if start < len(matches) {
+		end := start + limit
+		if end > len(matches) {
+			end = len(matches)
+		}
+		result.Entries = matches[start:end]
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetDirectoryCategories godoc
+// @Summary     Categories a community can be listed under
+// @Tags        GroupChat
+// @Produce     json
+// @Success     200 {array} string
+// @Router      /directory/categories [get]
+func GetDirectoryCategories(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(DirectoryCategories)
+}
+
+// SetCommunityProfile godoc
+// @Summary     Set the directory category and tags of a community (admins) or NFT/POAP chat (moderators)
+// @Description Up to 10 tags of at most 32 characters, unlisted hides the chat from the directory
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string                  true "community slug or NFT/POAP address"
+// @Param       message body     CommunityProfileRequest true "directory listing"
+// @Success     200     {object} entity.Communityprofile
+// @Router      /v1/community/{slug}/profile [put]
+func SetCommunityProfile(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var profileRequest CommunityProfileRequest
+	if err := json.Unmarshal(requestBody, &profileRequest); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !canEditCommunityProfile(slug, Authuser.Address) {
+		writeCommunityError(w, errCommunityNotAllowed)
+		return
+	}
+
+	category := strings.ToLower(strings.TrimSpace(profileRequest.Category))
+	validCategory := category == ""
+	for _, known := range DirectoryCategories {
+		if category == known {
+			validCategory = true
+		}
+	}
+	if !validCategory {
+		http.Error(w, "category must be one of "+strings.Join(DirectoryCategories, ", "), http.StatusBadRequest)
+		return
+	}
+	tags, err := normalizeFilterList(profileRequest.Tags, false)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(tags) > directoryMaxTags {
+		http.Error(w, "at most 10 tags", http.StatusBadRequest)
+		return
+	}
+	for _, tag := range tags {
+		if len(tag) > directoryMaxTagSize {
+			http.Error(w, "tags are limited to 32 characters: "+tag, http.StatusBadRequest)
+			return
+		}
+	}
+
+	profile, _ := getCommunityProfile(slug)
+	profile.Slug = slug
+	profile.Category = category
+	profile.Tags = strings.Join(tags, ",")
+	profile.Unlisted = profileRequest.Unlisted
+	profile.Updatedby = strings.ToLower(Authuser.Address)
+	profile.Timestamp = time.Now()
+	if profile.Id == 0 {
+		database.Connector.Create(&profile)
+	} else {
+		database.Connector.Save(&profile)
+	}
+	invalidateDirectoryCache()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// SetCommunityVerified godoc
+// @Summary     Give or remove the verified badge of a community/NFT chat
+// @Description Only for WalletChat, requires an admin API key as the Bearer token
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       slug    path     string                   true "community slug or NFT/POAP address"
+// @Param       message body     CommunityVerifiedRequest true "verified"
+// @Success     200     {object} entity.Communityprofile
+// @Router      /v1/directory/{slug}/verified [put]
+func SetCommunityVerified(w http.ResponseWriter, r *http.Request) {
+	slug := mux.Vars(r)["slug"]
+	if !isAdminApiRequest(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var verifiedRequest CommunityVerifiedRequest
+	if err := json.Unmarshal(requestBody, &verifiedRequest); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	profile, _ := getCommunityProfile(slug)
+	profile.Slug = slug
+	profile.Verified = verifiedRequest.Verified
+	profile.Verifiedby = ""
+	if verifiedRequest.Verified {
+		profile.Verifiedby = Authuser.Address //short API key id
+	}
+	profile.Timestamp = time.Now()
+	if profile.Id == 0 {
+		database.Connector.Create(&profile)
+	} else {
+		database.Connector.Save(&profile)
+	}
+	invalidateDirectoryCache()
+	log.Println("Community verified: ", slug, verifiedRequest.Verified, "by", Authuser.Address)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(profile)
+}
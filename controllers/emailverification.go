@@ -0,0 +1,298 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/email"
+	"rest-go-demo/entity"
+	"rest-go-demo/webhooks"
+	"strings"
+	"time"
+
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/gorilla/mux"
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+const (
+	emailVerificationCodeLength = 10
+	emailVerificationTtl        = 24 * time.Hour
+	emailVerificationIpBurst    = 10          //verify requests per IP before they are limited to one a minute
+	emailVerificationResendGap  = time.Minute //minimum time between verification emails
+	emailVerificationDailyMax   = 5           //verification emails per wallet per 24h
+)
+
+var errVerificationRateLimited = errors.New("too many verification emails, please try again later")
+
+// guesses are limited per IP, counting them against the code would let anyone lock the owner out with a few requests
+var verifyEmailLimiter = tollbooth.NewLimiter(1.0/60, &limiter.ExpirableOptions{DefaultExpirationTTL: time.Hour}).
+	SetBurst(emailVerificationIpBurst).
+	SetIPLookups([]string{"RemoteAddr", "X-Forwarded-For", "X-Real-IP"}).
+	SetIgnoreURL(true).
+	SetMessage("too many verification attempts, please try again later")
+
+// no 0/O or 1/I so codes can be typed from the email, 32 symbols so a random byte maps without bias
+const verificationAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+func newVerificationCode() (string, error) {
+	return randomCode(emailVerificationCodeLength)
+}
+
+// randomCode is a crypto/rand code that is easy to read and type (also used for invite links)
+func randomCode(length int) (string, error) {
+	b := make([]byte, length)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = verificationAlphabet[int(b[i])%len(verificationAlphabet)]
+	}
+	return string(b), nil
+}
+
+// only the hash is stored, tied to the email so a code can't be used for another address
+func hashVerificationCode(toEmail string, code string) string {
+	sum := sha256.Sum256([]byte(strings.ToLower(strings.TrimSpace(toEmail)) + ":" + strings.ToUpper(strings.TrimSpace(code))))
+	return hex.EncodeToString(sum[:])
+}
+
+// maskEmail shows enough of an address to recognize it (ab***@example.com)
+func maskEmail(address string) string {
+	at := strings.LastIndex(address, "@")
+	if at < 1 {
+		return "***"
+	}
+	visible := 2
+	if at < 3 {
+		visible = 1
+	}
+	return address[:visible] + "***" + address[at:]
+}
+
+// issueEmailVerification replaces any pending token for the wallet, marks the email unverified and sends a new code
+func issueEmailVerification(walletaddr string, toEmail string, signupsite string, domain string, locale string) error {
+	walletaddr = strings.ToLower(walletaddr)
+
+	var recent []entity.Emailverification
+	database.Connector.Where("walletaddr = ?", walletaddr).
+		Where("timestamp > ?", time.Now().Add(-24*time.Hour)).
+		Order("timestamp desc").
+		Find(&recent)
+	if len(recent) >= emailVerificationDailyMax ||
+		(len(recent) > 0 && time.Since(recent[0].Timestamp) < emailVerificationResendGap) {
+		return errVerificationRateLimited
+	}
+
+	code, err := newVerificationCode()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	database.Connector.Model(&entity.Emailverification{}).
+		Where("walletaddr = ?", walletaddr).
+		Where("usedat IS NULL").
+		Update("usedat", now)
+
+	verification := entity.Emailverification{
+		Walletaddr: walletaddr,
+		Email:      strings.ToLower(strings.TrimSpace(toEmail)),
+		Tokenhash:  hashVerificationCode(toEmail, code),
+		Expires:    now.Add(emailVerificationTtl),
+		Timestamp:  now,
+	}
+	if err := database.Connector.Create(&verification).Error; err != nil {
+		return err
+	}
+	database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", walletaddr).Update("verified", "false")
+
+	var toAddrname entity.Addrnameitem
+	dbResults := database.Connector.Where("address = ?", walletaddr).Find(&toAddrname)
+	if dbResults.RowsAffected == 0 {
+		log.Println("Did not find addrname item for: ", walletaddr)
+		toAddrname.Address = walletaddr
+	}
+	sendVerificationEmail(toAddrname, toEmail, code, signupsite, domain, locale)
+	return nil
+}
+
+// sendVerificationEmail sends the verification code, branded for the site the email was entered on
+func sendVerificationEmail(toAddrname entity.Addrnameitem, toEmail string, verificationCode string, signupsite string, domain string, locale string) {
+	verifyUrl := ""
+	if domain != "" {
+		if !strings.HasPrefix(domain, "http") {
+			domain = "https://" + domain
+		}
+		verifyUrl = strings.TrimSuffix(domain, "/") + "/verify-email?email=" + url.QueryEscape(toEmail) + "&code=" + verificationCode
+	}
+
+	rendered, err := email.RenderVerify(locale, signupsite, email.VerifyData{
+		ToAddress:  toAddrname.Address,
+		ToName:     toAddrname.Name,
+		Signupsite: signupsite,
+		Code:       verificationCode,
+		VerifyUrl:  verifyUrl,
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	from := mail.NewEmail(rendered.Brand.FromName, "contact@walletchat.fun")
+	to := mail.NewEmail(toAddrname.Name, toEmail)
+	message := mail.NewSingleEmail(from, rendered.Subject, to, rendered.Text, rendered.Html)
+	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
+	response, err := client.Send(message)
+	if err != nil {
+		log.Println(err)
+	} else {
+		_ = response
+	}
+}
+
+// notifyEmailChanged tells the previous (verified) address that notifications now go somewhere else
+func notifyEmailChanged(settings entity.Settings, newEmail string) {
+	if !strings.Contains(settings.Email, "@") || !strings.EqualFold(settings.Verified, "true") ||
+		strings.EqualFold(strings.TrimSpace(settings.Email), strings.TrimSpace(newEmail)) {
+		return
+	}
+
+	var addrname entity.Addrnameitem
+	database.Connector.Where("address = ?", settings.Walletaddr).Find(&addrname)
+	rendered, err := email.RenderEmailChanged(settings.Locale, settings.Signupsite, email.EmailChangedData{
+		ToAddress: settings.Walletaddr,
+		ToName:    addrname.Name,
+		NewEmail:  maskEmail(newEmail),
+	})
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	from := mail.NewEmail(rendered.Brand.FromName, "contact@walletchat.fun")
+	to := mail.NewEmail(addrname.Name, settings.Email)
+	message := mail.NewSingleEmail(from, rendered.Subject, to, rendered.Text, rendered.Html)
+	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
+	if _, err := client.Send(message); err != nil {
+		log.Println(err)
+	}
+}
+
+// VerifyEmail godoc
+// @Summary     Link a user can click in email to verify email address, will have unique code
+// @Description Users will get an email when signing-up to verify email, to ensure we do not send spam
+// @Description Codes expire after 24 hours and can only be used once, requests are rate limited per IP
+// @Tags        Common
+// @Produce     json
+// @Param       email path string true "Email address the code was sent to"
+// @Param       code  path string true "Verification code from the email"
+// @Success     200
+// @Router      /verify_email/{email}/{code} [get]
+func VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	toEmail := strings.ToLower(strings.TrimSpace(vars["email"]))
+	code := vars["code"]
+	log.Println("Verify Email: ", toEmail)
+	if httpError := tollbooth.LimitByRequest(verifyEmailLimiter, w, r); httpError != nil {
+		http.Error(w, httpError.Message, httpError.StatusCode)
+		return
+	}
+
+	var pending []entity.Emailverification
+	database.Connector.Where("email = ?", toEmail).
+		Where("usedat IS NULL").
+		Where("expires > ?", time.Now()).
+		Find(&pending)
+	if len(pending) == 0 {
+		http.Error(w, "verification code expired or invalid, please request a new one", http.StatusForbidden)
+		return
+	}
+
+	codeHash := hashVerificationCode(toEmail, code)
+	for _, verification := range pending {
+		if subtle.ConstantTimeCompare([]byte(verification.Tokenhash), []byte(codeHash)) != 1 {
+			continue
+		}
+
+		//single use - only the request that flips usedat gets to verify
+		now := time.Now()
+		claim := database.Connector.Model(&entity.Emailverification{}).
+			Where("id = ?", verification.Id).
+			Where("usedat IS NULL").
+			Update("usedat", now)
+		if claim.RowsAffected == 0 {
+			break
+		}
+
+		//the wallet may have changed its email since the code was sent
+		dbResults := database.Connector.Model(&entity.Settings{}).
+			Where("walletaddr = ?", verification.Walletaddr).
+			Where("email = ?", verification.Email).
+			Update("verified", "true")
+		if dbResults.RowsAffected == 0 {
+			break
+		}
+		log.Println("Verified Email for: ", verification.Walletaddr)
+		auditSettingsField(verification.Walletaddr, settingsAuditSourceSystem, settingsAuditSourceSystem, "email_verified", "false", "true")
+		webhooks.EmitForWallet(entity.WebhookEmailVerified, verification.Walletaddr, map[string]string{
+			"walletaddr": verification.Walletaddr,
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(dbResults.RowsAffected)
+		return
+	}
+
+	w.WriteHeader(http.StatusForbidden)
+}
+
+// ResendVerificationEmail godoc
+// @Summary     Send a new email verification code
+// @Description Limited to one email per minute and 5 per day, any previous code stops working
+// @Tags        Common
+// @Produce     json
+// @Security    BearerAuth
+// @Success     202
+// @Failure     429
+// @Router      /v1/resend_verification_email [post]
+func ResendVerificationEmail(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+
+	var settings entity.Settings
+	dbResults := database.Connector.Where("walletaddr = ?", strings.ToLower(Authuser.Address)).Find(&settings)
+	if dbResults.RowsAffected == 0 || !strings.Contains(settings.Email, "@") {
+		http.Error(w, "no email address in settings", http.StatusBadRequest)
+		return
+	}
+	if strings.EqualFold(settings.Verified, "true") {
+		http.Error(w, "email already verified", http.StatusConflict)
+		return
+	}
+
+	signupsite := settings.Signupsite
+	if signupsite == "" {
+		signupsite = settings.Domain
+	}
+	err := issueEmailVerification(settings.Walletaddr, settings.Email, signupsite, settings.Domain, settings.Locale)
+	if errors.Is(err, errVerificationRateLimited) {
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	} else if err != nil {
+		log.Println("ResendVerificationEmail error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
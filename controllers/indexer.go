@@ -0,0 +1,195 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/indexer"
+	"rest-go-demo/onchain"
+	"rest-go-demo/webhooks"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+const (
+	indexerPollTimeout          = 2 * time.Minute
+	defaultIndexerConfirmations = 3
+)
+
+var (
+	transferIndexersMutex sync.RWMutex
+	transferIndexers      []*indexer.Indexer
+)
+
+// InitIndexers starts following Transfer events on the chains in INDEXER_CHAINS (ie "ethereum,polygon"), each needs
+// an ONCHAIN_RPC_<CHAIN> endpoint. Unset leaves membership to the auto-join worker alone.
+// Only one replica should set it, INDEXER_CONFIRMATIONS is how many blocks to stay behind the head (default 3).
+func InitIndexers() {
+	indexers := []*indexer.Indexer{}
+	confirmations := uint64(defaultIndexerConfirmations)
+	if value, err := strconv.ParseUint(os.Getenv("INDEXER_CONFIRMATIONS"), 10, 64); err == nil {
+		confirmations = value
+	}
+
+	for _, name := range strings.Split(os.Getenv("INDEXER_CHAINS"), ",") {
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		chain := onchain.NormalizeChain(name)
+		if !onchain.IsEvmChain(chain) {
+			fmt.Println("indexer - not an enabled EVM chain, skipped: ", name)
+			continue
+		}
+		rpcUrl, ok := onchain.RpcUrl(chain)
+		if !ok {
+			fmt.Println("indexer - no ONCHAIN_RPC_ endpoint, skipped: ", chain)
+			continue
+		}
+		client, err := ethclient.Dial(rpcUrl)
+		if err != nil {
+			fmt.Println("indexer - could not connect, skipped: ", chain, err)
+			continue
+		}
+
+		indexerChain := chain
+		indexers = append(indexers, &indexer.Indexer{
+			Chain:         indexerChain,
+			Client:        client,
+			Store:         indexer.DbStore{},
+			Contracts:     func() []string { return indexedContracts(indexerChain) },
+			Handle:        func(transfers []indexer.Transfer) { applyIndexedTransfers(indexerChain, transfers) },
+			Confirmations: confirmations,
+		})
+		fmt.Println("indexer - following transfers on ", chain)
+	}
+
+	transferIndexersMutex.Lock()
+	transferIndexers = indexers
+	transferIndexersMutex.Unlock()
+}
+
+// PollIndexers catches every chain's indexer up with the head, run on a schedule
+func PollIndexers() {
+	transferIndexersMutex.RLock()
+	indexers := transferIndexers
+	transferIndexersMutex.RUnlock()
+	for _, transferIndexer := range indexers {
+		ctx, cancel := context.WithTimeout(context.Background(), indexerPollTimeout)
+		handled, err := transferIndexer.Poll(ctx)
+		cancel()
+		if err != nil {
+			fmt.Println("indexer - poll failed: ", transferIndexer.Chain, err)
+		}
+		if handled > 0 {
+			log.Println("indexer - transfers handled: ", transferIndexer.Chain, handled)
+		}
+	}
+}
+
+// indexedContracts are the NFT contracts with WalletChat members on the chain
+func indexedContracts(chain string) []string {
+	var contracts []string
+	database.Connector.Model(&entity.Bookmarkitem{}).Where("chain = ?", chain).Where("nftaddr LIKE ?", "0x%").Pluck("DISTINCT nftaddr", &contracts)
+	return contracts
+}
+
+// applyIndexedTransfers re-checks the membership of both sides of every transfer. Checking the balance (instead of
+// counting transfers) makes it idempotent and undoes reorged transfers the same way.
+func applyIndexedTransfers(chain string, transfers []indexer.Transfer) {
+	checked := map[string]bool{}
+	for _, transfer := range transfers {
+		for _, wallet := range []string{transfer.From, transfer.To} {
+			key := wallet + "|" + transfer.Contract
+			if wallet == indexer.ZeroAddress || checked[key] {
+				continue
+			}
+			checked[key] = true
+			syncIndexedMembership(chain, transfer.Contract, wallet)
+		}
+	}
+}
+
+// syncIndexedMembership joins or leaves the NFT chat for WalletChat users (wallets the auto-join worker knows),
+// chats the user left on purpose (Userunjoined) are never joined again
+func syncIndexedMembership(chain string, contract string, wallet string) {
+	autoJoin, known := getAutoJoinSync(wallet)
+	if !known {
+		return
+	}
+	walletaddr := autoJoin.Walletaddr
+	InvalidateOwnershipCache(walletaddr) //the cached holder check is out of date either way
+
+	balance, err := onchain.NftBalance(chain, contract, walletaddr, "")
+	if err != nil {
+		fmt.Println("indexer - balance check failed: ", chain, contract, walletaddr, err)
+		return
+	}
+
+	var bookmark entity.Bookmarkitem
+	member := database.Connector.Where("walletaddr = ?", walletaddr).Where("nftaddr = ?", contract).Find(&bookmark).RowsAffected > 0
+
+	if balance.Sign() > 0 && !member {
+		var unjoined entity.Userunjoined
+		if database.Connector.Where("walletaddr = ?", walletaddr).Where("nftaddr = ?", contract).Where("unjoined = ?", true).Find(&unjoined).RowsAffected > 0 {
+			return
+		}
+		database.Connector.Create(&entity.Bookmarkitem{Walletaddr: walletaddr, Nftaddr: contract, Chain: chain})
+		postMembershipEvent(chain, contract, walletaddr, entity.MemberJoined)
+		webhooks.EmitForWallet(entity.WebhookCommunityMemberJoined, walletaddr, map[string]string{
+			"community":  contract,
+			"walletaddr": walletaddr,
+			"chain":      chain,
+		})
+	}
+	if balance.Sign() == 0 && member && (bookmark.Chain == chain || bookmark.Chain == "") && !vaultHolds(chain, contract, walletaddr) {
+		database.Connector.Where("walletaddr = ?", walletaddr).Where("nftaddr = ?", contract).Delete(&entity.Bookmarkitem{})
+		postMembershipEvent(chain, contract, walletaddr, entity.MemberLeft)
+		webhooks.EmitForWallet(entity.WebhookCommunityMemberLeft, walletaddr, map[string]string{
+			"community":  contract,
+			"walletaddr": walletaddr,
+			"chain":      chain,
+		})
+	}
+}
+
+// vaultHolds - the wallet may be a member through a vault that delegated to it (delegate.cash), like auto-join does it.
+// A failed lookup counts as holding, the member stays until the next transfer or auto-join sync
+func vaultHolds(chain string, contract string, walletaddr string) bool {
+	for _, vault := range newWalletVaults(walletaddr).get() {
+		owner, err := isOwnerOfNftLocal(contract, vault, chain)
+		if err != nil {
+			fmt.Println("indexer - vault balance check failed: ", chain, contract, vault, err)
+			return true
+		}
+		if owner {
+			return true
+		}
+	}
+	return false
+}
+
+// postMembershipEvent adds a "joined"/"left" item to the NFT chat's message stream
+func postMembershipEvent(chain string, contract string, walletaddr string, eventType string) {
+	message := senderDisplayName(walletaddr) + " joined"
+	if eventType == entity.MemberLeft {
+		message = senderDisplayName(walletaddr) + " left"
+	}
+	now := time.Now()
+	database.Connector.Create(&entity.Groupchatitem{
+		Type:          eventType,
+		Contexttype:   entity.Nft,
+		Fromaddr:      walletaddr,
+		Nftaddr:       contract,
+		Chain:         chain,
+		Message:       message,
+		Timestamp_dtm: now,
+		Timestamp:     now.Format("2006-01-02T15:04:05.000Z"),
+	})
+}
@@ -0,0 +1,969 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	moderationLogPageSize   = 50
+	moderationLogMaxPage    = 200
+	moderationMaxMute       = 30 * 24 * time.Hour
+	moderationDefaultMute   = time.Hour
+	moderationMaxSlowmode   = 6 * 60 * 60 //seconds
+	moderationMaxReportSize = 500         //characters of reason kept
+	maxPinnedMessages       = 10
+)
+
+var (
+	errModerationNotAllowed = errors.New("not a moderator of this group chat")
+	errModerationNoMessage  = errors.New("message not found in this group chat")
+	errModerationOutranked  = errors.New("can't moderate a moderator or admin of this group chat")
+	errTooManyPins          = errors.New("a group chat can have at most 10 pinned messages, unpin one first")
+	errModeratorCommunity   = errors.New("community moderators are set with the member roles, this is for NFT/POAP group chats")
+)
+
+// ModerationMuteRequest mutes a wallet, duration_minutes 0 means one hour
+type ModerationMuteRequest struct {
+	Reason          string `json:"reason"`
+	Durationminutes int    `json:"duration_minutes"`
+}
+
+// ModerationSlowmodeRequest sets the minimum time between messages per wallet, 0 turns slow mode off
+type ModerationSlowmodeRequest struct {
+	Seconds int `json:"seconds"`
+}
+
+// ModerationReasonRequest is the optional body of delete/pin actions
+type ModerationReasonRequest struct {
+	Reason string `json:"reason"`
+}
+
+// MessageReportRequest reports a group chat message to the moderators
+type MessageReportRequest struct {
+	Messageid int    `json:"message_id"`
+	Reason    string `json:"reason"`
+}
+
+// ReportResolveRequest works a report off the queue: dismiss, resolve (handled elsewhere, e.g. mute/ban) or delete the message
+type ReportResolveRequest struct {
+	Action     string `json:"action"`
+	Resolution string `json:"resolution"`
+}
+
+// PinOrderRequest lists every pinned message id of a group chat in the new order
+type PinOrderRequest struct {
+	Messageids []int `json:"message_ids"`
+}
+
+// ModerationLogPage is one page of GET /moderation/{group}/log
+type ModerationLogPage struct {
+	Entries []entity.Moderationlog `json:"entries"`
+	Total   int                    `json:"total"`
+	Page    int                    `json:"page"`
+	Limit   int                    `json:"limit"`
+}
+
+// moderatorRole - communities use the Communityadmin rows, NFT/POAP group chats have no admins
+// so a Moderator row with the group address (Groupaddr) makes the wallet a moderator there
+func moderatorRole(groupaddr string, walletaddr string) string {
+	if role := communityStaffRole(groupaddr, walletaddr); role != "" {
+		return role
+	}
+	//group moderator rows are stored lowercased (SetGroupModerator) so the groupaddr index is used
+	var moderators []entity.Moderator
+	database.Connector.Where("groupaddr = ?", strings.ToLower(groupaddr)).
+		Where("address = ?", strings.ToLower(walletaddr)).
+		Limit(1).
+		Find(&moderators)
+	if len(moderators) > 0 {
+		return entity.CommunityRoleModerator
+	}
+	return ""
+}
+
+func isGroupModerator(groupaddr string, walletaddr string) bool {
+	return moderatorRole(groupaddr, walletaddr) != ""
+}
+
+// canReadGroup - community rules (membership, bans, access conditions) for communities,
+// NFT/POAP group chats are for holders of the collection/event and their moderators
+func canReadGroup(groupaddr string, walletaddr string) bool {
+	if directoryType(groupaddr) == directoryTypeCommunity {
+		return canReadCommunity(groupaddr, walletaddr)
+	}
+	if IsBannedFromCommunity(groupaddr, walletaddr) {
+		return false
+	}
+	return isGroupModerator(groupaddr, walletaddr) || isGroupHolder(groupaddr, walletaddr, groupChain(groupaddr, walletaddr, ""))
+}
+
+// moderationTarget checks the actor moderates the group and outranks the target (admins can act on moderators)
+func moderationTarget(groupaddr string, actor string, target string) error {
+	actorRole := moderatorRole(groupaddr, actor)
+	if actorRole == "" {
+		return errModerationNotAllowed
+	}
+	if strings.EqualFold(actor, target) {
+		return errCommunityNotAllowed
+	}
+	targetRole := moderatorRole(groupaddr, target)
+	if targetRole == entity.CommunityRoleAdmin || (targetRole == entity.CommunityRoleModerator && actorRole != entity.CommunityRoleAdmin) {
+		return errModerationOutranked
+	}
+	return nil
+}
+
+// logModeration records a moderator action in the audit log
+func logModeration(entry entity.Moderationlog) {
+	entry.Actor = strings.ToLower(entry.Actor)
+	entry.Targetaddr = strings.ToLower(entry.Targetaddr)
+	entry.Timestamp = time.Now()
+	if err := database.Connector.Create(&entry).Error; err != nil {
+		log.Println("logModeration error: ", err)
+	}
+}
+
+// getPinnedMessages returns the pinned messages of a group chat in pin order
+func getPinnedMessages(groupaddr string) []entity.Groupchatitem {
+	var messages []entity.Groupchatitem
+	database.Connector.Raw(`SELECT g.* FROM groupchatitems g
+		JOIN pinnedmessages p ON p.messageid = g.id AND p.groupaddr = g.nftaddr
+		WHERE p.groupaddr = ? ORDER BY p.position ASC, p.id ASC`, groupaddr).Scan(&messages)
+	if messages == nil {
+		messages = []entity.Groupchatitem{}
+	}
+	return messages
+}
+
+func getGroupMessage(groupaddr string, messageid int) (entity.Groupchatitem, bool) {
+	var message entity.Groupchatitem
+	dbQuery := database.Connector.Where("id = ?", messageid).Where("nftaddr = ?", groupaddr).Find(&message)
+	return message, dbQuery.RowsAffected > 0
+}
+
+// activeMute returns the mute for the wallet if it hasn't run out
+func activeMute(groupaddr string, walletaddr string) (entity.Groupmute, bool) {
+	var mute entity.Groupmute
+	dbQuery := database.Connector.Where("groupaddr = ?", groupaddr).
+		Where("walletaddr = ?", strings.ToLower(walletaddr)).
+		Where("until > ?", time.Now()).
+		Find(&mute)
+	return mute, dbQuery.RowsAffected > 0
+}
+
+// GetSlowmodeSeconds returns the slow mode interval of a group chat, 0 is off
+func GetSlowmodeSeconds(groupaddr string) int {
+	var settings entity.Groupmoderation
+	if database.Connector.Where("groupaddr = ?", groupaddr).Find(&settings).RowsAffected == 0 {
+		return 0
+	}
+	return settings.Slowmodeseconds
+}
+
+// checkGroupPost applies mutes and slow mode before a message is saved, moderators are exempt
+// writes the 403/429 response and returns false when the wallet can't post right now
+func checkGroupPost(w http.ResponseWriter, groupaddr string, walletaddr string) bool {
+	if isGroupModerator(groupaddr, walletaddr) {
+		return true
+	}
+	if mute, muted := activeMute(groupaddr, walletaddr); muted {
+		http.Error(w, "muted in this group chat until "+mute.Until.UTC().Format(time.RFC3339), http.StatusForbidden)
+		return false
+	}
+	seconds := GetSlowmodeSeconds(groupaddr)
+	if seconds <= 0 {
+		return true
+	}
+	var last []entity.Groupchatitem
+	database.Connector.Where("nftaddr = ?", groupaddr).
+		Where("LOWER(fromaddr) = ?", strings.ToLower(walletaddr)).
+		Where("type = ?", entity.Message).
+		Order("timestamp_dtm desc").
+		Limit(1).
+		Find(&last)
+	if len(last) > 0 {
+		wait := time.Duration(seconds)*time.Second - time.Since(last[0].Timestamp_dtm)
+		if wait > 0 {
+			w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+			http.Error(w, "slow mode is on, please wait before posting again", http.StatusTooManyRequests)
+			return false
+		}
+	}
+	return true
+}
+
+// deleteGroupMessage removes the message and its pins, logs a copy and closes its open reports
+func deleteGroupMessage(message entity.Groupchatitem, actor string, reason string) {
+	database.Connector.Where("id = ?", message.Id).Delete(&entity.Groupchatitem{})
+	database.Connector.Where("groupaddr = ?", message.Nftaddr).Where("messageid = ?", message.Id).Delete(&entity.Pinnedmessage{})
+
+	now := time.Now()
+	database.Connector.Model(&entity.Messagereport{}).
+		Where("messageid = ?", message.Id).
+		Where("status = ?", entity.ReportStatusOpen).
+		Updates(map[string]interface{}{
+			"status":     entity.ReportStatusResolved,
+			"resolvedby": strings.ToLower(actor),
+			"resolution": "message deleted",
+			"resolvedat": now,
+		})
+
+	logModeration(entity.Moderationlog{
+		Groupaddr:  message.Nftaddr,
+		Actor:      actor,
+		Action:     entity.ModActionDelete,
+		Targetaddr: message.Fromaddr,
+		Messageid:  message.Id,
+		Message:    message.Message,
+		Reason:     reason,
+	})
+}
+
+func writeModerationError(w http.ResponseWriter, err error) {
+	status := http.StatusForbidden
+	switch err {
+	case errModerationNoMessage:
+		status = http.StatusNotFound
+	case errTooManyPins:
+		status = http.StatusConflict
+	case errModeratorCommunity:
+		status = http.StatusBadRequest
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func readModerationReason(r *http.Request) string {
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var reasonRequest ModerationReasonRequest
+	json.Unmarshal(requestBody, &reasonRequest)
+	return reasonRequest.Reason
+}
+
+func moderationMessageID(r *http.Request) int {
+	messageid, _ := strconv.Atoi(mux.Vars(r)["id"])
+	return messageid
+}
+
+// ============================================================================
+
+// DeleteGroupMessage godoc
+// @Summary     Moderator deletes a message from a community/NFT group chat
+// @Description A copy of the message is kept in the moderation log, open reports for it are resolved
+// @Tags        Moderation
+// @Accept      json
+// @Security    BearerAuth
+// @Param       group   path string                  true  "community slug or NFT/POAP address"
+// @Param       id      path int                     true  "message id"
+// @Param       message body ModerationReasonRequest false "reason"
+// @Success     204
+// @Router      /v1/moderation/{group}/messages/{id} [delete]
+func DeleteGroupMessage(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+	message, found := getGroupMessage(groupaddr, moderationMessageID(r))
+	if !found {
+		writeModerationError(w, errModerationNoMessage)
+		return
+	}
+	deleteGroupMessage(message, Authuser.Address, readModerationReason(r))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PinGroupMessage godoc
+// @Summary     Moderator pins a message in a community/NFT group chat
+// @Description New pins go last, a group chat can have at most 10 (409 when full)
+// @Tags        Moderation
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group   path     string                  true  "community slug or NFT/POAP address"
+// @Param       id      path     int                     true  "message id"
+// @Param       message body     ModerationReasonRequest false "reason"
+// @Success     200     {object} entity.Pinnedmessage
+// @Router      /v1/moderation/{group}/messages/{id}/pin [post]
+func PinGroupMessage(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+	message, found := getGroupMessage(groupaddr, moderationMessageID(r))
+	if !found {
+		writeModerationError(w, errModerationNoMessage)
+		return
+	}
+
+	var pin entity.Pinnedmessage
+	if database.Connector.Where("groupaddr = ?", groupaddr).Where("messageid = ?", message.Id).Find(&pin).RowsAffected == 0 {
+		var pins []entity.Pinnedmessage
+		database.Connector.Where("groupaddr = ?", groupaddr).Order("position desc").Find(&pins)
+		if len(pins) >= maxPinnedMessages {
+			writeModerationError(w, errTooManyPins)
+			return
+		}
+		position := 1
+		if len(pins) > 0 {
+			position = pins[0].Position + 1
+		}
+		pin = entity.Pinnedmessage{
+			Groupaddr: groupaddr,
+			Messageid: message.Id,
+			Position:  position,
+			Pinnedby:  strings.ToLower(Authuser.Address),
+			Timestamp: time.Now(),
+		}
+		database.Connector.Create(&pin)
+		//concurrent pins both pass the count above, the earliest ones (by id) keep their place
+		var earlier int
+		database.Connector.Model(&entity.Pinnedmessage{}).Where("groupaddr = ?", groupaddr).Where("id <= ?", pin.Id).Count(&earlier)
+		if earlier > maxPinnedMessages {
+			database.Connector.Delete(&pin)
+			writeModerationError(w, errTooManyPins)
+			return
+		}
+		logModeration(entity.Moderationlog{
+			Groupaddr:  groupaddr,
+			Actor:      Authuser.Address,
+			Action:     entity.ModActionPin,
+			Targetaddr: message.Fromaddr,
+			Messageid:  message.Id,
+			Reason:     readModerationReason(r),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(pin)
+}
+
+// UnpinGroupMessage godoc
+// @Summary     Moderator unpins a message
+// @Tags        Moderation
+// @Security    BearerAuth
+// @Param       group path string true "community slug or NFT/POAP address"
+// @Param       id    path int    true "message id"
+// @Success     204
+// @Router      /v1/moderation/{group}/messages/{id}/pin [delete]
+func UnpinGroupMessage(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	messageid := moderationMessageID(r)
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+	dbResult := database.Connector.Where("groupaddr = ?", groupaddr).Where("messageid = ?", messageid).Delete(&entity.Pinnedmessage{})
+	if dbResult.RowsAffected == 0 {
+		writeModerationError(w, errModerationNoMessage)
+		return
+	}
+	logModeration(entity.Moderationlog{
+		Groupaddr: groupaddr,
+		Actor:     Authuser.Address,
+		Action:    entity.ModActionUnpin,
+		Messageid: messageid,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ReorderPinnedMessages godoc
+// @Summary     Moderator changes the order of the pinned messages
+// @Description message_ids must contain every pinned message id of the group chat exactly once
+// @Tags        Moderation
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group   path    string          true "community slug or NFT/POAP address"
+// @Param       message body    PinOrderRequest true "pinned message ids in the new order"
+// @Success     200     {array} entity.Groupchatitem
+// @Router      /v1/moderation/{group}/pins [put]
+func ReorderPinnedMessages(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var orderRequest PinOrderRequest
+	if err := json.Unmarshal(requestBody, &orderRequest); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+
+	var pins []entity.Pinnedmessage
+	database.Connector.Where("groupaddr = ?", groupaddr).Find(&pins)
+	pinned := map[int]entity.Pinnedmessage{}
+	for _, pin := range pins {
+		pinned[pin.Messageid] = pin
+	}
+	seen := map[int]bool{}
+	for _, messageid := range orderRequest.Messageids {
+		if _, found := pinned[messageid]; !found || seen[messageid] {
+			http.Error(w, "message_ids must list every pinned message exactly once", http.StatusBadRequest)
+			return
+		}
+		seen[messageid] = true
+	}
+	if len(seen) != len(pinned) {
+		http.Error(w, "message_ids must list every pinned message exactly once", http.StatusBadRequest)
+		return
+	}
+
+	for i, messageid := range orderRequest.Messageids {
+		database.Connector.Model(&entity.Pinnedmessage{}).Where("id = ?", pinned[messageid].Id).Update("position", i+1)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(getPinnedMessages(groupaddr))
+}
+
+// GetPinnedMessages godoc
+// @Summary     Get the pinned messages of a community/NFT group chat, in pin order
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group path    string true "community slug or NFT/POAP address"
+// @Success     200   {array} entity.Groupchatitem
+// @Router      /v1/pins/{group} [get]
+func GetPinnedMessages(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !canReadGroup(groupaddr, Authuser.Address) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	//pins in channels the wallet can't read are left out
+	readable := map[string]bool{}
+	for _, key := range readableChannelKeys(groupaddr, Authuser.Address) {
+		readable[key] = true
+	}
+	pinned := []entity.Groupchatitem{}
+	for _, message := range getPinnedMessages(groupaddr) {
+		if readable[message.Channel] {
+			pinned = append(pinned, message)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(pinned)
+}
+
+// MuteGroupMember godoc
+// @Summary     Moderator mutes a wallet in a community/NFT group chat
+// @Description Muted wallets can still read, duration_minutes defaults to 60 and is capped at 30 days
+// @Tags        Moderation
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group   path     string                true  "community slug or NFT/POAP address"
+// @Param       address path     string                true  "wallet address"
+// @Param       message body     ModerationMuteRequest false "reason and duration"
+// @Success     200     {object} entity.Groupmute
+// @Router      /v1/moderation/{group}/mutes/{address} [post]
+func MuteGroupMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupaddr := vars["group"]
+	target := strings.ToLower(vars["address"])
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var muteRequest ModerationMuteRequest
+	json.Unmarshal(requestBody, &muteRequest)
+
+	if err := moderationTarget(groupaddr, Authuser.Address, target); err != nil {
+		writeModerationError(w, err)
+		return
+	}
+
+	duration := time.Duration(muteRequest.Durationminutes) * time.Minute
+	if duration <= 0 {
+		duration = moderationDefaultMute
+	} else if duration > moderationMaxMute {
+		duration = moderationMaxMute
+	}
+
+	var mute entity.Groupmute
+	database.Connector.Where("groupaddr = ?", groupaddr).Where("walletaddr = ?", target).Find(&mute)
+	mute.Groupaddr = groupaddr
+	mute.Walletaddr = target
+	mute.Reason = muteRequest.Reason
+	mute.Mutedby = strings.ToLower(Authuser.Address)
+	mute.Until = time.Now().Add(duration)
+	mute.Timestamp = time.Now()
+	if mute.Id == 0 {
+		database.Connector.Create(&mute)
+	} else {
+		database.Connector.Save(&mute)
+	}
+
+	logModeration(entity.Moderationlog{
+		Groupaddr:  groupaddr,
+		Actor:      Authuser.Address,
+		Action:     entity.ModActionMute,
+		Targetaddr: target,
+		Reason:     muteRequest.Reason,
+		Until:      &mute.Until,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(mute)
+}
+
+// UnmuteGroupMember godoc
+// @Summary     Moderator lifts a mute
+// @Tags        Moderation
+// @Security    BearerAuth
+// @Param       group   path string true "community slug or NFT/POAP address"
+// @Param       address path string true "wallet address"
+// @Success     204
+// @Router      /v1/moderation/{group}/mutes/{address} [delete]
+func UnmuteGroupMember(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupaddr := vars["group"]
+	target := strings.ToLower(vars["address"])
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+	dbResult := database.Connector.Where("groupaddr = ?", groupaddr).Where("walletaddr = ?", target).Delete(&entity.Groupmute{})
+	if dbResult.RowsAffected == 0 {
+		http.Error(w, "wallet is not muted", http.StatusNotFound)
+		return
+	}
+	logModeration(entity.Moderationlog{
+		Groupaddr:  groupaddr,
+		Actor:      Authuser.Address,
+		Action:     entity.ModActionUnmute,
+		Targetaddr: target,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetGroupMutes godoc
+// @Summary     Moderators list the wallets currently muted
+// @Tags        Moderation
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group path    string true "community slug or NFT/POAP address"
+// @Success     200   {array} entity.Groupmute
+// @Router      /v1/moderation/{group}/mutes [get]
+func GetGroupMutes(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+	mutes := []entity.Groupmute{}
+	database.Connector.Where("groupaddr = ?", groupaddr).Where("until > ?", time.Now()).Order("until asc").Find(&mutes)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(mutes)
+}
+
+// GetGroupSlowmode godoc
+// @Summary     Get the slow mode setting of a community/NFT group chat
+// @Description Clients can use this to show a countdown, moderators are not limited
+// @Tags        GroupChat
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group path     string true "community slug or NFT/POAP address"
+// @Success     200   {object} ModerationSlowmodeRequest
+// @Router      /v1/moderation/{group}/slowmode [get]
+func GetGroupSlowmode(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(ModerationSlowmodeRequest{Seconds: GetSlowmodeSeconds(groupaddr)})
+}
+
+// SetGroupSlowmode godoc
+// @Summary     Moderator turns slow mode on/off
+// @Description seconds is the minimum time between two messages of the same wallet (max 6 hours), 0 turns it off
+// @Tags        Moderation
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group   path     string                    true "community slug or NFT/POAP address"
+// @Param       message body     ModerationSlowmodeRequest true "interval in seconds"
+// @Success     200     {object} entity.Groupmoderation
+// @Router      /v1/moderation/{group}/slowmode [put]
+func SetGroupSlowmode(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var slowmodeRequest ModerationSlowmodeRequest
+	if err := json.Unmarshal(requestBody, &slowmodeRequest); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if slowmodeRequest.Seconds < 0 || slowmodeRequest.Seconds > moderationMaxSlowmode {
+		http.Error(w, "seconds must be between 0 and "+strconv.Itoa(moderationMaxSlowmode), http.StatusBadRequest)
+		return
+	}
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+
+	var settings entity.Groupmoderation
+	database.Connector.Where("groupaddr = ?", groupaddr).Find(&settings)
+	settings.Groupaddr = groupaddr
+	settings.Slowmodeseconds = slowmodeRequest.Seconds
+	settings.Updatedby = strings.ToLower(Authuser.Address)
+	settings.Timestamp = time.Now()
+	if settings.Id == 0 {
+		database.Connector.Create(&settings)
+	} else {
+		database.Connector.Save(&settings)
+	}
+	logModeration(entity.Moderationlog{
+		Groupaddr: groupaddr,
+		Actor:     Authuser.Address,
+		Action:    entity.ModActionSlowmode,
+		Reason:    strconv.Itoa(slowmodeRequest.Seconds) + "s",
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(settings)
+}
+
+// GetModerationLog godoc
+// @Summary     Moderators read the audit log of a community/NFT group chat, newest first
+// @Tags        Moderation
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group  path     string true  "community slug or NFT/POAP address"
+// @Param       page   query    int    false "page number, starts at 1"
+// @Param       limit  query    int    false "page size (default 50, max 200)"
+// @Param       action query    string false "only this action (delete, mute, ban, ...)"
+// @Success     200    {object} ModerationLogPage
+// @Router      /v1/moderation/{group}/log [get]
+func GetModerationLog(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+	query := r.URL.Query()
+
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+
+	page, _ := strconv.Atoi(query.Get("page"))
+	if page < 1 {
+		page = 1
+	}
+	limit, _ := strconv.Atoi(query.Get("limit"))
+	if limit < 1 || limit > moderationLogMaxPage {
+		limit = moderationLogPageSize
+	}
+
+	dbQuery := database.Connector.Model(&entity.Moderationlog{}).Where("groupaddr = ?", groupaddr)
+	if action := query.Get("action"); action != "" {
+		dbQuery = dbQuery.Where("action = ?", action)
+	}
+	var result ModerationLogPage
+	dbQuery.Count(&result.Total)
+	result.Entries = []entity.Moderationlog{}
+	dbQuery.Order("id desc").Offset((page - 1) * limit).Limit(limit).Find(&result.Entries)
+	result.Page = page
+	result.Limit = limit
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(result)
+}
+
+// ReportGroupMessage godoc
+// @Summary     Report a community/NFT group chat message to its moderators
+// @Description One report per wallet per message, the report waits in the moderation queue
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       message body     MessageReportRequest true "message id and reason"
+// @Success     201     {object} entity.Messagereport
+// @Failure     409
+// @Router      /v1/report_message [post]
+func ReportGroupMessage(w http.ResponseWriter, r *http.Request) {
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var reportRequest MessageReportRequest
+	json.Unmarshal(requestBody, &reportRequest)
+
+	Authuser := auth.GetUserFromReqContext(r)
+	reporter := strings.ToLower(Authuser.Address)
+
+	var message entity.Groupchatitem
+	if database.Connector.Where("id = ?", reportRequest.Messageid).Find(&message).RowsAffected == 0 {
+		writeModerationError(w, errModerationNoMessage)
+		return
+	}
+	if !canReadGroup(message.Nftaddr, reporter) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if strings.EqualFold(message.Fromaddr, reporter) {
+		http.Error(w, "can't report your own message", http.StatusBadRequest)
+		return
+	}
+
+	var existing []entity.Messagereport
+	if database.Connector.Where("messageid = ?", message.Id).Where("reporter = ?", reporter).Find(&existing).RowsAffected > 0 {
+		http.Error(w, "message already reported", http.StatusConflict)
+		return
+	}
+
+	reason := strings.TrimSpace(reportRequest.Reason)
+	if len(reason) > moderationMaxReportSize {
+		reason = reason[:moderationMaxReportSize]
+	}
+	report := entity.Messagereport{
+		Groupaddr: message.Nftaddr,
+		Messageid: message.Id,
+		Reporter:  reporter,
+		Author:    strings.ToLower(message.Fromaddr),
+		Message:   message.Message,
+		Reason:    reason,
+		Status:    entity.ReportStatusOpen,
+		Timestamp: time.Now(),
+	}
+	if err := database.Connector.Create(&report).Error; err != nil {
+		log.Println("ReportGroupMessage error: ", err)
+		http.Error(w, "message already reported", http.StatusConflict)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(report)
+}
+
+// GetModerationQueue godoc
+// @Summary     Moderators list reported messages, oldest first
+// @Tags        Moderation
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group  path    string true  "community slug or NFT/POAP address"
+// @Param       status query   string false "open (default), resolved or dismissed"
+// @Success     200    {array} entity.Messagereport
+// @Router      /v1/moderation/{group}/reports [get]
+func GetModerationQueue(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = entity.ReportStatusOpen
+	}
+	if status != entity.ReportStatusOpen && status != entity.ReportStatusResolved && status != entity.ReportStatusDismissed {
+		http.Error(w, "status must be open, resolved or dismissed", http.StatusBadRequest)
+		return
+	}
+
+	reports := []entity.Messagereport{}
+	dbQuery := database.Connector.Where("groupaddr = ?", groupaddr).Where("status = ?", status)
+	if status == entity.ReportStatusOpen {
+		dbQuery = dbQuery.Order("id asc")
+	} else {
+		dbQuery = dbQuery.Order("id desc")
+	}
+	dbQuery.Limit(moderationLogMaxPage).Find(&reports)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(reports)
+}
+
+// ResolveMessageReport godoc
+// @Summary     Moderator works a report off the queue
+// @Description action is dismiss, resolve (after e.g. muting the author) or delete (deletes the message and resolves all its reports)
+// @Tags        Moderation
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group   path     string               true "community slug or NFT/POAP address"
+// @Param       id      path     int                  true "report id"
+// @Param       message body     ReportResolveRequest true "action and note"
+// @Success     200     {object} entity.Messagereport
+// @Router      /v1/moderation/{group}/reports/{id} [post]
+func ResolveMessageReport(w http.ResponseWriter, r *http.Request) {
+	groupaddr := mux.Vars(r)["group"]
+	reportid, _ := strconv.Atoi(mux.Vars(r)["id"])
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var resolveRequest ReportResolveRequest
+	json.Unmarshal(requestBody, &resolveRequest)
+
+	if !isGroupModerator(groupaddr, Authuser.Address) {
+		writeModerationError(w, errModerationNotAllowed)
+		return
+	}
+	var report entity.Messagereport
+	if database.Connector.Where("id = ?", reportid).Where("groupaddr = ?", groupaddr).Find(&report).RowsAffected == 0 {
+		http.Error(w, "report not found", http.StatusNotFound)
+		return
+	}
+	if report.Status != entity.ReportStatusOpen {
+		http.Error(w, "report already "+report.Status, http.StatusConflict)
+		return
+	}
+
+	switch resolveRequest.Action {
+	case "delete":
+		if message, found := getGroupMessage(groupaddr, report.Messageid); found {
+			deleteGroupMessage(message, Authuser.Address, resolveRequest.Resolution)
+		}
+		report.Status = entity.ReportStatusResolved
+		if resolveRequest.Resolution == "" {
+			resolveRequest.Resolution = "message deleted"
+		}
+	case "resolve":
+		report.Status = entity.ReportStatusResolved
+	case "dismiss":
+		report.Status = entity.ReportStatusDismissed
+	default:
+		http.Error(w, "action must be dismiss, resolve or delete", http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	report.Resolvedby = strings.ToLower(Authuser.Address)
+	report.Resolution = resolveRequest.Resolution
+	report.Resolvedat = &now
+	database.Connector.Save(&report)
+	logModeration(entity.Moderationlog{
+		Groupaddr:  groupaddr,
+		Actor:      Authuser.Address,
+		Action:     entity.ModActionReport,
+		Targetaddr: report.Author,
+		Messageid:  report.Messageid,
+		Reason:     report.Status + ": " + report.Resolution,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(report)
+}
+
+// SetGroupModerator godoc
+// @Summary     Make a wallet a moderator of an NFT/POAP group chat
+// @Description Only for WalletChat, requires an admin API key as the Bearer token. Communities use the member roles instead.
+// @Tags        Moderation
+// @Produce     json
+// @Security    BearerAuth
+// @Param       group   path     string true "NFT contract or POAP (poap_<event id>) address"
+// @Param       address path     string true "wallet address"
+// @Success     200     {object} entity.Moderator
+// @Router      /v1/moderation/{group}/moderators/{address} [post]
+func SetGroupModerator(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupaddr := strings.ToLower(vars["group"])
+	target := strings.ToLower(vars["address"])
+	if !isAdminApiRequest(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if directoryType(groupaddr) == directoryTypeCommunity {
+		writeModerationError(w, errModeratorCommunity)
+		return
+	}
+	Authuser := auth.GetUserFromReqContext(r)
+
+	var moderator entity.Moderator
+	if database.Connector.Where("groupaddr = ?", groupaddr).Where("address = ?", target).Find(&moderator).RowsAffected == 0 {
+		moderator = entity.Moderator{Address: target, Groupaddr: groupaddr}
+		if err := database.Connector.Create(&moderator).Error; err != nil {
+			log.Println("SetGroupModerator error: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		logModeration(entity.Moderationlog{
+			Groupaddr:  groupaddr,
+			Actor:      Authuser.Address, //short API key id
+			Action:     entity.ModActionRole,
+			Targetaddr: target,
+			Reason:     entity.CommunityRoleModerator,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(moderator)
+}
+
+// RemoveGroupModerator godoc
+// @Summary     Remove a moderator of an NFT/POAP group chat
+// @Description Only for WalletChat, requires an admin API key as the Bearer token, 404 if the wallet isn't a moderator there
+// @Tags        Moderation
+// @Security    BearerAuth
+// @Param       group   path string true "NFT contract or POAP (poap_<event id>) address"
+// @Param       address path string true "wallet address"
+// @Success     204
+// @Router      /v1/moderation/{group}/moderators/{address} [delete]
+func RemoveGroupModerator(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	groupaddr := strings.ToLower(vars["group"])
+	target := strings.ToLower(vars["address"])
+	if !isAdminApiRequest(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	if directoryType(groupaddr) == directoryTypeCommunity {
+		writeModerationError(w, errModeratorCommunity)
+		return
+	}
+	Authuser := auth.GetUserFromReqContext(r)
+
+	if database.Connector.Where("groupaddr = ?", groupaddr).Where("address = ?", target).Delete(&entity.Moderator{}).RowsAffected == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	logModeration(entity.Moderationlog{
+		Groupaddr:  groupaddr,
+		Actor:      Authuser.Address,
+		Action:     entity.ModActionRole,
+		Targetaddr: target,
+		Reason:     entity.CommunityRoleMember,
+	})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,63 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"rest-go-demo/auth"
+	"rest-go-demo/entity"
+	"testing"
+
+	"github.com/gorilla/mux"
+)
+
+func moderatorRequest(method string, group string, address string, bearer string) *http.Request {
+	r := httptest.NewRequest(method, "/v1/moderation/"+group+"/moderators/"+address, nil)
+	r.Header.Set("Authorization", "Bearer "+bearer)
+	r = r.WithContext(context.WithValue(r.Context(), "Authuser", auth.Authuser{Address: bearer[:16]}))
+	return mux.SetURLVars(r, map[string]string{"group": group, "address": address})
+}
+
+func TestGroupModerators(t *testing.T) {
+	useTestDB(t, &entity.Moderator{}, &entity.Moderationlog{}, &entity.Communityadmin{})
+	t.Setenv("ADMIN_API_KEY_LIST", "admin-key-0123456789abcdef")
+	const (
+		group  = "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA"
+		wallet = "0x1111111111111111111111111111111111111111"
+	)
+
+	w := httptest.NewRecorder()
+	SetGroupModerator(w, moderatorRequest("POST", group, wallet, "not-an-admin-key-0123456789"))
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("a wallet JWT set a moderator: %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	SetGroupModerator(w, moderatorRequest("POST", "walletchat", wallet, "admin-key-0123456789abcdef"))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("a community moderator was set through the group route: %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	SetGroupModerator(w, moderatorRequest("POST", group, wallet, "admin-key-0123456789abcdef"))
+	if w.Code != http.StatusOK {
+		t.Fatalf("SetGroupModerator: %d %s", w.Code, w.Body.String())
+	}
+	//stored lowercased, matched whatever case the group and wallet come in
+	if !isGroupModerator("0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", wallet) || !isGroupModerator(group, wallet) {
+		t.Error("the wallet isn't a moderator of the group")
+	}
+	if isGroupModerator("0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", wallet) {
+		t.Error("the wallet moderates another group")
+	}
+
+	w = httptest.NewRecorder()
+	RemoveGroupModerator(w, moderatorRequest("DELETE", group, wallet, "admin-key-0123456789abcdef"))
+	if w.Code != http.StatusNoContent || isGroupModerator(group, wallet) {
+		t.Errorf("RemoveGroupModerator: %d, still a moderator %v", w.Code, isGroupModerator(group, wallet))
+	}
+	w = httptest.NewRecorder()
+	RemoveGroupModerator(w, moderatorRequest("DELETE", group, wallet, "admin-key-0123456789abcdef"))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("removing a wallet that isn't a moderator: %d", w.Code)
+	}
+}
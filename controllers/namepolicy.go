@@ -0,0 +1,256 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/names"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	maxDisplayNameLength = 64
+	nameRevalidateAfter  = 24 * time.Hour //how long a verified name is trusted before its ownership is checked again
+	nameRevalidateRetry  = time.Hour      //naming service failed, try again this much later
+	nameRevalidateBatch  = 200
+)
+
+var (
+	errNameInvalid      = errors.New("names must be at most 64 characters without control characters")
+	errNameReserved     = errors.New("this name is reserved")
+	errNameTaken        = errors.New("this name is already used by another wallet")
+	errNameConfusable   = errors.New("this name looks too much like another wallet's name")
+	errNameNotOwned     = errors.New("the name does not resolve to this address")
+	errNameLookupFailed = errors.New("could not verify name ownership, please try again")
+)
+
+// reservedNames can't be used (after folding lookalikes) except through the admin API, RESERVED_NAMES adds more
+var reservedNames = []string{"walletchat", "admin", "administrator", "support", "helpdesk", "help", "moderator", "mod", "official", "security", "team", "staff", "system"}
+
+// reservedNameParts can't appear anywhere in a name, "WalletChat Support" or "Official Help Desk"
+var reservedNameParts = []string{"walletchat", "support", "helpdesk", "moderator", "official"}
+
+// lookalikes folds characters that render (nearly) the same as a latin letter or are commonly swapped for one
+var lookalikes = map[rune]string{
+	//cyrillic
+	'а': "a", 'в': "b", 'е': "e", 'ё': "e", 'к': "k", 'м': "m", 'н': "h", 'о': "o", 'р': "p", 'с': "c", 'т': "t",
+	'у': "y", 'х': "x", 'і': "l", 'ї': "l", 'ј': "j", 'ѕ': "s", 'ԁ': "d", 'ӏ': "l", 'ԛ': "q", 'ԝ': "w", 'ь': "b",
+	//greek
+	'α': "a", 'β': "b", 'ε': "e", 'η': "n", 'ι': "l", 'κ': "k", 'ν': "v", 'ο': "o", 'ρ': "p", 'τ': "t", 'υ': "u",
+	'χ': "x", 'ω': "w", 'μ': "u",
+	//latin with marks
+	'à': "a", 'á': "a", 'â': "a", 'ã': "a", 'ä': "a", 'å': "a", 'ā': "a", 'ç': "c", 'è': "e", 'é': "e", 'ê': "e",
+	'ë': "e", 'ē': "e", 'ì': "l", 'í': "l", 'î': "l", 'ï': "l", 'ı': "l", 'ñ': "n", 'ò': "o", 'ó': "o", 'ô': "o",
+	'õ': "o", 'ö': "o", 'ø': "o", 'ō': "o", 'ù': "u", 'ú': "u", 'û': "u", 'ü': "u", 'ū': "u", 'ý': "y", 'ÿ': "y",
+	'ß': "ss",
+	//digits and letters used as letters
+	'0': "o", '1': "l", '3': "e", '4': "a", '5': "s", '7': "t", '8': "b", '9': "g", '$': "s", '@': "a", '|': "l",
+	'i': "l",
+}
+
+// canonicalName is the lookalike-folded form of a name, two names with the same canonical form are confusable.
+// Only letters and digits remain: "WalletChat Support", "wallet_chat-supp0rt" and "WаlletChat Support" (cyrillic а) all match.
+func canonicalName(name string) string {
+	var folded strings.Builder
+	for _, char := range strings.ToLower(name) {
+		if char >= 0xFF01 && char <= 0xFF5E { //full width forms
+			char = unicode.ToLower(char - 0xFEE0)
+		}
+		if replacement, ok := lookalikes[char]; ok {
+			folded.WriteString(replacement)
+			continue
+		}
+		if unicode.IsLetter(char) || unicode.IsDigit(char) {
+			folded.WriteRune(char) //other scripts are kept as is
+		}
+		//spaces, punctuation, marks and invisible characters are dropped
+	}
+	canonical := folded.String()
+	canonical = strings.ReplaceAll(canonical, "rn", "m")
+	canonical = strings.ReplaceAll(canonical, "vv", "w")
+	return canonical
+}
+
+// walletNames limits an Addrnameitem query to wallets, the table also holds the names of community slugs
+func walletNames() *gorm.DB {
+	return database.Connector.
+		Where("address NOT IN (?)", database.Connector.Model(&entity.Communityitem{}).Select("slug").QueryExpr()).
+		Where("address NOT IN (?)", database.Connector.Model(&entity.Communityalias{}).Select("oldslug").QueryExpr())
+}
+
+func isReservedName(canonical string) bool {
+	reserved := append([]string{}, reservedNames...)
+	for _, name := range strings.Split(os.Getenv("RESERVED_NAMES"), ",") {
+		if strings.TrimSpace(name) != "" {
+			reserved = append(reserved, name)
+		}
+	}
+	for _, name := range reserved {
+		if canonical == canonicalName(name) {
+			return true
+		}
+	}
+	for _, part := range reservedNameParts {
+		if strings.Contains(canonical, canonicalName(part)) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyNamePolicy checks a display name for address and fills in its verification fields:
+//   - web3 names (.eth, .bnb, .arb, .btc...) must resolve to the address and are Verified
+//   - other names can't be reserved (admins excepted) and can't match another wallet's name, lookalikes included
+//
+// A verified web3 name takes the name from wallets that still show it (they no longer own it)
+func applyNamePolicy(addrname *entity.Addrnameitem, isAdmin bool) error {
+	addrname.Name = strings.TrimSpace(addrname.Name)
+	addrname.Canonical = canonicalName(addrname.Name)
+	addrname.Verified = false
+	addrname.Source = ""
+	now := time.Now()
+	addrname.Checkedat = &now
+	if addrname.Name == "" {
+		return nil
+	}
+	if len([]rune(addrname.Name)) > maxDisplayNameLength {
+		return errNameInvalid
+	}
+	for _, char := range addrname.Name {
+		if unicode.IsControl(char) {
+			return errNameInvalid
+		}
+	}
+
+	if names.Handles(addrname.Name) {
+		owned, err := names.Owns(addrname.Name, addrname.Address)
+		if err != nil {
+			fmt.Println("could not verify name: ", addrname.Name, err)
+			return errNameLookupFailed
+		}
+		if !owned {
+			return errNameNotOwned
+		}
+		addrname.Verified = true
+		if record, err := names.Resolve(addrname.Name); err == nil {
+			addrname.Source = record.Source
+		}
+		demoteNameHolders(addrname.Name, addrname.Address)
+		return nil
+	}
+
+	if !isAdmin && isReservedName(addrname.Canonical) {
+		return errNameReserved
+	}
+	var others []entity.Addrnameitem
+	if addrname.Canonical != "" { //empty when nothing is left to compare (ie emoji only)
+		walletNames().Where("canonical = ?", addrname.Canonical).Where("address != ?", addrname.Address).Limit(1).Find(&others)
+	}
+	if len(others) == 0 { //older names get their canonical form from RevalidateNames, until then the exact name counts
+		walletNames().Where("name = ?", addrname.Name).Where("address != ?", addrname.Address).Limit(1).Find(&others)
+	}
+	if len(others) > 0 {
+		if strings.EqualFold(others[0].Name, addrname.Name) {
+			return errNameTaken
+		}
+		return errNameConfusable
+	}
+	return nil
+}
+
+func writeNamePolicyError(w http.ResponseWriter, err error) {
+	status := http.StatusForbidden
+	switch err {
+	case errNameInvalid:
+		status = http.StatusBadRequest
+	case errNameTaken, errNameConfusable:
+		status = http.StatusConflict
+	case errNameLookupFailed:
+		status = http.StatusBadGateway
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// namePolicyUpdates are the columns applyNamePolicy set, for updating an existing row
+func namePolicyUpdates(addrname entity.Addrnameitem) map[string]interface{} {
+	return map[string]interface{}{
+		"name":        addrname.Name,
+		"canonical":   addrname.Canonical,
+		"verified":    addrname.Verified,
+		"source":      addrname.Source,
+		"checkedat":   addrname.Checkedat,
+		"demotedname": "",
+	}
+}
+
+// demoteNameHolders removes a web3 name from other wallets that still show it, it is kept in Demotedname
+func demoteNameHolders(name string, owner string) {
+	var holders []entity.Addrnameitem
+	walletNames().Where("name = ?", name).Where("address != ?", owner).Find(&holders)
+	for _, holder := range holders {
+		demoteName(holder)
+	}
+}
+
+func demoteName(addrname entity.Addrnameitem) {
+	now := time.Now()
+	database.Connector.Model(&entity.Addrnameitem{}).Where("id = ?", addrname.Id).Updates(map[string]interface{}{
+		"name":        "",
+		"canonical":   "",
+		"verified":    false,
+		"source":      "",
+		"demotedname": addrname.Name,
+		"checkedat":   &now,
+	})
+	log.Println("Name demoted: ", addrname.Address, addrname.Name)
+}
+
+// RevalidateNames re-checks the ownership of verified names (a sold ENS name is demoted), checks web3 names that were
+// stored before names were verified, and fills in the canonical form of older names. Run on a schedule.
+// Rows never checked successfully keep an empty canonical form, so they are retried like verified ones.
+func RevalidateNames() {
+	//before names.Init no name is handled, so every row would be stamped checked without a check
+	if !names.Configured() {
+		return
+	}
+	cutoff := time.Now().Add(-nameRevalidateAfter)
+	var addrnames []entity.Addrnameitem
+	walletNames().Where("checkedat IS NULL OR (checkedat < ? AND (verified = ? OR (canonical = ? AND name <> ?)))", cutoff, true, "", "").
+		Order("checkedat").Limit(nameRevalidateBatch).Find(&addrnames)
+
+	demoted := 0
+	for _, addrname := range addrnames {
+		updates := map[string]interface{}{"canonical": canonicalName(addrname.Name), "checkedat": time.Now()}
+		if names.Handles(addrname.Name) {
+			owned, err := names.Owns(addrname.Name, addrname.Address)
+			if err != nil {
+				fmt.Println("name revalidation failed: ", addrname.Name, err)
+				updates["checkedat"] = time.Now().Add(nameRevalidateRetry - nameRevalidateAfter)
+				if addrname.Canonical == "" {
+					delete(updates, "canonical") //not checked yet, retried once the delay passed
+				}
+			} else if !owned {
+				demoteName(addrname)
+				demoted++
+				continue
+			} else {
+				updates["verified"] = true
+				if record, err := names.Resolve(addrname.Name); err == nil {
+					updates["source"] = record.Source
+				}
+			}
+		}
+		database.Connector.Model(&entity.Addrnameitem{}).Where("id = ?", addrname.Id).Updates(updates)
+	}
+	if demoted > 0 {
+		log.Println("Name revalidation demoted: ", demoted)
+	}
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"errors"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/names"
+	"strings"
+	"testing"
+	"time"
+)
+
+// namesResolver answers .test names from a map, Fail makes every lookup fail
+type namesResolver struct {
+	addresses map[string]string
+	Fail      error
+}
+
+func (f *namesResolver) Name() string { return "fake" }
+
+func (f *namesResolver) Handles(name string) bool { return strings.HasSuffix(name, ".test") }
+
+func (f *namesResolver) Resolve(name string) (names.Record, error) {
+	if f.Fail != nil {
+		return names.Record{}, f.Fail
+	}
+	if address, ok := f.addresses[name]; ok {
+		return names.Record{Name: name, Address: address, Source: "fake"}, nil
+	}
+	return names.Record{}, names.ErrNotFound
+}
+
+func (f *namesResolver) Reverse(address string) (names.Record, error) {
+	return names.Record{}, names.ErrNotFound
+}
+
+func useFakeNames(t *testing.T, addresses map[string]string) *namesResolver {
+	t.Helper()
+	resolver := &namesResolver{addresses: addresses}
+	names.SetResolvers(resolver)
+	t.Cleanup(func() { names.SetResolvers() })
+	return resolver
+}
+
+func getAddrname(address string) entity.Addrnameitem {
+	var addrname entity.Addrnameitem
+	database.Connector.Where("address = ?", address).Find(&addrname)
+	return addrname
+}
+
+func TestNamePolicyIgnoresCommunityNames(t *testing.T) {
+	useTestDB(t, &entity.Addrnameitem{}, &entity.Communityitem{}, &entity.Communityalias{})
+	const (
+		wallet = "0x1111111111111111111111111111111111111111"
+		other  = "0x2222222222222222222222222222222222222222"
+	)
+	useFakeNames(t, map[string]string{"alice.test": wallet})
+	for _, slug := range []string{"bob", "alice"} {
+		database.Connector.Create(&entity.Communityitem{Slug: slug})
+	}
+	database.Connector.Create(&entity.Addrnameitem{Address: "bob", Name: "Bob", Canonical: canonicalName("Bob")})
+	database.Connector.Create(&entity.Addrnameitem{Address: "alice", Name: "alice.test", Canonical: canonicalName("alice.test")})
+	database.Connector.Create(&entity.Addrnameitem{Address: other, Name: "alice.test", Canonical: canonicalName("alice.test")})
+
+	if err := applyNamePolicy(&entity.Addrnameitem{Address: wallet, Name: "Bob"}, false); err != nil {
+		t.Errorf("a community's name blocks a wallet name: %v", err)
+	}
+
+	if err := applyNamePolicy(&entity.Addrnameitem{Address: wallet, Name: "alice.test"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if community := getAddrname("alice"); community.Name != "alice.test" {
+		t.Errorf("the community's name was demoted: %+v", community)
+	}
+	if holder := getAddrname(other); holder.Name != "" || holder.Demotedname != "alice.test" {
+		t.Errorf("the wallet still showing the name was not demoted: %+v", holder)
+	}
+}
+
+func TestRevalidateNamesRetriesFailedFirstChecks(t *testing.T) {
+	useTestDB(t, &entity.Addrnameitem{}, &entity.Communityitem{}, &entity.Communityalias{})
+	const wallet = "0x1111111111111111111111111111111111111111"
+	resolver := useFakeNames(t, map[string]string{"dave.test": wallet})
+	database.Connector.Create(&entity.Communityitem{Slug: "carol"})
+	database.Connector.Create(&entity.Addrnameitem{Address: "carol", Name: "carol.test"})
+	database.Connector.Create(&entity.Addrnameitem{Address: wallet, Name: "dave.test"})
+
+	resolver.Fail = errors.New("naming service down")
+	RevalidateNames()
+	if community := getAddrname("carol"); community.Checkedat != nil || community.Name != "carol.test" {
+		t.Errorf("a community's name was revalidated: %+v", community)
+	}
+	pending := getAddrname(wallet)
+	if pending.Checkedat == nil || pending.Canonical != "" || pending.Verified {
+		t.Fatalf("a failed first check is not stamped for a retry: %+v", pending)
+	}
+	if retryAt := pending.Checkedat.Add(nameRevalidateAfter); retryAt.Before(time.Now()) || retryAt.After(time.Now().Add(nameRevalidateRetry)) {
+		t.Errorf("retried at %v, want within %v", retryAt, nameRevalidateRetry)
+	}
+
+	//once the retry delay passed the row is checked again
+	resolver.Fail = nil
+	database.Connector.Model(&entity.Addrnameitem{}).Where("id = ?", pending.Id).Update("checkedat", time.Now().Add(-nameRevalidateAfter-time.Minute))
+	RevalidateNames()
+	if checked := getAddrname(wallet); !checked.Verified || checked.Canonical != canonicalName("dave.test") {
+		t.Errorf("the retry did not verify the name: %+v", checked)
+	}
+}
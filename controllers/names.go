@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"rest-go-demo/names"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+const maxReverseNames = 50 //addresses per batch lookup, about one inbox page
+
+// ReverseNamesRequest is a batch of addresses to find primary names for
+type ReverseNamesRequest struct {
+	Addresses []string `json:"addresses"`
+}
+
+func writeNameError(w http.ResponseWriter, name string, err error) {
+	switch {
+	case errors.Is(err, names.ErrNotFound):
+		http.Error(w, "name not found", http.StatusNotFound)
+	case errors.Is(err, names.ErrUnsupported):
+		http.Error(w, "unsupported name", http.StatusBadRequest)
+	default:
+		fmt.Println("Error resolving name: ", name, err)
+		http.Error(w, "could not resolve name", http.StatusBadGateway)
+	}
+}
+
+// GetReverseName godoc
+// @Summary     Primary name of an address
+// @Description Reverse resolution (ENS, SpaceID, Stacks BNS, Unstoppable Domains) of an address to its primary name.
+// @Description Only names that resolve back to the address are returned. 404 if the address has no primary name.
+// @Tags        Common
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       address path     string true "Wallet Address"
+// @Success     200     {object} names.Record
+// @Router      /v1/reverse_name/{address} [get]
+func GetReverseName(w http.ResponseWriter, r *http.Request) {
+	address := mux.Vars(r)["address"]
+
+	record, err := names.Reverse(address)
+	if err != nil {
+		writeNameError(w, address, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(record)
+}
+
+// GetReverseNames godoc
+// @Summary     Primary names of many addresses
+// @Description Reverse resolution of up to 50 addresses at once, for rendering an inbox. Addresses without a primary name
+// @Description (or whose naming service failed) are left out of the result, which is keyed by lower case address.
+// @Tags        Common
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       message body     ReverseNamesRequest true "addresses to look up"
+// @Success     200     {object} map[string]names.Record
+// @Router      /v1/reverse_names [post]
+func GetReverseNames(w http.ResponseWriter, r *http.Request) {
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var request ReverseNamesRequest
+	if err := json.Unmarshal(requestBody, &request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	if len(request.Addresses) > maxReverseNames {
+		http.Error(w, fmt.Sprintf("at most %d addresses per request", maxReverseNames), http.StatusBadRequest)
+		return
+	}
+
+	type reverseResult struct {
+		address string
+		record  names.Record
+		err     error
+	}
+	results := make(chan reverseResult)
+	pending := 0
+	seen := map[string]bool{}
+	for _, address := range request.Addresses {
+		address = strings.ToLower(strings.TrimSpace(address))
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+		pending++
+		go func(address string) {
+			record, err := names.Reverse(address)
+			results <- reverseResult{address: address, record: record, err: err}
+		}(address)
+	}
+
+	found := map[string]names.Record{}
+	for ; pending > 0; pending-- {
+		result := <-results
+		if result.err == nil {
+			found[result.address] = result.record
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(found)
+}
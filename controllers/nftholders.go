@@ -0,0 +1,496 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/onchain"
+	"rest-go-demo/ttlcache"
+	"rest-go-demo/webhooks"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	holderThreadRefresh = 10 * time.Minute //how stale a thread's holder may be in the thread list
+	tokenHolderTtl      = time.Minute      //reads reuse a resolved holder this long, sending always resolves on-chain
+	tokenHolderEntries  = 10000
+)
+
+var tokenHolderCache = ttlcache.New[string](tokenHolderEntries) //"chain|nftaddr|nftid" -> holder
+
+var (
+	errHolderThreadNotFound = errors.New("thread not found")
+	errHolderThreadDenied   = errors.New("only the sender and the current holder of the token can use this thread")
+	errTokenNoHolder        = errors.New("the token has no holder (burnt, not minted or unknown contract)")
+	errTokenManyHolders     = errors.New("the token has more than one holder, message holders of ERC-1155 tokens through the NFT chat")
+	errTokenOwnHolder       = errors.New("you hold this token")
+	errHolderBlocked        = errors.New("the receiver blocked you")
+	errHolderLookupFailed   = errors.New("could not look up the token holder, please try again")
+)
+
+// NftHolderMessageRequest starts (or continues) a conversation with whoever holds the token
+type NftHolderMessageRequest struct {
+	Chain         string `json:"chain"` //empty tries every enabled EVM chain in registry order
+	Nftaddr       string `json:"nftaddr"`
+	Nftid         string `json:"nftid"`
+	Message       string `json:"message"`
+	Encryptsymkey string `json:"encrypted_sym_lit_key"`
+	Litaccesscond string `json:"lit_access_conditions"`
+}
+
+// NftHolderThreadMessages is a thread with its messages, Role is how the signed in wallet takes part (sender or holder)
+type NftHolderThreadMessages struct {
+	Thread   entity.Nftholderthread `json:"thread"`
+	Role     string                 `json:"role"`
+	Messages []entity.Chatitem      `json:"messages"`
+}
+
+func writeHolderThreadError(w http.ResponseWriter, err error) {
+	status := http.StatusForbidden
+	switch err {
+	case errHolderThreadNotFound, errTokenNoHolder:
+		status = http.StatusNotFound
+	case errTokenManyHolders, errTokenOwnHolder:
+		status = http.StatusConflict
+	case errHolderLookupFailed:
+		status = http.StatusServiceUnavailable
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func tokenHolderKey(chain string, nftaddr string, nftid string) string {
+	return chain + "|" + strings.ToLower(nftaddr) + "|" + nftid
+}
+
+// resolveTokenHolder finds the single current owner of an ERC-721 token, on the given chain or the first enabled
+// chain the token exists on. Always on-chain (no cache), messages must never go to a previous holder.
+// The holder found is kept for cachedTokenHolder.
+func resolveTokenHolder(chain string, nftaddr string, nftid string) (string, string, error) {
+	var lastErr error
+	for _, holderChain := range holderChains(chain) {
+		owners, err := onchain.TokenOwners(holderChain, nftaddr, nftid)
+		if errors.Is(err, onchain.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		switch len(owners) {
+		case 0:
+			continue
+		case 1:
+			tokenHolderCache.Set(tokenHolderKey(holderChain, nftaddr, nftid), owners[0], tokenHolderTtl)
+			return holderChain, owners[0], nil
+		default:
+			return holderChain, "", errTokenManyHolders
+		}
+	}
+	if lastErr != nil {
+		fmt.Println("Error resolving token holder: ", chain, nftaddr, nftid, lastErr)
+		return "", "", errHolderLookupFailed
+	}
+	return "", "", errTokenNoHolder
+}
+
+// isTokenHolder - the holder itself, or a wallet the holding vault delegated to (delegate.cash)
+func isTokenHolder(walletaddr string, holder string) bool {
+	if strings.EqualFold(walletaddr, holder) {
+		return true
+	}
+	for _, delegation := range auth.GetDelegationsByDelegate(walletaddr) {
+		if strings.EqualFold(delegation.Vault.Hex(), holder) {
+			return true
+		}
+	}
+	return false
+}
+
+// cachedTokenHolder is resolveTokenHolder for reads, a holder resolved in the last tokenHolderTtl is reused
+func cachedTokenHolder(chain string, nftaddr string, nftid string) (string, error) {
+	if holder, ok := tokenHolderCache.Get(tokenHolderKey(chain, nftaddr, nftid)); ok {
+		return holder, nil
+	}
+	_, holder, err := resolveTokenHolder(chain, nftaddr, nftid)
+	return holder, err
+}
+
+// refreshHolderThread re-resolves the holder and stores it when the token changed hands,
+// fresh skips the cache (sending)
+func refreshHolderThread(thread *entity.Nftholderthread, fresh bool) error {
+	var holder string
+	var err error
+	if fresh {
+		_, holder, err = resolveTokenHolder(thread.Chain, thread.Nftaddr, thread.Nftid)
+	} else {
+		holder, err = cachedTokenHolder(thread.Chain, thread.Nftaddr, thread.Nftid)
+	}
+	if err != nil {
+		return err
+	}
+	setHolderThreadHolder(thread, holder)
+	return nil
+}
+
+func setHolderThreadHolder(thread *entity.Nftholderthread, holder string) {
+	updates := map[string]interface{}{"holder": holder, "resolved": time.Now()}
+	if !strings.EqualFold(holder, thread.Holder) {
+		if thread.Holder != "" {
+			log.Println("NFT holder thread moved to new holder: ", thread.Id, thread.Nftaddr, thread.Nftid, thread.Holder, "->", holder)
+		}
+		since := time.Now()
+		thread.Holdersince = &since
+		updates["holdersince"] = since
+	}
+	thread.Holder = holder
+	thread.Resolved = updates["resolved"].(time.Time)
+	database.Connector.Model(thread).Updates(updates)
+}
+
+// holderThreadReadable - the sender reads the whole thread, the holder only the messages sent since it got the token
+// plus the ones it sent or received itself, so a new holder never sees what was said to previous holders.
+// Threads from before Holdersince was tracked only show the holder its own messages.
+func holderThreadReadable(thread entity.Nftholderthread, role string, walletaddr string, chat entity.Chatitem) bool {
+	if role == "sender" {
+		return true
+	}
+	for _, party := range []string{thread.Holder, walletaddr} {
+		if strings.EqualFold(chat.Toaddr, party) || strings.EqualFold(chat.Fromaddr, party) {
+			return true
+		}
+	}
+	return thread.Holdersince != nil && !chat.Timestamp_dtm.Before(*thread.Holdersince)
+}
+
+// holderThreadRole is "sender" or "holder", empty when the wallet is neither (a previous holder loses access)
+func holderThreadRole(thread entity.Nftholderthread, walletaddr string) string {
+	if strings.EqualFold(thread.Fromaddr, walletaddr) {
+		return "sender"
+	}
+	if isTokenHolder(walletaddr, thread.Holder) {
+		return "holder"
+	}
+	return ""
+}
+
+// loadHolderThread finds the thread, refreshes its holder and checks the wallet takes part.
+// The sender reads its thread without a lookup, fresh (sending) always resolves the holder on-chain.
+func loadHolderThread(id string, walletaddr string, fresh bool) (entity.Nftholderthread, string, error) {
+	var thread entity.Nftholderthread
+	if database.Connector.Where("id = ?", id).Find(&thread).RowsAffected == 0 {
+		return thread, "", errHolderThreadNotFound
+	}
+	if !fresh && strings.EqualFold(thread.Fromaddr, walletaddr) {
+		return thread, "sender", nil
+	}
+	if err := refreshHolderThread(&thread, fresh); err != nil {
+		return thread, "", err
+	}
+	role := holderThreadRole(thread, walletaddr)
+	if role == "" {
+		return thread, "", errHolderThreadDenied
+	}
+	return thread, role, nil
+}
+
+// sendHolderThreadMessage stores the message as an NFT DM of the thread and notifies the receiver
+func sendHolderThreadMessage(thread *entity.Nftholderthread, fromaddr string, toaddr string, request NftHolderMessageRequest) (entity.Chatitem, error) {
+	var blockedUser entity.Blockeduser
+	if database.Connector.Where("blockedaddress = ?", fromaddr).Where("owneraddress = ?", toaddr).Find(&blockedUser).RowsAffected > 0 {
+		return entity.Chatitem{}, errHolderBlocked
+	}
+
+	chat := entity.Chatitem{
+		Fromaddr:      fromaddr,
+		Toaddr:        toaddr,
+		Timestamp:     time.Now().Format("2006-01-02T15:04:05.000Z"),
+		Timestamp_dtm: time.Now(),
+		Message:       request.Message,
+		Nftaddr:       thread.Nftaddr,
+		Nftid:         thread.Nftid,
+		Encryptsymkey: request.Encryptsymkey,
+		Litaccesscond: request.Litaccesscond,
+		Holderthread:  thread.Id,
+	}
+	if dbQuery := database.Connector.Create(&chat); dbQuery.Error != nil {
+		return chat, dbQuery.Error
+	}
+	thread.Timestamp = chat.Timestamp_dtm
+	database.Connector.Model(thread).Update("timestamp", thread.Timestamp)
+
+	webhooks.EmitForWallet(entity.WebhookMessageCreated, chat.Toaddr, map[string]interface{}{
+		"id":            chat.Id,
+		"fromaddr":      chat.Fromaddr,
+		"toaddr":        chat.Toaddr,
+		"nftaddr":       chat.Nftaddr,
+		"nftid":         chat.Nftid,
+		"holder_thread": thread.Id,
+		"timestamp":     chat.Timestamp,
+	})
+	go pushNewDirectMessage(chat)
+	return chat, nil
+}
+
+// readableHolderThreadItems drops the NFT DMs of holder threads the wallet no longer takes part in (it sold the token)
+// or that were sent before it got the token, the holder is checked once per thread (cached briefly)
+func readableHolderThreadItems(chat []entity.Chatitem, walletaddr string) []entity.Chatitem {
+	type threadAccess struct {
+		thread entity.Nftholderthread
+		role   string
+	}
+	threads := map[int]*threadAccess{}
+	readable := []entity.Chatitem{}
+	for _, item := range chat {
+		if item.Holderthread == 0 {
+			readable = append(readable, item)
+			continue
+		}
+		access, loaded := threads[item.Holderthread]
+		if !loaded {
+			thread, role, err := loadHolderThread(strconv.Itoa(item.Holderthread), walletaddr, false)
+			if err != nil && err != errHolderThreadDenied {
+				fmt.Println("Error checking NFT holder thread access: ", item.Holderthread, err)
+			}
+			if err == nil {
+				access = &threadAccess{thread: thread, role: role}
+			}
+			threads[item.Holderthread] = access
+		}
+		if access != nil && holderThreadReadable(access.thread, access.role, walletaddr, item) {
+			readable = append(readable, item)
+		}
+	}
+	return readable
+}
+
+// ============================================================================
+
+// CreateNftHolderMessage godoc
+// @Summary     Message the holder of an NFT
+// @Description The holder of nftaddr #nftid is looked up on-chain when sending, the message goes to that wallet.
+// @Description Messages of the same wallet about the same token share a thread, which follows the token when it changes hands.
+// @Description Only ERC-721 style tokens with a single holder, 409 for ERC-1155 tokens held by several wallets.
+// @Tags        NFT
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       message body     NftHolderMessageRequest true "token and message"
+// @Success     201     {object} entity.Chatitem
+// @Router      /v1/nft_holder_threads [post]
+func CreateNftHolderMessage(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var request NftHolderMessageRequest
+	if err := json.Unmarshal(requestBody, &request); err != nil {
+		http.Error(w, "bad request body", http.StatusBadRequest)
+		return
+	}
+	request.Nftaddr = strings.ToLower(strings.TrimSpace(request.Nftaddr))
+	request.Nftid = strings.TrimSpace(request.Nftid)
+	if !evmAddressPattern.MatchString(request.Nftaddr) || !digitsPattern.MatchString(request.Nftid) {
+		http.Error(w, "nftaddr must be a 0x contract address and nftid a token id", http.StatusBadRequest)
+		return
+	}
+	if request.Message == "" {
+		http.Error(w, "message is required", http.StatusBadRequest)
+		return
+	}
+	chain, ok := validChain(request.Chain)
+	if !ok || (chain != "" && !onchain.IsEvmChain(chain)) {
+		http.Error(w, "unsupported chain", http.StatusBadRequest)
+		return
+	}
+
+	chain, holder, err := resolveTokenHolder(chain, request.Nftaddr, request.Nftid)
+	if err != nil {
+		writeHolderThreadError(w, err)
+		return
+	}
+	if isTokenHolder(Authuser.Address, holder) {
+		writeHolderThreadError(w, errTokenOwnHolder)
+		return
+	}
+
+	var thread entity.Nftholderthread
+	database.Connector.Where("chain = ?", chain).Where("nftaddr = ?", request.Nftaddr).Where("nftid = ?", request.Nftid).
+		Where("fromaddr = ?", Authuser.Address).Find(&thread)
+	if thread.Id == 0 {
+		thread = entity.Nftholderthread{Chain: chain, Nftaddr: request.Nftaddr, Nftid: request.Nftid, Fromaddr: Authuser.Address, Timestamp: time.Now()}
+		database.Connector.Create(&thread)
+	}
+	setHolderThreadHolder(&thread, holder)
+
+	chat, err := sendHolderThreadMessage(&thread, Authuser.Address, holder, request)
+	if err == errHolderBlocked {
+		writeHolderThreadError(w, err)
+		return
+	}
+	if err != nil {
+		fmt.Println("Error saving NFT holder message: ", err)
+		http.Error(w, "could not save the message", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(chat)
+}
+
+// GetNftHolderThreads godoc
+// @Summary     Threads the signed in wallet started or holds the token of
+// @Description Holders of threads not checked for a while are re-resolved, threads of tokens the wallet sold drop out.
+// @Description With nftaddr and nftid, every thread about that token is listed when the wallet holds it now (e.g. right after buying).
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Param       nftaddr query   string false "contract address"
+// @Param       nftid   query   string false "token id"
+// @Param       chain   query   string false "chain of the token"
+// @Success     200     {array} entity.Nftholderthread
+// @Router      /v1/nft_holder_threads [get]
+func GetNftHolderThreads(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+	chain, ok := chainParam(w, r)
+	if !ok {
+		return
+	}
+	nftaddr := strings.ToLower(r.URL.Query().Get("nftaddr"))
+	nftid := r.URL.Query().Get("nftid")
+
+	var candidates []entity.Nftholderthread
+	byToken := nftaddr != "" && nftid != ""
+	if byToken {
+		query := database.Connector.Where("nftaddr = ?", nftaddr).Where("nftid = ?", nftid)
+		if chain != "" {
+			query = query.Where("chain = ?", chain)
+		}
+		query.Find(&candidates)
+	} else {
+		database.Connector.Where("fromaddr = ? OR holder = ?", Authuser.Address, strings.ToLower(Authuser.Address)).Find(&candidates)
+	}
+
+	//threads of one token share the holder, cachedTokenHolder resolves it once
+	threads := []entity.Nftholderthread{}
+	for _, thread := range candidates {
+		stale := byToken || time.Since(thread.Resolved) > holderThreadRefresh
+		if !strings.EqualFold(thread.Fromaddr, Authuser.Address) && stale {
+			holder, err := cachedTokenHolder(thread.Chain, thread.Nftaddr, thread.Nftid)
+			if err != nil && err != errTokenNoHolder {
+				fmt.Println("Error refreshing NFT holder thread: ", thread.Id, err)
+			}
+			if holder != "" {
+				setHolderThreadHolder(&thread, holder)
+			}
+		}
+		if holderThreadRole(thread, Authuser.Address) != "" {
+			threads = append(threads, thread)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(threads)
+}
+
+// GetNftHolderThreadMessages godoc
+// @Summary     Messages of an NFT holder thread
+// @Description Only the wallet that started the thread and the current on-chain holder (or its delegate) can read it.
+// @Description The holder sees the messages sent since it got the token and the ones it sent or received, not what previous holders were told.
+// @Description Messages to the wallet are marked read.
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id  path     int true "thread id"
+// @Success     200 {object} NftHolderThreadMessages
+// @Router      /v1/nft_holder_threads/{id} [get]
+func GetNftHolderThreadMessages(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+
+	thread, role, err := loadHolderThread(mux.Vars(r)["id"], Authuser.Address, false)
+	if err != nil {
+		writeHolderThreadError(w, err)
+		return
+	}
+
+	var messages []entity.Chatitem
+	database.Connector.Where("holderthread = ?", thread.Id).Order("id asc").Find(&messages)
+	result := NftHolderThreadMessages{Thread: thread, Role: role, Messages: []entity.Chatitem{}}
+	for _, message := range messages {
+		if holderThreadReadable(thread, role, Authuser.Address, message) {
+			result.Messages = append(result.Messages, message)
+		}
+	}
+
+	reader := thread.Fromaddr
+	if role == "holder" {
+		reader = thread.Holder
+	}
+	database.Connector.Model(&entity.Chatitem{}).Where("holderthread = ?", thread.Id).Where("toaddr = ?", reader).Update("msgread", true)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(result)
+}
+
+// CreateNftHolderThreadMessage godoc
+// @Summary     Send a message in an NFT holder thread
+// @Description The sender's messages go to whoever holds the token now, the holder's replies go to the sender. nftaddr, nftid and chain of the body are not used.
+// @Tags        NFT
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id      path     int                     true "thread id"
+// @Param       message body     NftHolderMessageRequest true "message"
+// @Success     201     {object} entity.Chatitem
+// @Router      /v1/nft_holder_threads/{id}/messages [post]
+func CreateNftHolderThreadMessage(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var request NftHolderMessageRequest
+	if err := json.Unmarshal(requestBody, &request); err != nil || request.Message == "" {
+		http.Error(w, "message is required", http.StatusBadRequest)
+		return
+	}
+
+	thread, role, err := loadHolderThread(mux.Vars(r)["id"], Authuser.Address, true)
+	if err != nil {
+		writeHolderThreadError(w, err)
+		return
+	}
+
+	fromaddr, toaddr := thread.Fromaddr, thread.Holder
+	if role == "holder" {
+		fromaddr, toaddr = thread.Holder, thread.Fromaddr
+		if !strings.EqualFold(Authuser.Address, thread.Holder) {
+			fromaddr = Authuser.Address //a delegate answers for the vault
+		}
+	}
+	chat, err := sendHolderThreadMessage(&thread, fromaddr, toaddr, request)
+	if err == errHolderBlocked {
+		writeHolderThreadError(w, err)
+		return
+	}
+	if err != nil {
+		fmt.Println("Error saving NFT holder message: ", thread.Id, err)
+		http.Error(w, "could not save the message", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(chat)
+}
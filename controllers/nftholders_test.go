@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/onchain"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestHolderThreadReadable(t *testing.T) {
+	const (
+		sender      = "0x1111111111111111111111111111111111111111"
+		oldHolder   = "0x2222222222222222222222222222222222222222"
+		newHolder   = "0x3333333333333333333333333333333333333333"
+		newDelegate = "0x4444444444444444444444444444444444444444"
+	)
+	since := time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)
+	thread := entity.Nftholderthread{Fromaddr: sender, Holder: newHolder, Holdersince: &since}
+	toOldHolder := entity.Chatitem{Fromaddr: sender, Toaddr: oldHolder, Timestamp_dtm: since.Add(-time.Hour)}
+	fromOldHolder := entity.Chatitem{Fromaddr: oldHolder, Toaddr: sender, Timestamp_dtm: since.Add(-time.Minute)}
+	toNewHolder := entity.Chatitem{Fromaddr: sender, Toaddr: newHolder, Timestamp_dtm: since.Add(time.Hour)}
+	fromDelegate := entity.Chatitem{Fromaddr: newDelegate, Toaddr: sender, Timestamp_dtm: since.Add(2 * time.Hour)}
+
+	for _, chat := range []entity.Chatitem{toOldHolder, fromOldHolder, toNewHolder, fromDelegate} {
+		if !holderThreadReadable(thread, "sender", sender, chat) {
+			t.Errorf("the sender can't read %+v", chat)
+		}
+	}
+	if holderThreadReadable(thread, "holder", newHolder, toOldHolder) || holderThreadReadable(thread, "holder", newHolder, fromOldHolder) {
+		t.Error("the new holder reads what was said to the previous holder")
+	}
+	if !holderThreadReadable(thread, "holder", newHolder, toNewHolder) || !holderThreadReadable(thread, "holder", newDelegate, fromDelegate) {
+		t.Error("the new holder can't read its own part of the thread")
+	}
+
+	//threads from before Holdersince was stored only show the holder its own messages
+	thread.Holdersince = nil
+	if !holderThreadReadable(thread, "holder", newDelegate, toNewHolder) || holderThreadReadable(thread, "holder", newHolder, fromOldHolder) {
+		t.Error("without Holdersince the holder should only read messages it sent or received")
+	}
+}
+
+func readHolderThread(t *testing.T, id int, walletaddr string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("GET", "/v1/nft_holder_threads/"+strconv.Itoa(id), nil)
+	r = mux.SetURLVars(r, map[string]string{"id": strconv.Itoa(id)})
+	r = r.WithContext(context.WithValue(r.Context(), "Authuser", auth.Authuser{Address: walletaddr}))
+	w := httptest.NewRecorder()
+	GetNftHolderThreadMessages(w, r)
+	return w
+}
+
+func tokenOwnersCalls(fake *onchain.FakeProvider) int {
+	calls := 0
+	for _, call := range fake.CallLog() {
+		if strings.HasPrefix(call, "TokenOwners(") {
+			calls++
+		}
+	}
+	return calls
+}
+
+func TestHolderThreadReadsUseCachedHolder(t *testing.T) {
+	fake := useFakeChain(t)
+	useTestDB(t, &entity.Nftholderthread{}, &entity.Chatitem{})
+	const sender = "0x3333333333333333333333333333333333333333"
+	thread := entity.Nftholderthread{Chain: "ethereum", Nftaddr: fixtureEthereum, Nftid: "1", Fromaddr: sender, Holder: fixtureHolder}
+	database.Connector.Create(&thread)
+
+	//the sender reads its own thread while the provider is down, the holder can't be checked
+	fake.Fail = errors.New("provider down")
+	if w := readHolderThread(t, thread.Id, sender); w.Code != http.StatusOK {
+		t.Errorf("the sender during an outage: %d %s", w.Code, w.Body.String())
+	}
+	if w := readHolderThread(t, thread.Id, fixtureHolder); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("the holder during an outage: %d", w.Code)
+	}
+
+	//the holder is looked up once, then reused by reads
+	fake.Fail = nil
+	before := tokenOwnersCalls(fake)
+	for i := 0; i < 3; i++ {
+		if w := readHolderThread(t, thread.Id, fixtureHolder); w.Code != http.StatusOK {
+			t.Fatalf("the holder: %d %s", w.Code, w.Body.String())
+		}
+	}
+	if calls := tokenOwnersCalls(fake) - before; calls != 1 {
+		t.Errorf("3 reads made %d holder lookups, want 1", calls)
+	}
+
+	//sending never trusts the cache
+	if _, _, err := loadHolderThread(strconv.Itoa(thread.Id), sender, true); err != nil {
+		t.Fatal(err)
+	}
+	if calls := tokenOwnersCalls(fake) - before; calls != 2 {
+		t.Errorf("sending used the cached holder (%d lookups)", calls)
+	}
+}
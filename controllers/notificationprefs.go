@@ -0,0 +1,237 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"strings"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// notification channels that can be turned on/off per conversation
+const (
+	NotifyChannelEmail    = "email"
+	NotifyChannelTelegram = "telegram"
+	NotifyChannelPush     = "push"
+)
+
+// NotificationPreferenceUpdate is the PUT body, channels left out keep their current value (default on)
+type NotificationPreferenceUpdate struct {
+	Contexttype string     `json:"context_type"`
+	Target      string     `json:"target"`
+	Mode        string     `json:"mode"`
+	Muteduntil  *time.Time `json:"muted_until"`
+	Email       *bool      `json:"email"`
+	Telegram    *bool      `json:"telegram"`
+	Push        *bool      `json:"push"`
+}
+
+func defaultNotifyMode(contextType string) string {
+	if contextType == entity.DM {
+		return entity.NotifyModeAll
+	}
+	return entity.NotifyModeMentions
+}
+
+func defaultNotificationPreference(walletaddr string, contextType string, target string) entity.Notificationpreference {
+	return entity.Notificationpreference{
+		Walletaddr:  walletaddr,
+		Contexttype: contextType,
+		Target:      target,
+		Mode:        defaultNotifyMode(contextType),
+		Email:       true,
+		Telegram:    true,
+		Push:        true,
+	}
+}
+
+// GetNotificationPreference returns the stored preference or the defaults for the conversation
+func GetNotificationPreference(walletaddr string, contextType string, target string) entity.Notificationpreference {
+	walletaddr = strings.ToLower(walletaddr)
+	target = strings.ToLower(target)
+
+	var pref entity.Notificationpreference
+	dbQuery := database.Connector.Where("walletaddr = ?", walletaddr).
+		Where("contexttype = ?", contextType).
+		Where("target = ?", target).
+		Find(&pref)
+	if dbQuery.RowsAffected == 0 {
+		return defaultNotificationPreference(walletaddr, contextType, target)
+	}
+	return pref
+}
+
+// effectiveNotifyMode treats an expired mute as the conversation default
+func effectiveNotifyMode(pref entity.Notificationpreference) string {
+	if pref.Mode == entity.NotifyModeMuted && pref.Muteduntil != nil && pref.Muteduntil.Before(time.Now()) {
+		return defaultNotifyMode(pref.Contexttype)
+	}
+	return pref.Mode
+}
+
+func notifyChannelEnabled(pref entity.Notificationpreference, channel string) bool {
+	switch channel {
+	case NotifyChannelEmail:
+		return pref.Email
+	case NotifyChannelTelegram:
+		return pref.Telegram
+	case NotifyChannelPush:
+		return pref.Push
+	}
+	return false
+}
+
+// ShouldNotify checks the per-conversation preference for one channel
+// the global Settings.Notifydm flag is still checked by the caller (it turns everything off)
+func ShouldNotify(walletaddr string, contextType string, target string, channel string, mentioned bool) bool {
+	pref := GetNotificationPreference(walletaddr, contextType, target)
+	switch effectiveNotifyMode(pref) {
+	case entity.NotifyModeMuted:
+		return false
+	case entity.NotifyModeMentions:
+		//a DM is always addressed to the recipient (rows saved before "mentions" was refused for DMs)
+		if !mentioned && contextType != entity.DM {
+			return false
+		}
+	}
+	return notifyChannelEnabled(pref, channel)
+}
+
+// getAllMessagesSubscribers returns wallets that opted in to every message of a group chat
+func getAllMessagesSubscribers(contextType string, target string) []string {
+	var prefs []entity.Notificationpreference
+	database.Connector.Where("contexttype = ?", contextType).
+		Where("target = ?", strings.ToLower(target)).
+		Where("mode = ?", entity.NotifyModeAll).
+		Find(&prefs)
+
+	var wallets []string
+	for _, pref := range prefs {
+		wallets = append(wallets, pref.Walletaddr)
+	}
+	return wallets
+}
+
+// ============================================================================
+
+// GetNotificationPreferences godoc
+// @Summary     Get per-conversation notification preferences
+// @Description Only conversations that differ from the defaults are returned (DMs: all, NFT/community chats: mentions, all channels on)
+// @Tags        Common
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200     {array} entity.Notificationpreference
+// @Router      /v1/notification_preferences [get]
+func GetNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+
+	var prefs []entity.Notificationpreference
+	database.Connector.Where("walletaddr = ?", strings.ToLower(Authuser.Address)).Order("timestamp desc").Find(&prefs)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// UpdateNotificationPreference godoc
+// @Summary     Mute/unmute or change notifications for one DM, NFT chat or community
+// @Description context_type is dm, nft or community, target is the DM peer address, NFT contract or community slug
+// @Description mode is all, mentions (NFT/community chats only) or muted (optionally with muted_until), email/telegram/push turn single channels on/off
+// @Tags        Common
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       message body    NotificationPreferenceUpdate true "preference for one conversation"
+// @Success     200     {object} entity.Notificationpreference
+// @Router      /v1/notification_preferences [put]
+func UpdateNotificationPreference(w http.ResponseWriter, r *http.Request) {
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var update NotificationPreferenceUpdate
+	if err := json.Unmarshal(requestBody, &update); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if update.Contexttype != entity.DM && update.Contexttype != entity.Nft && update.Contexttype != entity.Community {
+		http.Error(w, "context_type must be dm, nft or community", http.StatusBadRequest)
+		return
+	}
+	if strings.TrimSpace(update.Target) == "" {
+		http.Error(w, "target is required", http.StatusBadRequest)
+		return
+	}
+
+	Authuser := auth.GetUserFromReqContext(r)
+	pref := GetNotificationPreference(Authuser.Address, update.Contexttype, strings.TrimSpace(update.Target))
+
+	if update.Mode != "" {
+		if update.Mode != entity.NotifyModeAll && update.Mode != entity.NotifyModeMentions && update.Mode != entity.NotifyModeMuted {
+			http.Error(w, "mode must be all, mentions or muted", http.StatusBadRequest)
+			return
+		}
+		if update.Mode == entity.NotifyModeMentions && update.Contexttype == entity.DM {
+			http.Error(w, "mode mentions is only for group chats, use all or muted for a DM", http.StatusBadRequest)
+			return
+		}
+		pref.Mode = update.Mode
+		pref.Muteduntil = nil
+	}
+	if update.Muteduntil != nil {
+		if pref.Mode != entity.NotifyModeMuted {
+			http.Error(w, "muted_until can only be set with mode muted", http.StatusBadRequest)
+			return
+		}
+		pref.Muteduntil = update.Muteduntil
+	}
+	if update.Email != nil {
+		pref.Email = *update.Email
+	}
+	if update.Telegram != nil {
+		pref.Telegram = *update.Telegram
+	}
+	if update.Push != nil {
+		pref.Push = *update.Push
+	}
+	pref.Timestamp = time.Now()
+
+	var err error
+	if pref.Id == 0 {
+		err = database.Connector.Create(&pref).Error
+	} else {
+		err = database.Connector.Save(&pref).Error
+	}
+	if err != nil {
+		fmt.Println("UpdateNotificationPreference error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(pref)
+}
+
+// DeleteNotificationPreference godoc
+// @Summary     Reset notifications for one conversation back to the defaults
+// @Tags        Common
+// @Security    BearerAuth
+// @Param       context_type path string true "dm, nft or community"
+// @Param       target       path string true "DM peer address, NFT contract or community slug"
+// @Success     204
+// @Router      /v1/notification_preferences/{context_type}/{target} [delete]
+func DeleteNotificationPreference(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	Authuser := auth.GetUserFromReqContext(r)
+
+	database.Connector.Where("walletaddr = ?", strings.ToLower(Authuser.Address)).
+		Where("contexttype = ?", vars["context_type"]).
+		Where("target = ?", strings.ToLower(vars["target"])).
+		Delete(&entity.Notificationpreference{})
+	w.WriteHeader(http.StatusNoContent)
+}
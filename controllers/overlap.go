@@ -0,0 +1,596 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/dune"
+	"rest-go-demo/entity"
+	"rest-go-demo/webhooks"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/libstring"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/gorilla/mux"
+)
+
+const (
+	overlapResultTtl     = 6 * time.Hour       //finished results are served from the DB, newer jobs are only run after this
+	overlapJobTimeout    = 20 * time.Minute    //queries still running after this are cancelled
+	overlapJobRetention  = 30 * 24 * time.Hour //finished jobs are deleted after this (their results stay until replaced)
+	overlapPollMin       = 15 * time.Second
+	overlapPollMax       = 2 * time.Minute
+	overlapClaimDuration = 5 * time.Minute //a worker owns a job this long, a crashed worker's jobs are picked up again after it
+	overlapBatch         = 20              //jobs per worker run
+	overlapMaxRows       = 200             //rows kept per contract
+	overlapJobsPerHour   = 10              //new jobs per IP and per wallet, cached results and running jobs don't count
+	overlapMaxActive     = 50              //pending and running jobs of everyone, new ones wait until some finish
+)
+
+// the saved Dune query of each chain, both take a token_address parameter
+var overlapQueries = map[string]int{
+	"ethereum": 3615247,
+	"solana":   3623869,
+}
+
+var (
+	errOverlapChain       = errors.New("unsupported chain, use ethereum or solana")
+	errOverlapAddress     = errors.New("invalid contract address")
+	errOverlapNotFound    = errors.New("token overlap job not found")
+	errOverlapNoResults   = errors.New("no token overlap results for this contract, submit a job first")
+	errOverlapUnavailable = errors.New("token overlap analytics are not configured")
+	errOverlapFinished    = errors.New("the job already finished")
+	errOverlapRateLimited = errors.New("too many token overlap jobs, please try again later")
+	errOverlapBusy        = errors.New("too many token overlap jobs are queued, please try again later")
+)
+
+// every new Dune job is counted against the requester's IP and, when signed in, the wallet
+var overlapJobLimiter = tollbooth.NewLimiter(overlapJobsPerHour/3600.0, &limiter.ExpirableOptions{DefaultExpirationTTL: time.Hour}).
+	SetBurst(overlapJobsPerHour).
+	SetIPLookups([]string{"RemoteAddr", "X-Forwarded-For", "X-Real-IP"})
+
+// OverlapResult is a job and, once it is done, the typed result rows (ordered by position)
+type OverlapResult struct {
+	Job  entity.Overlapjob   `json:"job"`
+	Rows []entity.Overlaprow `json:"rows"`
+}
+
+func writeOverlapError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err {
+	case errOverlapChain, errOverlapAddress:
+		status = http.StatusBadRequest
+	case errOverlapNotFound, errOverlapNoResults:
+		status = http.StatusNotFound
+	case errOverlapUnavailable, errOverlapBusy:
+		status = http.StatusServiceUnavailable
+	case errOverlapRateLimited:
+		status = http.StatusTooManyRequests
+	case errOverlapFinished:
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// normalizeOverlapContract checks the address for the chain, EVM addresses are lowercased (Solana mints are case sensitive)
+func normalizeOverlapContract(chain string, contract string) (string, error) {
+	contract = strings.TrimSpace(contract)
+	switch chain {
+	case "ethereum":
+		if !common.IsHexAddress(contract) {
+			return "", errOverlapAddress
+		}
+		return strings.ToLower(contract), nil
+	case "solana":
+		if len(contract) < 32 || len(contract) > 44 || strings.ContainsAny(contract, "0OIl+/=") {
+			return "", errOverlapAddress
+		}
+		return contract, nil
+	}
+	return "", errOverlapChain
+}
+
+func newOverlapJobId() (string, error) {
+	code, err := randomCode(24)
+	if err != nil {
+		return "", err
+	}
+	return strings.ToLower(code), nil
+}
+
+func getOverlapJob(jobId string) (entity.Overlapjob, error) {
+	var job entity.Overlapjob
+	if database.Connector.Where("jobid = ?", jobId).Find(&job).RowsAffected == 0 {
+		return job, errOverlapNotFound
+	}
+	return job, nil
+}
+
+// cachedOverlapJob is the newest finished job of the contract, if it finished within the result TTL
+func cachedOverlapJob(chain string, contract string) (entity.Overlapjob, bool) {
+	var job entity.Overlapjob
+	found := database.Connector.Where("chain = ?", chain).Where("contract = ?", contract).
+		Where("status = ?", entity.OverlapStatusDone).Where("finished >= ?", time.Now().Add(-overlapResultTtl)).
+		Order("finished desc").Limit(1).Find(&job).RowsAffected > 0
+	return job, found
+}
+
+func getOverlapRows(chain string, contract string) []entity.Overlaprow {
+	rows := []entity.Overlaprow{}
+	database.Connector.Where("chain = ?", chain).Where("contract = ?", contract).Order("position asc").Find(&rows)
+	for i := range rows {
+		if rows[i].Extracolumns != "" {
+			json.Unmarshal([]byte(rows[i].Extracolumns), &rows[i].Extra)
+		}
+	}
+	return rows
+}
+
+// overlapResult adds the rows to a done job, the rows are those of the contract's newest done job
+func overlapResult(job entity.Overlapjob) OverlapResult {
+	result := OverlapResult{Job: job, Rows: []entity.Overlaprow{}}
+	if job.Status == entity.OverlapStatusDone {
+		result.Rows = getOverlapRows(job.Chain, job.Contract)
+	}
+	return result
+}
+
+// overlapRequestIp is the caller's IP as the limiters see it
+func overlapRequestIp(r *http.Request) string {
+	return libstring.RemoteIP(overlapJobLimiter.GetIPLookups(), overlapJobLimiter.GetForwardedForIndexFromBehind(), r)
+}
+
+// overlapRateLimited takes a new job from the IP's and the wallet's allowance
+func overlapRateLimited(walletaddr string, ip string) bool {
+	if tollbooth.LimitByKeys(overlapJobLimiter, []string{"ip|" + ip}) != nil {
+		return true
+	}
+	return walletaddr != "" && tollbooth.LimitByKeys(overlapJobLimiter, []string{"wallet|" + strings.ToLower(walletaddr)}) != nil
+}
+
+// submitOverlapJob returns fresh cached results (a done job) unless refresh is set, or the pending/running job of the
+// contract, or queues a new job for the worker. Only new jobs count against the requester's limits.
+func submitOverlapJob(chain string, contract string, walletaddr string, ip string, refresh bool) (entity.Overlapjob, error) {
+	if _, ok := overlapQueries[chain]; !ok {
+		return entity.Overlapjob{}, errOverlapChain
+	}
+	contract, err := normalizeOverlapContract(chain, contract)
+	if err != nil {
+		return entity.Overlapjob{}, err
+	}
+	if !refresh {
+		if job, found := cachedOverlapJob(chain, contract); found {
+			return job, nil
+		}
+	}
+
+	var active entity.Overlapjob
+	if database.Connector.Where("chain = ?", chain).Where("contract = ?", contract).
+		Where("status IN (?)", []string{entity.OverlapStatusPending, entity.OverlapStatusRunning}).
+		Order("submitted desc").Limit(1).Find(&active).RowsAffected > 0 {
+		return active, nil
+	}
+	if !dune.Configured() {
+		return entity.Overlapjob{}, errOverlapUnavailable
+	}
+	var activeCount int
+	database.Connector.Model(&entity.Overlapjob{}).
+		Where("status IN (?)", []string{entity.OverlapStatusPending, entity.OverlapStatusRunning}).Count(&activeCount)
+	if activeCount >= overlapMaxActive {
+		return entity.Overlapjob{}, errOverlapBusy
+	}
+	if overlapRateLimited(walletaddr, ip) {
+		log.Println("Token overlap - rate limited: ", ip, walletaddr)
+		return entity.Overlapjob{}, errOverlapRateLimited
+	}
+
+	jobId, err := newOverlapJobId()
+	if err != nil {
+		return entity.Overlapjob{}, err
+	}
+	now := time.Now()
+	job := entity.Overlapjob{
+		Jobid:      jobId,
+		Chain:      chain,
+		Contract:   contract,
+		Walletaddr: walletaddr,
+		Status:     entity.OverlapStatusPending,
+		Nextpoll:   now,
+		Submitted:  now,
+	}
+	if err := database.Connector.Create(&job).Error; err != nil {
+		return entity.Overlapjob{}, err
+	}
+	return job, nil
+}
+
+// overlapPollDelay backs off from the scheduler interval to overlapPollMax while a query runs
+func overlapPollDelay(polls int) time.Duration {
+	delay := overlapPollMin
+	for i := 0; i < polls && delay < overlapPollMax; i++ {
+		delay *= 2
+	}
+	if delay > overlapPollMax {
+		delay = overlapPollMax
+	}
+	return delay
+}
+
+// RunOverlapJobs starts pending overlap queries and polls running ones, run on a schedule. State is in the DB so any
+// replica can run it, a job is only handled by the replica that moved its Nextpoll (the claim).
+func RunOverlapJobs() {
+	now := time.Now()
+	var expired []entity.Overlapjob
+	database.Connector.Where("status IN (?)", []string{entity.OverlapStatusPending, entity.OverlapStatusRunning}).
+		Where("submitted < ?", now.Add(-overlapJobTimeout)).Find(&expired)
+	for _, job := range expired {
+		cancelOverlapJob(job, "timed out after "+overlapJobTimeout.String())
+	}
+	database.Connector.Where("status NOT IN (?)", []string{entity.OverlapStatusPending, entity.OverlapStatusRunning}).
+		Where("submitted < ?", now.Add(-overlapJobRetention)).Delete(&entity.Overlapjob{})
+
+	var due []entity.Overlapjob
+	database.Connector.Where("status IN (?)", []string{entity.OverlapStatusPending, entity.OverlapStatusRunning}).
+		Where("nextpoll <= ?", now).Order("nextpoll asc").Limit(overlapBatch).Find(&due)
+	for _, job := range due {
+		claimed := database.Connector.Model(&entity.Overlapjob{}).Where("id = ?", job.Id).Where("status = ?", job.Status).
+			Where("nextpoll = ?", job.Nextpoll).Update("nextpoll", time.Now().Add(overlapClaimDuration)).RowsAffected
+		if claimed == 0 {
+			continue //another replica took it
+		}
+		if job.Status == entity.OverlapStatusPending {
+			startOverlapJob(job)
+		} else {
+			pollOverlapJob(job)
+		}
+	}
+}
+
+// startOverlapJob executes the Dune query, failures to start are retried with backoff until the job times out
+func startOverlapJob(job entity.Overlapjob) {
+	executionId, err := dune.Execute(overlapQueries[job.Chain], map[string]string{"token_address": job.Contract})
+	if err != nil {
+		log.Println("Token overlap - could not start job: ", job.Jobid, err)
+		database.Connector.Model(&entity.Overlapjob{}).Where("id = ?", job.Id).Where("status = ?", entity.OverlapStatusPending).
+			Updates(map[string]interface{}{"polls": job.Polls + 1, "nextpoll": time.Now().Add(overlapPollDelay(job.Polls + 1))})
+		return
+	}
+	now := time.Now()
+	database.Connector.Model(&entity.Overlapjob{}).Where("id = ?", job.Id).Where("status = ?", entity.OverlapStatusPending).
+		Updates(map[string]interface{}{
+			"status":      entity.OverlapStatusRunning,
+			"executionid": executionId,
+			"started":     now,
+			"polls":       0,
+			"nextpoll":    now.Add(overlapPollMin),
+		})
+}
+
+func pollOverlapJob(job entity.Overlapjob) {
+	execution, err := dune.Status(job.Executionid)
+	if errors.Is(err, dune.ErrNotFound) {
+		finishOverlapJob(job, entity.OverlapStatusFailed, "the Dune execution no longer exists", nil)
+		return
+	}
+	if err != nil || !execution.Finished {
+		if err != nil {
+			log.Println("Token overlap - could not poll job: ", job.Jobid, err)
+		}
+		database.Connector.Model(&entity.Overlapjob{}).Where("id = ?", job.Id).Where("status = ?", entity.OverlapStatusRunning).
+			Updates(map[string]interface{}{"polls": job.Polls + 1, "nextpoll": time.Now().Add(overlapPollDelay(job.Polls + 1))})
+		return
+	}
+	if !execution.Succeeded() {
+		message := execution.Error
+		if message == "" {
+			message = "the query ended in state " + execution.State
+		}
+		finishOverlapJob(job, entity.OverlapStatusFailed, message, nil)
+		return
+	}
+	finishOverlapJob(job, entity.OverlapStatusDone, "", execution.Rows)
+}
+
+// finishOverlapJob replaces the contract's cached rows when the job is done, and tells the submitter
+func finishOverlapJob(job entity.Overlapjob, status string, message string, duneRows []dune.Row) {
+	now := time.Now()
+	if status == entity.OverlapStatusDone {
+		if err := replaceOverlapRows(job.Chain, job.Contract, duneRows, now); err != nil {
+			log.Println("Token overlap - could not store results: ", job.Jobid, err)
+			status, message = entity.OverlapStatusFailed, "could not store the results"
+		}
+	}
+	rowCount := 0
+	if status == entity.OverlapStatusDone {
+		rowCount = len(duneRows)
+		if rowCount > overlapMaxRows {
+			rowCount = overlapMaxRows
+		}
+	}
+	updated := database.Connector.Model(&entity.Overlapjob{}).Where("id = ?", job.Id).
+		Where("status IN (?)", []string{entity.OverlapStatusPending, entity.OverlapStatusRunning}).
+		Updates(map[string]interface{}{"status": status, "error": message, "rowcount": rowCount, "finished": now}).RowsAffected
+	if updated == 0 || job.Walletaddr == "" {
+		return
+	}
+	webhooks.EmitForWallet(entity.WebhookOverlapCompleted, job.Walletaddr, map[string]interface{}{
+		"job_id":           job.Jobid,
+		"chain":            job.Chain,
+		"contract_address": job.Contract,
+		"status":           status,
+		"error":            message,
+		"row_count":        rowCount,
+	})
+}
+
+// cancelOverlapJob stops the Dune execution (if it started), the job isn't polled again
+func cancelOverlapJob(job entity.Overlapjob, reason string) bool {
+	if job.Executionid != "" {
+		if err := dune.Cancel(job.Executionid); err != nil && !errors.Is(err, dune.ErrNotFound) {
+			log.Println("Token overlap - could not cancel the Dune execution: ", job.Jobid, err)
+		}
+	}
+	return database.Connector.Model(&entity.Overlapjob{}).Where("id = ?", job.Id).
+		Where("status IN (?)", []string{entity.OverlapStatusPending, entity.OverlapStatusRunning}).
+		Updates(map[string]interface{}{"status": entity.OverlapStatusCancelled, "error": reason, "finished": time.Now()}).RowsAffected > 0
+}
+
+func replaceOverlapRows(chain string, contract string, duneRows []dune.Row, timestamp time.Time) error {
+	tx := database.Connector.Begin()
+	if err := tx.Where("chain = ?", chain).Where("contract = ?", contract).Delete(&entity.Overlaprow{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	for i, duneRow := range duneRows {
+		if i >= overlapMaxRows {
+			break
+		}
+		row := normalizeOverlapRow(duneRow)
+		row.Chain, row.Contract, row.Position, row.Timestamp = chain, contract, i+1, timestamp
+		if err := tx.Create(&row).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit().Error
+}
+
+// column names the overlap queries (and older versions of them) use for each field
+var (
+	overlapTokenColumns      = []string{"token_address", "token", "contract_address", "token_mint_address", "mint", "address"}
+	overlapSymbolColumns     = []string{"symbol", "token_symbol"}
+	overlapNameColumns       = []string{"name", "token_name"}
+	overlapHoldersColumns    = []string{"holders", "overlapping_holders", "overlap_holders", "shared_holders", "common_holders", "holder_count", "overlap"}
+	overlapPercentageColumns = []string{"percentage", "overlap_percentage", "holder_percentage", "pct", "percent", "share"}
+)
+
+// normalizeOverlapRow maps a Dune row to the typed fields, columns it doesn't know are kept in Extracolumns
+func normalizeOverlapRow(duneRow dune.Row) entity.Overlaprow {
+	extra := map[string]interface{}{}
+	for column, value := range duneRow {
+		extra[strings.ToLower(column)] = value
+	}
+	take := func(columns []string) (interface{}, bool) {
+		for _, column := range columns {
+			if value, ok := extra[column]; ok && value != nil {
+				delete(extra, column)
+				return value, true
+			}
+		}
+		return nil, false
+	}
+
+	var row entity.Overlaprow
+	if value, ok := take(overlapTokenColumns); ok {
+		row.Token = fmt.Sprint(value)
+	}
+	if value, ok := take(overlapSymbolColumns); ok {
+		row.Symbol = fmt.Sprint(value)
+	}
+	if value, ok := take(overlapNameColumns); ok {
+		row.Name = fmt.Sprint(value)
+	}
+	if value, ok := take(overlapHoldersColumns); ok {
+		holders, _ := overlapNumber(value)
+		row.Holders = int64(holders)
+	}
+	if value, ok := take(overlapPercentageColumns); ok {
+		row.Percentage, _ = overlapNumber(value)
+	}
+	if len(extra) > 0 {
+		if encoded, err := json.Marshal(extra); err == nil {
+			row.Extracolumns = string(encoded)
+		}
+	}
+	return row
+}
+
+// overlapNumber reads a number Dune returned as a JSON number or a string
+func overlapNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// ============================================================================
+
+// SubmitTokenOverlap godoc
+// @Summary     Start a token holder overlap analysis
+// @Description Finds the other tokens the holders of a token hold, computed by a Dune query in the background.
+// @Description Returns the job right away: 200 with the rows when fresh results (under 6 hours old) exist, 202 otherwise.
+// @Description Poll GET /v1/token_overlap_job/{job_id}, or subscribe to the overlap.completed webhook.
+// @Description Only one job per contract runs at a time, submitting again returns the running job.
+// @Description New jobs (including refresh) are limited to 10 an hour per IP and wallet (429), 503 while the queue is full.
+// @Tags        Analytics
+// @Produce     json
+// @Security    BearerAuth
+// @Param       chain            path     string true  "ethereum or solana"
+// @Param       contract_address path     string true  "Token contract (mint) address"
+// @Param       refresh          query    bool   false "ignore cached results"
+// @Success     200              {object} OverlapResult
+// @Success     202              {object} OverlapResult
+// @Router      /v1/token_overlap/{chain}/{contract_address} [post]
+func SubmitTokenOverlap(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+	vars := mux.Vars(r)
+
+	job, err := submitOverlapJob(strings.ToLower(vars["chain"]), vars["contract_address"], Authuser.Address, overlapRequestIp(r), r.URL.Query().Get("refresh") == "true")
+	if err != nil {
+		writeOverlapError(w, err)
+		return
+	}
+	writeOverlapResult(w, job)
+}
+
+// GetTokenOverlap godoc
+// @Summary     Latest token holder overlap results of a token
+// @Description The rows of the newest finished job, whatever its age (see job.finished), 404 if no job finished yet
+// @Tags        Analytics
+// @Produce     json
+// @Security    BearerAuth
+// @Param       chain            path     string true "ethereum or solana"
+// @Param       contract_address path     string true "Token contract (mint) address"
+// @Success     200              {object} OverlapResult
+// @Router      /v1/token_overlap/{chain}/{contract_address} [get]
+func GetTokenOverlap(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	chain := strings.ToLower(vars["chain"])
+	if _, ok := overlapQueries[chain]; !ok {
+		writeOverlapError(w, errOverlapChain)
+		return
+	}
+	contract, err := normalizeOverlapContract(chain, vars["contract_address"])
+	if err != nil {
+		writeOverlapError(w, err)
+		return
+	}
+
+	var job entity.Overlapjob
+	if database.Connector.Where("chain = ?", chain).Where("contract = ?", contract).Where("status = ?", entity.OverlapStatusDone).
+		Order("finished desc").Limit(1).Find(&job).RowsAffected == 0 {
+		writeOverlapError(w, errOverlapNoResults)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(overlapResult(job))
+}
+
+// GetTokenOverlapJob godoc
+// @Summary     Status of a token holder overlap job
+// @Description status is pending, running, done (rows included), failed or cancelled (see error). Also public at /token_overlap_job/{job_id}.
+// @Tags        Analytics
+// @Produce     json
+// @Security    BearerAuth
+// @Param       job_id path     string true "Job id"
+// @Success     200    {object} OverlapResult
+// @Router      /v1/token_overlap_job/{job_id} [get]
+func GetTokenOverlapJob(w http.ResponseWriter, r *http.Request) {
+	job, err := getOverlapJob(mux.Vars(r)["job_id"])
+	if err != nil {
+		writeOverlapError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(overlapResult(job))
+}
+
+// CancelTokenOverlapJob godoc
+// @Summary     Cancel a token holder overlap job
+// @Description Only the wallet that submitted it (or an admin API key), 409 if it already finished
+// @Tags        Analytics
+// @Produce     json
+// @Security    BearerAuth
+// @Param       job_id path     string true "Job id"
+// @Success     200    {object} entity.Overlapjob
+// @Router      /v1/token_overlap_job/{job_id} [delete]
+func CancelTokenOverlapJob(w http.ResponseWriter, r *http.Request) {
+	job, err := getOverlapJob(mux.Vars(r)["job_id"])
+	if err != nil {
+		writeOverlapError(w, err)
+		return
+	}
+	if !isAdminApiRequest(r) {
+		Authuser := auth.GetUserFromReqContext(r)
+		if job.Walletaddr == "" || !strings.EqualFold(job.Walletaddr, Authuser.Address) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+	if !cancelOverlapJob(job, "cancelled by the requester") {
+		writeOverlapError(w, errOverlapFinished)
+		return
+	}
+	job, _ = getOverlapJob(job.Jobid)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(job)
+}
+
+// writeOverlapResult answers 200 for a done job (with its rows) and 202 while it is queued or running
+func writeOverlapResult(w http.ResponseWriter, job entity.Overlapjob) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if job.Status != entity.OverlapStatusDone {
+		w.WriteHeader(http.StatusAccepted)
+	}
+	json.NewEncoder(w).Encode(overlapResult(job))
+}
+
+// publicTokenOverlap is the custom GPT endpoint of a chain, it no longer waits for the query (see SubmitTokenOverlap)
+func publicTokenOverlap(w http.ResponseWriter, r *http.Request, chain string) {
+	job, err := submitOverlapJob(chain, mux.Vars(r)["contract_address"], "", overlapRequestIp(r), false)
+	if err != nil {
+		writeOverlapError(w, err)
+		return
+	}
+	writeOverlapResult(w, job)
+}
+
+// Erc20TokenOverlap godoc
+// @Summary     Token holder overlap of an ERC20 token (for the custom GPT)
+// @Description No sign-in, same as POST /v1/token_overlap/ethereum/{contract_address}: 200 with cached rows,
+// @Description otherwise 202 with a job to poll at GET /token_overlap_job/{job_id}. New jobs are limited per IP (429).
+// @Tags        Analytics
+// @Produce     json
+// @Param       contract_address path     string true "ERC20 contract address"
+// @Success     200              {object} OverlapResult
+// @Success     202              {object} OverlapResult
+// @Router      /ethereum_token_overlap/{contract_address} [get]
+func Erc20TokenOverlap(w http.ResponseWriter, r *http.Request) {
+	publicTokenOverlap(w, r, "ethereum")
+}
+
+// SolTokenOverlap godoc
+// @Summary     Token holder overlap of a Solana token (for the custom GPT)
+// @Description No sign-in, same as POST /v1/token_overlap/solana/{contract_address}: 200 with cached rows,
+// @Description otherwise 202 with a job to poll at GET /token_overlap_job/{job_id}. New jobs are limited per IP (429).
+// @Tags        Analytics
+// @Produce     json
+// @Param       contract_address path     string true "SPL token mint address"
+// @Success     200              {object} OverlapResult
+// @Success     202              {object} OverlapResult
+// @Router      /solana_token_overlap/{contract_address} [get]
+func SolTokenOverlap(w http.ResponseWriter, r *http.Request) {
+	publicTokenOverlap(w, r, "solana")
+}
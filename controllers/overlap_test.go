@@ -0,0 +1,43 @@
+package controllers
+
+import (
+	"fmt"
+	"rest-go-demo/dune"
+	"testing"
+)
+
+func TestOverlapRateLimited(t *testing.T) {
+	const (
+		ip     = "203.0.113.7"
+		wallet = "0x1111111111111111111111111111111111111111"
+	)
+	for i := 0; i < overlapJobsPerHour; i++ {
+		if overlapRateLimited("", ip) {
+			t.Fatalf("job %d from the IP was limited", i)
+		}
+	}
+	if !overlapRateLimited("", ip) {
+		t.Error("the IP isn't limited after its hourly allowance")
+	}
+	if !overlapRateLimited(wallet, ip) {
+		t.Error("a signed in wallet gets around its IP's limit")
+	}
+
+	//a wallet is limited on its own, whatever IPs it comes from
+	for i := 0; i < overlapJobsPerHour; i++ {
+		overlapRateLimited("0x2222222222222222222222222222222222222222", fmt.Sprintf("198.51.100.%d", i))
+	}
+	if !overlapRateLimited("0x2222222222222222222222222222222222222222", "198.51.100.200") {
+		t.Error("the wallet isn't limited after its hourly allowance")
+	}
+}
+
+func TestNormalizeOverlapRow(t *testing.T) {
+	row := normalizeOverlapRow(dune.Row{"token_mint_address": "EPjF", "symbol": "USDC", "holder_count": "5400", "pct": 27.1, "chain": "solana"})
+	if row.Token != "EPjF" || row.Symbol != "USDC" || row.Holders != 5400 || row.Percentage != 27.1 {
+		t.Errorf("row = %+v", row)
+	}
+	if row.Extracolumns != `{"chain":"solana"}` {
+		t.Errorf("extra columns = %s", row.Extracolumns)
+	}
+}
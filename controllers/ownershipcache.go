@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/onchain"
+	"rest-go-demo/ttlcache"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	ownershipOwnerTtl     = 10 * time.Minute
+	ownershipNonOwnerTtl  = 2 * time.Minute //short so people who just bought in don't wait long
+	ownershipCacheEntries = 100000
+)
+
+var (
+	ownershipCache  = ttlcache.New[bool](ownershipCacheEntries)
+	ownershipFlight singleflight.Group
+
+	//delegate.cash lookup, replaced in tests
+	delegationsByDelegate = auth.GetDelegationsByDelegate
+
+	ownershipHits      int64
+	ownershipMisses    int64
+	ownershipCoalesced int64
+	ownershipErrors    int64
+)
+
+// OwnershipCacheStats are the holder check cache counters since startup
+type OwnershipCacheStats struct {
+	Hits      int64   `json:"hits"`
+	Misses    int64   `json:"misses"`
+	Coalesced int64   `json:"coalesced"` //misses that shared a provider check with concurrent requests
+	Errors    int64   `json:"errors"`    //provider failures, these results are not cached
+	Entries   int     `json:"entries"`
+	HitRate   float64 `json:"hit_rate"`
+}
+
+// the wallet comes first so InvalidateOwnershipCache can drop a wallet by prefix
+func ownershipCacheKey(walletAddr string, contractAddr string, chain string) string {
+	return strings.ToLower(walletAddr) + "|" + strings.ToLower(contractAddr) + "|" + chain
+}
+
+func setCachedOwnership(key string, owner bool) {
+	ttl := ownershipNonOwnerTtl
+	if owner {
+		ttl = ownershipOwnerTtl
+	}
+	ownershipCache.Set(key, owner, ttl)
+}
+
+// InvalidateOwnershipCache drops every cached holder check of the wallet, called on sign-in
+// so a wallet that just bought (or sold) is checked again
+func InvalidateOwnershipCache(walletAddr string) {
+	ownershipCache.DeletePrefix(strings.ToLower(walletAddr) + "|")
+}
+
+// walletVaults looks up the vaults that delegated to a wallet (delegate.cash) on first use and only once,
+// so checking a contract on every enabled chain makes a single registry call
+type walletVaults struct {
+	wallet string
+	once   sync.Once
+	vaults []string
+}
+
+func newWalletVaults(walletAddr string) *walletVaults {
+	return &walletVaults{wallet: walletAddr}
+}
+
+func (v *walletVaults) get() []string {
+	v.once.Do(func() {
+		for _, delegateWallet := range delegationsByDelegate(v.wallet) {
+			v.vaults = append(v.vaults, delegateWallet.Vault.Hex())
+		}
+	})
+	return v.vaults
+}
+
+// isOwnerOnChains is IsOwnerOfNFT over several chains in order, sharing one delegation lookup
+func isOwnerOnChains(contractAddr string, walletAddr string, chains []string) bool {
+	owner, _ := ownsOnChains(contractAddr, walletAddr, chains)
+	return owner
+}
+
+// ownsOnChains is isOwnerOnChains with the provider error, set when no chain passed and a check failed, so callers
+// that take access away can tell "not an owner" from "could not check"
+func ownsOnChains(contractAddr string, walletAddr string, chains []string) (bool, error) {
+	vaults := newWalletVaults(walletAddr)
+	var lastErr error
+	for _, chain := range chains {
+		owner, err := cachedNftOwnership(contractAddr, walletAddr, chain, vaults)
+		if owner {
+			return true, nil
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return false, lastErr
+}
+
+// cachedNftOwnership answers from the cache, concurrent misses for the same key share one provider check.
+// Failed checks are not cached and return the provider error.
+func cachedNftOwnership(contractAddr string, walletAddr string, chain string, vaults *walletVaults) (bool, error) {
+	key := ownershipCacheKey(walletAddr, contractAddr, chain)
+	if owner, ok := ownershipCache.Get(key); ok {
+		atomic.AddInt64(&ownershipHits, 1)
+		return owner, nil
+	}
+	atomic.AddInt64(&ownershipMisses, 1)
+
+	value, err, shared := ownershipFlight.Do(key, func() (interface{}, error) {
+		owner, err := checkNftOwnership(contractAddr, walletAddr, chain, vaults)
+		if err == nil {
+			setCachedOwnership(key, owner)
+		}
+		return owner, err
+	})
+	if shared {
+		atomic.AddInt64(&ownershipCoalesced, 1)
+	}
+	if err != nil {
+		atomic.AddInt64(&ownershipErrors, 1)
+		fmt.Println("Error checking NFT owner - IsOwnerOfNFT: ", contractAddr, walletAddr, chain, err)
+	}
+	return value.(bool), err
+}
+
+// checkNftOwnership checks the wallet and, on EVM chains, the vaults that delegated to it (delegate.cash)
+// the error is only set when no wallet passed and a provider failed, so a failure is never cached as "not an owner"
+func checkNftOwnership(contractAddr string, walletAddr string, chain string, vaults *walletVaults) (bool, error) {
+	owner, err := isOwnerOfNftLocal(contractAddr, walletAddr, chain)
+	if owner || !onchain.IsEvmChain(chain) {
+		return owner, err
+	}
+
+	lastErr := err
+	for _, vault := range vaults.get() {
+		owner, err := isOwnerOfNftLocal(contractAddr, vault, chain)
+		if owner {
+			return true, nil //if we find an NFT, can stop here
+		}
+		if err != nil {
+			lastErr = err
+		}
+	}
+	return false, lastErr
+}
+
+func isOwnerOfNftLocal(contractAddr string, walletAddr string, chain string) (bool, error) {
+	balance, err := onchain.NftBalance(chain, contractAddr, walletAddr, "")
+	if err != nil {
+		return false, err
+	}
+	return balance.Sign() > 0, nil
+}
+
+func getOwnershipCacheStats() OwnershipCacheStats {
+	stats := OwnershipCacheStats{
+		Hits:      atomic.LoadInt64(&ownershipHits),
+		Misses:    atomic.LoadInt64(&ownershipMisses),
+		Coalesced: atomic.LoadInt64(&ownershipCoalesced),
+		Errors:    atomic.LoadInt64(&ownershipErrors),
+		Entries:   ownershipCache.Len(),
+	}
+	if stats.Hits+stats.Misses > 0 {
+		stats.HitRate = float64(stats.Hits) / float64(stats.Hits+stats.Misses)
+	}
+	return stats
+}
+
+// GetOwnershipCacheStats godoc
+// @Summary     Holder check cache hit/miss counters
+// @Description Only for WalletChat, requires an admin API key as the Bearer token
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} OwnershipCacheStats
+// @Router      /v1/ownership_cache/stats [get]
+func GetOwnershipCacheStats(w http.ResponseWriter, r *http.Request) {
+	if !isAdminApiRequest(r) {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(getOwnershipCacheStats())
+}
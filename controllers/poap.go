@@ -0,0 +1,477 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/poap"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/didip/tollbooth/v7"
+	"github.com/didip/tollbooth/v7/libstring"
+	"github.com/didip/tollbooth/v7/limiter"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+)
+
+const (
+	poapEventTtl      = 24 * time.Hour //event metadata rarely changes after the drop
+	maxPoapGateEvents = 20             //events in one "any of these events" condition
+
+	//wrong secret codes lock the wallet and the IP out for poapSecretLockout after the last miss, never the event,
+	//so guessing can't lock an organiser out of their own event. The IP limit is higher for shared connections.
+	poapSecretWalletFailures = 5
+	poapSecretIpFailures     = 20
+	poapSecretLockout        = time.Hour
+	poapSecretIpBurst        = 10
+)
+
+var (
+	errPoapEventNotFound = errors.New("POAP event not found")
+	errPoapSecretInvalid = errors.New("the secret code is not valid for this POAP event")
+	errPoapLookupFailed  = errors.New("could not reach POAP, please try again")
+	errPoapSecretLocked  = errors.New("too many wrong secret codes, please try again later")
+)
+
+// secret guesses are also limited per IP, like email verification codes
+var poapSecretLimiter = tollbooth.NewLimiter(1.0/60, &limiter.ExpirableOptions{DefaultExpirationTTL: time.Hour}).
+	SetBurst(poapSecretIpBurst).
+	SetIPLookups([]string{"RemoteAddr", "X-Forwarded-For", "X-Real-IP"}).
+	SetIgnoreURL(true).
+	SetMessage(errPoapSecretLocked.Error())
+
+var (
+	poapEventRefreshing      = map[int]bool{}
+	poapEventRefreshingMutex sync.Mutex
+)
+
+// PoapEventSecret proves the caller organises the event, the secret code is the one POAP gave when the event was created
+type PoapEventSecret struct {
+	Eventid    int    `json:"event_id"`
+	Secretcode string `json:"secret_code"`
+}
+
+// PoapCommunityRequest creates a community only holders of the events' POAPs can join
+type PoapCommunityRequest struct {
+	Name   string                         `json:"name"` //defaults to the first event's name
+	Image  string                         `json:"image"`
+	Social []entity.CommunitySocialStruct `json:"social"`
+	Events []PoapEventSecret              `json:"events"`
+}
+
+// PoapCommunityResult is the new community with its POAP gate
+type PoapCommunityResult struct {
+	Slug   string             `json:"slug"`
+	Events []entity.Poapevent `json:"events"`
+}
+
+func writePoapError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch err {
+	case errPoapLookupFailed:
+		status = http.StatusBadGateway
+	case errPoapEventNotFound:
+		status = http.StatusNotFound
+	case errPoapSecretInvalid:
+		status = http.StatusForbidden
+	case errPoapSecretLocked:
+		status = http.StatusTooManyRequests
+	case errCommunitySlugTaken:
+		status = http.StatusConflict
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// poapEventIds are the events of a chat address or access condition, "poap_1" or a list "poap_1,poap_2" (prefix optional)
+func poapEventIds(nftaddr string) ([]int, bool) {
+	var eventIds []int
+	for _, part := range strings.Split(nftaddr, ",") {
+		eventId, ok := poap.ParseEventId(part)
+		if !ok {
+			return nil, false
+		}
+		eventIds = append(eventIds, eventId)
+	}
+	return eventIds, len(eventIds) > 0
+}
+
+func poapEventRow(event poap.Event) entity.Poapevent {
+	return entity.Poapevent{
+		Id:          event.Id,
+		Fancyid:     event.FancyId,
+		Name:        event.Name,
+		Description: event.Description,
+		Imageurl:    event.ImageUrl,
+		Eventurl:    event.EventUrl,
+		City:        event.City,
+		Country:     event.Country,
+		Startdate:   event.StartDate,
+		Enddate:     event.EndDate,
+		Expirydate:  event.ExpiryDate,
+		Supply:      event.Supply,
+		Cachedat:    time.Now(),
+	}
+}
+
+func savePoapEvent(event poap.Event) entity.Poapevent {
+	row := poapEventRow(event)
+	database.Connector.Save(&row)
+	return row
+}
+
+// cachedPoapEvent is the event's metadata, fetched again once a day. A stale row is used while POAP is down.
+func cachedPoapEvent(eventId int) (entity.Poapevent, error) {
+	var row entity.Poapevent
+	found := database.Connector.Where("id = ?", eventId).Find(&row).RowsAffected > 0
+	if found && time.Since(row.Cachedat) < poapEventTtl {
+		return row, nil
+	}
+
+	event, err := poap.GetEvent(eventId)
+	if errors.Is(err, poap.ErrNotFound) {
+		return entity.Poapevent{}, errPoapEventNotFound
+	}
+	if err != nil {
+		fmt.Println("Error getting POAP event: ", eventId, err)
+		if found {
+			return row, nil
+		}
+		return entity.Poapevent{}, errPoapLookupFailed
+	}
+	return savePoapEvent(event), nil
+}
+
+// inboxPoapEvents loads the stored metadata of every POAP chat in the inbox with one query, keyed by event id
+func inboxPoapEvents(bookmarks []entity.Bookmarkitem) map[int]entity.Poapevent {
+	events := map[int]entity.Poapevent{}
+	var eventIds []int
+	for _, bookmark := range bookmarks {
+		if eventId, ok := poap.ParseEventId(bookmark.Nftaddr); ok {
+			eventIds = append(eventIds, eventId)
+		}
+	}
+	if len(eventIds) == 0 {
+		return events
+	}
+	var rows []entity.Poapevent
+	database.Connector.Where("id IN (?)", eventIds).Find(&rows)
+	for _, row := range rows {
+		events[row.Id] = row
+	}
+	return events
+}
+
+// inboxPoapEvent never waits on POAP: it returns the metadata loaded by inboxPoapEvents (nil when there is none yet)
+// and refreshes missing or stale rows in the background
+func inboxPoapEvent(events map[int]entity.Poapevent, nftaddr string) *entity.Poapevent {
+	eventId, ok := poap.ParseEventId(nftaddr)
+	if !ok {
+		return nil
+	}
+	row, found := events[eventId]
+	if !found || time.Since(row.Cachedat) >= poapEventTtl {
+		poapEventRefreshingMutex.Lock()
+		refreshing := poapEventRefreshing[eventId]
+		poapEventRefreshing[eventId] = true
+		poapEventRefreshingMutex.Unlock()
+		if !refreshing {
+			go func() {
+				cachedPoapEvent(eventId)
+				poapEventRefreshingMutex.Lock()
+				delete(poapEventRefreshing, eventId)
+				poapEventRefreshingMutex.Unlock()
+			}()
+		}
+	}
+	if !found {
+		return nil
+	}
+	return &row
+}
+
+// poapSecretKeys are the wallet's and the IP's miss counters with their limits
+func poapSecretKeys(walletAddr string, ip string) map[string]int {
+	return map[string]int{
+		"wallet|" + strings.ToLower(walletAddr): poapSecretWalletFailures,
+		"ip|" + ip:                              poapSecretIpFailures,
+	}
+}
+
+// takePoapSecretAttempt counts a secret code guess against a key up front, as a miss. The check and the count are one
+// UPDATE, so concurrent guesses can't pass the limit. False once the key used up its misses within poapSecretLockout.
+func takePoapSecretAttempt(key string, limit int) bool {
+	for try := 0; try < 2; try++ {
+		now := time.Now()
+		expires := now.Add(poapSecretLockout)
+		counted := database.Connector.Model(&entity.Poapsecretfailure{}).Where("failurekey = ?", key).
+			Where("expires > ?", now).Where("misses < ?", limit).
+			UpdateColumns(map[string]interface{}{"misses": gorm.Expr("misses + ?", 1), "expires": expires})
+		if counted.Error == nil && counted.RowsAffected > 0 {
+			return true
+		}
+		//an expired count starts over, a live one at the limit is locked out
+		restarted := database.Connector.Model(&entity.Poapsecretfailure{}).Where("failurekey = ?", key).
+			Where("expires <= ?", now).UpdateColumns(map[string]interface{}{"misses": 1, "expires": expires})
+		if restarted.Error == nil && restarted.RowsAffected > 0 {
+			return true
+		}
+		var existing []entity.Poapsecretfailure
+		if database.Connector.Where("failurekey = ?", key).Limit(1).Find(&existing).RowsAffected > 0 {
+			return false
+		}
+		//a concurrent first guess may create the row too, the unique index lets one win and the other tries again
+		if database.Connector.Create(&entity.Poapsecretfailure{Failurekey: key, Misses: 1, Expires: expires}).Error == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// returnPoapSecretAttempt takes back a guess that turned out right (or could not be checked)
+func returnPoapSecretAttempt(key string) {
+	database.Connector.Model(&entity.Poapsecretfailure{}).Where("failurekey = ?", key).Where("misses > ?", 0).
+		UpdateColumn("misses", gorm.Expr("misses - ?", 1))
+}
+
+// takePoapSecretAttempts counts the guess against the wallet and the IP, false (nothing counted) when either is locked out
+func takePoapSecretAttempts(walletAddr string, ip string) bool {
+	taken := []string{}
+	for key, limit := range poapSecretKeys(walletAddr, ip) {
+		if !takePoapSecretAttempt(key, limit) {
+			for _, takenKey := range taken {
+				returnPoapSecretAttempt(takenKey)
+			}
+			return false
+		}
+		taken = append(taken, key)
+	}
+	return true
+}
+
+func returnPoapSecretAttempts(walletAddr string, ip string) {
+	for key := range poapSecretKeys(walletAddr, ip) {
+		returnPoapSecretAttempt(key)
+	}
+}
+
+// holdsAnyPoap checks the wallet holds a POAP of any of the events, errors are POAP API failures
+func holdsAnyPoap(eventIds []int, walletAddr string) (bool, error) {
+	return poap.HoldsAnyEvent(walletAddr, eventIds)
+}
+
+// IsOwnerOfPOAP - a failed POAP lookup counts as not holding it
+func IsOwnerOfPOAP(eventId string, walletAddr string) bool {
+	id, ok := poap.ParseEventId(eventId)
+	if !ok {
+		return false
+	}
+	owner, err := poap.HoldsEvent(walletAddr, id)
+	if err != nil {
+		fmt.Println("Error checking POAP owner - IsOwnerOfPOAP: ", eventId, walletAddr, err)
+	}
+	return owner
+}
+
+// internal use only
+func AutoJoinPoapChats(walletAddr string) {
+	//https://documentation.poap.tech/reference/getactionsscan-5
+	tokens, err := poap.Scan(walletAddr)
+	if err != nil {
+		if !errors.Is(err, poap.ErrUnsupported) {
+			fmt.Println("Error getting POAPs - AutoJoinPoapChats: ", walletAddr, err)
+		}
+		return
+	}
+	for _, token := range tokens {
+		var poapAddr = poap.ChatAddress(token.Event.Id)
+
+		//the scan has the event metadata, keep it for the inbox
+		var cached entity.Poapevent
+		if database.Connector.Where("id = ?", token.Event.Id).Where("cachedat > ?", time.Now().Add(-poapEventTtl)).Find(&cached).RowsAffected == 0 {
+			savePoapEvent(token.Event)
+		}
+
+		var bookmarkExists entity.Bookmarkitem
+		var dbResult = database.Connector.Where("nftaddr = ?", poapAddr).Where("walletaddr = ?", walletAddr).Find(&bookmarkExists)
+
+		if dbResult.RowsAffected == 0 {
+			//check if the user already manually unjoined, if so don't auto rejoin them
+			var userUnjoined entity.Userunjoined
+			var dbUnjoined = database.Connector.Where("nftaddr = ?", poapAddr).Where("walletaddr = ?", walletAddr).Find(&userUnjoined)
+			userAlreadyUnjoined := false
+			if dbUnjoined.RowsAffected > 0 {
+				userAlreadyUnjoined = userUnjoined.Unjoined
+			}
+
+			if !userAlreadyUnjoined {
+				fmt.Printf("POAP is new for user: %#v\n", walletAddr)
+				var bookmark entity.Bookmarkitem
+
+				bookmark.Nftaddr = poapAddr
+				bookmark.Walletaddr = walletAddr
+				bookmark.Chain = token.Chain
+
+				database.Connector.Create(&bookmark)
+			}
+		}
+	}
+}
+
+// GetPoapsByAddr godoc
+// @Summary     POAPs held by a wallet
+// @Description Every POAP of the wallet with its event, straight from the POAP API (keeps the API key server side)
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Param       wallet path    string true "Wallet Address"
+// @Success     200    {array} poap.Token
+// @Router      /v1/get_poaps/{wallet} [get]
+func GetPoapsByAddr(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	walletAddr := vars["wallet"]
+
+	result, err := poap.Scan(walletAddr)
+	if errors.Is(err, poap.ErrUnsupported) {
+		result, err = []poap.Token{}, nil
+	}
+	if err != nil {
+		fmt.Println("Error getting POAPs - GetPoapsByAddr: ", walletAddr, err)
+		writePoapError(w, errPoapLookupFailed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GetPoapEvent godoc
+// @Summary     POAP event metadata
+// @Description Name, image, dates and place of a POAP event, cached for a day
+// @Tags        NFT
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id  path     string true "POAP event id (with or without poap_)"
+// @Success     200 {object} entity.Poapevent
+// @Router      /v1/poap_event/{id} [get]
+func GetPoapEvent(w http.ResponseWriter, r *http.Request) {
+	eventId, ok := poap.ParseEventId(mux.Vars(r)["id"])
+	if !ok {
+		http.Error(w, "id must be a POAP event id", http.StatusBadRequest)
+		return
+	}
+
+	event, err := cachedPoapEvent(eventId)
+	if err != nil {
+		writePoapError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(event)
+}
+
+// CreatePoapCommunity godoc
+// @Summary     Create a community for POAP holders (event organisers)
+// @Description The caller proves they organise every event with its secret code (from when the event was created on POAP).
+// @Description The community is gated on holding a POAP of any of the events and the caller becomes its admin.
+// @Description 403 if a secret code is wrong, 404 for unknown events, 429 after too many wrong codes from the wallet
+// @Description or from the IP (locked out for an hour)
+// @Tags        GroupChat
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       message body     PoapCommunityRequest true "events with their secret codes, name and image are optional"
+// @Success     201     {object} PoapCommunityResult
+// @Router      /v1/poap_community [post]
+func CreatePoapCommunity(w http.ResponseWriter, r *http.Request) {
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var request PoapCommunityRequest
+	if err := json.Unmarshal(requestBody, &request); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+	Authuser := auth.GetUserFromReqContext(r)
+	if httpError := tollbooth.LimitByRequest(poapSecretLimiter, w, r); httpError != nil {
+		http.Error(w, httpError.Message, httpError.StatusCode)
+		return
+	}
+	ip := libstring.RemoteIP(poapSecretLimiter.GetIPLookups(), poapSecretLimiter.GetForwardedForIndexFromBehind(), r)
+
+	if len(request.Events) == 0 || len(request.Events) > maxPoapGateEvents {
+		http.Error(w, fmt.Sprintf("events needs 1 to %d POAP events", maxPoapGateEvents), http.StatusBadRequest)
+		return
+	}
+
+	result := PoapCommunityResult{Events: []entity.Poapevent{}}
+	var chatAddrs []string
+	seen := map[int]bool{}
+	for _, secret := range request.Events {
+		if secret.Eventid <= 0 || strings.TrimSpace(secret.Secretcode) == "" {
+			http.Error(w, "every event needs an event_id and its secret_code", http.StatusBadRequest)
+			return
+		}
+		if seen[secret.Eventid] {
+			continue
+		}
+		seen[secret.Eventid] = true
+		if !takePoapSecretAttempts(Authuser.Address, ip) {
+			writePoapError(w, errPoapSecretLocked)
+			return
+		}
+
+		event, err := cachedPoapEvent(secret.Eventid)
+		if err != nil {
+			returnPoapSecretAttempts(Authuser.Address, ip)
+			writePoapError(w, err)
+			return
+		}
+		valid, err := poap.ValidateSecret(secret.Eventid, strings.TrimSpace(secret.Secretcode))
+		if err != nil {
+			returnPoapSecretAttempts(Authuser.Address, ip)
+			fmt.Println("Error validating POAP secret - CreatePoapCommunity: ", secret.Eventid, err)
+			writePoapError(w, errPoapLookupFailed)
+			return
+		}
+		if !valid {
+			log.Println("POAP secret rejected: ", secret.Eventid, Authuser.Address, ip)
+			writePoapError(w, errPoapSecretInvalid)
+			return
+		}
+		returnPoapSecretAttempts(Authuser.Address, ip)
+		result.Events = append(result.Events, event)
+		chatAddrs = append(chatAddrs, poap.ChatAddress(event.Id))
+	}
+
+	name := strings.TrimSpace(request.Name)
+	if name == "" {
+		name = result.Events[0].Name
+	}
+	slug, err := createCommunity(entity.Createcommunityitem{Name: name, Image: request.Image, Social: request.Social}, Authuser.Address)
+	if err != nil {
+		writePoapError(w, err)
+		return
+	}
+	replaceAccessConditions(slug, "", []entity.Communityaccesscondition{{
+		Slug:    slug,
+		Type:    AccessTypePoap,
+		Nftaddr: strings.Join(chatAddrs, ","),
+		Count:   "1",
+	}})
+	result.Slug = slug
+	log.Println("POAP community created: ", slug, len(chatAddrs), "events by", Authuser.Address)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"fmt"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPoapSecretLockout(t *testing.T) {
+	useTestDB(t, &entity.Poapsecretfailure{})
+	const (
+		wallet = "0xAbC0000000000000000000000000000000000001"
+		other  = "0x0000000000000000000000000000000000000002"
+		ip     = "203.0.113.7"
+	)
+
+	for i := 0; i < poapSecretWalletFailures; i++ {
+		if !takePoapSecretAttempts(wallet, ip) {
+			t.Fatalf("locked out after %d misses", i)
+		}
+	}
+	if takePoapSecretAttempts(wallet, "198.51.100.1") {
+		t.Error("the wallet isn't locked out from another IP")
+	}
+	if !takePoapSecretAttempts(other, "198.51.100.1") {
+		t.Error("another wallet on another IP is locked out, guesses must not lock the event")
+	}
+
+	//misses from many wallets on one IP lock the IP
+	for i := poapSecretWalletFailures; i < poapSecretIpFailures; i++ {
+		takePoapSecretAttempts(fmt.Sprintf("0x%040d", i), ip)
+	}
+	if takePoapSecretAttempts("0x0000000000000000000000000000000000000003", ip) {
+		t.Error("the IP isn't locked out after too many misses")
+	}
+
+	//right codes are given back, an expired count starts over
+	returnPoapSecretAttempts(wallet, ip)
+	if !takePoapSecretAttempt("wallet|0xabc0000000000000000000000000000000000001", poapSecretWalletFailures) {
+		t.Error("a guess given back still counts")
+	}
+	database.Connector.Model(&entity.Poapsecretfailure{}).Update("expires", time.Now().Add(-time.Minute))
+	if !takePoapSecretAttempts(wallet, ip) {
+		t.Error("still locked out after the lockout expired")
+	}
+}
+
+func TestPoapSecretAttemptsConcurrent(t *testing.T) {
+	useTestDB(t, &entity.Poapsecretfailure{})
+	database.Connector.Create(&entity.Poapsecretfailure{Failurekey: "wallet|0x1", Misses: 0, Expires: time.Now().Add(time.Hour)})
+
+	var taken int64
+	var wg sync.WaitGroup
+	for i := 0; i < 3*poapSecretWalletFailures; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if takePoapSecretAttempt("wallet|0x1", poapSecretWalletFailures) {
+				atomic.AddInt64(&taken, 1)
+			}
+		}()
+	}
+	wg.Wait()
+	if taken != poapSecretWalletFailures {
+		t.Errorf("%d concurrent guesses got through, the limit is %d", taken, poapSecretWalletFailures)
+	}
+}
@@ -0,0 +1,232 @@
+package controllers
+
+import (
+	"regexp"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"rest-go-demo/names"
+	"rest-go-demo/push"
+	"strconv"
+	"strings"
+)
+
+// cap on how many people one group message can ping
+const maxMentionsPerMessage = 10
+
+// @name.eth, @somename or @0x... (names are looked up in Addrnameitem)
+var mentionRegex = regexp.MustCompile(`@([a-zA-Z0-9_.\-]{2,64})`)
+
+func senderDisplayName(walletaddr string) string {
+	var addrname entity.Addrnameitem
+	database.Connector.Where("address = ?", walletaddr).Find(&addrname)
+	if addrname.Name != "" {
+		return addrname.Name
+	}
+	if primaryName := names.CachedPrimaryName(walletaddr); primaryName != "" {
+		return primaryName
+	}
+	if len(walletaddr) > 10 {
+		return walletaddr[:6] + "..." + walletaddr[len(walletaddr)-4:]
+	}
+	return walletaddr
+}
+
+// pushNewDirectMessage notifies the receiver's devices of a new DM (message content is not sent, DMs are usually encrypted)
+func pushNewDirectMessage(chat entity.Chatitem) {
+	if !ShouldNotify(chat.Toaddr, entity.DM, chat.Fromaddr, NotifyChannelPush, false) {
+		return
+	}
+
+	data := map[string]string{
+		"type":     entity.DM,
+		"fromaddr": chat.Fromaddr,
+		"id":       strconv.Itoa(chat.Id),
+	}
+	if chat.Nftaddr != "" {
+		data["type"] = entity.Nft
+		data["nftaddr"] = chat.Nftaddr
+		data["nftid"] = chat.Nftid
+	}
+	push.NotifyWallet(chat.Toaddr, push.Notification{
+		Title: "New message in WalletChat",
+		Body:  "You have a message from " + senderDisplayName(chat.Fromaddr),
+		Url:   "https://app.walletchat.fun",
+		Data:  data,
+	})
+}
+
+// isGroupMember checks the wallet has joined (bookmarked) the NFT or community chat
+func isGroupMember(walletaddr string, groupaddr string) bool {
+	var bookmark entity.Bookmarkitem
+	dbQuery := database.Connector.Where("walletaddr = ?", walletaddr).Where("nftaddr = ?", groupaddr).Find(&bookmark)
+	return dbQuery.RowsAffected > 0
+}
+
+// findMentionedWallets returns the joined members of a group chat that are @mentioned in the message
+func findMentionedWallets(message string, groupaddr string, fromaddr string) []string {
+	var wallets []string
+	seen := map[string]bool{strings.ToLower(fromaddr): true}
+	for _, match := range mentionRegex.FindAllStringSubmatch(message, -1) {
+		if len(wallets) >= maxMentionsPerMessage {
+			break
+		}
+
+		mention := strings.TrimRight(match[1], ".-")
+		walletaddr := ""
+		if strings.HasPrefix(strings.ToLower(mention), "0x") && len(mention) == 42 {
+			walletaddr = strings.ToLower(mention)
+		} else {
+			var addrname entity.Addrnameitem
+			dbQuery := database.Connector.Where("name = ?", mention).Find(&addrname)
+			if dbQuery.RowsAffected == 0 {
+				continue
+			}
+			walletaddr = strings.ToLower(addrname.Address)
+		}
+		if seen[walletaddr] {
+			continue
+		}
+		seen[walletaddr] = true
+
+		//only ping people who are in the chat
+		if isGroupMember(walletaddr, groupaddr) {
+			wallets = append(wallets, walletaddr)
+		}
+	}
+	return wallets
+}
+
+// walletsBlocking are the (lowercased) wallets that blocked the wallet
+func walletsBlocking(walletaddr string) map[string]bool {
+	var owners []string
+	database.Connector.Model(&entity.Blockeduser{}).Where("LOWER(blockedaddress) = ?", strings.ToLower(walletaddr)).Pluck("owneraddress", &owners)
+	blocking := map[string]bool{}
+	for _, owner := range owners {
+		blocking[strings.ToLower(owner)] = true
+	}
+	return blocking
+}
+
+// notifyCommunityAnnouncement notifies every active member of an admin announcement. Announcements ignore the
+// all/mentions/muted mode so members who muted the regular chatter still get them, the per-channel switches still apply.
+// Members who blocked the sender are skipped.
+func notifyCommunityAnnouncement(chat entity.Groupchatitem) {
+	var members []entity.Communitymember
+	database.Connector.Where("slug = ?", chat.Nftaddr).Where("status = ?", entity.MemberStatusActive).Find(&members)
+	if len(members) == 0 {
+		return
+	}
+
+	var addrname entity.Addrnameitem
+	database.Connector.Where("address = ?", chat.Nftaddr).Find(&addrname)
+	communityName := addrname.Name
+	if communityName == "" {
+		communityName = chat.Nftaddr
+	}
+	title := "Announcement in " + communityName
+	body := []rune(chat.Message)
+	if len(body) > 140 {
+		body = append(body[:137], []rune("...")...)
+	}
+	data := map[string]string{
+		"type":     entity.Community,
+		"fromaddr": chat.Fromaddr,
+		"nftaddr":  chat.Nftaddr,
+		"channel":  chat.Channel,
+		"id":       strconv.Itoa(chat.Id),
+	}
+
+	blockedBy := walletsBlocking(chat.Fromaddr)
+	for _, member := range members {
+		if strings.EqualFold(member.Walletaddr, chat.Fromaddr) || blockedBy[strings.ToLower(member.Walletaddr)] {
+			continue
+		}
+		if chat.Channel != "" && !channelAccessAllowed(chat.Nftaddr, chat.Channel, member.Walletaddr) {
+			continue
+		}
+		pref := GetNotificationPreference(member.Walletaddr, entity.Community, chat.Nftaddr)
+		if notifyChannelEnabled(pref, NotifyChannelPush) {
+			push.NotifyWallet(member.Walletaddr, push.Notification{
+				Title: title,
+				Body:  string(body),
+				Url:   "https://app.walletchat.fun",
+				Data:  data,
+			})
+		}
+		if notifyChannelEnabled(pref, NotifyChannelTelegram) {
+			var settings entity.Settings
+			dbQuery := database.Connector.Where("walletaddr = ?", member.Walletaddr).Find(&settings)
+			if dbQuery.RowsAffected > 0 && settings.Telegramid != "" && strings.EqualFold(settings.Notifydm, "true") {
+				SendTelegramMessage(title+": "+string(body), settings.Telegramid)
+			}
+		}
+	}
+}
+
+// notifyGroupMessage notifies members that were @mentioned, or that set the chat to "all" messages,
+// over push and Telegram depending on their per-conversation preferences (group chats are not encrypted)
+func notifyGroupMessage(chat entity.Groupchatitem, contextType string) {
+	mentioned := map[string]bool{}
+	for _, walletaddr := range findMentionedWallets(chat.Message, chat.Nftaddr, chat.Fromaddr) {
+		mentioned[walletaddr] = true
+	}
+	recipients := []string{}
+	for walletaddr := range mentioned {
+		recipients = append(recipients, walletaddr)
+	}
+	for _, walletaddr := range getAllMessagesSubscribers(contextType, chat.Nftaddr) {
+		if !mentioned[walletaddr] && !strings.EqualFold(walletaddr, chat.Fromaddr) && isGroupMember(walletaddr, chat.Nftaddr) {
+			recipients = append(recipients, walletaddr)
+		}
+	}
+	//channels with their own access conditions only notify wallets that can read them
+	if chat.Channel != "" {
+		allowed := []string{}
+		for _, walletaddr := range recipients {
+			if channelAccessAllowed(chat.Nftaddr, chat.Channel, walletaddr) {
+				allowed = append(allowed, walletaddr)
+			}
+		}
+		recipients = allowed
+	}
+	if len(recipients) == 0 {
+		return
+	}
+
+	senderName := senderDisplayName(chat.Fromaddr)
+	body := []rune(chat.Message)
+	if len(body) > 140 {
+		body = append(body[:137], []rune("...")...)
+	}
+	data := map[string]string{
+		"type":     contextType,
+		"fromaddr": chat.Fromaddr,
+		"nftaddr":  chat.Nftaddr,
+		"channel":  chat.Channel,
+		"id":       strconv.Itoa(chat.Id),
+	}
+
+	for _, walletaddr := range recipients {
+		title := senderName + " in " + chat.Nftaddr
+		if mentioned[walletaddr] {
+			title = senderName + " mentioned you"
+		}
+
+		if ShouldNotify(walletaddr, contextType, chat.Nftaddr, NotifyChannelPush, mentioned[walletaddr]) {
+			push.NotifyWallet(walletaddr, push.Notification{
+				Title: title,
+				Body:  string(body),
+				Url:   "https://app.walletchat.fun",
+				Data:  data,
+			})
+		}
+
+		if ShouldNotify(walletaddr, contextType, chat.Nftaddr, NotifyChannelTelegram, mentioned[walletaddr]) {
+			var settings entity.Settings
+			dbQuery := database.Connector.Where("walletaddr = ?", walletaddr).Find(&settings)
+			if dbQuery.RowsAffected > 0 && settings.Telegramid != "" && strings.EqualFold(settings.Notifydm, "true") {
+				SendTelegramMessage(title+": "+string(body), settings.Telegramid)
+			}
+		}
+	}
+}
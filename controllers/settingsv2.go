@@ -0,0 +1,502 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"mime"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/email"
+	"rest-go-demo/entity"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SettingsV2 is the typed view of entity.Settings served by /v2/settings
+// the Telegram chat id, Twitter id and verification codes are never included
+type SettingsV2 struct {
+	Walletaddr      string `json:"walletaddr"`              //read-only, from the JWT
+	Email           string `json:"email"`                   //changing it sends a new verification code
+	EmailVerified   bool   `json:"email_verified"`          //read-only
+	NotifyDm        bool   `json:"notify_dm"`               //notification for every new DM
+	NotifyDaily     bool   `json:"notify_daily"`            //daily unread summary email
+	Locale          string `json:"locale"`                  //en, es, fr, de or pt
+	TelegramHandle  string `json:"telegram_handle"`         //changing it unlinks Telegram and issues a new link code
+	TelegramLinked  bool   `json:"telegram_linked"`         //read-only, set once the code was sent to the bot
+	TelegramCode    string `json:"telegram_code,omitempty"` //only in the response that issued the code
+	TwitterUser     string `json:"twitter_user"`            //without the @, changing it requires re-verification
+	TwitterVerified bool   `json:"twitter_verified"`        //read-only
+	Signupsite      string `json:"signupsite"`              //host only, https:// is stripped
+	Domain          string `json:"domain"`                  //host only, https:// is stripped
+	InstalledSnap   bool   `json:"installed_snap"`
+}
+
+// SettingsValidationError is returned with 422 when a PATCH has invalid fields
+type SettingsValidationError struct {
+	Errors map[string]string `json:"errors"`
+}
+
+const (
+	settingsAuditSourceV1     = "v1"
+	settingsAuditSourceV2     = "v2"
+	settingsAuditSourceSystem = "system"
+)
+
+var (
+	settingsReadOnlyFields = map[string]bool{
+		"walletaddr":       true,
+		"email_verified":   true,
+		"telegram_linked":  true,
+		"telegram_code":    true,
+		"twitter_verified": true,
+	}
+	telegramHandlePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z0-9_]{4,31}$`)
+	twitterUserPattern    = regexp.MustCompile(`^[A-Za-z0-9_]{1,15}$`)
+	siteHostPattern       = regexp.MustCompile(`^[a-z0-9]([a-z0-9.-]*[a-z0-9])?(:[0-9]{1,5})?$`)
+)
+
+func settingsFlag(value string) bool {
+	return strings.EqualFold(value, "true")
+}
+
+func settingsFlagString(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
+
+func settingsV2View(settings entity.Settings) SettingsV2 {
+	return SettingsV2{
+		Walletaddr:      settings.Walletaddr,
+		Email:           settings.Email,
+		EmailVerified:   settingsFlag(settings.Verified), //rows from before verification tokens can hold a code, that is not verified
+		NotifyDm:        settingsFlag(settings.Notifydm),
+		NotifyDaily:     settingsFlag(settings.Notify24),
+		Locale:          email.NormalizeLocale(settings.Locale),
+		TelegramHandle:  settings.Telegramhandle,
+		TelegramLinked:  settings.Telegramid != "",
+		TwitterUser:     settings.Twitteruser,
+		TwitterVerified: settingsFlag(settings.Twitterverified),
+		Signupsite:      settings.Signupsite,
+		Domain:          settings.Domain,
+		InstalledSnap:   settingsFlag(settings.Installedsnap),
+	}
+}
+
+// settingsAuditValues is what the audit trail compares, keyed by the v2 field name
+// emails are masked so the trail doesn't become a second copy of the address
+func settingsAuditValues(settings entity.Settings) map[string]string {
+	view := settingsV2View(settings)
+	maskedEmail := ""
+	if view.Email != "" {
+		maskedEmail = maskEmail(view.Email)
+	}
+	return map[string]string{
+		"email":            maskedEmail,
+		"email_verified":   strconv.FormatBool(view.EmailVerified),
+		"notify_dm":        strconv.FormatBool(view.NotifyDm),
+		"notify_daily":     strconv.FormatBool(view.NotifyDaily),
+		"locale":           view.Locale,
+		"telegram_handle":  view.TelegramHandle,
+		"telegram_linked":  strconv.FormatBool(view.TelegramLinked),
+		"twitter_user":     view.TwitterUser,
+		"twitter_verified": strconv.FormatBool(view.TwitterVerified),
+		"signupsite":       view.Signupsite,
+		"domain":           view.Domain,
+		"installed_snap":   strconv.FormatBool(view.InstalledSnap),
+	}
+}
+
+// auditSettingsField records a single settings change
+func auditSettingsField(walletaddr string, actor string, source string, field string, oldValue string, newValue string) {
+	audit := entity.Settingsaudit{
+		Walletaddr: strings.ToLower(walletaddr),
+		Actor:      strings.ToLower(actor),
+		Source:     source,
+		Field:      field,
+		Oldvalue:   oldValue,
+		Newvalue:   newValue,
+		Timestamp:  time.Now(),
+	}
+	if err := database.Connector.Create(&audit).Error; err != nil {
+		log.Println("settings audit failed: ", walletaddr, field, err)
+	}
+}
+
+// recordSettingsAudit records every field that differs between the two rows
+func recordSettingsAudit(before entity.Settings, after entity.Settings, actor string, source string) {
+	walletaddr := after.Walletaddr
+	if walletaddr == "" {
+		walletaddr = before.Walletaddr
+	}
+	oldValues := settingsAuditValues(before)
+	for field, newValue := range settingsAuditValues(after) {
+		if oldValues[field] != newValue {
+			auditSettingsField(walletaddr, actor, source, field, oldValues[field], newValue)
+		}
+	}
+}
+
+// settingsColumns are the stored values of every column a PATCH can touch
+func settingsColumns(settings entity.Settings) map[string]string {
+	return map[string]string{
+		"email":           settings.Email,
+		"verified":        settings.Verified,
+		"notifydm":        settings.Notifydm,
+		"notify24":        settings.Notify24,
+		"locale":          settings.Locale,
+		"telegramhandle":  settings.Telegramhandle,
+		"telegramid":      settings.Telegramid,
+		"telegramcode":    settings.Telegramcode,
+		"twitteruser":     settings.Twitteruser,
+		"twitterverified": settings.Twitterverified,
+		"twitterid":       settings.Twitterid,
+		"signupsite":      settings.Signupsite,
+		"domain":          settings.Domain,
+		"installedsnap":   settings.Installedsnap,
+	}
+}
+
+// patch values: JSON null clears the field (RFC 7396), so it decodes to the zero value
+func patchString(raw json.RawMessage) (string, error) {
+	var value *string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", fmt.Errorf("must be a string or null")
+	}
+	if value == nil {
+		return "", nil
+	}
+	return strings.TrimSpace(*value), nil
+}
+
+func patchBool(raw json.RawMessage) (bool, error) {
+	var value *bool
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return false, fmt.Errorf("must be true, false or null")
+	}
+	return value != nil && *value, nil
+}
+
+func validSettingsEmail(address string) bool {
+	if len(address) > 254 {
+		return false
+	}
+	parsed, err := mail.ParseAddress(address)
+	return err == nil && parsed.Address == address && strings.Contains(address[strings.LastIndex(address, "@"):], ".")
+}
+
+// normalizeSiteHost strips the scheme and trailing / like UpdateSettings always did, and checks what is left is a host
+func normalizeSiteHost(site string) (string, bool) {
+	site = strings.ToLower(site)
+	site = strings.TrimPrefix(site, "https://")
+	site = strings.TrimPrefix(site, "http://")
+	site = strings.TrimSuffix(site, "/")
+	return site, site == "" || (len(site) <= 253 && siteHostPattern.MatchString(site))
+}
+
+func supportedLocale(locale string) (string, bool) {
+	if locale == "" {
+		return "", true
+	}
+	base := strings.ToLower(locale)
+	if i := strings.IndexAny(base, "-_"); i > 0 {
+		base = base[:i]
+	}
+	for _, supported := range email.SupportedLocales() {
+		if base == supported {
+			return supported, true
+		}
+	}
+	return "", false
+}
+
+// applySettingsPatch applies a merge patch to a copy of the settings, nothing is saved
+// fields missing from the patch keep their value, server-owned state (verification, Telegram link) follows the changes
+func applySettingsPatch(current entity.Settings, patch map[string]json.RawMessage) (entity.Settings, map[string]string) {
+	updated := current
+	errs := map[string]string{}
+
+	for field, raw := range patch {
+		if settingsReadOnlyFields[field] {
+			errs[field] = "read-only"
+			continue
+		}
+		var err error
+		switch field {
+		case "email":
+			var value string
+			if value, err = patchString(raw); err == nil {
+				if value != "" && !validSettingsEmail(value) {
+					err = fmt.Errorf("not a valid email address")
+				} else {
+					updated.Email = value
+				}
+			}
+		case "notify_dm":
+			var value bool
+			if value, err = patchBool(raw); err == nil {
+				updated.Notifydm = settingsFlagString(value)
+			}
+		case "notify_daily":
+			var value bool
+			if value, err = patchBool(raw); err == nil {
+				updated.Notify24 = settingsFlagString(value)
+			}
+		case "installed_snap":
+			var value bool
+			if value, err = patchBool(raw); err == nil {
+				updated.Installedsnap = settingsFlagString(value)
+			}
+		case "locale":
+			var value string
+			if value, err = patchString(raw); err == nil {
+				if locale, ok := supportedLocale(value); ok {
+					updated.Locale = locale
+				} else {
+					err = fmt.Errorf("must be one of %s", strings.Join(email.SupportedLocales(), ", "))
+				}
+			}
+		case "telegram_handle":
+			var value string
+			if value, err = patchString(raw); err == nil {
+				value = strings.TrimPrefix(value, "@")
+				if value != "" && !telegramHandlePattern.MatchString(value) {
+					err = fmt.Errorf("not a valid Telegram username")
+				} else {
+					updated.Telegramhandle = value
+				}
+			}
+		case "twitter_user":
+			var value string
+			if value, err = patchString(raw); err == nil {
+				//stored without the @ because twitter doesn't store it this way either
+				value = strings.TrimPrefix(value, "@")
+				if value != "" && !twitterUserPattern.MatchString(value) {
+					err = fmt.Errorf("not a valid Twitter username")
+				} else {
+					updated.Twitteruser = value
+				}
+			}
+		case "signupsite", "domain":
+			var value string
+			if value, err = patchString(raw); err == nil {
+				if host, ok := normalizeSiteHost(value); !ok {
+					err = fmt.Errorf("must be a host name like app.walletchat.fun")
+				} else if field == "signupsite" {
+					updated.Signupsite = host
+				} else {
+					updated.Domain = host
+				}
+			}
+		default:
+			err = fmt.Errorf("unknown field")
+		}
+		if err != nil {
+			errs[field] = err.Error()
+		}
+	}
+	if len(errs) > 0 {
+		return current, errs
+	}
+
+	if !strings.EqualFold(updated.Email, current.Email) {
+		updated.Verified = "false"
+	}
+	if !strings.EqualFold(updated.Telegramhandle, current.Telegramhandle) {
+		//the chat id belongs to the old handle, the new one has to message the bot again
+		updated.Telegramid = ""
+		updated.Telegramcode = ""
+		if updated.Telegramhandle != "" {
+			code, err := randomCode(20) //crypto/rand, the code proves the Telegram account belongs to the wallet
+			if err != nil {
+				return current, map[string]string{"telegram_handle": "could not create a verification code, please try again"}
+			}
+			updated.Telegramcode = code
+		}
+	}
+	if !strings.EqualFold(updated.Twitteruser, current.Twitteruser) {
+		updated.Twitterverified = "false"
+		updated.Twitterid = ""
+	}
+	return updated, nil
+}
+
+func writeSettingsV2(w http.ResponseWriter, status int, view SettingsV2) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(view)
+}
+
+// ============================================================================
+
+// GetSettingsV2 godoc
+// @Summary     Get typed notification and account settings
+// @Description Booleans are real JSON booleans, secrets (Telegram chat id/code, verification codes) are never returned
+// @Tags        Common
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200 {object} SettingsV2
+// @Router      /v2/settings [get]
+func GetSettingsV2(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+	addr := strings.ToLower(Authuser.Address)
+
+	var settings entity.Settings
+	dbResults := database.Connector.Where("walletaddr = ?", addr).Find(&settings)
+	if dbResults.RowsAffected == 0 {
+		settings.Walletaddr = addr
+	}
+	writeSettingsV2(w, http.StatusOK, settingsV2View(settings))
+}
+
+// PatchSettingsV2 godoc
+// @Summary     Update settings with JSON Merge Patch (RFC 7396)
+// @Description Only fields in the body change, null clears a field. Invalid or read-only fields fail the whole patch with 422.
+// @Description A new email is unverified until the emailed code is used, a new Telegram handle returns telegram_code once.
+// @Tags        Common
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       message body     SettingsV2 true "fields to change"
+// @Success     200     {object} SettingsV2
+// @Failure     422     {object} SettingsValidationError
+// @Router      /v2/settings [patch]
+func PatchSettingsV2(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+	addr := strings.ToLower(Authuser.Address)
+
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		mediaType, _, _ := mime.ParseMediaType(contentType)
+		if mediaType != "application/merge-patch+json" && mediaType != "application/json" {
+			http.Error(w, "use application/merge-patch+json", http.StatusUnsupportedMediaType)
+			return
+		}
+	}
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(requestBody, &patch); err != nil || patch == nil {
+		http.Error(w, "body must be a JSON object", http.StatusBadRequest)
+		return
+	}
+
+	var current entity.Settings
+	dbResults := database.Connector.Where("walletaddr = ?", addr).Find(&current)
+	exists := dbResults.RowsAffected > 0
+	if !exists {
+		current.Walletaddr = addr
+		current.Verified = "false"
+		current.Twitterverified = "false"
+	}
+
+	updated, errs := applySettingsPatch(current, patch)
+	if len(errs) > 0 {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		json.NewEncoder(w).Encode(SettingsValidationError{Errors: errs})
+		return
+	}
+
+	if !exists {
+		if err := database.Connector.Create(&updated).Error; err != nil {
+			log.Println("PatchSettingsV2 create error: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	} else {
+		changes := map[string]interface{}{}
+		oldColumns := settingsColumns(current)
+		for column, value := range settingsColumns(updated) {
+			if oldColumns[column] != value {
+				changes[column] = value
+			}
+		}
+		if len(changes) > 0 {
+			if err := database.Connector.Model(&entity.Settings{}).Where("walletaddr = ?", addr).Updates(changes).Error; err != nil {
+				log.Println("PatchSettingsV2 update error: ", err)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	recordSettingsAudit(current, updated, addr, settingsAuditSourceV2)
+
+	if !strings.EqualFold(updated.Email, current.Email) {
+		notifyEmailChanged(current, updated.Email)
+		if updated.Email != "" {
+			signupsite := updated.Signupsite
+			if signupsite == "" {
+				signupsite = updated.Domain //from the main webapp, domain and signup site is the same
+			}
+			if err := issueEmailVerification(addr, updated.Email, signupsite, updated.Domain, updated.Locale); err != nil {
+				log.Println("Verification email not sent: ", addr, err)
+			}
+		}
+	}
+
+	view := settingsV2View(updated)
+	if updated.Telegramcode != current.Telegramcode {
+		view.TelegramCode = updated.Telegramcode
+	}
+	writeSettingsV2(w, http.StatusOK, view)
+}
+
+// DeleteSettingsV2 godoc
+// @Summary     Delete all settings (email, Telegram, Twitter) for the signed in wallet
+// @Tags        Common
+// @Security    BearerAuth
+// @Success     204
+// @Router      /v2/settings [delete]
+func DeleteSettingsV2(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+	addr := strings.ToLower(Authuser.Address)
+
+	var settings entity.Settings
+	dbResults := database.Connector.Where("walletaddr = ?", addr).Find(&settings)
+	if dbResults.RowsAffected > 0 {
+		database.Connector.Where("walletaddr = ?", addr).Delete(&entity.Settings{})
+		database.Connector.Model(&entity.Emailverification{}).
+			Where("walletaddr = ?", addr).
+			Where("usedat IS NULL").
+			Update("usedat", time.Now())
+		recordSettingsAudit(settings, entity.Settings{Walletaddr: addr}, addr, settingsAuditSourceV2)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetSettingsAudit godoc
+// @Summary     Settings change history for the signed in wallet, newest first
+// @Description One entry per changed field, source is v1, v2 or system (email/Telegram/Twitter verification). Emails are masked.
+// @Tags        Common
+// @Produce     json
+// @Security    BearerAuth
+// @Param       limit query    int false "max entries (default 50, max 200)"
+// @Success     200   {array} entity.Settingsaudit
+// @Router      /v2/settings/audit [get]
+func GetSettingsAudit(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+
+	limit := 50
+	if value, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && value > 0 && value <= 200 {
+		limit = value
+	}
+
+	var audit []entity.Settingsaudit
+	database.Connector.Where("walletaddr = ?", strings.ToLower(Authuser.Address)).
+		Order("id desc").
+		Limit(limit).
+		Find(&audit)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	json.NewEncoder(w).Encode(audit)
+}
@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"fmt"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Telegram admin commands for the support blocklist
+const (
+	tgCmdBlockUser   = "BLOCK_USER"
+	tgCmdUnblockUser = "UNBLOCK_USER"
+	tgCmdListBlocked = "LIST_BLOCKED"
+)
+
+// parseBlockUserCommand splits "BLOCK_USER [duration] [reason]" into expiry and reason
+// duration is optional and accepts Go durations (12h, 90m) or days (7d), no duration blocks forever
+func parseBlockUserCommand(text string) (*time.Time, string) {
+	args := strings.Fields(strings.TrimSpace(text))
+	if len(args) > 0 && strings.EqualFold(args[0], tgCmdBlockUser) {
+		args = args[1:]
+	}
+	if len(args) == 0 {
+		return nil, ""
+	}
+
+	var expires *time.Time
+	duration, err := time.ParseDuration(args[0])
+	if err != nil && strings.HasSuffix(args[0], "d") {
+		days, errDays := strconv.Atoi(strings.TrimSuffix(args[0], "d"))
+		if errDays == nil {
+			duration = time.Duration(days) * 24 * time.Hour
+			err = nil
+		}
+	}
+	if err == nil && duration > 0 {
+		expiry := time.Now().Add(duration)
+		expires = &expiry
+		args = args[1:]
+	}
+
+	return expires, strings.Join(args, " ")
+}
+
+// isTelegramCommand checks if a Telegram message starts with the given admin command
+func isTelegramCommand(text string, command string) bool {
+	args := strings.Fields(text)
+	return len(args) > 0 && strings.EqualFold(args[0], command)
+}
+
+// IsSupportBlocked checks the DB so all API replicas see the same support blocklist
+func IsSupportBlocked(walletAddr string) bool {
+	var blocked []entity.Supportblockeduser
+	dbQuery := database.Connector.Where("walletaddr = ?", strings.ToLower(walletAddr)).
+		Where("expires IS NULL OR expires > ?", time.Now()).
+		Find(&blocked)
+	return dbQuery.RowsAffected > 0
+}
+
+// BlockSupportUser creates or refreshes a support block for the given wallet
+func BlockSupportUser(walletAddr string, reason string, adminId string, expires *time.Time) error {
+	walletAddr = strings.ToLower(walletAddr)
+	if walletAddr == "" {
+		return fmt.Errorf("no wallet address to block")
+	}
+
+	var blocked entity.Supportblockeduser
+	dbQuery := database.Connector.Where("walletaddr = ?", walletAddr).Find(&blocked)
+	blocked.Walletaddr = walletAddr
+	blocked.Reason = reason
+	blocked.Adminid = adminId
+	blocked.Expires = expires
+	blocked.Timestamp = time.Now()
+
+	if dbQuery.RowsAffected == 0 {
+		return database.Connector.Create(&blocked).Error
+	}
+	return database.Connector.Save(&blocked).Error
+}
+
+// UnblockSupportUser removes a support block, returns the number of blocks removed
+func UnblockSupportUser(walletAddr string) int64 {
+	dbQuery := database.Connector.Where("walletaddr = ?", strings.ToLower(walletAddr)).Delete(&entity.Supportblockeduser{})
+	return dbQuery.RowsAffected
+}
+
+// GetSupportBlockedUsers returns all active (non-expired) support blocks
+func GetSupportBlockedUsers() []entity.Supportblockeduser {
+	var blocked []entity.Supportblockeduser
+	database.Connector.Where("expires IS NULL OR expires > ?", time.Now()).Order("timestamp desc").Find(&blocked)
+	return blocked
+}
+
+// formatSupportBlockedList builds the Telegram reply for LIST_BLOCKED
+func formatSupportBlockedList(blocked []entity.Supportblockeduser) string {
+	if len(blocked) == 0 {
+		return "No wallets are blocked from support"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Blocked wallets (" + strconv.Itoa(len(blocked)) + "):")
+	for _, item := range blocked {
+		sb.WriteString("\n" + item.Walletaddr)
+		if item.Expires != nil {
+			sb.WriteString(" until " + item.Expires.UTC().Format("2006-01-02 15:04") + " UTC")
+		}
+		if item.Reason != "" {
+			sb.WriteString(" - " + item.Reason)
+		}
+	}
+	return sb.String()
+}
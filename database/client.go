@@ -35,6 +35,16 @@ func MigrateChatitem(table *entity.Chatitem) {
 	log.Println("Chatitems migrated")
 }
 
+// MigrateTables creates newer tables (and adds missing columns) that are not created by hand in MySQL
+func MigrateTables(tables ...interface{}) {
+	for _, table := range tables {
+		if err := Connector.AutoMigrate(table).Error; err != nil {
+			log.Println("Migrate error: ", err)
+		}
+	}
+	log.Println("Tables migrated: ", len(tables))
+}
+
 // func SetPrimaryKeyReq(result bool) {
 // 	Connector.Raw("SET SESSION sql_require_primary_key = 0").Scan(&result)
 // 	log.Println("Chatitems migrated")
@@ -0,0 +1,117 @@
+package dune
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"rest-go-demo/onchain"
+	"strconv"
+)
+
+const defaultBaseUrl = "https://api.dune.com/api/v1"
+
+// ApiClient calls the Dune API with the shared onchain.HttpClient, every call returns quickly (no waiting on queries)
+type ApiClient struct {
+	ApiKey      string
+	BaseUrl     string
+	Performance string //medium or large
+	Http        *http.Client
+}
+
+func NewApiClient(apiKey string) *ApiClient {
+	return &ApiClient{ApiKey: apiKey, BaseUrl: defaultBaseUrl, Performance: "medium", Http: onchain.HttpClient}
+}
+
+// do sends the request, 404 is ErrNotFound and any other non 2xx status an error with Dune's message
+func (c *ApiClient) do(method string, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, c.BaseUrl+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-Dune-API-Key", c.ApiKey)
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := c.Http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var failure struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(responseBody, &failure)
+		return fmt.Errorf("dune %s %s: status %d %s", method, path, resp.StatusCode, failure.Error)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(responseBody, out)
+}
+
+func (c *ApiClient) Execute(queryId int, params map[string]string) (string, error) {
+	request := map[string]interface{}{"query_parameters": params, "performance": c.Performance}
+	var response struct {
+		ExecutionId string `json:"execution_id"`
+		State       string `json:"state"`
+	}
+	if err := c.do("POST", "/query/"+strconv.Itoa(queryId)+"/execute", request, &response); err != nil {
+		return "", err
+	}
+	if response.ExecutionId == "" {
+		return "", fmt.Errorf("dune query %d: no execution id", queryId)
+	}
+	return response.ExecutionId, nil
+}
+
+// Status gets the state, and the rows once completed, in one call
+func (c *ApiClient) Status(executionId string) (Execution, error) {
+	var response struct {
+		ExecutionId string `json:"execution_id"`
+		State       string `json:"state"`
+		Finished    bool   `json:"is_execution_finished"`
+		Result      struct {
+			Rows []Row `json:"rows"`
+		} `json:"result"`
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := c.do("GET", "/execution/"+executionId+"/results", nil, &response); err != nil {
+		return Execution{}, err
+	}
+	execution := Execution{Id: response.ExecutionId, State: response.State, Finished: response.Finished, Rows: response.Result.Rows}
+	if response.Error.Message != "" {
+		execution.Error = response.Error.Message
+	} else if response.Error.Type != "" {
+		execution.Error = response.Error.Type
+	}
+	return execution, nil
+}
+
+func (c *ApiClient) Cancel(executionId string) error {
+	return c.do("POST", "/execution/"+executionId+"/cancel", nil, nil)
+}
@@ -0,0 +1,109 @@
+// Package dune runs saved Dune queries (https://docs.dune.com/api-reference) without blocking: Execute starts one,
+// Status is polled until the execution finished. The Client interface lets a fixture backed fake stand in for the API.
+package dune
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// execution states as the API reports them
+const (
+	StatePending   = "QUERY_STATE_PENDING"
+	StateExecuting = "QUERY_STATE_EXECUTING"
+	StateCompleted = "QUERY_STATE_COMPLETED"
+	StateFailed    = "QUERY_STATE_FAILED"
+	StateCancelled = "QUERY_STATE_CANCELLED"
+	StateExpired   = "QUERY_STATE_EXPIRED"
+)
+
+// ErrNotFound means Dune doesn't know the query or execution
+var ErrNotFound = errors.New("not found")
+
+// Row is one result row, column name -> value (numbers are float64, as decoded from JSON)
+type Row map[string]interface{}
+
+// Execution is the state of one query run, Rows are only set once it completed
+type Execution struct {
+	Id       string `json:"execution_id"`
+	State    string `json:"state"`
+	Finished bool   `json:"is_execution_finished"`
+	Rows     []Row  `json:"rows,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Succeeded is true for a finished execution that has results
+func (e Execution) Succeeded() bool {
+	return e.Finished && e.State == StateCompleted
+}
+
+// Client is the Dune API, or a fake of it
+type Client interface {
+	Execute(queryId int, params map[string]string) (string, error) //returns the execution id
+	Status(executionId string) (Execution, error)
+	Cancel(executionId string) error
+}
+
+// NewClientFromEnv uses DUNE_API_KEY, DUNE_PROVIDER=fake serves DUNE_FIXTURES instead (no network, for local runs and tests)
+func NewClientFromEnv() (Client, error) {
+	if strings.EqualFold(os.Getenv("DUNE_PROVIDER"), "fake") {
+		fixtures, err := LoadFixtures(os.Getenv("DUNE_FIXTURES"))
+		if err != nil {
+			fmt.Println("dune - fixtures not loaded, the fake client is empty: ", err)
+		}
+		return NewFakeClient(fixtures), nil
+	}
+	apiKey := os.Getenv("DUNE_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("DUNE_API_KEY not set")
+	}
+	return NewApiClient(apiKey), nil
+}
+
+// ErrNotConfigured is returned by the package functions when there's no client (no DUNE_API_KEY)
+var ErrNotConfigured = errors.New("dune client not configured")
+
+var defaultClient Client
+
+// Init builds the client from the environment, call it after the .env file is loaded
+func Init() {
+	client, err := NewClientFromEnv()
+	if err != nil {
+		fmt.Println("dune - overlap analytics disabled: ", err)
+		client = nil
+	}
+	defaultClient = client
+}
+
+// SetClient replaces the client the package functions use, ie with a FakeClient
+func SetClient(client Client) {
+	defaultClient = client
+}
+
+// Configured is false when Dune queries can't run
+func Configured() bool {
+	return defaultClient != nil
+}
+
+func Execute(queryId int, params map[string]string) (string, error) {
+	if defaultClient == nil {
+		return "", ErrNotConfigured
+	}
+	return defaultClient.Execute(queryId, params)
+}
+
+func Status(executionId string) (Execution, error) {
+	if defaultClient == nil {
+		return Execution{}, ErrNotConfigured
+	}
+	return defaultClient.Status(executionId)
+}
+
+func Cancel(executionId string) error {
+	if defaultClient == nil {
+		return ErrNotConfigured
+	}
+	return defaultClient.Cancel(executionId)
+}
@@ -0,0 +1,126 @@
+package dune
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// FixtureResult is what one query returns for one set of parameters
+type FixtureResult struct {
+	Queryid int               `json:"query_id"`
+	Params  map[string]string `json:"params"`
+	Rows    []Row             `json:"rows"`
+	Polls   int               `json:"polls"` //Status calls before the execution finishes, 0 finishes at once
+	Error   string            `json:"error"` //set to make the execution fail
+}
+
+// Fixtures are the query results the FakeClient serves, see fixtures/overlap.json
+type Fixtures struct {
+	Results []FixtureResult `json:"results"`
+}
+
+// LoadFixtures reads a fixture file (DUNE_FIXTURES)
+func LoadFixtures(path string) (Fixtures, error) {
+	var fixtures Fixtures
+	if path == "" {
+		return fixtures, fmt.Errorf("DUNE_FIXTURES not set")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fixtures, err
+	}
+	err = json.Unmarshal(data, &fixtures)
+	return fixtures, err
+}
+
+type fakeExecution struct {
+	result    FixtureResult
+	polls     int
+	cancelled bool
+}
+
+// FakeClient answers from fixtures without network access. Queries without a fixture complete with no rows.
+type FakeClient struct {
+	mutex      sync.Mutex
+	Fixtures   Fixtures
+	Fail       error //makes every call fail
+	executions map[string]*fakeExecution
+	next       int
+}
+
+func NewFakeClient(fixtures Fixtures) *FakeClient {
+	return &FakeClient{Fixtures: fixtures, executions: map[string]*fakeExecution{}}
+}
+
+// paramsKey is the parameters in a stable order, values case insensitive (they are addresses)
+func paramsKey(params map[string]string) string {
+	var keys []string
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, key := range keys {
+		parts = append(parts, key+"="+strings.ToLower(params[key]))
+	}
+	return strings.Join(parts, "&")
+}
+
+func (c *FakeClient) Execute(queryId int, params map[string]string) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.Fail != nil {
+		return "", c.Fail
+	}
+	result := FixtureResult{Queryid: queryId, Params: params, Rows: []Row{}}
+	for _, fixture := range c.Fixtures.Results {
+		if fixture.Queryid == queryId && paramsKey(fixture.Params) == paramsKey(params) {
+			result = fixture
+			break
+		}
+	}
+	c.next++
+	executionId := "fake-" + strconv.Itoa(c.next)
+	c.executions[executionId] = &fakeExecution{result: result}
+	return executionId, nil
+}
+
+func (c *FakeClient) Status(executionId string) (Execution, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.Fail != nil {
+		return Execution{}, c.Fail
+	}
+	run, ok := c.executions[executionId]
+	if !ok {
+		return Execution{}, ErrNotFound
+	}
+	execution := Execution{Id: executionId, State: StateExecuting}
+	switch {
+	case run.cancelled:
+		execution.State, execution.Finished = StateCancelled, true
+	case run.polls < run.result.Polls:
+		run.polls++
+	case run.result.Error != "":
+		execution.State, execution.Finished, execution.Error = StateFailed, true, run.result.Error
+	default:
+		execution.State, execution.Finished, execution.Rows = StateCompleted, true, run.result.Rows
+	}
+	return execution, nil
+}
+
+func (c *FakeClient) Cancel(executionId string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	run, ok := c.executions[executionId]
+	if !ok {
+		return ErrNotFound
+	}
+	run.cancelled = true
+	return nil
+}
@@ -0,0 +1,105 @@
+package dune
+
+import (
+	"errors"
+	"testing"
+)
+
+const overlapQuery = 3615247
+
+func loadTestFixtures(t *testing.T) *FakeClient {
+	t.Helper()
+	fixtures, err := LoadFixtures("fixtures/overlap.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := NewFakeClient(fixtures)
+	SetClient(fake)
+	t.Cleanup(func() { SetClient(nil) })
+	return fake
+}
+
+func execute(t *testing.T, queryId int, token string) string {
+	t.Helper()
+	executionId, err := Execute(queryId, map[string]string{"token_address": token})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return executionId
+}
+
+func status(t *testing.T, executionId string) Execution {
+	t.Helper()
+	execution, err := Status(executionId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return execution
+}
+
+func TestFakeCompletesAfterPolls(t *testing.T) {
+	loadTestFixtures(t)
+	//params match case insensitively, addresses come in checksummed
+	executionId := execute(t, overlapQuery, "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+
+	for i := 0; i < 2; i++ {
+		if execution := status(t, executionId); execution.Finished || execution.State != StateExecuting {
+			t.Fatalf("poll %d = %+v, want executing", i, execution)
+		}
+	}
+	execution := status(t, executionId)
+	if !execution.Succeeded() || len(execution.Rows) != 3 {
+		t.Fatalf("execution = %+v, want completed with 3 rows", execution)
+	}
+	if execution.Rows[0]["symbol"] != "USDT" {
+		t.Errorf("first row = %v", execution.Rows[0])
+	}
+}
+
+func TestFakeFailsAndCancels(t *testing.T) {
+	loadTestFixtures(t)
+	failing := execute(t, overlapQuery, "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb")
+	status(t, failing)
+	if execution := status(t, failing); !execution.Finished || execution.Succeeded() || execution.Error != "query timed out" {
+		t.Errorf("failing execution = %+v", execution)
+	}
+
+	cancelled := execute(t, overlapQuery, "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA")
+	if err := Cancel(cancelled); err != nil {
+		t.Fatal(err)
+	}
+	if execution := status(t, cancelled); !execution.Finished || execution.State != StateCancelled {
+		t.Errorf("cancelled execution = %+v", execution)
+	}
+
+	if _, err := Status("fake-unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown execution error = %v, want ErrNotFound", err)
+	}
+	if err := Cancel("fake-unknown"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("cancelling an unknown execution error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFakeWithoutFixture(t *testing.T) {
+	fake := loadTestFixtures(t)
+	//queries without a fixture complete at once with no rows
+	execution := status(t, execute(t, overlapQuery, "0xcccccccccccccccccccccccccccccccccccccccc"))
+	if !execution.Succeeded() || len(execution.Rows) != 0 {
+		t.Errorf("execution = %+v, want completed without rows", execution)
+	}
+
+	fake.Fail = errors.New("dune is down")
+	if _, err := Execute(overlapQuery, map[string]string{"token_address": "0xcccccccccccccccccccccccccccccccccccccccc"}); err != fake.Fail {
+		t.Errorf("Execute error = %v, want the injected failure", err)
+	}
+}
+
+func TestNotConfigured(t *testing.T) {
+	SetClient(nil)
+	if Configured() {
+		t.Fatal("configured without a client")
+	}
+	if _, err := Execute(overlapQuery, nil); !errors.Is(err, ErrNotConfigured) {
+		t.Errorf("Execute error = %v, want ErrNotConfigured", err)
+	}
+}
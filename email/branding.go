@@ -0,0 +1,63 @@
+package email
+
+import "strings"
+
+// Branding is the per signup site look of an email (partner dApps embed WalletChat under their own name)
+type Branding struct {
+	Name         string //shown in the header when there is no logo, and in subjects
+	AppUrl       string //where "Go to ..." buttons link
+	LogoUrl      string //optional header image
+	PrimaryColor string //hero background
+	AccentColor  string //buttons
+	SupportEmail string
+	FromName     string //sender name for the From: header
+	TwitterUrl   string //optional footer link
+}
+
+var walletChatBranding = Branding{
+	Name:         "WalletChat",
+	AppUrl:       "https://app.walletchat.fun",
+	LogoUrl:      "https://assets.unlayer.com/projects/115376/1669172570120-walletchat%20logo_large.png",
+	PrimaryColor: "#003399",
+	AccentColor:  "#ff6600",
+	SupportEmail: "contact@walletchat.fun",
+	FromName:     "WalletChat Notifications",
+	TwitterUrl:   "https://twitter.com/wallet_chat",
+}
+
+// BrandingForSite picks the branding from Settings.Signupsite (or Domain), unknown sites get WalletChat branding
+func BrandingForSite(signupsite string) Branding {
+	site := strings.ToLower(strings.TrimSpace(signupsite))
+	site = strings.TrimPrefix(site, "https://")
+	site = strings.TrimPrefix(site, "http://")
+	site = strings.TrimSuffix(site, "/")
+
+	switch {
+	case strings.Contains(site, "good"): //same check as the GoodDollar welcome message
+		brand := walletChatBranding
+		brand.Name = "GoodDollar"
+		brand.AppUrl = "https://" + site
+		brand.LogoUrl = ""
+		brand.PrimaryColor = "#00b0ff"
+		brand.AccentColor = "#00c3ae"
+		brand.FromName = "GoodDollar Notifications"
+		brand.TwitterUrl = ""
+		return brand
+	case strings.Contains(site, "oura"):
+		brand := walletChatBranding
+		brand.Name = "Oura"
+		brand.AppUrl = "https://" + site
+		brand.LogoUrl = ""
+		brand.PrimaryColor = "#1c1c28"
+		brand.AccentColor = "#6e5ff0"
+		brand.FromName = "Oura x WalletChat"
+		brand.TwitterUrl = ""
+		return brand
+	}
+	return walletChatBranding
+}
+
+// host of the app link without the scheme, used in "Login to app.walletchat.fun to read!"
+func (b Branding) appHost() string {
+	return strings.TrimPrefix(strings.TrimPrefix(b.AppUrl, "https://"), "http://")
+}
@@ -0,0 +1,213 @@
+package email
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const defaultLocale = "en"
+
+// translations for every string used in the templates, English is the fallback for missing keys
+// values are fmt formats, the arguments are escaped by html/template like any other value
+var translations = map[string]map[string]string{
+	"en": {
+		"layout.preheader":  "From your frens at %s",
+		"layout.questions":  "Questions?",
+		"layout.poweredby":  "Powered by WalletChat.fun",
+		"button.open":       "Go to %s",
+		"hero.waiting":      "You have messages waiting!",
+		"hero.login":        "Login to %s to read!",
+		"dm.subject":        "Message Waiting In %s",
+		"dm.greeting":       "Hi %s",
+		"dm.intro":          "you have a new message from %s",
+		"daily.subject":     "Messages Waiting In %s",
+		"daily.greeting":    "Hi %s",
+		"daily.intro":       "you have unread messages:",
+		"daily.dms":         "%d new DMs",
+		"daily.nfts":        "%d new NFT group chat messages",
+		"daily.communities": "%d new Community chat messages",
+		"verify.subject":    "Please Verify Email for %s",
+		"verify.greeting":   "Hi %s",
+		"verify.intro":      "please verify your email for: %s!",
+		"verify.code":       "Use the code:",
+		"verify.button":     "Verify Email",
+		"twitter.subject":   "Prize Notification For %s",
+		"twitter.greeting":  "Hi %s",
+		"twitter.contest":   "Join the daily Twitter contest to win $25 USDC!",
+		"twitter.winner":    "Tweet with most engagement wins daily!",
+		"twitter.hashtags":  "Include #chat2earn #chat2win and tag @wallet_chat",
+		"twitter.button":    "Tweet Now!",
+		"changed.subject":   "Your %s Notification Email Was Changed",
+		"changed.greeting":  "Hi %s",
+		"changed.intro":     "the notification email for your wallet was changed to %s.",
+		"changed.warning":   "If you did not make this change, please contact us right away.",
+	},
+	"es": {
+		"layout.preheader":  "De parte de tus amigos en %s",
+		"layout.questions":  "¿Preguntas?",
+		"layout.poweredby":  "Con la tecnología de WalletChat.fun",
+		"button.open":       "Ir a %s",
+		"hero.waiting":      "¡Tienes mensajes esperando!",
+		"hero.login":        "¡Inicia sesión en %s para leerlos!",
+		"dm.subject":        "Tienes un mensaje en %s",
+		"dm.greeting":       "Hola %s",
+		"dm.intro":          "tienes un nuevo mensaje de %s",
+		"daily.subject":     "Tienes mensajes en %s",
+		"daily.greeting":    "Hola %s",
+		"daily.intro":       "tienes mensajes sin leer:",
+		"daily.dms":         "%d mensajes directos nuevos",
+		"daily.nfts":        "%d mensajes nuevos en chats de NFT",
+		"daily.communities": "%d mensajes nuevos en comunidades",
+		"verify.subject":    "Verifica tu correo para %s",
+		"verify.greeting":   "Hola %s",
+		"verify.intro":      "verifica tu correo para: %s",
+		"verify.code":       "Usa el código:",
+		"verify.button":     "Verificar correo",
+		"twitter.subject":   "Notificación de premio de %s",
+		"twitter.greeting":  "Hola %s",
+		"twitter.contest":   "¡Participa en el concurso diario de Twitter y gana 25 USDC!",
+		"twitter.winner":    "¡El tweet con más interacción gana cada día!",
+		"twitter.hashtags":  "Incluye #chat2earn #chat2win y menciona a @wallet_chat",
+		"twitter.button":    "¡Twittea ahora!",
+		"changed.subject":   "Se cambió tu correo de notificaciones de %s",
+		"changed.greeting":  "Hola %s",
+		"changed.intro":     "el correo de notificaciones de tu wallet se cambió a %s.",
+		"changed.warning":   "Si no hiciste este cambio, contáctanos de inmediato.",
+	},
+	"fr": {
+		"layout.preheader":  "De la part de vos amis de %s",
+		"layout.questions":  "Des questions ?",
+		"layout.poweredby":  "Propulsé par WalletChat.fun",
+		"button.open":       "Aller sur %s",
+		"hero.waiting":      "Des messages vous attendent !",
+		"hero.login":        "Connectez-vous à %s pour les lire !",
+		"dm.subject":        "Un message vous attend sur %s",
+		"dm.greeting":       "Bonjour %s",
+		"dm.intro":          "vous avez un nouveau message de %s",
+		"daily.subject":     "Des messages vous attendent sur %s",
+		"daily.greeting":    "Bonjour %s",
+		"daily.intro":       "vous avez des messages non lus :",
+		"daily.dms":         "%d nouveaux messages privés",
+		"daily.nfts":        "%d nouveaux messages dans les chats NFT",
+		"daily.communities": "%d nouveaux messages dans les communautés",
+		"verify.subject":    "Veuillez vérifier votre e-mail pour %s",
+		"verify.greeting":   "Bonjour %s",
+		"verify.intro":      "veuillez vérifier votre e-mail pour : %s",
+		"verify.code":       "Utilisez le code :",
+		"verify.button":     "Vérifier l'e-mail",
+		"twitter.subject":   "Notification de prix de %s",
+		"twitter.greeting":  "Bonjour %s",
+		"twitter.contest":   "Participez au concours Twitter quotidien pour gagner 25 USDC !",
+		"twitter.winner":    "Le tweet avec le plus d'engagement gagne chaque jour !",
+		"twitter.hashtags":  "Ajoutez #chat2earn #chat2win et mentionnez @wallet_chat",
+		"twitter.button":    "Tweeter !",
+		"changed.subject":   "Votre e-mail de notification %s a été modifié",
+		"changed.greeting":  "Bonjour %s",
+		"changed.intro":     "l'e-mail de notification de votre wallet a été remplacé par %s.",
+		"changed.warning":   "Si vous n'êtes pas à l'origine de ce changement, contactez-nous immédiatement.",
+	},
+	"de": {
+		"layout.preheader":  "Von deinen Freunden bei %s",
+		"layout.questions":  "Fragen?",
+		"layout.poweredby":  "Bereitgestellt von WalletChat.fun",
+		"button.open":       "Zu %s",
+		"hero.waiting":      "Du hast neue Nachrichten!",
+		"hero.login":        "Melde dich bei %s an, um sie zu lesen!",
+		"dm.subject":        "Neue Nachricht in %s",
+		"dm.greeting":       "Hallo %s",
+		"dm.intro":          "du hast eine neue Nachricht von %s",
+		"daily.subject":     "Neue Nachrichten in %s",
+		"daily.greeting":    "Hallo %s",
+		"daily.intro":       "du hast ungelesene Nachrichten:",
+		"daily.dms":         "%d neue Direktnachrichten",
+		"daily.nfts":        "%d neue Nachrichten in NFT-Gruppenchats",
+		"daily.communities": "%d neue Nachrichten in Communities",
+		"verify.subject":    "Bitte bestätige deine E-Mail für %s",
+		"verify.greeting":   "Hallo %s",
+		"verify.intro":      "bitte bestätige deine E-Mail für: %s",
+		"verify.code":       "Verwende den Code:",
+		"verify.button":     "E-Mail bestätigen",
+		"twitter.subject":   "Gewinnbenachrichtigung von %s",
+		"twitter.greeting":  "Hallo %s",
+		"twitter.contest":   "Mach beim täglichen Twitter-Wettbewerb mit und gewinne 25 USDC!",
+		"twitter.winner":    "Der Tweet mit den meisten Interaktionen gewinnt täglich!",
+		"twitter.hashtags":  "Nutze #chat2earn #chat2win und markiere @wallet_chat",
+		"twitter.button":    "Jetzt twittern!",
+		"changed.subject":   "Deine %s Benachrichtigungs-E-Mail wurde geändert",
+		"changed.greeting":  "Hallo %s",
+		"changed.intro":     "die Benachrichtigungs-E-Mail deiner Wallet wurde zu %s geändert.",
+		"changed.warning":   "Falls du das nicht warst, kontaktiere uns bitte sofort.",
+	},
+	"pt": {
+		"layout.preheader":  "Dos seus amigos da %s",
+		"layout.questions":  "Dúvidas?",
+		"layout.poweredby":  "Desenvolvido por WalletChat.fun",
+		"button.open":       "Ir para %s",
+		"hero.waiting":      "Você tem mensagens esperando!",
+		"hero.login":        "Entre em %s para ler!",
+		"dm.subject":        "Mensagem esperando em %s",
+		"dm.greeting":       "Olá %s",
+		"dm.intro":          "você tem uma nova mensagem de %s",
+		"daily.subject":     "Mensagens esperando em %s",
+		"daily.greeting":    "Olá %s",
+		"daily.intro":       "você tem mensagens não lidas:",
+		"daily.dms":         "%d novas mensagens diretas",
+		"daily.nfts":        "%d novas mensagens em chats de NFT",
+		"daily.communities": "%d novas mensagens em comunidades",
+		"verify.subject":    "Verifique seu e-mail para %s",
+		"verify.greeting":   "Olá %s",
+		"verify.intro":      "verifique seu e-mail para: %s",
+		"verify.code":       "Use o código:",
+		"verify.button":     "Verificar e-mail",
+		"twitter.subject":   "Notificação de prêmio da %s",
+		"twitter.greeting":  "Olá %s",
+		"twitter.contest":   "Participe do concurso diário no Twitter e ganhe 25 USDC!",
+		"twitter.winner":    "O tweet com mais engajamento ganha todos os dias!",
+		"twitter.hashtags":  "Inclua #chat2earn #chat2win e marque @wallet_chat",
+		"twitter.button":    "Tuitar agora!",
+		"changed.subject":   "Seu e-mail de notificações da %s foi alterado",
+		"changed.greeting":  "Olá %s",
+		"changed.intro":     "o e-mail de notificações da sua carteira foi alterado para %s.",
+		"changed.warning":   "Se você não fez essa alteração, entre em contato conosco imediatamente.",
+	},
+}
+
+// NormalizeLocale maps a user preference like "es-MX" or "pt_BR" to a supported locale, defaulting to English
+func NormalizeLocale(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if _, ok := translations[locale]; ok {
+		return locale
+	}
+	if i := strings.IndexAny(locale, "-_"); i > 0 {
+		if _, ok := translations[locale[:i]]; ok {
+			return locale[:i]
+		}
+	}
+	return defaultLocale
+}
+
+// SupportedLocales lists the locales that have translations, sorted
+func SupportedLocales() []string {
+	locales := []string{}
+	for locale := range translations {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// translate formats the string for key in the locale, falling back to English and then the key itself
+func translate(locale string, key string, args ...interface{}) string {
+	format, ok := translations[locale][key]
+	if !ok {
+		format, ok = translations[defaultLocale][key]
+	}
+	if !ok {
+		return key
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
@@ -0,0 +1,221 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"html"
+	"html/template"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+//go:embed templates/*.html
+var templateFiles embed.FS
+
+// Message is a rendered email, Text is the plain-text alternative generated from the same template
+type Message struct {
+	Subject string
+	Html    string
+	Text    string
+	Brand   Branding
+}
+
+// DMData is a new DM notification, Message is escaped when rendered
+type DMData struct {
+	ToAddress   string
+	ToName      string
+	FromAddress string
+	FromName    string
+	Message     string
+}
+
+// DailyData is the daily unread summary
+type DailyData struct {
+	ToAddress   string
+	ToName      string
+	Dms         int
+	Nfts        int
+	Communities int
+}
+
+// VerifyData is the email verification code email, VerifyUrl is optional
+type VerifyData struct {
+	ToAddress  string
+	ToName     string
+	Signupsite string
+	Code       string
+	VerifyUrl  string
+}
+
+// EmailChangedData is the notice sent to the previous address when the email is changed
+type EmailChangedData struct {
+	ToAddress string
+	ToName    string
+	NewEmail  string
+}
+
+// TwitterData is the daily Twitter contest email
+type TwitterData struct {
+	ToAddress string
+	ToName    string
+}
+
+// everything the layout needs, Data is the per email struct used by the content template
+type pageData struct {
+	Locale       string
+	Brand        Branding
+	Subject      string
+	Hero         string
+	HeroSubtitle string
+	ButtonUrl    string
+	ButtonText   string
+	TrackingUrl  string
+	Data         interface{}
+}
+
+// one template set (layout + content) per email, parsed once at startup
+var pages = map[string]*template.Template{}
+
+func init() {
+	for _, name := range []string{"dm", "daily", "verify", "emailchanged", "twitter"} {
+		pages[name] = template.Must(template.New("layout.html").
+			Funcs(templateFuncs(defaultLocale)).
+			ParseFS(templateFiles, "templates/layout.html", "templates/"+name+".html"))
+	}
+}
+
+func templateFuncs(locale string) template.FuncMap {
+	return template.FuncMap{
+		"T": func(key string, args ...interface{}) string {
+			return translate(locale, key, args...)
+		},
+		"short": shortAddress,
+	}
+}
+
+// shortAddress is 0x123...abcd for display, short names are left as-is
+func shortAddress(address string) string {
+	if len(address) <= 10 {
+		return address
+	}
+	return address[0:5] + "..." + address[len(address)-4:]
+}
+
+// trackingUrl is the GA4 open tracking pixel (see TrackEventGA4)
+func trackingUrl(event string, email string, toAddress string) string {
+	if email == "" {
+		return ""
+	}
+	return "https://api.v2.walletchat.fun/track_ga4/" + event + "/" + url.PathEscape(email) + "/_" + url.PathEscape(toAddress) + "_/test.png"
+}
+
+func render(name string, page pageData) (Message, error) {
+	tmpl, err := pages[name].Clone()
+	if err != nil {
+		return Message{}, err
+	}
+	tmpl = tmpl.Funcs(templateFuncs(page.Locale))
+
+	var htmlBody bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&htmlBody, "layout.html", page); err != nil {
+		return Message{}, err
+	}
+	var content bytes.Buffer
+	if err := tmpl.ExecuteTemplate(&content, "content", page); err != nil {
+		return Message{}, err
+	}
+
+	text := htmlToText(content.String())
+	if page.ButtonUrl != "" {
+		text += "\n\n" + page.ButtonText + ": " + page.ButtonUrl
+	}
+	text += "\n\n" + translate(page.Locale, "layout.questions") + " " + page.Brand.SupportEmail + "\n"
+
+	return Message{Subject: page.Subject, Html: htmlBody.String(), Text: text, Brand: page.Brand}, nil
+}
+
+var (
+	lineBreakTags = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</tr>`)
+	htmlTags      = regexp.MustCompile(`<[^>]*>`)
+	spaces        = regexp.MustCompile(`[ \t]+`)
+)
+
+// htmlToText turns the rendered (escaped) content block into the plain-text alternative
+func htmlToText(body string) string {
+	body = lineBreakTags.ReplaceAllString(body, "\n")
+	body = htmlTags.ReplaceAllString(body, "")
+	body = html.UnescapeString(body)
+
+	var lines []string
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(spaces.ReplaceAllString(line, " "))
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func newPage(locale string, signupsite string) pageData {
+	brand := BrandingForSite(signupsite)
+	locale = NormalizeLocale(locale)
+	return pageData{
+		Locale:     locale,
+		Brand:      brand,
+		ButtonUrl:  brand.AppUrl,
+		ButtonText: translate(locale, "button.open", brand.Name),
+	}
+}
+
+// RenderDM renders the new DM notification
+func RenderDM(locale string, signupsite string, email string, data DMData) (Message, error) {
+	page := newPage(locale, signupsite)
+	page.Subject = translate(page.Locale, "dm.subject", page.Brand.Name)
+	page.Hero = translate(page.Locale, "hero.waiting")
+	page.HeroSubtitle = translate(page.Locale, "hero.login", page.Brand.appHost())
+	page.TrackingUrl = trackingUrl("email_opened_dm", email, data.ToAddress)
+	page.Data = data
+	return render("dm", page)
+}
+
+// RenderDaily renders the daily unread messages summary
+func RenderDaily(locale string, signupsite string, email string, data DailyData) (Message, error) {
+	page := newPage(locale, signupsite)
+	page.Subject = translate(page.Locale, "daily.subject", page.Brand.Name)
+	page.Hero = translate(page.Locale, "hero.waiting")
+	page.HeroSubtitle = translate(page.Locale, "hero.login", page.Brand.appHost())
+	page.TrackingUrl = trackingUrl("email_opened_24hr", email, data.ToAddress)
+	page.Data = data
+	return render("daily", page)
+}
+
+// RenderVerify renders the email verification code, the button links to VerifyUrl when set
+func RenderVerify(locale string, signupsite string, data VerifyData) (Message, error) {
+	page := newPage(locale, signupsite)
+	page.Subject = translate(page.Locale, "verify.subject", data.Signupsite)
+	page.ButtonUrl = data.VerifyUrl
+	page.ButtonText = translate(page.Locale, "verify.button")
+	page.Data = data
+	return render("verify", page)
+}
+
+// RenderEmailChanged renders the security notice for the old address after an email change
+func RenderEmailChanged(locale string, signupsite string, data EmailChangedData) (Message, error) {
+	page := newPage(locale, signupsite)
+	page.Subject = translate(page.Locale, "changed.subject", page.Brand.Name)
+	page.ButtonUrl = ""
+	page.Data = data
+	return render("emailchanged", page)
+}
+
+// RenderTwitter renders the daily Twitter contest email
+func RenderTwitter(locale string, signupsite string, email string, data TwitterData) (Message, error) {
+	page := newPage(locale, signupsite)
+	page.Subject = translate(page.Locale, "twitter.subject", page.Brand.Name)
+	page.ButtonUrl = "https://twitter.com/intent/tweet?text=SomethingCreativeHere%20%40wallet_chat%20%23chat2earn%20%23chat2win"
+	page.ButtonText = translate(page.Locale, "twitter.button")
+	page.TrackingUrl = trackingUrl("email_opened_prize", email, data.ToAddress)
+	page.Data = data
+	return render("twitter", page)
+}
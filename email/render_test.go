@@ -0,0 +1,122 @@
+package email
+
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// go test ./email -update rewrites the golden files after an intended template or translation change
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+var goldenLocales = []string{"en", "es", "fr", "de", "pt"}
+
+// goldenTemplates render every template with the same fixed data, the signup site picks the branding
+var goldenTemplates = map[string]func(locale string) (Message, error){
+	"dm": func(locale string) (Message, error) {
+		return RenderDM(locale, "app.walletchat.fun", "alice@example.com", DMData{
+			ToAddress:   "0x1111111111111111111111111111111111111111",
+			ToName:      "alice.eth",
+			FromAddress: "0x2222222222222222222222222222222222222222",
+			FromName:    "bob.eth",
+			Message:     "gm <b>alice</b> & welcome!",
+		})
+	},
+	"daily": func(locale string) (Message, error) {
+		return RenderDaily(locale, "app.walletchat.fun", "alice@example.com", DailyData{
+			ToAddress:   "0x1111111111111111111111111111111111111111",
+			ToName:      "alice.eth",
+			Dms:         3,
+			Nfts:        2,
+			Communities: 1,
+		})
+	},
+	"verify": func(locale string) (Message, error) {
+		return RenderVerify(locale, "gooddollar.org", VerifyData{
+			ToAddress:  "0x1111111111111111111111111111111111111111",
+			ToName:     "alice.eth",
+			Signupsite: "gooddollar.org",
+			Code:       "K7QX2M",
+			VerifyUrl:  "https://api.v2.walletchat.fun/verify_email/alice%40example.com/K7QX2M",
+		})
+	},
+	"emailchanged": func(locale string) (Message, error) {
+		return RenderEmailChanged(locale, "oura.network", EmailChangedData{
+			ToAddress: "0x1111111111111111111111111111111111111111",
+			ToName:    "alice.eth",
+			NewEmail:  "new@example.com",
+		})
+	},
+	"twitter": func(locale string) (Message, error) {
+		return RenderTwitter(locale, "app.walletchat.fun", "alice@example.com", TwitterData{
+			ToAddress: "0x1111111111111111111111111111111111111111",
+			ToName:    "alice.eth",
+		})
+	},
+}
+
+func checkGolden(t *testing.T, path string, got string) {
+	t.Helper()
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v (run go test ./email -update to create it)", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the rendered email (run go test ./email -update if the change is intended)\ngot:\n%s", path, got)
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	for _, locale := range goldenLocales {
+		if NormalizeLocale(locale) != locale {
+			t.Fatalf("locale %s has no translations", locale)
+		}
+	}
+	for name, renderTemplate := range goldenTemplates {
+		for _, locale := range goldenLocales {
+			t.Run(name+"/"+locale, func(t *testing.T) {
+				message, err := renderTemplate(locale)
+				if err != nil {
+					t.Fatal(err)
+				}
+				base := filepath.Join("testdata", "golden", name+"."+locale)
+				checkGolden(t, base+".html", message.Html)
+				checkGolden(t, base+".txt", "Subject: "+message.Subject+"\n\n"+message.Text)
+			})
+		}
+	}
+}
+
+func TestRenderEscapesMessage(t *testing.T) {
+	message, err := goldenTemplates["dm"]("en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(message.Html, "<b>alice</b>") {
+		t.Error("the DM text is not escaped in the html body")
+	}
+	if !strings.Contains(message.Text, "gm <b>alice</b> & welcome!") {
+		t.Error("the plain-text body should have the DM text unescaped")
+	}
+}
+
+func TestNormalizeLocale(t *testing.T) {
+	cases := map[string]string{"es-MX": "es", "pt_BR": "pt", "DE": "de", "": "en", "xx": "en", "fr": "fr"}
+	for input, want := range cases {
+		if got := NormalizeLocale(input); got != want {
+			t.Errorf("NormalizeLocale(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
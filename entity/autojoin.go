@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// auto-join sync status mapping, stored in Autojoinsync.Status
+const (
+	AutoJoinStatusPending string = "pending" //waiting for the worker, Nextsync is when it may run
+	AutoJoinStatusRunning string = "running" //claimed by a worker (any replica)
+	AutoJoinStatusDone    string = "done"    //synced, picked up again at Nextsync while the wallet is active
+)
+
+// one row per wallet, when its NFT/POAP chat memberships were last refreshed by the auto-join worker
+type Autojoinsync struct {
+	Id         int        `gorm:"primaryKey;autoIncrement"`
+	Walletaddr string     `json:"walletaddr" gorm:"unique_index"`
+	Status     string     `json:"status"`
+	Lastsynced *time.Time `json:"last_synced"` //nil until the first sync finished
+	Nextsync   time.Time  `json:"next_sync" gorm:"index"`
+	Lastactive time.Time  `json:"last_active"` //last sign-in or inbox load, inactive wallets are not refreshed
+	Claimed    time.Time  `json:"-"`           //when the running worker took it, stale claims are released
+	Duration   int        `json:"duration_ms"` //of the last sync
+	Source     string     `json:"source"`      //what queued the last sync: signin, inbox, schedule, user, backfill
+}
@@ -18,8 +18,11 @@ const ( //context_type mapping just for bookkeeping(golang sucks for enums as we
 	All       string = "all"
 )
 const ( //type mapping just for bookkeeping(golang sucks for enums as well...)
-	Welcome string = "welcome"
-	Message string = "message"
+	Welcome      string = "welcome"
+	Message      string = "message"
+	Announcement string = "announcement"  //community admins only, members can read but not post in reply
+	MemberJoined string = "member_joined" //NFT chats, posted by the Transfer indexer when a wallet receives the collection
+	MemberLeft   string = "member_left"   //NFT chats, posted by the Transfer indexer when a wallet no longer holds any
 )
 
 type Unreadcountitem struct {
@@ -43,6 +46,7 @@ type Chatitem struct {
 	Name          string    `json:"sender_name"`                 //AUTO-SET BY BACKED FOR RETURN VALUE
 	Encryptsymkey string    `json:"encrypted_sym_lit_key"`       //USE IF USING LIT ENCRYPTION
 	Litaccesscond string    `json:"lit_access_conditions"`
+	Holderthread  int       `json:"holder_thread,omitempty" gorm:"default:0"` //Nftholderthread id, for "message the holder" conversations
 }
 
 //for olivers view function
@@ -72,6 +76,9 @@ type Groupchatitem struct {
 	Type          string    `json:"type"`
 	Contexttype   string    `json:"context_type"`
 	Name          string    `json:"sender_name"`
+	Channel       string    `json:"channel,omitempty" gorm:"default:''"` //community channel, empty is the general channel
+	Chain         string    `json:"chain,omitempty" gorm:"default:''"`   //chain of the NFT contract, empty when not sent
+	Replyto       int       `json:"reply_to,omitempty" gorm:"default:0"` //id of the message of the same group chat this replies to
 }
 
 //secondary table to help only load new messages for each user (not reload whole chat history)
@@ -80,6 +87,7 @@ type Groupchatreadtime struct {
 	Fromaddr          string    `json:"fromaddr"`
 	Readtimestamp_dtm time.Time `json:"readtimestamp_dtm"`
 	Nftaddr           string    `json:"nftaddr"`
+	Channel           string    `json:"channel,omitempty" gorm:"default:''"` //read state is per community channel
 }
 
 type Blockeduser struct {
@@ -89,9 +97,10 @@ type Blockeduser struct {
 }
 
 type Moderator struct {
-	Id      int    `gorm:"primary_key"`                 //AUTO-GENERATED (PRIMARY KEY)
-	Address string `json:"address" binding:"required"`  //*** REQUIRED INPUT ***
-	Company string `json:"company" validate:"required"` //*** REQUIRED INPUT ***
+	Id        int    `gorm:"primary_key"`                 //AUTO-GENERATED (PRIMARY KEY)
+	Address   string `json:"address" binding:"required"`  //*** REQUIRED INPUT ***
+	Company   string `json:"company" validate:"required"` //*** REQUIRED INPUT ***
+	Groupaddr string `json:"group_address" gorm:"index"`  //NFT/POAP group chat the wallet moderates, empty for company moderators
 }
 
 //potentially use this to keep track of user logins for DAU metrics
@@ -111,9 +120,14 @@ type Addrnamesignupitem struct {
 }
 
 type Addrnameitem struct {
-	Id      int    `gorm:"primaryKey;autoIncrement"`
-	Address string `json:"address"`
-	Name    string `json:"name"`
+	Id          int        `gorm:"primaryKey;autoIncrement"`
+	Address     string     `json:"address"`
+	Name        string     `json:"name"`
+	Verified    bool       `json:"verified" gorm:"default:false"`            //backed by a web3 name (ENS, SpaceID, .btc) that resolves to Address
+	Source      string     `json:"source,omitempty" gorm:"default:''"`       //naming system of a verified name
+	Canonical   string     `json:"-" gorm:"index;default:''"`                //lookalike-folded name used for uniqueness checks
+	Checkedat   *time.Time `json:"checked_at,omitempty"`                     //last ownership re-validation
+	Demotedname string     `json:"demoted_name,omitempty" gorm:"default:''"` //web3 name removed because Address no longer owns it
 }
 
 type Imageitem struct {
@@ -157,11 +171,21 @@ type CommunitySocialStruct struct {
 }
 
 //cant use camel notation because gorm adds "_" for any subsequent capital letter
+//conditions with the same Groupid must all pass (AND), any passing group grants access (OR)
 type Communityaccesscondition struct {
-	Id      int    `gorm:"primaryKey;autoIncrement"`
-	Slug    string `json:"slug"` //change community to slug in DB table too
-	Nftaddr string `json:"address"`
-	Count   string `json:"count"`
+	Id         int    `gorm:"primaryKey;autoIncrement"`
+	Slug       string `json:"slug"`     //change community to slug in DB table too
+	Nftaddr    string `json:"address"`  //contract address, or POAP event id
+	Count      string `json:"count"`    //minimum held, whole tokens for erc20 (can be fractional)
+	Type       string `json:"type"`     //erc721 (default), erc1155, erc20, poap
+	Chain      string `json:"chain"`    //empty checks every enabled EVM chain in registry order
+	Tokenid    string `json:"token_id"` //optional, only this token id counts (erc721/erc1155)
+	Groupid    int    `json:"group"`
+	Channel    string `json:"channel,omitempty" gorm:"default:''"`      //set for conditions of one community channel, on top of the community's
+	Tokenidmin string `json:"token_id_min,omitempty" gorm:"default:''"` //optional inclusive token id range (erc721/erc1155), either end can be open
+	Tokenidmax string `json:"token_id_max,omitempty" gorm:"default:''"`
+	Traittype  string `json:"trait_type,omitempty" gorm:"default:''"`  //optional, only tokens whose metadata has this trait value count
+	Traitvalue string `json:"trait_value,omitempty" gorm:"default:''"` //compared case insensitively
 }
 type Createcommunityitem struct {
 	Id     int                     `gorm:"primaryKey;autoIncrement"`
@@ -199,26 +223,52 @@ type Nftsidebar struct {
 // Chatiteminbox entity info
 // @Description Used as Return Data Struct Only
 type Chatiteminbox struct {
-	Id            int       `gorm:"primaryKey;autoIncrement"`
-	Fromaddr      string    `json:"fromaddr"`
-	Toaddr        string    `json:"toaddr"`
-	Timestamp     string    `json:"timestamp"`
-	Timestamp_dtm time.Time `json:"timestamp_dtm"`
-	Msgread       bool      `json:"read"`
-	Message       string    `json:"message"`
-	Nftaddr       string    `json:"nftaddr"`
-	Nftid         string    `json:"nftid"`
-	Unreadcnt     int       `json:"unread"`
-	Type          string    `json:"type"`
-	Contexttype   string    `json:"context_type"`
-	Sendername    string    `json:"sender_name"`
-	Name          string    `json:"name"`
-	LogoData      string    `json:"logo"`
-	Chain         string    `json:"chain"`
-	Encryptsymkey string    `json:"encrypted_sym_lit_key"` //USE IF USING LIT ENCRYPTION
-	Litaccesscond string    `json:"lit_access_conditions"`
+	Id             int        `gorm:"primaryKey;autoIncrement"`
+	Fromaddr       string     `json:"fromaddr"`
+	Toaddr         string     `json:"toaddr"`
+	Timestamp      string     `json:"timestamp"`
+	Timestamp_dtm  time.Time  `json:"timestamp_dtm"`
+	Msgread        bool       `json:"read"`
+	Message        string     `json:"message"`
+	Nftaddr        string     `json:"nftaddr"`
+	Nftid          string     `json:"nftid"`
+	Unreadcnt      int        `json:"unread"`
+	Type           string     `json:"type"`
+	Contexttype    string     `json:"context_type"`
+	Sendername     string     `json:"sender_name"`
+	Senderverified bool       `json:"sender_verified"` //sender_name is backed by a web3 name the sender owns
+	Name           string     `json:"name"`
+	LogoData       string     `json:"logo"`
+	Chain          string     `json:"chain"`
+	Encryptsymkey  string     `json:"encrypted_sym_lit_key"` //USE IF USING LIT ENCRYPTION
+	Litaccesscond  string     `json:"lit_access_conditions"`
+	Poapevent      *Poapevent `json:"poap_event,omitempty"` //POAP chats, the event's name, image and dates
 }
 
 type Chatiteminboxconvos struct {
 	Address string `json:"address"`
 }
+
+//support-level blocks set by Telegram support admins (separate from user-to-user Blockeduser)
+type Supportblockeduser struct {
+	Id         int        `gorm:"primaryKey;autoIncrement"`
+	Walletaddr string     `json:"walletaddr" gorm:"unique_index"` //blocked wallet (lower case)
+	Reason     string     `json:"reason"`                         //optional free text from the admin
+	Adminid    string     `json:"adminid"`                        //Telegram user ID of the admin who blocked
+	Expires    *time.Time `json:"expires"`                        //nil means the block never expires
+	Timestamp  time.Time  `json:"timestamp"`                      //when the block was created/updated
+}
+
+// Nftholderthread is a "message the holder of token #N" conversation, the holder side follows the token:
+// Holder is re-resolved on-chain whenever a message is sent or the thread is read
+type Nftholderthread struct {
+	Id          int        `gorm:"primaryKey;autoIncrement" json:"id"`
+	Chain       string     `json:"chain" gorm:"unique_index:idx_nftholderthread"`
+	Nftaddr     string     `json:"nftaddr" gorm:"unique_index:idx_nftholderthread"`
+	Nftid       string     `json:"nftid" gorm:"unique_index:idx_nftholderthread"`
+	Fromaddr    string     `json:"fromaddr" gorm:"unique_index:idx_nftholderthread"` //the wallet that started it
+	Holder      string     `json:"holder" gorm:"index"`                              //owner of the token when last resolved
+	Holdersince *time.Time `json:"-"`                                                //when Holder was first seen with the token, a holder only reads what came after (and what it sent or received)
+	Resolved    time.Time  `json:"resolved"`
+	Timestamp   time.Time  `json:"timestamp"` //last message
+}
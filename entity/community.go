@@ -0,0 +1,39 @@
+package entity
+
+import "time"
+
+// community created with CreateCommunity, Slug is the key used everywhere else (Groupchatitem.Nftaddr, Communitymember.Slug, ...)
+// the name is also kept in Addrnameitem for the older lookups
+type Communityitem struct {
+	Id        int       `gorm:"primaryKey;autoIncrement"`
+	Slug      string    `json:"slug" gorm:"unique_index"`
+	Name      string    `json:"name"`
+	Createdby string    `json:"created_by"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// a previous slug of a renamed community, old links resolve to Slug
+type Communityalias struct {
+	Id        int       `gorm:"primaryKey;autoIncrement"`
+	Oldslug   string    `json:"old_slug" gorm:"unique_index"`
+	Slug      string    `json:"slug" gorm:"index"`
+	Changedby string    `json:"changed_by"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// name of the channel every community has, its messages are stored with an empty Groupchatitem.Channel
+const DefaultChannel = "general"
+
+// topic channel in a community, each has its own message stream and read state (Groupchatitem.Channel = Name)
+// a community without rows only has the general channel
+type Communitychannel struct {
+	Id          int       `gorm:"primaryKey;autoIncrement"`
+	Slug        string    `json:"slug" gorm:"unique_index:idx_communitychannel"`
+	Name        string    `json:"name" gorm:"unique_index:idx_communitychannel"` //URL safe, unique in the community
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Position    int       `json:"position"`
+	Readonly    bool      `json:"read_only"` //only admins and moderators can post, e.g. announcements
+	Createdby   string    `json:"created_by"`
+	Timestamp   time.Time `json:"timestamp"`
+}
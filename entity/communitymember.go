@@ -0,0 +1,62 @@
+package entity
+
+import "time"
+
+// community roles, admins and moderators are also kept in Communityadmin.Accesslevel
+const (
+	CommunityRoleAdmin     string = "admin"     //manage roles, invites, join policy and everything moderators can do
+	CommunityRoleModerator string = "moderator" //kick/ban members and approve join requests
+	CommunityRoleMember    string = "member"
+)
+
+// membership status mapping
+const (
+	MemberStatusActive  string = "active"  //joined (has a Bookmarkitem so it shows in the sidebar)
+	MemberStatusPending string = "pending" //asked to join a community that requires approval
+	MemberStatusLeft    string = "left"    //left or was kicked, may join again
+	MemberStatusBanned  string = "banned"  //can't join again until unbanned or Banneduntil passes
+)
+
+// community join policies
+const (
+	JoinModeOpen     string = "open"     //anyone can join (default, WalletChat HQ auto-joins)
+	JoinModeApproval string = "approval" //private - join requests wait for an admin/moderator, invite links skip approval
+	JoinModeInvite   string = "invite"   //private - only invite links
+)
+
+// one row per wallet per community, source of truth for membership (Bookmarkitem only drives the sidebar)
+type Communitymember struct {
+	Id          int        `gorm:"primaryKey;autoIncrement"`
+	Slug        string     `json:"slug" gorm:"unique_index:idx_communitymember"`
+	Walletaddr  string     `json:"walletaddr" gorm:"unique_index:idx_communitymember"`
+	Role        string     `json:"role"`   //admin, moderator, member
+	Status      string     `json:"status"` //active, pending, left, banned
+	Invitecode  string     `json:"invite_code,omitempty"`
+	Actor       string     `json:"actor,omitempty"` //who approved/kicked/banned
+	Banreason   string     `json:"ban_reason,omitempty"`
+	Banneduntil *time.Time `json:"banned_until,omitempty"` //nil with status banned means permanent
+	Joinedat    *time.Time `json:"joined_at"`
+	Timestamp   time.Time  `json:"timestamp"` //last status change
+}
+
+// invite link, the code is the shareable part
+type Communityinvite struct {
+	Id        int        `gorm:"primaryKey;autoIncrement"`
+	Slug      string     `json:"slug"`
+	Code      string     `json:"code" gorm:"unique_index"`
+	Createdby string     `json:"created_by"`
+	Maxuses   int        `json:"max_uses"` //0 means unlimited
+	Uses      int        `json:"uses"`
+	Expires   *time.Time `json:"expires"` //nil means never
+	Revoked   bool       `json:"revoked"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// join policy per community, no row means open
+type Communitypolicy struct {
+	Id        int       `gorm:"primaryKey;autoIncrement"`
+	Slug      string    `json:"slug" gorm:"unique_index"`
+	Joinmode  string    `json:"join_mode"` //open, approval, invite
+	Updatedby string    `json:"updated_by"`
+	Timestamp time.Time `json:"timestamp"`
+}
@@ -0,0 +1,16 @@
+package entity
+
+import "time"
+
+// directory listing of a community or NFT/POAP group chat, no row means uncategorized, unverified and listed
+type Communityprofile struct {
+	Id         int       `gorm:"primaryKey;autoIncrement"`
+	Slug       string    `json:"slug" gorm:"unique_index"` //community slug or NFT contract / poap_ event
+	Category   string    `json:"category"`
+	Tags       string    `json:"tags"`     //comma separated, lower case
+	Unlisted   bool      `json:"unlisted"` //hidden from the directory, the chat itself still works
+	Verified   bool      `json:"verified"` //only set by WalletChat (admin API key)
+	Verifiedby string    `json:"verified_by,omitempty"`
+	Updatedby  string    `json:"updated_by"`
+	Timestamp  time.Time `json:"timestamp"`
+}
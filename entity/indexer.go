@@ -0,0 +1,27 @@
+package entity
+
+import "time"
+
+// a block range the Transfer indexer finished, the newest row of a chain is its cursor
+// and the older ones find the fork point after a reorg
+type Indexercheckpoint struct {
+	Id        int       `gorm:"primaryKey;autoIncrement"`
+	Chain     string    `json:"chain" gorm:"index"`
+	Block     uint64    `json:"block"`
+	Hash      string    `json:"hash"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// a recently indexed token transfer, kept (as long as its checkpoint) so a reorg can undo it
+type Indexertransfer struct {
+	Id       int    `gorm:"primaryKey;autoIncrement"`
+	Chain    string `json:"chain" gorm:"index:idx_indexertransfer"`
+	Block    uint64 `json:"block" gorm:"index:idx_indexertransfer"`
+	Contract string `json:"contract"`
+	Fromaddr string `json:"fromaddr"`
+	Toaddr   string `json:"toaddr"`
+	Tokenid  string `json:"token_id"`
+	Amount   string `json:"amount"`
+	Txhash   string `json:"txhash"`
+	Logindex uint   `json:"log_index"`
+}
@@ -0,0 +1,121 @@
+package entity
+
+import "time"
+
+// moderation action mapping, stored in Moderationlog.Action
+const (
+	ModActionDelete   string = "delete"
+	ModActionPin      string = "pin"
+	ModActionUnpin    string = "unpin"
+	ModActionMute     string = "mute"
+	ModActionUnmute   string = "unmute"
+	ModActionSlowmode string = "slowmode"
+	ModActionKick     string = "kick"
+	ModActionBan      string = "ban"
+	ModActionUnban    string = "unban"
+	ModActionRole     string = "role"
+	ModActionApprove  string = "approve"
+	ModActionReject   string = "reject"
+	ModActionReport   string = "report" //a report was resolved or dismissed
+	ModActionFilters  string = "filters"
+	ModActionChannel  string = "channel" //channel created, changed or deleted
+)
+
+// report status mapping
+const (
+	ReportStatusOpen      string = "open"
+	ReportStatusResolved  string = "resolved"  //a moderator acted on it
+	ReportStatusDismissed string = "dismissed" //looked at, nothing to do
+)
+
+// audit log of every moderator action in a group chat (community slug or NFT/POAP address)
+type Moderationlog struct {
+	Id         int        `gorm:"primaryKey;autoIncrement"`
+	Groupaddr  string     `json:"groupaddr" gorm:"index"`
+	Actor      string     `json:"actor"`
+	Action     string     `json:"action"`
+	Targetaddr string     `json:"target_address,omitempty"`
+	Messageid  int        `json:"message_id,omitempty"`
+	Message    string     `json:"message,omitempty"` //copy of a deleted/reported message
+	Reason     string     `json:"reason,omitempty"`
+	Until      *time.Time `json:"until,omitempty"` //end of a mute/ban, nil means permanent
+	Timestamp  time.Time  `json:"timestamp"`
+}
+
+// a muted wallet can still read the group chat but can't post until Until
+type Groupmute struct {
+	Id         int       `gorm:"primaryKey;autoIncrement"`
+	Groupaddr  string    `json:"groupaddr" gorm:"unique_index:idx_groupmute"`
+	Walletaddr string    `json:"walletaddr" gorm:"unique_index:idx_groupmute"`
+	Reason     string    `json:"reason"`
+	Mutedby    string    `json:"muted_by"`
+	Until      time.Time `json:"until"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// per group chat moderation settings, no row means no slow mode
+type Groupmoderation struct {
+	Id              int       `gorm:"primaryKey;autoIncrement"`
+	Groupaddr       string    `json:"groupaddr" gorm:"unique_index"`
+	Slowmodeseconds int       `json:"slowmode_seconds"` //minimum time between messages per wallet, 0 is off
+	Updatedby       string    `json:"updated_by"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// pinned message in a group chat, shown in Position order (a group has at most 10)
+type Pinnedmessage struct {
+	Id        int       `gorm:"primaryKey;autoIncrement"`
+	Groupaddr string    `json:"groupaddr" gorm:"unique_index:idx_pinnedmessage"`
+	Messageid int       `json:"message_id" gorm:"unique_index:idx_pinnedmessage"`
+	Position  int       `json:"position"`
+	Pinnedby  string    `json:"pinned_by"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// a message reported by a user, waits in the moderation queue until a moderator resolves or dismisses it
+type Messagereport struct {
+	Id         int        `gorm:"primaryKey;autoIncrement"`
+	Groupaddr  string     `json:"groupaddr" gorm:"index"`
+	Messageid  int        `json:"message_id" gorm:"unique_index:idx_messagereport"`
+	Reporter   string     `json:"reporter" gorm:"unique_index:idx_messagereport"`
+	Author     string     `json:"author"`
+	Message    string     `json:"message"` //copy, the message may be deleted
+	Reason     string     `json:"reason"`
+	Status     string     `json:"status"` //open, resolved, dismissed
+	Resolvedby string     `json:"resolved_by,omitempty"`
+	Resolution string     `json:"resolution,omitempty"`
+	Resolvedat *time.Time `json:"resolved_at,omitempty"`
+	Timestamp  time.Time  `json:"timestamp"`
+}
+
+// content filter rule actions
+const (
+	FilterActionOff   string = "off"
+	FilterActionBlock string = "block" //message is rejected
+	FilterActionMask  string = "mask"  //offending part is replaced, message is posted
+	FilterActionFlag  string = "flag"  //message is posted and goes in the moderation queue
+)
+
+// link filter modes
+const (
+	LinkModeDeny  string = "deny"  //links are fine except the Linkdeny domains
+	LinkModeAllow string = "allow" //only Linkallow domains are fine
+)
+
+// per group chat message filter settings, no row means the defaults (mask profanity, allow links, block phishing)
+// word and domain lists are comma separated and lower case
+type Chatfilter struct {
+	Id              int       `gorm:"primaryKey;autoIncrement"`
+	Groupaddr       string    `json:"groupaddr" gorm:"unique_index"`
+	Profanityaction string    `json:"profanity_action"`
+	Customwordsonly bool      `json:"custom_words_only"` //skip the built in profanity list
+	Customwords     string    `json:"custom_words" gorm:"type:text"`
+	Allowedwords    string    `json:"allowed_words" gorm:"type:text"` //never treated as profanity (false positives)
+	Linkmode        string    `json:"link_mode"`
+	Linkaction      string    `json:"link_action"`
+	Linkallow       string    `json:"link_allow" gorm:"type:text"`
+	Linkdeny        string    `json:"link_deny" gorm:"type:text"`
+	Phishingaction  string    `json:"phishing_action"`
+	Updatedby       string    `json:"updated_by"`
+	Timestamp       time.Time `json:"timestamp"`
+}
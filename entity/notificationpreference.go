@@ -0,0 +1,25 @@
+package entity
+
+import "time"
+
+// notification mode mapping for a single conversation
+const (
+	NotifyModeAll      string = "all"      //every message (default for DMs)
+	NotifyModeMentions string = "mentions" //only @mentions (default for NFT/community group chats)
+	NotifyModeMuted    string = "muted"    //nothing, until Muteduntil if set
+)
+
+// per-conversation override of the global Settings.Notifydm flag, no row means the defaults above
+// Contexttype is dm, nft or community (same values as Chatiteminbox.Contexttype)
+type Notificationpreference struct {
+	Id          int        `gorm:"primaryKey;autoIncrement"`
+	Walletaddr  string     `json:"walletaddr" gorm:"unique_index:idx_notificationpreference"`   //AUTO-SET FROM JWT
+	Contexttype string     `json:"context_type" gorm:"unique_index:idx_notificationpreference"` //*** REQUIRED INPUT *** - dm, nft, community
+	Target      string     `json:"target" gorm:"unique_index:idx_notificationpreference"`       //*** REQUIRED INPUT *** - DM peer address, NFT contract or community slug
+	Mode        string     `json:"mode"`                                                        //all, mentions, muted
+	Muteduntil  *time.Time `json:"muted_until"`                                                 //only for muted - nil means muted until unmuted
+	Email       bool       `json:"email"`                                                       //send email for this conversation
+	Telegram    bool       `json:"telegram"`                                                    //send Telegram for this conversation
+	Push        bool       `json:"push"`                                                        //send push notifications for this conversation
+	Timestamp   time.Time  `json:"timestamp"`
+}
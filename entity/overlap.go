@@ -0,0 +1,46 @@
+package entity
+
+import "time"
+
+// token overlap job status mapping, stored in Overlapjob.Status
+const (
+	OverlapStatusPending   string = "pending"   //waiting for the worker to start the Dune query
+	OverlapStatusRunning   string = "running"   //the query is executing, Nextpoll is when the worker checks it again
+	OverlapStatusDone      string = "done"      //results are in Overlaprow
+	OverlapStatusFailed    string = "failed"    //see Error
+	OverlapStatusCancelled string = "cancelled" //by the requester or because it ran longer than the timeout
+)
+
+// one token holder overlap analysis (which other tokens the holders of Contract hold), run as a Dune query
+type Overlapjob struct {
+	Id          int        `json:"-" gorm:"primaryKey;autoIncrement"`
+	Jobid       string     `json:"job_id" gorm:"unique_index"`
+	Chain       string     `json:"chain"` //ethereum or solana
+	Contract    string     `json:"contract_address" gorm:"index"`
+	Walletaddr  string     `json:"-"` //who submitted it (if signed in), gets the overlap.completed webhook
+	Executionid string     `json:"-"`
+	Status      string     `json:"status" gorm:"index"`
+	Error       string     `json:"error,omitempty"`
+	Rowcount    int        `json:"row_count"`
+	Polls       int        `json:"-"`
+	Nextpoll    time.Time  `json:"-" gorm:"index"` //when the worker looks at it next, moving it is also how a worker claims the job
+	Submitted   time.Time  `json:"submitted"`
+	Started     *time.Time `json:"started,omitempty"`
+	Finished    *time.Time `json:"finished,omitempty"`
+}
+
+// one result row of the last finished overlap job of a contract, replaced when a newer job finishes
+type Overlaprow struct {
+	Id           int                    `json:"-" gorm:"primaryKey;autoIncrement"`
+	Chain        string                 `json:"-" gorm:"index:idx_overlaprow"`
+	Contract     string                 `json:"-" gorm:"index:idx_overlaprow"`
+	Position     int                    `json:"position"` //1 = most overlapping holders, in the order the query returned
+	Token        string                 `json:"token_address"`
+	Symbol       string                 `json:"symbol"`
+	Name         string                 `json:"name"`
+	Holders      int64                  `json:"holders"`                  //holders of Contract that also hold Token
+	Percentage   float64                `json:"percentage"`               //of Contract's holders
+	Extracolumns string                 `json:"-" gorm:"type:text"`       //the other columns of the Dune row, as JSON
+	Extra        map[string]interface{} `json:"extra,omitempty" gorm:"-"` //Extracolumns decoded
+	Timestamp    time.Time              `json:"timestamp"`
+}
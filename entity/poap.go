@@ -0,0 +1,29 @@
+package entity
+
+import "time"
+
+// cached POAP event metadata for the inbox and directory, Id is the POAP event id (chat nftaddr "poap_" + Id)
+type Poapevent struct {
+	Id          int       `json:"id" gorm:"primary_key;auto_increment:false"`
+	Fancyid     string    `json:"fancy_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description" gorm:"type:text"`
+	Imageurl    string    `json:"image_url"`
+	Eventurl    string    `json:"event_url"`
+	City        string    `json:"city"`
+	Country     string    `json:"country"`
+	Startdate   string    `json:"start_date"` //as POAP sends them, ie 05-Nov-2023
+	Enddate     string    `json:"end_date"`
+	Expirydate  string    `json:"expiry_date"`
+	Supply      int       `json:"supply"`
+	Cachedat    time.Time `json:"cached_at"`
+}
+
+// Poapsecretfailure counts the wrong POAP secret codes of a wallet ("wallet|<addr>") or an IP ("ip|<ip>"),
+// the count starts over once Expires passed (each guess moves it poapSecretLockout ahead)
+type Poapsecretfailure struct {
+	Id         int       `gorm:"primaryKey;autoIncrement"`
+	Failurekey string    `gorm:"unique_index"`
+	Misses     int       `gorm:"default:0"`
+	Expires    time.Time `gorm:"index"`
+}
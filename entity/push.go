@@ -0,0 +1,23 @@
+package entity
+
+import "time"
+
+// push device platform mapping
+const (
+	PushPlatformWeb  string = "webpush" //browser Web Push subscription (VAPID)
+	PushPlatformFcm  string = "fcm"     //Firebase Cloud Messaging token (Android)
+	PushPlatformApns string = "apns"    //Apple Push Notification service device token (iOS)
+)
+
+// one row per device/browser registered for push notifications
+type Pushdevice struct {
+	Id         int       `gorm:"primaryKey;autoIncrement"`
+	Walletaddr string    `json:"walletaddr"`             //AUTO-SET FROM JWT
+	Platform   string    `json:"platform"`               //*** REQUIRED INPUT *** - webpush, fcm or apns
+	Token      string    `json:"token" gorm:"type:text"` //*** REQUIRED INPUT *** - FCM/APNs token or Web Push endpoint URL
+	P256dh     string    `json:"p256dh"`                 //WEB PUSH ONLY - browser public key (base64url)
+	Auth       string    `json:"auth"`                   //WEB PUSH ONLY - browser auth secret (base64url)
+	Name       string    `json:"name"`                   //optional label shown in the device list (ie "Chrome on Mac")
+	Lastused   time.Time `json:"lastused"`               //last successful push
+	Timestamp  time.Time `json:"timestamp"`              //when the device was registered/refreshed
+}
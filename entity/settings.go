@@ -1,5 +1,7 @@
 package entity
 
+import "time"
+
 //Settings object for REST(CRUD)
 type Settings struct {
 	ID              int    `json:"id"`                             //AUTO-GENERATED (PRIMARY KEY)
@@ -17,4 +19,28 @@ type Settings struct {
 	Twitteruser     string `json:"twitteruser"`                    //TWITTER/X @user
 	Twitterverified string `json:"twitterverified"`                //HAS USER VERIFIED @user with WALLETCHAT (not twitter blue checkmark)
 	Twitterid       string `json:"twitterid"`                      //TWITTER USER ID - FUTURE USE IF USER CHANGES NAME?
+	Locale          string `json:"locale"`                         //EMAIL LANGUAGE (en, es, fr, de, pt - empty means en)
+}
+
+//email verification tokens, only the SHA-256 hash of the code is stored
+type Emailverification struct {
+	Id         int        `gorm:"primaryKey;autoIncrement"`
+	Walletaddr string     `json:"walletaddr"`
+	Email      string     `json:"email"`
+	Tokenhash  string     `json:"-"`
+	Expires    time.Time  `json:"expires"`
+	Usedat     *time.Time `json:"used_at"` //set once verified (or when replaced by a newer token)
+	Timestamp  time.Time  `json:"timestamp"`
+}
+
+//audit trail of settings changes, one row per changed field (secrets are never recorded)
+type Settingsaudit struct {
+	Id         int       `gorm:"primaryKey;autoIncrement"`
+	Walletaddr string    `json:"walletaddr" gorm:"index"` //settings that changed
+	Actor      string    `json:"actor"`                   //wallet from the JWT, or "system" for verification callbacks
+	Source     string    `json:"source"`                  //v1, v2 or system
+	Field      string    `json:"field"`                   //v2 field name, e.g. notify_dm
+	Oldvalue   string    `json:"old_value"`
+	Newvalue   string    `json:"new_value"`
+	Timestamp  time.Time `json:"timestamp"`
 }
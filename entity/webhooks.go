@@ -0,0 +1,49 @@
+package entity
+
+import "time"
+
+// webhook event names sent to integrators
+const (
+	WebhookMessageCreated        string = "message.created"
+	WebhookCommunityMemberJoined string = "community.member_joined"
+	WebhookCommunityMemberLeft   string = "community.member_left"
+	WebhookReferralRedeemed      string = "referral.redeemed"
+	WebhookEmailVerified         string = "settings.email_verified"
+	WebhookOverlapCompleted      string = "overlap.completed"
+	WebhookPing                  string = "ping"
+)
+
+// delivery status mapping
+const (
+	WebhookStatusPending   string = "pending"
+	WebhookStatusSending   string = "sending"
+	WebhookStatusDelivered string = "delivered"
+	WebhookStatusFailed    string = "failed"
+)
+
+// per-integration webhook subscription (integration is the signup site/domain of the partner dApp)
+type Webhooksubscription struct {
+	Id         int       `gorm:"primaryKey;autoIncrement"`
+	Owner      string    `json:"owner"`      //AUTO-SET - short API key ID of the integrator that created it
+	Signupsite string    `json:"signupsite"` //*** REQUIRED INPUT *** - matches Settings.Signupsite or Settings.Domain
+	Url        string    `json:"url"`        //*** REQUIRED INPUT *** - https endpoint to POST events to
+	Events     string    `json:"events"`     //comma separated event names, empty means all events
+	Secret     string    `json:"secret"`     //AUTO-SET - HMAC secret, only returned on creation
+	Active     bool      `json:"active"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// delivery log, one row per event per subscription (replays create a new row)
+type Webhookdelivery struct {
+	Id             int        `gorm:"primaryKey;autoIncrement"`
+	Subscriptionid int        `json:"subscription_id"`
+	Event          string     `json:"event"`
+	Payload        string     `json:"payload" gorm:"type:text"`
+	Status         string     `json:"status"` //pending, sending, delivered, failed
+	Attempts       int        `json:"attempts"`
+	Responsecode   int        `json:"response_code"`
+	Lasterror      string     `json:"last_error"`
+	Nextattempt    time.Time  `json:"next_attempt"`
+	Deliveredat    *time.Time `json:"delivered_at"`
+	Timestamp      time.Time  `json:"timestamp"`
+}
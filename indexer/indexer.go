@@ -0,0 +1,314 @@
+package indexer
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+const (
+	defaultBatchSize   = 500 //blocks per eth_getLogs, most RPC providers allow at least this
+	maxBatchesPerPoll  = 10  //a poll that is far behind catches up over several polls
+	contractsPerFilter = 100 //addresses per eth_getLogs
+	keepCheckpoints    = 128 //how far back a reorg can be undone, in checkpoints
+)
+
+// the ERC-721 and ERC-1155 transfer events
+const transferEventsAbiJson = `[
+{"type":"event","name":"TransferSingle","anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"id","type":"uint256"},{"indexed":false,"name":"value","type":"uint256"}]},
+{"type":"event","name":"TransferBatch","anonymous":false,"inputs":[{"indexed":true,"name":"operator","type":"address"},{"indexed":true,"name":"from","type":"address"},{"indexed":true,"name":"to","type":"address"},{"indexed":false,"name":"ids","type":"uint256[]"},{"indexed":false,"name":"values","type":"uint256[]"}]}
+]`
+
+var (
+	topicTransfer       = crypto.Keccak256Hash([]byte("Transfer(address,address,uint256)")) //ERC-20 uses it too, with 3 topics
+	topicTransferSingle = crypto.Keccak256Hash([]byte("TransferSingle(address,address,address,uint256,uint256)"))
+	topicTransferBatch  = crypto.Keccak256Hash([]byte("TransferBatch(address,address,address,uint256[],uint256[])"))
+
+	transferEventsAbi abi.ABI
+)
+
+func init() {
+	var err error
+	if transferEventsAbi, err = abi.JSON(strings.NewReader(transferEventsAbiJson)); err != nil {
+		panic(err)
+	}
+}
+
+// ZeroAddress is From of mints and To of burns
+const ZeroAddress = "0x0000000000000000000000000000000000000000"
+
+// Transfer is one token movement, a TransferBatch log gives one Transfer per id
+type Transfer struct {
+	Chain    string `json:"chain"`
+	Contract string `json:"contract"` //lower case, like From and To
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Tokenid  string `json:"token_id"`
+	Amount   string `json:"amount"` //1 for ERC-721
+	Block    uint64 `json:"block"`
+	Txhash   string `json:"txhash"`
+	Logindex uint   `json:"log_index"`
+	Removed  bool   `json:"removed"` //its block was reorged out, the transfer didn't happen after all
+}
+
+// Checkpoint is the last block of a finished range and its hash
+type Checkpoint struct {
+	Block uint64
+	Hash  string
+}
+
+// ChainReader is the part of ethclient.Client the indexer uses, go-ethereum's SimulatedBackend implements it too
+type ChainReader interface {
+	HeaderByNumber(ctx context.Context, number *big.Int) (*types.Header, error)
+	FilterLogs(ctx context.Context, query ethereum.FilterQuery) ([]types.Log, error)
+}
+
+// Store keeps the checkpoints and recent transfers of each chain
+type Store interface {
+	Checkpoints(chain string) ([]Checkpoint, error) //newest first, the first one is the cursor
+	Save(chain string, checkpoint Checkpoint, transfers []Transfer) error
+	Rewind(chain string, block uint64) ([]Transfer, error) //drops everything after the block, returns the dropped transfers
+	Prune(chain string, keep int) error                    //keeps the newest checkpoints and their transfers
+}
+
+// Indexer follows the Transfer events of a changing set of contracts on one chain by polling blocks
+type Indexer struct {
+	Chain         string
+	Client        ChainReader
+	Store         Store
+	Contracts     func() []string            //the contracts to follow, asked on every poll
+	Handle        func(transfers []Transfer) //new transfers in block order, or the undone ones (Removed) after a reorg
+	Confirmations uint64                     //how far behind the head to stay, fewer reorgs reach the indexer
+	BatchSize     uint64
+}
+
+// Poll indexes the blocks since the cursor, a new chain starts at the current block (auto-join covers older holders).
+// Returns the number of transfers handled, removed ones included.
+func (ix *Indexer) Poll(ctx context.Context) (int, error) {
+	head, err := ix.Client.HeaderByNumber(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	if head.Number.Uint64() < ix.Confirmations {
+		return 0, nil
+	}
+	target := head.Number.Uint64() - ix.Confirmations
+
+	checkpoints, err := ix.Store.Checkpoints(ix.Chain)
+	if err != nil {
+		return 0, err
+	}
+	if len(checkpoints) == 0 {
+		checkpoint, err := ix.checkpoint(ctx, target)
+		if err != nil {
+			return 0, err
+		}
+		return 0, ix.Store.Save(ix.Chain, checkpoint, nil)
+	}
+
+	cursor, handled, err := ix.unwindReorg(ctx, checkpoints)
+	if err != nil {
+		return handled, err
+	}
+
+	batchSize := ix.BatchSize
+	if batchSize == 0 {
+		batchSize = defaultBatchSize
+	}
+	for batch := 0; batch < maxBatchesPerPoll && cursor.Block < target; batch++ {
+		from := cursor.Block + 1
+		to := from + batchSize - 1
+		if to > target {
+			to = target
+		}
+		transfers, err := ix.transfers(ctx, from, to)
+		if err != nil {
+			return handled, err
+		}
+		checkpoint, err := ix.checkpoint(ctx, to)
+		if err != nil {
+			return handled, err
+		}
+		//handled before saving, a crash in between handles them again (the handler has to be idempotent)
+		if len(transfers) > 0 {
+			ix.Handle(transfers)
+			handled += len(transfers)
+		}
+		if err := ix.Store.Save(ix.Chain, checkpoint, transfers); err != nil {
+			return handled, err
+		}
+		cursor = checkpoint
+	}
+	return handled, ix.Store.Prune(ix.Chain, keepCheckpoints)
+}
+
+// unwindReorg checks the cursor is still on the canonical chain, otherwise it goes back to the newest checkpoint
+// that is and undoes the transfers after it
+func (ix *Indexer) unwindReorg(ctx context.Context, checkpoints []Checkpoint) (Checkpoint, int, error) {
+	for i, checkpoint := range checkpoints {
+		canonical, err := ix.checkpoint(ctx, checkpoint.Block)
+		if err != nil {
+			return Checkpoint{}, 0, err
+		}
+		if canonical.Hash != checkpoint.Hash {
+			continue
+		}
+		if i == 0 {
+			return checkpoint, 0, nil
+		}
+		removed, err := ix.undo(checkpoint.Block)
+		return checkpoint, removed, err
+	}
+
+	//deeper than the kept checkpoints, start over from the oldest block we know of
+	oldest := checkpoints[len(checkpoints)-1]
+	removed, err := ix.undo(0)
+	if err != nil {
+		return Checkpoint{}, removed, err
+	}
+	restart, err := ix.checkpoint(ctx, oldest.Block)
+	if err != nil {
+		return Checkpoint{}, removed, err
+	}
+	return restart, removed, ix.Store.Save(ix.Chain, restart, nil)
+}
+
+func (ix *Indexer) undo(block uint64) (int, error) {
+	removed, err := ix.Store.Rewind(ix.Chain, block)
+	if err != nil {
+		return 0, err
+	}
+	fmt.Println("indexer - reorg on ", ix.Chain, "back to block", block, "undoing transfers:", len(removed))
+	if len(removed) == 0 {
+		return 0, nil
+	}
+	//undone newest first
+	sort.SliceStable(removed, func(i, j int) bool {
+		if removed[i].Block != removed[j].Block {
+			return removed[i].Block > removed[j].Block
+		}
+		return removed[i].Logindex > removed[j].Logindex
+	})
+	for i := range removed {
+		removed[i].Removed = true
+	}
+	ix.Handle(removed)
+	return len(removed), nil
+}
+
+func (ix *Indexer) checkpoint(ctx context.Context, block uint64) (Checkpoint, error) {
+	header, err := ix.Client.HeaderByNumber(ctx, new(big.Int).SetUint64(block))
+	if err != nil {
+		return Checkpoint{}, err
+	}
+	return Checkpoint{Block: block, Hash: header.Hash().Hex()}, nil
+}
+
+// transfers fetches and decodes the transfer logs of the followed contracts in the block range
+func (ix *Indexer) transfers(ctx context.Context, from uint64, to uint64) ([]Transfer, error) {
+	contracts := ix.Contracts()
+	transfers := []Transfer{}
+	for start := 0; start < len(contracts); start += contractsPerFilter {
+		end := start + contractsPerFilter
+		if end > len(contracts) {
+			end = len(contracts)
+		}
+		addresses := []common.Address{}
+		for _, contract := range contracts[start:end] {
+			if common.IsHexAddress(contract) {
+				addresses = append(addresses, common.HexToAddress(contract))
+			}
+		}
+		if len(addresses) == 0 {
+			continue
+		}
+		logs, err := ix.Client.FilterLogs(ctx, ethereum.FilterQuery{
+			FromBlock: new(big.Int).SetUint64(from),
+			ToBlock:   new(big.Int).SetUint64(to),
+			Addresses: addresses,
+			Topics:    [][]common.Hash{{topicTransfer, topicTransferSingle, topicTransferBatch}},
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, log := range logs {
+			transfers = append(transfers, DecodeTransfers(ix.Chain, log)...)
+		}
+	}
+	sort.SliceStable(transfers, func(i, j int) bool {
+		if transfers[i].Block != transfers[j].Block {
+			return transfers[i].Block < transfers[j].Block
+		}
+		return transfers[i].Logindex < transfers[j].Logindex
+	})
+	return transfers, nil
+}
+
+// DecodeTransfers turns an ERC-721 Transfer or ERC-1155 TransferSingle/TransferBatch log into transfers,
+// anything else (ie ERC-20 Transfer, which has no indexed token id) gives none
+func DecodeTransfers(chain string, log types.Log) []Transfer {
+	if len(log.Topics) == 0 {
+		return nil
+	}
+	transfer := Transfer{
+		Chain:    chain,
+		Contract: strings.ToLower(log.Address.Hex()),
+		Block:    log.BlockNumber,
+		Txhash:   log.TxHash.Hex(),
+		Logindex: log.Index,
+		Removed:  log.Removed,
+	}
+
+	switch log.Topics[0] {
+	case topicTransfer:
+		if len(log.Topics) != 4 {
+			return nil
+		}
+		transfer.From = topicAddress(log.Topics[1])
+		transfer.To = topicAddress(log.Topics[2])
+		transfer.Tokenid = log.Topics[3].Big().String()
+		transfer.Amount = "1"
+		return []Transfer{transfer}
+	case topicTransferSingle, topicTransferBatch:
+		if len(log.Topics) != 4 {
+			return nil
+		}
+		transfer.From = topicAddress(log.Topics[2])
+		transfer.To = topicAddress(log.Topics[3])
+		event := "TransferSingle"
+		if log.Topics[0] == topicTransferBatch {
+			event = "TransferBatch"
+		}
+		values, err := transferEventsAbi.Unpack(event, log.Data)
+		if err != nil || len(values) != 2 {
+			fmt.Println("indexer - bad transfer log: ", chain, transfer.Contract, transfer.Txhash, err)
+			return nil
+		}
+		if event == "TransferSingle" {
+			transfer.Tokenid = values[0].(*big.Int).String()
+			transfer.Amount = values[1].(*big.Int).String()
+			return []Transfer{transfer}
+		}
+		ids, amounts := values[0].([]*big.Int), values[1].([]*big.Int)
+		transfers := []Transfer{}
+		for i := 0; i < len(ids) && i < len(amounts); i++ {
+			transfer.Tokenid = ids[i].String()
+			transfer.Amount = amounts[i].String()
+			transfers = append(transfers, transfer)
+		}
+		return transfers
+	}
+	return nil
+}
+
+func topicAddress(topic common.Hash) string {
+	return strings.ToLower(common.BytesToAddress(topic.Bytes()).Hex())
+}
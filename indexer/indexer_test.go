@@ -0,0 +1,208 @@
+package indexer
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// transferEmitter is a contract that logs Transfer(from, to, tokenId) with the three calldata words as topics,
+// like an ERC-721 would: PUSH1 40 CALLDATALOAD, PUSH1 20 CALLDATALOAD, PUSH1 00 CALLDATALOAD, PUSH32 topic,
+// PUSH1 00, PUSH1 00, LOG4, STOP. The init code copies those 48 bytes after it and returns them.
+func transferEmitterCode() []byte {
+	runtime := append([]byte{0x60, 0x40, 0x35, 0x60, 0x20, 0x35, 0x60, 0x00, 0x35, 0x7f}, topicTransfer.Bytes()...)
+	runtime = append(runtime, 0x60, 0x00, 0x60, 0x00, 0xa4, 0x00)
+	init := []byte{0x60, byte(len(runtime)), 0x80, 0x60, 0x0b, 0x60, 0x00, 0x39, 0x60, 0x00, 0xf3}
+	return append(init, runtime...)
+}
+
+type simulatedChain struct {
+	t       *testing.T
+	backend *backends.SimulatedBackend
+	key     *ecdsa.PrivateKey
+	signer  types.Signer
+}
+
+func newSimulatedChain(t *testing.T) *simulatedChain {
+	t.Helper()
+	key, err := crypto.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	funds := new(big.Int).Mul(big.NewInt(100), big.NewInt(1e18))
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{crypto.PubkeyToAddress(key.PublicKey): {Balance: funds}}, 10000000)
+	t.Cleanup(func() { backend.Close() })
+	return &simulatedChain{t: t, backend: backend, key: key, signer: types.LatestSignerForChainID(big.NewInt(1337))}
+}
+
+// send mines a block with one transaction, to is nil for a contract creation
+func (c *simulatedChain) send(to *common.Address, data []byte) {
+	c.t.Helper()
+	ctx := context.Background()
+	from := crypto.PubkeyToAddress(c.key.PublicKey)
+	nonce, err := c.backend.PendingNonceAt(ctx, from)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	gasPrice, err := c.backend.SuggestGasPrice(ctx)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	tx, err := types.SignNewTx(c.key, c.signer, &types.LegacyTx{Nonce: nonce, To: to, Gas: 500000, GasPrice: gasPrice, Data: data})
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if err := c.backend.SendTransaction(ctx, tx); err != nil {
+		c.t.Fatal(err)
+	}
+	c.backend.Commit()
+}
+
+func (c *simulatedChain) deploy() common.Address {
+	c.t.Helper()
+	from := crypto.PubkeyToAddress(c.key.PublicKey)
+	nonce, err := c.backend.PendingNonceAt(context.Background(), from)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	c.send(nil, transferEmitterCode())
+	return crypto.CreateAddress(from, nonce)
+}
+
+func (c *simulatedChain) transfer(contract common.Address, from string, to string, tokenId int64) {
+	c.t.Helper()
+	data := append(common.HexToHash(from).Bytes(), common.HexToHash(to).Bytes()...)
+	data = append(data, common.BigToHash(big.NewInt(tokenId)).Bytes()...)
+	c.send(&contract, data)
+}
+
+func (c *simulatedChain) head() *types.Header {
+	c.t.Helper()
+	header, err := c.backend.HeaderByNumber(context.Background(), nil)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	return header
+}
+
+const (
+	alice = "0x1111111111111111111111111111111111111111"
+	bob   = "0x2222222222222222222222222222222222222222"
+)
+
+func newTestIndexer(chain *simulatedChain, contracts ...common.Address) (*Indexer, *[]Transfer) {
+	handled := &[]Transfer{}
+	followed := []string{}
+	for _, contract := range contracts {
+		followed = append(followed, strings.ToLower(contract.Hex()))
+	}
+	return &Indexer{
+		Chain:     "ethereum",
+		Client:    chain.backend,
+		Store:     NewMemoryStore(),
+		Contracts: func() []string { return followed },
+		Handle:    func(transfers []Transfer) { *handled = append(*handled, transfers...) },
+	}, handled
+}
+
+func poll(t *testing.T, ix *Indexer) int {
+	t.Helper()
+	handled, err := ix.Poll(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return handled
+}
+
+func TestPollFollowsTransfers(t *testing.T) {
+	chain := newSimulatedChain(t)
+	followed := chain.deploy()
+	other := chain.deploy()
+	ix, handled := newTestIndexer(chain, followed)
+
+	//a new chain starts at the head, older transfers are auto-join's job
+	chain.transfer(followed, ZeroAddress, alice, 7)
+	if n := poll(t, ix); n != 0 {
+		t.Fatalf("first poll handled %d transfers, want 0", n)
+	}
+
+	chain.transfer(followed, ZeroAddress, alice, 1)
+	chain.transfer(other, ZeroAddress, bob, 1) //not followed
+	chain.transfer(followed, alice, bob, 1)
+	if n := poll(t, ix); n != 2 {
+		t.Fatalf("handled %d transfers, want 2: %+v", n, *handled)
+	}
+	contract := strings.ToLower(followed.Hex())
+	want := []Transfer{
+		{Chain: "ethereum", Contract: contract, From: ZeroAddress, To: alice, Tokenid: "1", Amount: "1"},
+		{Chain: "ethereum", Contract: contract, From: alice, To: bob, Tokenid: "1", Amount: "1"},
+	}
+	for i, transfer := range *handled {
+		got := Transfer{Chain: transfer.Chain, Contract: transfer.Contract, From: transfer.From, To: transfer.To, Tokenid: transfer.Tokenid, Amount: transfer.Amount}
+		if got != want[i] {
+			t.Errorf("transfer %d = %+v, want %+v", i, got, want[i])
+		}
+	}
+	if (*handled)[0].Block >= (*handled)[1].Block {
+		t.Error("transfers are not handled in block order")
+	}
+
+	if n := poll(t, ix); n != 0 {
+		t.Errorf("a poll without new blocks handled %d transfers", n)
+	}
+}
+
+func TestPollStaysBehindConfirmations(t *testing.T) {
+	chain := newSimulatedChain(t)
+	contract := chain.deploy()
+	ix, handled := newTestIndexer(chain, contract)
+	ix.Confirmations = 2
+	poll(t, ix)
+
+	chain.transfer(contract, ZeroAddress, alice, 1)
+	if n := poll(t, ix); n != 0 {
+		t.Fatalf("an unconfirmed transfer was handled: %+v", *handled)
+	}
+	chain.backend.Commit()
+	chain.backend.Commit()
+	if n := poll(t, ix); n != 1 {
+		t.Fatalf("handled %d transfers after 2 confirmations, want 1", n)
+	}
+}
+
+func TestPollUndoesReorgedTransfers(t *testing.T) {
+	chain := newSimulatedChain(t)
+	contract := chain.deploy()
+	ix, handled := newTestIndexer(chain, contract)
+	poll(t, ix)
+	forkPoint := chain.head().Hash()
+
+	chain.transfer(contract, ZeroAddress, alice, 1)
+	if n := poll(t, ix); n != 1 {
+		t.Fatalf("handled %d transfers, want 1", n)
+	}
+
+	//the block with the transfer is replaced by a longer chain without it
+	if err := chain.backend.Fork(context.Background(), forkPoint); err != nil {
+		t.Fatal(err)
+	}
+	chain.backend.Commit()
+	chain.backend.Commit()
+
+	*handled = nil
+	if n := poll(t, ix); n != 1 {
+		t.Fatalf("handled %d transfers after the reorg, want the undone one", n)
+	}
+	undone := (*handled)[0]
+	if !undone.Removed || undone.To != alice || undone.Tokenid != "1" {
+		t.Errorf("undone transfer = %+v", undone)
+	}
+}
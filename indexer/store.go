@@ -0,0 +1,161 @@
+package indexer
+
+import (
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DbStore keeps the indexer state in the Indexercheckpoint and Indexertransfer tables
+type DbStore struct{}
+
+func (DbStore) Checkpoints(chain string) ([]Checkpoint, error) {
+	var rows []entity.Indexercheckpoint
+	if err := database.Connector.Where("chain = ?", chain).Order("block desc").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	checkpoints := []Checkpoint{}
+	for _, row := range rows {
+		checkpoints = append(checkpoints, Checkpoint{Block: row.Block, Hash: row.Hash})
+	}
+	return checkpoints, nil
+}
+
+func (DbStore) Save(chain string, checkpoint Checkpoint, transfers []Transfer) error {
+	tx := database.Connector.Begin()
+	for _, transfer := range transfers {
+		row := entity.Indexertransfer{
+			Chain:    chain,
+			Block:    transfer.Block,
+			Contract: transfer.Contract,
+			Fromaddr: transfer.From,
+			Toaddr:   transfer.To,
+			Tokenid:  transfer.Tokenid,
+			Amount:   transfer.Amount,
+			Txhash:   transfer.Txhash,
+			Logindex: transfer.Logindex,
+		}
+		if err := tx.Create(&row).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	row := entity.Indexercheckpoint{Chain: chain, Block: checkpoint.Block, Hash: checkpoint.Hash, Timestamp: time.Now()}
+	if err := tx.Create(&row).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (DbStore) Rewind(chain string, block uint64) ([]Transfer, error) {
+	var rows []entity.Indexertransfer
+	if err := database.Connector.Where("chain = ?", chain).Where("block > ?", block).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	tx := database.Connector.Begin()
+	tx.Where("chain = ?", chain).Where("block > ?", block).Delete(&entity.Indexertransfer{})
+	tx.Where("chain = ?", chain).Where("block > ?", block).Delete(&entity.Indexercheckpoint{})
+	if err := tx.Commit().Error; err != nil {
+		return nil, err
+	}
+
+	transfers := []Transfer{}
+	for _, row := range rows {
+		transfers = append(transfers, Transfer{
+			Chain:    chain,
+			Contract: row.Contract,
+			From:     row.Fromaddr,
+			To:       row.Toaddr,
+			Tokenid:  row.Tokenid,
+			Amount:   row.Amount,
+			Block:    row.Block,
+			Txhash:   row.Txhash,
+			Logindex: row.Logindex,
+		})
+	}
+	return transfers, nil
+}
+
+func (DbStore) Prune(chain string, keep int) error {
+	var rows []entity.Indexercheckpoint
+	database.Connector.Where("chain = ?", chain).Order("block desc").Offset(keep - 1).Limit(1).Find(&rows)
+	if len(rows) == 0 {
+		return nil
+	}
+	oldest := rows[0].Block
+	database.Connector.Where("chain = ?", chain).Where("block < ?", oldest).Delete(&entity.Indexercheckpoint{})
+	return database.Connector.Where("chain = ?", chain).Where("block <= ?", oldest).Delete(&entity.Indexertransfer{}).Error
+}
+
+// MemoryStore keeps the indexer state in memory, for tests against a simulated backend and dry runs
+type MemoryStore struct {
+	mutex       sync.Mutex
+	checkpoints map[string][]Checkpoint //oldest first
+	transfers   map[string][]Transfer
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{checkpoints: map[string][]Checkpoint{}, transfers: map[string][]Transfer{}}
+}
+
+func (s *MemoryStore) Checkpoints(chain string) ([]Checkpoint, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	checkpoints := append([]Checkpoint{}, s.checkpoints[chain]...)
+	sort.Slice(checkpoints, func(i, j int) bool { return checkpoints[i].Block > checkpoints[j].Block })
+	return checkpoints, nil
+}
+
+func (s *MemoryStore) Save(chain string, checkpoint Checkpoint, transfers []Transfer) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.checkpoints[chain] = append(s.checkpoints[chain], checkpoint)
+	s.transfers[chain] = append(s.transfers[chain], transfers...)
+	return nil
+}
+
+func (s *MemoryStore) Rewind(chain string, block uint64) ([]Transfer, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	keptCheckpoints := []Checkpoint{}
+	for _, checkpoint := range s.checkpoints[chain] {
+		if checkpoint.Block <= block {
+			keptCheckpoints = append(keptCheckpoints, checkpoint)
+		}
+	}
+	s.checkpoints[chain] = keptCheckpoints
+
+	kept, removed := []Transfer{}, []Transfer{}
+	for _, transfer := range s.transfers[chain] {
+		if transfer.Block > block {
+			removed = append(removed, transfer)
+		} else {
+			kept = append(kept, transfer)
+		}
+	}
+	s.transfers[chain] = kept
+	return removed, nil
+}
+
+func (s *MemoryStore) Prune(chain string, keep int) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	checkpoints := s.checkpoints[chain]
+	if len(checkpoints) <= keep {
+		return nil
+	}
+	oldest := checkpoints[len(checkpoints)-keep].Block
+	s.checkpoints[chain] = append([]Checkpoint{}, checkpoints[len(checkpoints)-keep:]...)
+
+	kept := []Transfer{}
+	for _, transfer := range s.transfers[chain] {
+		if transfer.Block > oldest {
+			kept = append(kept, transfer)
+		}
+	}
+	s.transfers[chain] = kept
+	return nil
+}
@@ -7,8 +7,15 @@ import (
 	"rest-go-demo/auth"
 	"rest-go-demo/controllers"
 	"rest-go-demo/database"
+	"rest-go-demo/dune"
+	"rest-go-demo/entity"
+	"rest-go-demo/names"
+	"rest-go-demo/onchain"
+	"rest-go-demo/poap"
+	"rest-go-demo/push"
 	"rest-go-demo/referrals"
 	"rest-go-demo/twitter"
+	"rest-go-demo/webhooks"
 
 	"github.com/joho/godotenv"
 	"github.com/rs/cors"
@@ -59,6 +66,7 @@ func main() {
 	router.HandleFunc("/resolve_name/{name}", controllers.ResolveName).Methods("GET")
 	router.HandleFunc("/ethereum_token_overlap/{contract_address}", controllers.Erc20TokenOverlap).Methods("GET") //for custom GPT - not WC directly
 	router.HandleFunc("/solana_token_overlap/{contract_address}", controllers.SolTokenOverlap).Methods("GET")     //for custom GPT - not WC directly
+	router.HandleFunc("/token_overlap_job/{job_id}", controllers.GetTokenOverlapJob).Methods("GET")               //polled by the custom GPT
 	router.PathPrefix("/docs").Handler(httpSwagger.WrapHandler)
 	router.HandleFunc("/track_ga4/{event}/{email}/{addr}/{placeholder_photo}", controllers.TrackEventGA4).Methods("GET")
 	router.HandleFunc("/get_leaderboard_data", referrals.GetLeaderboardData).Methods("GET")
@@ -72,6 +80,14 @@ func main() {
 	//debugging
 	router.HandleFunc("/debug_print", controllers.DebugPrint).Methods("POST")
 
+	//public community directory
+	router.HandleFunc("/directory", controllers.GetDirectory).Methods("GET")
+	router.HandleFunc("/community_slug/{slug}", controllers.GetCommunitySlug).Methods("GET")
+	router.HandleFunc("/directory/categories", controllers.GetDirectoryCategories).Methods("GET")
+
+	//chain registry (ONCHAIN_CHAINS)
+	router.HandleFunc("/chains", controllers.GetChains).Methods("GET")
+
 	//bookmarks
 	router.HandleFunc("/oura_register", controllers.RegisterOuraUser).Methods("POST")
 
@@ -82,6 +98,10 @@ func main() {
 
 	initaliseHandlers(wsRouter)
 
+	v2Router := router.PathPrefix("/v2").Subrouter()
+	v2Router.Use(auth.AuthMiddleware(jwtProvider))
+	initaliseV2Handlers(v2Router)
+
 	//schedule daily notifications
 	s := gocron.NewScheduler(time.UTC)
 	// set time
@@ -97,6 +117,16 @@ func main() {
 	// starts the scheduler asynchronously
 	t.StartAsync()
 
+	//retry failed webhook deliveries with backoff (state is in the DB so any replica can pick them up)
+	hooks := gocron.NewScheduler(time.UTC)
+	hooks.Every(30).Seconds().Do(func() { webhooks.RetryPendingDeliveries() })
+	hooks.StartAsync()
+
+	//remove members of token gated communities that no longer hold the tokens
+	gates := gocron.NewScheduler(time.UTC)
+	gates.Every(6).Hours().Do(func() { controllers.RecheckGatedCommunities() })
+	gates.StartAsync()
+
 	//schedule twitter username polling for new verified users
 	// u := gocron.NewScheduler(time.UTC)
 	// // set time
@@ -132,6 +162,43 @@ func main() {
 	controllers.InitGlobals()
 	controllers.InitRandom()
 	referrals.InitRandom()
+	push.InitProviders()
+	onchain.Init()
+	names.Init()
+	poap.Init()
+	dune.Init()
+	controllers.InitIndexers()
+	auth.OnSignin(controllers.InvalidateOwnershipCache)
+	auth.OnSignin(controllers.QueueAutoJoinOnSignin)
+
+	//the schedulers below need the providers set up above, started earlier their first run would see none
+
+	//auto-join NFT/POAP chats in the background (queued on sign-in, refreshed daily for active wallets)
+	autojoin := gocron.NewScheduler(time.UTC)
+	autojoin.SingletonMode()
+	autojoin.Every(1).Minute().Do(func() { controllers.RunAutoJoinWorker() })
+	autojoin.StartAsync()
+
+	//re-check verified display names (demotes ENS/SpaceID/.btc names that were transferred)
+	displaynames := gocron.NewScheduler(time.UTC)
+	displaynames.SingletonMode()
+	displaynames.Every(1).Hour().Do(func() { controllers.RevalidateNames() })
+	displaynames.StartAsync()
+
+	//follow NFT Transfer events of joined collections (only on chains in INDEXER_CHAINS)
+	transfers := gocron.NewScheduler(time.UTC)
+	transfers.SingletonMode()
+	transfers.Every(15).Seconds().Do(func() { controllers.PollIndexers() })
+	transfers.StartAsync()
+
+	//start and poll token overlap Dune queries (jobs are submitted by the overlap endpoints)
+	overlap := gocron.NewScheduler(time.UTC)
+	overlap.SingletonMode()
+	overlap.Every(15).Seconds().Do(func() { controllers.RunOverlapJobs() })
+	overlap.StartAsync()
+
+	go controllers.BackfillCommunityMembers()
+	go controllers.BackfillCommunities()
 	twitter.InitSearchParams()
 	referrals.GetOuraLeaderboardDataCronJob()
 
@@ -172,10 +239,20 @@ func updateTelegramVerifiedUsers() {
 // 	}
 // }
 
+// typed v2 resources, also protected by JWTs
+func initaliseV2Handlers(router *mux.Router) {
+	router.HandleFunc("/settings", controllers.GetSettingsV2).Methods("GET")
+	router.HandleFunc("/settings", controllers.PatchSettingsV2).Methods("PATCH")
+	router.HandleFunc("/settings", controllers.DeleteSettingsV2).Methods("DELETE")
+	router.HandleFunc("/settings/audit", controllers.GetSettingsAudit).Methods("GET")
+}
+
 // these endpoints are protected by JWTs
 func initaliseHandlers(router *mux.Router) {
 	router.HandleFunc("/apicount", auth.GetCountsAPI()).Methods("GET")
 	router.HandleFunc("/resolve_name/{name}", controllers.ResolveName).Methods("GET")
+	router.HandleFunc("/reverse_name/{address}", controllers.GetReverseName).Methods("GET")
+	router.HandleFunc("/reverse_names", controllers.GetReverseNames).Methods("POST")
 
 	//1-to-1 chats (both general and NFT related)
 	router.HandleFunc("/get_unread_cnt/{address}", controllers.GetUnreadMsgCntTotal).Methods("GET")
@@ -192,6 +269,13 @@ func initaliseHandlers(router *mux.Router) {
 	router.HandleFunc("/getnft_chatitems/{fromaddr}/{toaddr}/{nftaddr}/{nftid}", controllers.GetChatNftAllItemsFromAddrAndNFT).Methods("GET")
 	router.HandleFunc("/getnft_chatitems/{address}/{nftaddr}/{nftid}", controllers.GetChatNftAllItemsFromAddr).Methods("GET")
 	router.HandleFunc("/getnft_chatitems/{nftaddr}/{nftid}", controllers.GetChatNftContext).Methods("GET")
+	router.HandleFunc("/nft_holder_threads", controllers.CreateNftHolderMessage).Methods("POST")
+	router.HandleFunc("/nft_holder_threads", controllers.GetNftHolderThreads).Methods("GET")
+	router.HandleFunc("/nft_holder_threads/{id}", controllers.GetNftHolderThreadMessages).Methods("GET")
+	router.HandleFunc("/nft_holder_threads/{id}/messages", controllers.CreateNftHolderThreadMessage).Methods("POST")
+	router.HandleFunc("/autojoin", controllers.GetAutoJoinStatus).Methods("GET")
+	router.HandleFunc("/autojoin", controllers.RequestAutoJoin).Methods("POST")
+	router.HandleFunc("/autojoin/backfill", controllers.BackfillAutoJoin).Methods("POST")
 	router.HandleFunc("/getnft_chatitems/{address}", controllers.GetNftChatFromAddress).Methods("GET")
 	router.HandleFunc("/update_chatitem/{fromaddr}/{toaddr}", controllers.UpdateChatitemByOwner).Methods("PUT")
 	router.HandleFunc("/deleteall_chatitems/{address}", controllers.DeleteAllChatitemsToAddressByOwner).Methods("GET")
@@ -204,6 +288,26 @@ func initaliseHandlers(router *mux.Router) {
 	router.HandleFunc("/block_user/{address}", controllers.BlockUser).Methods("GET")
 	router.HandleFunc("/is_moderator/{company}/{address}", controllers.IsModerator).Methods("GET")
 
+	//moderation of community and NFT/POAP group chats
+	router.HandleFunc("/report_message", controllers.ReportGroupMessage).Methods("POST")
+	router.HandleFunc("/pins/{group}", controllers.GetPinnedMessages).Methods("GET")
+	router.HandleFunc("/moderation/{group}/messages/{id}", controllers.DeleteGroupMessage).Methods("DELETE")
+	router.HandleFunc("/moderation/{group}/messages/{id}/pin", controllers.PinGroupMessage).Methods("POST")
+	router.HandleFunc("/moderation/{group}/messages/{id}/pin", controllers.UnpinGroupMessage).Methods("DELETE")
+	router.HandleFunc("/moderation/{group}/pins", controllers.ReorderPinnedMessages).Methods("PUT")
+	router.HandleFunc("/moderation/{group}/mutes", controllers.GetGroupMutes).Methods("GET")
+	router.HandleFunc("/moderation/{group}/mutes/{address}", controllers.MuteGroupMember).Methods("POST")
+	router.HandleFunc("/moderation/{group}/mutes/{address}", controllers.UnmuteGroupMember).Methods("DELETE")
+	router.HandleFunc("/moderation/{group}/slowmode", controllers.GetGroupSlowmode).Methods("GET")
+	router.HandleFunc("/moderation/{group}/slowmode", controllers.SetGroupSlowmode).Methods("PUT")
+	router.HandleFunc("/moderation/{group}/log", controllers.GetModerationLog).Methods("GET")
+	router.HandleFunc("/moderation/{group}/filters", controllers.GetChatFilter).Methods("GET")
+	router.HandleFunc("/moderation/{group}/filters", controllers.SetChatFilter).Methods("PUT")
+	router.HandleFunc("/moderation/{group}/reports", controllers.GetModerationQueue).Methods("GET")
+	router.HandleFunc("/moderation/{group}/reports/{id}", controllers.ResolveMessageReport).Methods("POST")
+	router.HandleFunc("/moderation/{group}/moderators/{address}", controllers.SetGroupModerator).Methods("POST")      //admin API key only
+	router.HandleFunc("/moderation/{group}/moderators/{address}", controllers.RemoveGroupModerator).Methods("DELETE") //admin API key only
+
 	//unreadcnt per week4 requirements
 	router.HandleFunc("/unreadcount/{address}", controllers.GetUnreadcnt).Methods("GET", "OPTIONS")
 	//router.HandleFunc("/unreadcount/{address}", controllers.PutUnreadcnt).Methods("PUT")
@@ -214,12 +318,43 @@ func initaliseHandlers(router *mux.Router) {
 	router.HandleFunc("/get_groupchatitems/{address}/{useraddress}", controllers.GetGroupChatItemsByAddr).Methods("GET")
 	router.HandleFunc("/get_groupchatitems_unreadcnt/{address}/{useraddress}", controllers.GetGroupChatItemsByAddrLen).Methods("GET")
 
+	//community membership (registered before /community/{community}/{address} which would match /members and /invites)
+	router.HandleFunc("/community/{slug}/join", controllers.JoinCommunity).Methods("POST")
+	router.HandleFunc("/community/{slug}/leave", controllers.LeaveCommunity).Methods("POST")
+	router.HandleFunc("/community/{slug}/members", controllers.GetCommunityMembers).Methods("GET")
+	router.HandleFunc("/community/{slug}/members/{address}/kick", controllers.KickCommunityMember).Methods("POST")
+	router.HandleFunc("/community/{slug}/members/{address}/ban", controllers.BanCommunityMember).Methods("POST")
+	router.HandleFunc("/community/{slug}/members/{address}/ban", controllers.UnbanCommunityMember).Methods("DELETE")
+	router.HandleFunc("/community/{slug}/members/{address}/approve", controllers.ApproveCommunityMember).Methods("POST")
+	router.HandleFunc("/community/{slug}/members/{address}/reject", controllers.RejectCommunityMember).Methods("POST")
+	router.HandleFunc("/community/{slug}/members/{address}/role", controllers.SetCommunityMemberRole).Methods("PUT")
+	router.HandleFunc("/community/{slug}/invites", controllers.CreateCommunityInvite).Methods("POST")
+	router.HandleFunc("/community/{slug}/invites", controllers.GetCommunityInvites).Methods("GET")
+	router.HandleFunc("/community/{slug}/invites/{code}", controllers.RevokeCommunityInvite).Methods("DELETE")
+	router.HandleFunc("/community/{slug}/policy", controllers.SetCommunityPolicy).Methods("PUT")
+	router.HandleFunc("/community/{slug}/conditions", controllers.GetCommunityConditions).Methods("GET")
+	router.HandleFunc("/community/{slug}/conditions", controllers.SetCommunityConditions).Methods("PUT")
+	router.HandleFunc("/community/{slug}/access", controllers.GetCommunityAccessCheck).Methods("GET")
+	router.HandleFunc("/community/{slug}/announcements", controllers.GetCommunityAnnouncements).Methods("GET")
+	router.HandleFunc("/community/{slug}/profile", controllers.SetCommunityProfile).Methods("PUT")
+	router.HandleFunc("/community/{slug}/slug", controllers.ChangeCommunitySlug).Methods("PUT")
+	router.HandleFunc("/community/{slug}/channels", controllers.GetCommunityChannels).Methods("GET")
+	router.HandleFunc("/community/{slug}/channels", controllers.CreateCommunityChannel).Methods("POST")
+	router.HandleFunc("/community/{slug}/channels", controllers.ReorderCommunityChannels).Methods("PUT")
+	router.HandleFunc("/community/{slug}/channels/{channel}", controllers.UpdateCommunityChannel).Methods("PUT")
+	router.HandleFunc("/community/{slug}/channels/{channel}", controllers.DeleteCommunityChannel).Methods("DELETE")
+	router.HandleFunc("/community/{slug}/channels/{channel}/conditions", controllers.GetChannelConditions).Methods("GET")
+	router.HandleFunc("/community/{slug}/channels/{channel}/conditions", controllers.SetChannelConditions).Methods("PUT")
+	router.HandleFunc("/directory/{slug}/verified", controllers.SetCommunityVerified).Methods("PUT")
+	router.HandleFunc("/ownership_cache/stats", controllers.GetOwnershipCacheStats).Methods("GET")
+
 	//community chat
 	router.HandleFunc("/community/{community}/{address}", controllers.GetCommunityChat).Methods("GET") //TODO: make common
 	router.HandleFunc("/community/{community}/{time}/{count}", controllers.GetCommunityChatAfterTime).Methods("GET")
 	router.HandleFunc("/community_pagenum/{community}/{pagenum}", controllers.GetCommunityChatPage).Methods("GET")
 	router.HandleFunc("/community", controllers.CreateCommunityChatItem).Methods("POST")
 	router.HandleFunc("/create_community", controllers.CreateCommunity).Methods("POST")
+	router.HandleFunc("/poap_community", controllers.CreatePoapCommunity).Methods("POST")
 	router.HandleFunc("/update_community", controllers.UpdateCommunity).Methods("POST")
 	router.HandleFunc("/community/conditions", controllers.ChangeCommunityConditions).Methods("POST")
 
@@ -247,6 +382,10 @@ func initaliseHandlers(router *mux.Router) {
 	router.HandleFunc("/update_settings", controllers.UpdateSettings).Methods("POST")
 	router.HandleFunc("/get_settings/{address}", controllers.GetSettings).Methods("GET")
 	router.HandleFunc("/delete_settings/{address}", controllers.DeleteSettings).Methods("DELETE")
+	router.HandleFunc("/resend_verification_email", controllers.ResendVerificationEmail).Methods("POST")
+	router.HandleFunc("/notification_preferences", controllers.GetNotificationPreferences).Methods("GET")
+	router.HandleFunc("/notification_preferences", controllers.UpdateNotificationPreference).Methods("PUT")
+	router.HandleFunc("/notification_preferences/{context_type}/{target}", controllers.DeleteNotificationPreference).Methods("DELETE")
 	router.HandleFunc("/verify_email/{email}/{code}", controllers.VerifyEmail).Methods("GET")
 
 	//comments on a specific NFT
@@ -267,11 +406,18 @@ func initaliseHandlers(router *mux.Router) {
 	//holder functions
 	//TODO: this would need a signature from holder to fully verify - ok for now
 	router.HandleFunc("/is_owner/{contract}/{wallet}", controllers.IsOwner).Methods("GET")
-	router.HandleFunc("/rejoin_all/{wallet}", controllers.AutoJoinCommunities).Methods("GET")
-	router.HandleFunc("/backfill_all_bookmarks", controllers.FixUpBookmarks).Methods("GET") //just meant for internal use - not for external use
+	router.HandleFunc("/rejoin_all/{wallet}", controllers.AutoJoinCommunities).Methods("GET") //deprecated, queues a sync like POST /autojoin
+	router.HandleFunc("/backfill_all_bookmarks", controllers.FixUpBookmarks).Methods("GET")   //deprecated, queues a sync of the signed in wallet
 
 	//POAP related stuff (some could be called client side directly but this protects the API key)
 	router.HandleFunc("/get_poaps/{wallet}", controllers.GetPoapsByAddr).Methods("GET")
+	router.HandleFunc("/poap_event/{id}", controllers.GetPoapEvent).Methods("GET")
+
+	//token holder overlap analytics (async Dune jobs)
+	router.HandleFunc("/token_overlap/{chain}/{contract_address}", controllers.SubmitTokenOverlap).Methods("POST")
+	router.HandleFunc("/token_overlap/{chain}/{contract_address}", controllers.GetTokenOverlap).Methods("GET")
+	router.HandleFunc("/token_overlap_job/{job_id}", controllers.GetTokenOverlapJob).Methods("GET")
+	router.HandleFunc("/token_overlap_job/{job_id}", controllers.CancelTokenOverlapJob).Methods("DELETE")
 
 	//OpenSea Pass-Thru to prevent CORS error and API key leakage
 	router.HandleFunc("/opensea_asset_contract/{contract}", controllers.GetOpenseaAssetContract).Methods("GET")
@@ -289,6 +435,20 @@ func initaliseHandlers(router *mux.Router) {
 	router.HandleFunc("/redeem_referral_code/{code}", referrals.RedeemReferralCode).Methods("GET")
 	router.HandleFunc("/get_leaderboard_data", referrals.GetLeaderboardData).Methods("GET")
 	router.HandleFunc("/get_valid_referred_user", referrals.GetHasEnteredValidCode).Methods("GET")
+
+	//outbound webhooks for integrations (admin API key only)
+	router.HandleFunc("/webhooks", webhooks.CreateWebhook).Methods("POST")
+	router.HandleFunc("/webhooks", webhooks.GetWebhooks).Methods("GET")
+	router.HandleFunc("/webhooks/{id}", webhooks.DeleteWebhook).Methods("DELETE")
+	router.HandleFunc("/webhooks/{id}/deliveries", webhooks.GetWebhookDeliveries).Methods("GET")
+	router.HandleFunc("/webhooks/{id}/deliveries/{delivery}/replay", webhooks.ReplayWebhookDelivery).Methods("POST")
+	router.HandleFunc("/webhooks/{id}/ping", webhooks.PingWebhook).Methods("POST")
+
+	//push notifications (Web Push, FCM, APNs)
+	router.HandleFunc("/push/vapid_public_key", push.GetVapidPublicKey).Methods("GET")
+	router.HandleFunc("/push/devices", push.RegisterPushDevice).Methods("POST")
+	router.HandleFunc("/push/devices", push.GetPushDevices).Methods("GET")
+	router.HandleFunc("/push/devices/{id}", push.DeletePushDevice).Methods("DELETE")
 }
 
 func initDB() {
@@ -325,4 +485,39 @@ func initDB() {
 	// database.Migrate(&entity.Settings{})
 	//database.MigrateComments(&entity.Comments{})
 	// database.MigrateChatitem(&entity.Chatitem{})
+	database.MigrateTables(
+		&entity.Groupchatitem{}, //adds the channel, chain and reply_to columns to the hand made table
+		&entity.Supportblockeduser{},
+		&entity.Webhooksubscription{},
+		&entity.Webhookdelivery{},
+		&entity.Pushdevice{},
+		&entity.Notificationpreference{},
+		&entity.Emailverification{},
+		&entity.Settingsaudit{},
+		&entity.Communitymember{},
+		&entity.Communityinvite{},
+		&entity.Communitypolicy{},
+		&entity.Communityaccesscondition{},
+		&entity.Moderator{},
+		&entity.Moderationlog{},
+		&entity.Groupmute{},
+		&entity.Groupmoderation{},
+		&entity.Pinnedmessage{},
+		&entity.Messagereport{},
+		&entity.Chatfilter{},
+		&entity.Communityprofile{},
+		&entity.Communityitem{},
+		&entity.Communityalias{},
+		&entity.Communitychannel{},
+		&entity.Nftholderthread{},
+		&entity.Autojoinsync{},
+		&entity.Indexercheckpoint{},
+		&entity.Indexertransfer{},
+		&entity.Addrnameitem{},
+		&entity.Poapevent{},
+		&entity.Poapsecretfailure{},
+		&entity.Overlapjob{},
+		&entity.Overlaprow{},
+	)
+	controllers.SeedVerifiedCommunities()
 }
@@ -0,0 +1,84 @@
+package names
+
+import (
+	"fmt"
+	"rest-go-demo/onchain"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	ens "github.com/wealdtech/go-ens/v3"
+)
+
+// EnsResolver gets addresses and primary names through the onchain providers (with their failover),
+// avatar and expiry come straight from the ENS contracts when an Ethereum RPC endpoint is configured
+type EnsResolver struct {
+	mutex  sync.Mutex
+	client *ethclient.Client
+}
+
+func NewEnsResolver() *EnsResolver {
+	return &EnsResolver{}
+}
+
+func (r *EnsResolver) Name() string { return "ens" }
+
+func (r *EnsResolver) Handles(name string) bool {
+	return strings.HasSuffix(name, ".eth")
+}
+
+// rpc dials the Ethereum endpoint on first use, nil when there is none
+func (r *EnsResolver) rpc() *ethclient.Client {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.client != nil {
+		return r.client
+	}
+	rpcUrl, ok := onchain.RpcUrl(onchain.ChainEthereum)
+	if !ok {
+		return nil
+	}
+	client, err := ethclient.Dial(rpcUrl)
+	if err != nil {
+		fmt.Println("names - ens rpc: ", err)
+		return nil
+	}
+	r.client = client
+	return client
+}
+
+func (r *EnsResolver) Resolve(name string) (Record, error) {
+	address, err := onchain.ResolveName(name)
+	if err != nil {
+		return Record{}, err
+	}
+	record := Record{Name: name, Address: address, Chain: onchain.ChainEthereum, Source: r.Name()}
+
+	//the details are optional, a failure still returns the address
+	if client := r.rpc(); client != nil {
+		if resolver, err := ens.NewResolver(client, name); err == nil {
+			record.Avatar, _ = resolver.Text("avatar")
+		}
+		//only second level .eth names are registrations with an expiry, subnames live under them
+		if strings.Count(name, ".") == 1 {
+			if registration, err := ens.NewName(client, name); err == nil {
+				if expires, err := registration.Expires(); err == nil && !expires.IsZero() && expires.Unix() > 0 {
+					expiry := expires.UTC()
+					record.Expiry = &expiry
+				}
+			}
+		}
+	}
+	return record, nil
+}
+
+func (r *EnsResolver) Reverse(address string) (Record, error) {
+	if !strings.HasPrefix(address, "0x") {
+		return Record{}, ErrUnsupported
+	}
+	name, err := onchain.LookupAddress(address)
+	if err != nil {
+		return Record{}, err
+	}
+	return Record{Name: Normalize(name), Address: address, Chain: onchain.ChainEthereum, Source: r.Name()}, nil
+}
@@ -0,0 +1,250 @@
+// Package names resolves web3 names (ENS, SpaceID, Stacks BNS, Unstoppable Domains) to addresses and addresses back to
+// their primary name, with one response shape for every naming system and a TTL cache in front of the resolvers.
+package names
+
+import (
+	"errors"
+	"fmt"
+	"rest-go-demo/onchain"
+	"rest-go-demo/ttlcache"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrNotFound means the naming system answered and the name (or the address's primary name) doesn't exist
+	ErrNotFound = onchain.ErrNotFound
+	// ErrUnsupported is returned for names no configured resolver handles
+	ErrUnsupported = errors.New("unsupported name")
+)
+
+const (
+	foundTtl    = time.Hour
+	notFoundTtl = 5 * time.Minute //short so a name registered just now shows up soon
+	notOwnerTtl = time.Minute     //Owns answers "no" from here after a fresh check, so retries don't reach the resolver
+	cacheSize   = 50000           //per direction, names and addresses come from user input
+	maxWarming  = 20              //background reverse lookups at once, the rest are warmed on a later call
+)
+
+// Record is a resolved name, the same shape for every naming system
+type Record struct {
+	Name     string     `json:"name"`
+	Address  string     `json:"address"`
+	Chain    string     `json:"chain"` //where Address lives: ethereum, bsc, arbitrum, polygon, stacks
+	Avatar   string     `json:"avatar,omitempty"`
+	Expiry   *time.Time `json:"expiry,omitempty"` //nil when the naming system has no expiry or didn't say
+	Source   string     `json:"source"`           //ens, spaceid, stacks, unstoppable
+	Verified bool       `json:"verified"`         //reverse lookups: the name resolves back to the address
+}
+
+// Resolver is one naming system
+type Resolver interface {
+	Name() string
+	Handles(name string) bool
+	Resolve(name string) (Record, error)
+	Reverse(address string) (Record, error) //the primary name, ErrUnsupported when the naming system has none for this address format
+}
+
+type cacheEntry struct {
+	record Record
+	err    error //only ErrNotFound is cached
+}
+
+var (
+	resolversMutex sync.RWMutex
+	resolvers      []Resolver
+
+	forwardCache  = ttlcache.New[cacheEntry](cacheSize)
+	reverseCache  = ttlcache.New[cacheEntry](cacheSize)
+	notOwnerCache = ttlcache.New[bool](cacheSize) //name|address pairs Owns checked uncached and found not to match
+	warmingMutex  sync.Mutex
+	warming       = map[string]bool{}
+)
+
+// Init sets up every resolver, Unstoppable Domains only with UNSTOPPABLE_API_KEY
+func Init() {
+	configured := []Resolver{NewEnsResolver(), NewSpaceIdResolver(), NewStacksResolver()}
+	if unstoppable, err := NewUnstoppableResolverFromEnv(); err == nil {
+		configured = append(configured, unstoppable)
+	} else {
+		fmt.Println("names - unstoppable domains disabled: ", err)
+	}
+	SetResolvers(configured...)
+}
+
+// SetResolvers replaces the resolvers (in reverse lookup order) and clears the cache, tests use it with fakes
+func SetResolvers(configured ...Resolver) {
+	resolversMutex.Lock()
+	resolvers = configured
+	resolversMutex.Unlock()
+
+	forwardCache.Clear()
+	reverseCache.Clear()
+	notOwnerCache.Clear()
+}
+
+// Configured is false until Init (or SetResolvers) set up the resolvers, every name looks unsupported before that
+func Configured() bool {
+	return len(currentResolvers()) > 0
+}
+
+func currentResolvers() []Resolver {
+	resolversMutex.RLock()
+	defer resolversMutex.RUnlock()
+	return resolvers
+}
+
+func resolverFor(name string) (Resolver, bool) {
+	for _, resolver := range currentResolvers() {
+		if resolver.Handles(name) {
+			return resolver, true
+		}
+	}
+	return nil, false
+}
+
+// Normalize lower cases and trims a name, names are case insensitive in every supported system
+func Normalize(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Handles is true when a resolver knows the name's TLD (ie .eth, .bnb, .btc, .crypto)
+func Handles(name string) bool {
+	_, ok := resolverFor(Normalize(name))
+	return ok
+}
+
+// setCached keeps answers and ErrNotFound, provider failures are never cached
+func setCached(cache *ttlcache.Cache[cacheEntry], key string, record Record, err error) {
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return
+	}
+	ttl := foundTtl
+	if err != nil {
+		ttl = notFoundTtl
+	}
+	cache.Set(key, cacheEntry{record: record, err: err}, ttl)
+}
+
+// Resolve returns the address (and avatar, expiry when known) of a name
+func Resolve(name string) (Record, error) {
+	name = Normalize(name)
+	if entry, ok := forwardCache.Get(name); ok {
+		return entry.record, entry.err
+	}
+	return resolveUncached(name)
+}
+
+func resolveUncached(name string) (Record, error) {
+	resolver, ok := resolverFor(name)
+	if !ok {
+		return Record{}, ErrUnsupported
+	}
+	record, err := resolver.Resolve(name)
+	if err == nil && record.Address == "" {
+		err = ErrNotFound
+	}
+	setCached(forwardCache, name, record, err)
+	return record, err
+}
+
+// Reverse returns the primary name of an address, asking each naming system in order. Only names that resolve
+// back to the address are returned (Verified), anyone can point a reverse record at any name.
+func Reverse(address string) (Record, error) {
+	key := strings.ToLower(strings.TrimSpace(address))
+	if entry, ok := reverseCache.Get(key); ok {
+		return entry.record, entry.err
+	}
+
+	var lastErr error
+	for _, resolver := range currentResolvers() {
+		record, err := resolver.Reverse(key)
+		if errors.Is(err, ErrNotFound) || errors.Is(err, ErrUnsupported) {
+			continue
+		}
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		forward, err := Resolve(record.Name)
+		if err != nil || !strings.EqualFold(forward.Address, key) {
+			continue
+		}
+		forward.Verified = true
+		setCached(reverseCache, key, forward, nil)
+		return forward, nil
+	}
+	if lastErr != nil {
+		return Record{}, lastErr
+	}
+	setCached(reverseCache, key, Record{}, ErrNotFound)
+	return Record{}, ErrNotFound
+}
+
+// CachedPrimaryName never waits on a resolver: it returns the cached primary name (empty when there is none yet)
+// and looks the address up in the background, for rendering inboxes and notifications. At most maxWarming lookups
+// run at once, an inbox full of unknown members is warmed over a few loads instead of all at once
+func CachedPrimaryName(address string) string {
+	key := strings.ToLower(strings.TrimSpace(address))
+	if entry, ok := reverseCache.Get(key); ok {
+		return entry.record.Name
+	}
+	warmingMutex.Lock()
+	if warming[key] || len(warming) >= maxWarming {
+		warmingMutex.Unlock()
+		return ""
+	}
+	warming[key] = true
+	warmingMutex.Unlock()
+
+	go func() {
+		Reverse(key)
+		warmingMutex.Lock()
+		delete(warming, key)
+		warmingMutex.Unlock()
+	}()
+	return ""
+}
+
+// Owns checks the name resolves to the address right now, a cached answer that doesn't match is checked again
+// (the name may have just been pointed at the address). That fresh "no" is remembered for notOwnerTtl.
+func Owns(name string, address string) (bool, error) {
+	name = Normalize(name)
+	record, err := Resolve(name)
+	if err == nil && strings.EqualFold(record.Address, address) {
+		return true, nil
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	ownerKey := name + "|" + strings.ToLower(strings.TrimSpace(address))
+	if _, checked := notOwnerCache.Get(ownerKey); checked {
+		return false, nil
+	}
+	record, err = resolveUncached(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return false, err
+	}
+	if err == nil && strings.EqualFold(record.Address, address) {
+		return true, nil
+	}
+	notOwnerCache.Set(ownerKey, false, notOwnerTtl)
+	return false, nil
+}
+
+// Forget drops the cached answers of a name and an address, ie after a user registered the name with us
+func Forget(name string, address string) {
+	forwardCache.Delete(Normalize(name))
+	reverseCache.Delete(strings.ToLower(strings.TrimSpace(address)))
+	notOwnerCache.Delete(Normalize(name) + "|" + strings.ToLower(strings.TrimSpace(address)))
+}
+
+func hasSuffix(name string, tlds []string) bool {
+	for _, tld := range tlds {
+		if strings.HasSuffix(name, "."+tld) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package names
+
+import (
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeResolver answers .test names from a map and blocks reverse lookups until release is closed
+type fakeResolver struct {
+	addresses map[string]string
+	resolves  int64
+	reverses  int64
+	release   chan struct{}
+}
+
+func (f *fakeResolver) Name() string { return "fake" }
+
+func (f *fakeResolver) Handles(name string) bool { return strings.HasSuffix(name, ".test") }
+
+func (f *fakeResolver) Resolve(name string) (Record, error) {
+	atomic.AddInt64(&f.resolves, 1)
+	if address, ok := f.addresses[name]; ok {
+		return Record{Name: name, Address: address, Source: "fake"}, nil
+	}
+	return Record{}, ErrNotFound
+}
+
+func (f *fakeResolver) Reverse(address string) (Record, error) {
+	atomic.AddInt64(&f.reverses, 1)
+	if f.release != nil {
+		<-f.release
+	}
+	return Record{}, ErrNotFound
+}
+
+const (
+	owner = "0x1111111111111111111111111111111111111111"
+	other = "0x2222222222222222222222222222222222222222"
+)
+
+func TestOwnsCachesMismatches(t *testing.T) {
+	resolver := &fakeResolver{addresses: map[string]string{"alice.test": owner}}
+	SetResolvers(resolver)
+	defer SetResolvers()
+
+	if owns, err := Owns("Alice.test", owner); err != nil || !owns {
+		t.Fatalf("Owns(owner) = %v, %v", owns, err)
+	}
+	for i := 0; i < 5; i++ {
+		if owns, err := Owns("alice.test", other); err != nil || owns {
+			t.Fatalf("Owns(other) = %v, %v", owns, err)
+		}
+	}
+	//the first Resolve is cached, the mismatch is checked fresh once and then answered from the cache
+	if resolves := atomic.LoadInt64(&resolver.resolves); resolves != 2 {
+		t.Errorf("resolver called %d times, want 2", resolves)
+	}
+
+	//a name just pointed at the wallet is seen after Forget
+	resolver.addresses["alice.test"] = other
+	Forget("alice.test", other)
+	if owns, _ := Owns("alice.test", other); !owns {
+		t.Error("Owns after Forget still uses the old answer")
+	}
+}
+
+func TestCachedPrimaryNameLimitsLookups(t *testing.T) {
+	resolver := &fakeResolver{release: make(chan struct{})}
+	SetResolvers(resolver)
+	defer SetResolvers()
+
+	for i := 0; i < maxWarming*3; i++ {
+		if name := CachedPrimaryName("0x" + strconv.Itoa(i)); name != "" {
+			t.Fatalf("uncached address has the name %q", name)
+		}
+	}
+	warmingMutex.Lock()
+	running := len(warming)
+	warmingMutex.Unlock()
+	if running != maxWarming {
+		t.Errorf("%d background lookups, want at most %d", running, maxWarming)
+	}
+
+	close(resolver.release)
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		warmingMutex.Lock()
+		done := len(warming) == 0
+		warmingMutex.Unlock()
+		if done {
+			break
+		}
+	}
+	if reverses := atomic.LoadInt64(&resolver.reverses); reverses != maxWarming {
+		t.Errorf("%d reverse lookups, want %d", reverses, maxWarming)
+	}
+}
+
+func TestConfigured(t *testing.T) {
+	SetResolvers()
+	if Configured() || Handles("alice.test") {
+		t.Error("configured without resolvers")
+	}
+	SetResolvers(&fakeResolver{})
+	defer SetResolvers()
+	if !Configured() {
+		t.Error("not configured with a resolver")
+	}
+}
@@ -0,0 +1,77 @@
+package names
+
+import (
+	"net/url"
+	"strings"
+)
+
+const spaceIdApi = "https://api.prd.space.id/v1"
+
+// SpaceIdResolver resolves .bnb and .arb names, https://docs.space.id/developer-guide/web3-name-sdk/sid-api
+type SpaceIdResolver struct{}
+
+// spaceIdTlds maps the name suffix to the API's tld id and the chain the address lives on
+var spaceIdTlds = map[string]struct {
+	tld   string
+	chain string
+}{
+	"bnb": {tld: "bnb", chain: "bsc"},
+	"arb": {tld: "arb1", chain: "arbitrum"},
+}
+
+type spaceIdResponse struct {
+	Code    int    `json:"code"`
+	Msg     string `json:"msg"`
+	Address string `json:"address"`
+	Name    string `json:"name"`
+}
+
+func NewSpaceIdResolver() *SpaceIdResolver {
+	return &SpaceIdResolver{}
+}
+
+func (r *SpaceIdResolver) Name() string { return "spaceid" }
+
+func (r *SpaceIdResolver) Handles(name string) bool {
+	return hasSuffix(name, []string{"bnb", "arb"})
+}
+
+func (r *SpaceIdResolver) Resolve(name string) (Record, error) {
+	tld := spaceIdTlds[name[strings.LastIndex(name, ".")+1:]]
+
+	var parsed spaceIdResponse
+	query := url.Values{"tld": {tld.tld}, "domain": {name}}
+	if err := getJson(spaceIdApi+"/getAddress?"+query.Encode(), nil, &parsed); err != nil {
+		return Record{}, err
+	}
+	address := strings.ToLower(parsed.Address)
+	if address == "" || address == zeroAddress {
+		return Record{}, ErrNotFound
+	}
+	return Record{Name: name, Address: address, Chain: tld.chain, Source: r.Name()}, nil
+}
+
+// Reverse asks for the .bnb primary name first, then .arb
+func (r *SpaceIdResolver) Reverse(address string) (Record, error) {
+	if !strings.HasPrefix(address, "0x") {
+		return Record{}, ErrUnsupported
+	}
+	for _, suffix := range []string{"bnb", "arb"} {
+		tld := spaceIdTlds[suffix]
+		var parsed spaceIdResponse
+		query := url.Values{"tld": {tld.tld}, "address": {address}}
+		err := getJson(spaceIdApi+"/getName?"+query.Encode(), nil, &parsed)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return Record{}, err
+		}
+		if parsed.Name != "" {
+			return Record{Name: Normalize(parsed.Name), Address: address, Chain: tld.chain, Source: r.Name()}, nil
+		}
+	}
+	return Record{}, ErrNotFound
+}
+
+const zeroAddress = "0x0000000000000000000000000000000000000000"
@@ -0,0 +1,57 @@
+package names
+
+import (
+	"net/url"
+	"strings"
+)
+
+const stacksApi = "https://stacks-node-api.mainnet.stacks.co/v1"
+
+// StacksResolver resolves .btc names with the Stacks BNS API, addresses are Stacks (SP...) addresses
+type StacksResolver struct{}
+
+type stacksNameResponse struct {
+	Address    string `json:"address"`
+	Blockchain string `json:"blockchain"`
+	Status     string `json:"status"`
+}
+
+type stacksAddressResponse struct {
+	Names []string `json:"names"`
+}
+
+func NewStacksResolver() *StacksResolver {
+	return &StacksResolver{}
+}
+
+func (r *StacksResolver) Name() string { return "stacks" }
+
+func (r *StacksResolver) Handles(name string) bool {
+	return strings.HasSuffix(name, ".btc")
+}
+
+// Resolve has no expiry, BNS expires at a block height rather than a time
+func (r *StacksResolver) Resolve(name string) (Record, error) {
+	var parsed stacksNameResponse
+	if err := getJson(stacksApi+"/names/"+url.PathEscape(name), nil, &parsed); err != nil {
+		return Record{}, err
+	}
+	if parsed.Address == "" || parsed.Status == "name-revoke" {
+		return Record{}, ErrNotFound
+	}
+	return Record{Name: name, Address: strings.ToLower(parsed.Address), Chain: "stacks", Source: r.Name()}, nil
+}
+
+func (r *StacksResolver) Reverse(address string) (Record, error) {
+	if !strings.HasPrefix(address, "sp") {
+		return Record{}, ErrUnsupported
+	}
+	var parsed stacksAddressResponse
+	if err := getJson(stacksApi+"/addresses/stacks/"+url.PathEscape(strings.ToUpper(address)), nil, &parsed); err != nil {
+		return Record{}, err
+	}
+	if len(parsed.Names) == 0 {
+		return Record{}, ErrNotFound
+	}
+	return Record{Name: Normalize(parsed.Names[0]), Address: address, Chain: "stacks", Source: r.Name()}, nil
+}
@@ -0,0 +1,80 @@
+package names
+
+import (
+	"errors"
+	"net/url"
+	"os"
+	"rest-go-demo/onchain"
+	"strings"
+)
+
+const unstoppableApi = "https://api.unstoppabledomains.com"
+
+// unstoppableTlds are the Unstoppable Domains TLDs, none of them collide with the other naming systems
+var unstoppableTlds = []string{"crypto", "nft", "x", "wallet", "blockchain", "bitcoin", "dao", "888", "zil", "polygon", "unstoppable", "klever", "hi", "kresus", "anime", "manga", "binanceus", "go", "altimist", "pudgy", "austin", "bitget", "pog", "clay", "witg", "metropolis", "wrkx", "secret", "raiin", "tball", "stepn", "farms", "dfz", "ubu", "kryptic"}
+
+// UnstoppableResolver resolves Unstoppable Domains with the Resolution API, it needs UNSTOPPABLE_API_KEY
+type UnstoppableResolver struct {
+	ApiKey string
+}
+
+type unstoppableResponse struct {
+	Meta struct {
+		Domain     string `json:"domain"`
+		Owner      string `json:"owner"`
+		Blockchain string `json:"blockchain"`
+	} `json:"meta"`
+	Records map[string]string `json:"records"`
+}
+
+func NewUnstoppableResolverFromEnv() (*UnstoppableResolver, error) {
+	apiKey := os.Getenv("UNSTOPPABLE_API_KEY")
+	if apiKey == "" {
+		return nil, errors.New("UNSTOPPABLE_API_KEY not set")
+	}
+	return &UnstoppableResolver{ApiKey: apiKey}, nil
+}
+
+func (r *UnstoppableResolver) Name() string { return "unstoppable" }
+
+func (r *UnstoppableResolver) Handles(name string) bool {
+	return hasSuffix(name, unstoppableTlds)
+}
+
+func (r *UnstoppableResolver) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + r.ApiKey}
+}
+
+// Resolve prefers the ETH address record, the owner is the fallback (a name without records still belongs to someone)
+func (r *UnstoppableResolver) Resolve(name string) (Record, error) {
+	var parsed unstoppableResponse
+	if err := getJson(unstoppableApi+"/resolve/domains/"+url.PathEscape(name), r.headers(), &parsed); err != nil {
+		return Record{}, err
+	}
+	address := strings.ToLower(parsed.Records["crypto.ETH.address"])
+	chain := onchain.ChainEthereum
+	if address == "" {
+		address = strings.ToLower(parsed.Meta.Owner)
+		if parsed.Meta.Blockchain == "MATIC" {
+			chain = onchain.ChainPolygon
+		}
+	}
+	if address == "" || address == zeroAddress {
+		return Record{}, ErrNotFound
+	}
+	return Record{Name: name, Address: address, Chain: chain, Avatar: parsed.Records["social.picture.value"], Source: r.Name()}, nil
+}
+
+func (r *UnstoppableResolver) Reverse(address string) (Record, error) {
+	if !strings.HasPrefix(address, "0x") {
+		return Record{}, ErrUnsupported
+	}
+	var parsed unstoppableResponse
+	if err := getJson(unstoppableApi+"/resolve/reverse/"+url.PathEscape(address), r.headers(), &parsed); err != nil {
+		return Record{}, err
+	}
+	if parsed.Meta.Domain == "" {
+		return Record{}, ErrNotFound
+	}
+	return Record{Name: Normalize(parsed.Meta.Domain), Address: address, Source: r.Name()}, nil
+}
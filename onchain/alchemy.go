@@ -0,0 +1,268 @@
+package onchain
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Alchemy network subdomains
+var alchemyNetworks = map[string]string{
+	ChainEthereum: "eth-mainnet",
+	ChainPolygon:  "polygon-mainnet",
+	"arbitrum":    "arb-mainnet",
+	"optimism":    "opt-mainnet",
+	"base":        "base-mainnet",
+}
+
+// AlchemyProvider uses the Alchemy NFT API v3 and token API, names go through ENS on its Ethereum RPC endpoint
+type AlchemyProvider struct {
+	apiKey string
+	ens    *RpcProvider
+}
+
+// NewAlchemyProviderFromEnv reads ALCHEMY_API_KEY
+func NewAlchemyProviderFromEnv() (*AlchemyProvider, error) {
+	apiKey := os.Getenv("ALCHEMY_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("ALCHEMY_API_KEY not set")
+	}
+	return NewAlchemyProvider(apiKey), nil
+}
+
+func NewAlchemyProvider(apiKey string) *AlchemyProvider {
+	return &AlchemyProvider{
+		apiKey: apiKey,
+		ens:    NewRpcProvider(map[string]string{ChainEthereum: "https://eth-mainnet.g.alchemy.com/v2/" + apiKey}),
+	}
+}
+
+func (p *AlchemyProvider) Name() string { return "alchemy" }
+
+func (p *AlchemyProvider) Supports(chain string) bool {
+	_, ok := alchemyNetworks[chain]
+	return ok
+}
+
+func (p *AlchemyProvider) nftUrl(chain string, method string, query url.Values) string {
+	return "https://" + alchemyNetworks[chain] + ".g.alchemy.com/nft/v3/" + p.apiKey + "/" + method + "?" + query.Encode()
+}
+
+func (p *AlchemyProvider) getJson(requestUrl string, result interface{}) error {
+	req, _ := http.NewRequest("GET", requestUrl, nil)
+	req.Header.Add("accept", "application/json")
+	return alchemyDo(req, result)
+}
+
+// rpc calls one of the alchemy_* JSON-RPC methods
+func (p *AlchemyProvider) rpc(chain string, method string, params []interface{}, result interface{}) error {
+	payload, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	req, _ := http.NewRequest("POST", "https://"+alchemyNetworks[chain]+".g.alchemy.com/v2/"+p.apiKey, bytes.NewReader(payload))
+	req.Header.Add("Content-Type", "application/json")
+
+	var response struct {
+		Result json.RawMessage `json:"result"`
+		Error  *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if err := alchemyDo(req, &response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("alchemy %s: %s", method, response.Error.Message)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+func alchemyDo(req *http.Request, result interface{}) error {
+	resp, err := HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("alchemy returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, result)
+}
+
+type alchemyNftPage struct {
+	OwnedNfts []struct {
+		Contract struct {
+			Address   string `json:"address"`
+			Name      string `json:"name"`
+			Symbol    string `json:"symbol"`
+			TokenType string `json:"tokenType"`
+		} `json:"contract"`
+		TokenId string `json:"tokenId"`
+		Balance string `json:"balance"`
+	} `json:"ownedNfts"`
+	PageKey string `json:"pageKey"`
+}
+
+func (p *AlchemyProvider) OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error) {
+	query := url.Values{}
+	query.Set("owner", normalizeAddress(wallet))
+	query.Set("withMetadata", "false")
+	query.Set("pageSize", strconv.Itoa(ownedPageLimit))
+	for _, contract := range contracts {
+		query.Add("contractAddresses[]", normalizeAddress(contract))
+	}
+
+	nfts := []Nft{}
+	for page := 0; page < maxOwnedPages; page++ {
+		var result alchemyNftPage
+		if err := p.getJson(p.nftUrl(chain, "getNFTsForOwner", query), &result); err != nil {
+			return nil, err
+		}
+		for _, nft := range result.OwnedNfts {
+			nfts = append(nfts, Nft{
+				Chain:    chain,
+				Contract: normalizeAddress(nft.Contract.Address),
+				Tokenid:  nft.TokenId,
+				Amount:   nft.Balance,
+				Standard: strings.ToLower(nft.Contract.TokenType),
+				Name:     nft.Contract.Name,
+				Symbol:   nft.Contract.Symbol,
+			})
+		}
+		if result.PageKey == "" {
+			break
+		}
+		query.Set("pageKey", result.PageKey)
+	}
+	return nfts, nil
+}
+
+func (p *AlchemyProvider) NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error) {
+	nfts, err := p.OwnedNfts(chain, wallet, []string{contract})
+	if err != nil {
+		return nil, err
+	}
+	return sumNfts(nfts, contract, tokenId), nil
+}
+
+func (p *AlchemyProvider) TokenBalance(chain string, contract string, wallet string) (TokenBalance, error) {
+	var balances struct {
+		TokenBalances []struct {
+			ContractAddress string `json:"contractAddress"`
+			TokenBalance    string `json:"tokenBalance"` //hex
+		} `json:"tokenBalances"`
+	}
+	if err := p.rpc(chain, "alchemy_getTokenBalances", []interface{}{normalizeAddress(wallet), []string{normalizeAddress(contract)}}, &balances); err != nil {
+		return TokenBalance{}, err
+	}
+	var metadata struct {
+		Decimals *int `json:"decimals"`
+	}
+	if err := p.rpc(chain, "alchemy_getTokenMetadata", []interface{}{normalizeAddress(contract)}, &metadata); err != nil {
+		return TokenBalance{}, err
+	}
+	if metadata.Decimals == nil {
+		return TokenBalance{}, ErrNotFound
+	}
+
+	balance := TokenBalance{Contract: normalizeAddress(contract), Balance: big.NewInt(0), Decimals: *metadata.Decimals}
+	for _, token := range balances.TokenBalances {
+		if !strings.EqualFold(token.ContractAddress, contract) {
+			continue
+		}
+		raw, ok := new(big.Int).SetString(strings.TrimPrefix(token.TokenBalance, "0x"), 16)
+		if !ok && token.TokenBalance != "0x" {
+			return TokenBalance{}, fmt.Errorf("alchemy returned a bad balance %q", token.TokenBalance)
+		}
+		if ok {
+			balance.Balance = raw
+		}
+	}
+	return balance, nil
+}
+
+func (p *AlchemyProvider) ResolveName(name string) (string, error) {
+	return p.ens.ResolveName(name)
+}
+
+func (p *AlchemyProvider) LookupAddress(address string) (string, error) {
+	return p.ens.LookupAddress(address)
+}
+
+func (p *AlchemyProvider) Collection(chain string, contract string) (Collection, error) {
+	query := url.Values{}
+	query.Set("contractAddress", normalizeAddress(contract))
+	var result struct {
+		Address     string `json:"address"`
+		Name        string `json:"name"`
+		Symbol      string `json:"symbol"`
+		TotalSupply string `json:"totalSupply"`
+		TokenType   string `json:"tokenType"`
+	}
+	if err := p.getJson(p.nftUrl(chain, "getContractMetadata", query), &result); err != nil {
+		return Collection{}, err
+	}
+	standard := strings.ToLower(result.TokenType)
+	if standard != StandardErc721 && standard != StandardErc1155 {
+		return Collection{}, ErrNotFound //NOT_A_CONTRACT, UNKNOWN
+	}
+	return Collection{
+		Chain:       chain,
+		Contract:    normalizeAddress(contract),
+		Name:        result.Name,
+		Symbol:      result.Symbol,
+		Standard:    standard,
+		Totalsupply: result.TotalSupply,
+	}, nil
+}
+
+func (p *AlchemyProvider) TokenOwners(chain string, contract string, tokenId string) ([]string, error) {
+	query := url.Values{}
+	query.Set("contractAddress", normalizeAddress(contract))
+	query.Set("tokenId", tokenId)
+	var result struct {
+		Owners []string `json:"owners"`
+	}
+	if err := p.getJson(p.nftUrl(chain, "getOwnersForNFT", query), &result); err != nil {
+		return nil, err
+	}
+	owners := []string{}
+	for _, owner := range result.Owners {
+		owners = append(owners, normalizeAddress(owner))
+	}
+	return owners, nil
+}
+
+func (p *AlchemyProvider) TokenTraits(chain string, contract string, tokenId string) (map[string]string, error) {
+	query := url.Values{}
+	query.Set("contractAddress", normalizeAddress(contract))
+	query.Set("tokenId", tokenId)
+	var result struct {
+		Raw struct {
+			Metadata struct {
+				Attributes []tokenAttribute `json:"attributes"`
+			} `json:"metadata"`
+			Error string `json:"error"`
+		} `json:"raw"`
+	}
+	if err := p.getJson(p.nftUrl(chain, "getNFTMetadata", query), &result); err != nil {
+		return nil, err
+	}
+	if result.Raw.Error != "" {
+		return nil, fmt.Errorf("alchemy metadata: %s", result.Raw.Error)
+	}
+	return traitsFromAttributes(result.Raw.Metadata.Attributes), nil
+}
@@ -0,0 +1,172 @@
+package onchain
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Chain is one entry of the chain registry, Name is what bookmarks, messages and access conditions store
+type Chain struct {
+	Name    string `json:"name"`
+	Title   string `json:"title"`
+	Chainid int64  `json:"chain_id,omitempty"` //EVM chain id
+	Evm     bool   `json:"evm"`
+}
+
+// every chain we know, ONCHAIN_CHAINS picks the EVM ones that are used and their order
+var knownChains = []Chain{
+	{Name: ChainEthereum, Title: "Ethereum", Chainid: 1, Evm: true},
+	{Name: ChainPolygon, Title: "Polygon", Chainid: 137, Evm: true},
+	{Name: "base", Title: "Base", Chainid: 8453, Evm: true},
+	{Name: "arbitrum", Title: "Arbitrum One", Chainid: 42161, Evm: true},
+	{Name: "optimism", Title: "OP Mainnet", Chainid: 10, Evm: true},
+	{Name: "bsc", Title: "BNB Smart Chain", Chainid: 56, Evm: true},
+	{Name: "avalanche", Title: "Avalanche C-Chain", Chainid: 43114, Evm: true},
+	{Name: "vana", Title: "Vana", Chainid: 1480, Evm: true},
+	{Name: "xdai", Title: "Gnosis", Chainid: 100, Evm: true},
+	{Name: ChainNear, Title: "NEAR"},
+	{Name: ChainTezos, Title: "Tezos"},
+}
+
+// the order holder checks and auto-join go through when no chain is given, ethereum and polygon first as before
+var defaultEvmChains = "ethereum,polygon,base,arbitrum,optimism,bsc,avalanche,vana"
+
+// other names clients and providers use for the same chain
+var chainAliases = map[string]string{
+	"eth":          ChainEthereum,
+	"mainnet":      ChainEthereum,
+	"matic":        ChainPolygon,
+	"arb":          "arbitrum",
+	"arb1":         "arbitrum",
+	"op":           "optimism",
+	"bnb":          "bsc",
+	"avax":         "avalanche",
+	"gnosis":       "xdai",
+	"tez":          ChainTezos,
+	"near-mainnet": ChainNear,
+}
+
+var (
+	chainsMutex sync.RWMutex
+	chains      = buildChains(defaultEvmChains)
+)
+
+// buildChains returns the EVM chains of the list, in its order, followed by NEAR and Tezos (always enabled,
+// their wallets are told apart by address format). Unknown EVM chains can be added as name:chainid.
+func buildChains(list string) []Chain {
+	enabled := []Chain{}
+	seen := map[string]bool{}
+	for _, entry := range strings.Split(list, ",") {
+		name := strings.ToLower(strings.TrimSpace(entry))
+		if name == "" {
+			continue
+		}
+		chain, ok := Chain{}, false
+		if parts := strings.SplitN(name, ":", 2); len(parts) == 2 {
+			chainid, err := strconv.ParseInt(parts[1], 10, 64)
+			if err != nil {
+				fmt.Println("onchain - bad chain id, chain skipped: ", entry)
+				continue
+			}
+			chain, ok = Chain{Name: parts[0], Title: parts[0], Chainid: chainid, Evm: true}, true
+		} else {
+			chain, ok = knownChain(name)
+		}
+		if !ok || !chain.Evm {
+			fmt.Println("onchain - unknown EVM chain, skipped: ", entry)
+			continue
+		}
+		if !seen[chain.Name] {
+			seen[chain.Name] = true
+			enabled = append(enabled, chain)
+		}
+	}
+	for _, name := range []string{ChainNear, ChainTezos} {
+		chain, _ := knownChain(name)
+		enabled = append(enabled, chain)
+	}
+	return enabled
+}
+
+func knownChain(name string) (Chain, bool) {
+	if alias, ok := chainAliases[name]; ok {
+		name = alias
+	}
+	for _, chain := range knownChains {
+		if chain.Name == name {
+			return chain, true
+		}
+	}
+	return Chain{}, false
+}
+
+// InitChains reads ONCHAIN_CHAINS, the comma separated EVM chains in holder check order (ie "ethereum,polygon,base,zora:7777777")
+func InitChains() {
+	list := os.Getenv("ONCHAIN_CHAINS")
+	if list == "" {
+		list = defaultEvmChains
+	}
+	enabled := buildChains(list)
+	chainsMutex.Lock()
+	chains = enabled
+	chainsMutex.Unlock()
+
+	names := []string{}
+	for _, chain := range enabled {
+		names = append(names, chain.Name)
+	}
+	fmt.Println("onchain - chains: ", strings.Join(names, ","))
+}
+
+// Chains returns the enabled chains, EVM chains first in holder check order
+func Chains() []Chain {
+	chainsMutex.RLock()
+	defer chainsMutex.RUnlock()
+	return append([]Chain{}, chains...)
+}
+
+// EvmChains returns the names of the enabled EVM chains in holder check order
+func EvmChains() []string {
+	names := []string{}
+	for _, chain := range Chains() {
+		if chain.Evm {
+			names = append(names, chain.Name)
+		}
+	}
+	return names
+}
+
+// GetChain looks up an enabled chain by name or alias
+func GetChain(name string) (Chain, bool) {
+	name = NormalizeChain(name)
+	for _, chain := range Chains() {
+		if chain.Name == name {
+			return chain, true
+		}
+	}
+	return Chain{}, false
+}
+
+// NormalizeChain lower cases the name and maps aliases (eth, matic, bnb...) to the registry name
+func NormalizeChain(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := chainAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+// IsChain is true for enabled chains
+func IsChain(name string) bool {
+	_, ok := GetChain(name)
+	return ok
+}
+
+// IsEvmChain is true for enabled EVM chains
+func IsEvmChain(name string) bool {
+	chain, ok := GetChain(name)
+	return ok && chain.Evm
+}
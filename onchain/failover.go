@@ -0,0 +1,346 @@
+package onchain
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// a provider that failed is tried last for this long, so one outage doesn't slow every request down
+const failoverCooldown = 30 * time.Second
+
+// Failover tries its providers in order, skipping the ones that don't support the chain/call
+// and moving on when one fails. It implements every provider interface.
+type Failover struct {
+	providers []Provider
+
+	mutex     sync.Mutex
+	downUntil map[string]time.Time
+}
+
+func NewFailover(providers ...Provider) *Failover {
+	return &Failover{providers: providers, downUntil: map[string]time.Time{}}
+}
+
+func (f *Failover) Name() string {
+	names := []string{}
+	for _, provider := range f.providers {
+		names = append(names, provider.Name())
+	}
+	return "failover(" + strings.Join(names, ",") + ")"
+}
+
+func (f *Failover) Supports(chain string) bool {
+	for _, provider := range f.providers {
+		if provider.Supports(chain) {
+			return true
+		}
+	}
+	return false
+}
+
+// candidates returns the providers for the chain, healthy ones first and in configured order
+func (f *Failover) candidates(chain string) []Provider {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	now := time.Now()
+	var healthy, cooling []Provider
+	for _, provider := range f.providers {
+		if !provider.Supports(chain) {
+			continue
+		}
+		if f.downUntil[provider.Name()].After(now) {
+			cooling = append(cooling, provider)
+		} else {
+			healthy = append(healthy, provider)
+		}
+	}
+	return append(healthy, cooling...)
+}
+
+func (f *Failover) markDown(provider Provider, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	fmt.Println("onchain - provider failed, trying the next one: ", provider.Name(), err)
+	f.downUntil[provider.Name()] = time.Now().Add(failoverCooldown)
+}
+
+func (f *Failover) markUp(provider Provider) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	delete(f.downUntil, provider.Name())
+}
+
+// try calls each candidate until one answers, ErrNotFound is an answer too
+func (f *Failover) try(chain string, call func(provider Provider) error) error {
+	lastErr := ErrNoProvider
+	for _, provider := range f.candidates(chain) {
+		err := call(provider)
+		if err == nil || errors.Is(err, ErrNotFound) {
+			f.markUp(provider)
+			return err
+		}
+		if errors.Is(err, ErrUnsupported) {
+			continue
+		}
+		f.markDown(provider, err)
+		lastErr = err
+	}
+	return lastErr
+}
+
+func (f *Failover) NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error) {
+	var balance *big.Int
+	err := f.try(chain, func(provider Provider) error {
+		ownership, ok := provider.(OwnershipProvider)
+		if !ok {
+			return ErrUnsupported
+		}
+		var err error
+		balance, err = ownership.NftBalance(chain, contract, wallet, tokenId)
+		return err
+	})
+	return balance, err
+}
+
+func (f *Failover) OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error) {
+	var nfts []Nft
+	err := f.try(chain, func(provider Provider) error {
+		ownership, ok := provider.(OwnershipProvider)
+		if !ok {
+			return ErrUnsupported
+		}
+		var err error
+		nfts, err = ownership.OwnedNfts(chain, wallet, contracts)
+		return err
+	})
+	return nfts, err
+}
+
+func (f *Failover) TokenBalance(chain string, contract string, wallet string) (TokenBalance, error) {
+	var balance TokenBalance
+	err := f.try(chain, func(provider Provider) error {
+		balances, ok := provider.(BalanceProvider)
+		if !ok {
+			return ErrUnsupported
+		}
+		var err error
+		balance, err = balances.TokenBalance(chain, contract, wallet)
+		return err
+	})
+	return balance, err
+}
+
+func (f *Failover) ResolveName(name string) (string, error) {
+	var address string
+	err := f.try(ChainEthereum, func(provider Provider) error {
+		names, ok := provider.(NameProvider)
+		if !ok {
+			return ErrUnsupported
+		}
+		var err error
+		address, err = names.ResolveName(name)
+		return err
+	})
+	return address, err
+}
+
+func (f *Failover) LookupAddress(address string) (string, error) {
+	var name string
+	err := f.try(ChainEthereum, func(provider Provider) error {
+		names, ok := provider.(NameProvider)
+		if !ok {
+			return ErrUnsupported
+		}
+		var err error
+		name, err = names.LookupAddress(address)
+		return err
+	})
+	return name, err
+}
+
+func (f *Failover) Collection(chain string, contract string) (Collection, error) {
+	var collection Collection
+	err := f.try(chain, func(provider Provider) error {
+		collections, ok := provider.(CollectionProvider)
+		if !ok {
+			return ErrUnsupported
+		}
+		var err error
+		collection, err = collections.Collection(chain, contract)
+		return err
+	})
+	return collection, err
+}
+
+func (f *Failover) TokenOwners(chain string, contract string, tokenId string) ([]string, error) {
+	var owners []string
+	err := f.try(chain, func(provider Provider) error {
+		tokens, ok := provider.(TokenProvider)
+		if !ok {
+			return ErrUnsupported
+		}
+		var err error
+		owners, err = tokens.TokenOwners(chain, contract, tokenId)
+		return err
+	})
+	return owners, err
+}
+
+func (f *Failover) TokenTraits(chain string, contract string, tokenId string) (map[string]string, error) {
+	var traits map[string]string
+	err := f.try(chain, func(provider Provider) error {
+		tokens, ok := provider.(TokenProvider)
+		if !ok {
+			return ErrUnsupported
+		}
+		var err error
+		traits, err = tokens.TokenTraits(chain, contract, tokenId)
+		return err
+	})
+	return traits, err
+}
+
+// ============================================================================
+
+// AllProvider is a provider answering every kind of call, like Failover, FakeProvider and Recorder
+type AllProvider interface {
+	OwnershipProvider
+	BalanceProvider
+	NameProvider
+	CollectionProvider
+	TokenProvider
+}
+
+var (
+	activeMutex sync.RWMutex
+	active      AllProvider = NewFailover()
+)
+
+// SetProviders replaces the providers used by the package functions, in failover order (ie a FakeProvider for offline runs)
+func SetProviders(providers ...Provider) {
+	activeMutex.Lock()
+	defer activeMutex.Unlock()
+	active = NewFailover(providers...)
+}
+
+// SetProvider uses one provider as is, without a Failover around it (ie a Recorder)
+func SetProvider(provider AllProvider) {
+	activeMutex.Lock()
+	defer activeMutex.Unlock()
+	active = provider
+}
+
+func current() AllProvider {
+	activeMutex.RLock()
+	defer activeMutex.RUnlock()
+	return active
+}
+
+// default order, cheapest and most complete first, the RPC fallback keeps holder checks working when the APIs are down
+var defaultProviderOrder = "moralis,alchemy,rpc,pagoda,tzkt"
+
+// Init sets up the providers that have credentials in the environment
+// ONCHAIN_PROVIDERS changes the order/selection (comma separated), ONCHAIN_PROVIDER=fake uses ONCHAIN_FIXTURES only
+// and ONCHAIN_RECORD=path writes every answer to a fixture file for later offline runs
+func Init() {
+	InitChains()
+
+	if strings.EqualFold(os.Getenv("ONCHAIN_PROVIDER"), "fake") {
+		fixtures, err := LoadFixtures(os.Getenv("ONCHAIN_FIXTURES"))
+		if err != nil {
+			fmt.Println("onchain - fixtures not loaded, the fake provider is empty: ", err)
+		}
+		SetProvider(NewFakeProvider(fixtures))
+		fmt.Println("onchain - using the fake provider")
+		return
+	}
+
+	order := os.Getenv("ONCHAIN_PROVIDERS")
+	if order == "" {
+		order = defaultProviderOrder
+	}
+	var providers []Provider
+	for _, name := range strings.Split(order, ",") {
+		provider, err := newProviderFromEnv(strings.ToLower(strings.TrimSpace(name)))
+		if err != nil {
+			fmt.Println("onchain - ", name, " disabled: ", err)
+			continue
+		}
+		providers = append(providers, provider)
+	}
+	failover := NewFailover(providers...)
+	fmt.Println("onchain - providers: ", failover.Name())
+
+	if path := os.Getenv("ONCHAIN_RECORD"); path != "" {
+		SetProvider(NewRecorder(failover, path))
+		fmt.Println("onchain - recording fixtures to ", path)
+		return
+	}
+	SetProvider(failover)
+}
+
+func newProviderFromEnv(name string) (Provider, error) {
+	switch name {
+	case "moralis":
+		return NewMoralisProviderFromEnv()
+	case "alchemy":
+		return NewAlchemyProviderFromEnv()
+	case "rpc":
+		return NewRpcProviderFromEnv()
+	case "pagoda":
+		return NewPagodaProviderFromEnv()
+	case "tzkt":
+		return NewTzktProvider(), nil
+	}
+	return nil, fmt.Errorf("unknown provider %q", name)
+}
+
+// Supports is true when a configured provider answers for the chain
+func Supports(chain string) bool {
+	return current().Supports(chain)
+}
+
+// NftBalance - see OwnershipProvider
+func NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error) {
+	return current().NftBalance(chain, contract, wallet, tokenId)
+}
+
+// OwnedNfts - see OwnershipProvider
+func OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error) {
+	return current().OwnedNfts(chain, wallet, contracts)
+}
+
+// GetTokenBalance - see BalanceProvider
+func GetTokenBalance(chain string, contract string, wallet string) (TokenBalance, error) {
+	return current().TokenBalance(chain, contract, wallet)
+}
+
+// ResolveName returns the address of an ENS name
+func ResolveName(name string) (string, error) {
+	return current().ResolveName(name)
+}
+
+// LookupAddress returns the primary ENS name of an address
+func LookupAddress(address string) (string, error) {
+	return current().LookupAddress(address)
+}
+
+// TokenOwners - see TokenProvider
+func TokenOwners(chain string, contract string, tokenId string) ([]string, error) {
+	return current().TokenOwners(chain, contract, tokenId)
+}
+
+// TokenTraits - see TokenProvider
+func TokenTraits(chain string, contract string, tokenId string) (map[string]string, error) {
+	return current().TokenTraits(chain, contract, tokenId)
+}
+
+// GetCollection - see CollectionProvider
+func GetCollection(chain string, contract string) (Collection, error) {
+	return current().Collection(chain, contract)
+}
@@ -0,0 +1,205 @@
+package onchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// FixtureNft is an Nft held by Wallet
+type FixtureNft struct {
+	Wallet string `json:"wallet"`
+	Nft
+}
+
+// FixtureToken is a fungible balance held by Wallet
+type FixtureToken struct {
+	Chain    string `json:"chain"`
+	Contract string `json:"contract"`
+	Wallet   string `json:"wallet"`
+	Balance  string `json:"balance"` //smallest unit, decimal string
+	Decimals int    `json:"decimals"`
+}
+
+// FixtureTraits are the metadata attributes of one token
+type FixtureTraits struct {
+	Chain    string            `json:"chain"`
+	Contract string            `json:"contract"`
+	Tokenid  string            `json:"token_id"`
+	Traits   map[string]string `json:"traits"`
+}
+
+// Fixtures is the recorded chain data the FakeProvider answers from, see fixtures/holders.json
+type Fixtures struct {
+	Nfts        []FixtureNft      `json:"nfts"`
+	Tokens      []FixtureToken    `json:"tokens"`
+	Names       map[string]string `json:"names"`   //name -> address
+	Reverse     map[string]string `json:"reverse"` //address -> name
+	Collections []Collection      `json:"collections"`
+	Traits      []FixtureTraits   `json:"traits"`
+}
+
+// LoadFixtures reads a fixture file written by hand or by the Recorder (ONCHAIN_RECORD)
+func LoadFixtures(path string) (Fixtures, error) {
+	fixtures := Fixtures{Names: map[string]string{}, Reverse: map[string]string{}}
+	if path == "" {
+		return fixtures, fmt.Errorf("ONCHAIN_FIXTURES not set")
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fixtures, err
+	}
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return fixtures, err
+	}
+	if fixtures.Names == nil {
+		fixtures.Names = map[string]string{}
+	}
+	if fixtures.Reverse == nil {
+		fixtures.Reverse = map[string]string{}
+	}
+	return fixtures, nil
+}
+
+// FakeProvider answers from fixtures without any network access, for local development and tests
+// wallets/contracts not in the fixtures hold nothing, Fail makes every call fail (to exercise failover)
+type FakeProvider struct {
+	mutex    sync.Mutex
+	Fixtures Fixtures
+	Fail     error
+	Calls    []string
+}
+
+func NewFakeProvider(fixtures Fixtures) *FakeProvider {
+	return &FakeProvider{Fixtures: fixtures}
+}
+
+func (p *FakeProvider) Name() string { return "fake" }
+
+func (p *FakeProvider) Supports(chain string) bool { return true }
+
+// record logs the call and returns Fail
+func (p *FakeProvider) record(call string, args ...string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.Calls = append(p.Calls, call+"("+strings.Join(args, ",")+")")
+	return p.Fail
+}
+
+func (p *FakeProvider) OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error) {
+	if err := p.record("OwnedNfts", chain, wallet, strings.Join(contracts, "|")); err != nil {
+		return nil, err
+	}
+	nfts := []Nft{}
+	for _, fixture := range p.Fixtures.Nfts {
+		if fixture.Chain != chain || !strings.EqualFold(fixture.Wallet, wallet) {
+			continue
+		}
+		if len(contracts) > 0 && !containsAddress(contracts, fixture.Contract) {
+			continue
+		}
+		nfts = append(nfts, fixture.Nft)
+	}
+	return nfts, nil
+}
+
+func (p *FakeProvider) NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error) {
+	nfts, err := p.OwnedNfts(chain, wallet, []string{contract})
+	if err != nil {
+		return nil, err
+	}
+	return sumNfts(nfts, contract, tokenId), nil
+}
+
+func (p *FakeProvider) TokenBalance(chain string, contract string, wallet string) (TokenBalance, error) {
+	if err := p.record("TokenBalance", chain, contract, wallet); err != nil {
+		return TokenBalance{}, err
+	}
+	for _, fixture := range p.Fixtures.Tokens {
+		if fixture.Chain != chain || !strings.EqualFold(fixture.Contract, contract) || !strings.EqualFold(fixture.Wallet, wallet) {
+			continue
+		}
+		balance, ok := new(big.Int).SetString(fixture.Balance, 10)
+		if !ok {
+			return TokenBalance{}, fmt.Errorf("bad fixture balance %q", fixture.Balance)
+		}
+		return TokenBalance{Contract: normalizeAddress(contract), Balance: balance, Decimals: fixture.Decimals}, nil
+	}
+	return TokenBalance{Contract: normalizeAddress(contract), Balance: big.NewInt(0)}, nil
+}
+
+func (p *FakeProvider) ResolveName(name string) (string, error) {
+	if err := p.record("ResolveName", name); err != nil {
+		return "", err
+	}
+	if address, ok := p.Fixtures.Names[strings.ToLower(name)]; ok {
+		return normalizeAddress(address), nil
+	}
+	return "", ErrNotFound
+}
+
+func (p *FakeProvider) LookupAddress(address string) (string, error) {
+	if err := p.record("LookupAddress", address); err != nil {
+		return "", err
+	}
+	if name, ok := p.Fixtures.Reverse[normalizeAddress(address)]; ok {
+		return name, nil
+	}
+	return "", ErrNotFound
+}
+
+func (p *FakeProvider) Collection(chain string, contract string) (Collection, error) {
+	if err := p.record("Collection", chain, contract); err != nil {
+		return Collection{}, err
+	}
+	for _, collection := range p.Fixtures.Collections {
+		if collection.Chain == chain && strings.EqualFold(collection.Contract, contract) {
+			return collection, nil
+		}
+	}
+	return Collection{}, ErrNotFound
+}
+
+func (p *FakeProvider) TokenOwners(chain string, contract string, tokenId string) ([]string, error) {
+	if err := p.record("TokenOwners", chain, contract, tokenId); err != nil {
+		return nil, err
+	}
+	owners := []string{}
+	for _, fixture := range p.Fixtures.Nfts {
+		if fixture.Chain == chain && strings.EqualFold(fixture.Contract, contract) && fixture.Tokenid == tokenId {
+			owners = append(owners, normalizeAddress(fixture.Wallet))
+		}
+	}
+	return owners, nil
+}
+
+func (p *FakeProvider) TokenTraits(chain string, contract string, tokenId string) (map[string]string, error) {
+	if err := p.record("TokenTraits", chain, contract, tokenId); err != nil {
+		return nil, err
+	}
+	for _, fixture := range p.Fixtures.Traits {
+		if fixture.Chain == chain && strings.EqualFold(fixture.Contract, contract) && fixture.Tokenid == tokenId {
+			return fixture.Traits, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// CallLog returns a copy of the calls made so far
+func (p *FakeProvider) CallLog() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]string{}, p.Calls...)
+}
+
+func containsAddress(addresses []string, address string) bool {
+	for _, candidate := range addresses {
+		if strings.EqualFold(candidate, address) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,78 @@
+package onchain
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func loadTestFixtures(t *testing.T) *FakeProvider {
+	t.Helper()
+	fixtures, err := LoadFixtures("fixtures/holders.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake := NewFakeProvider(fixtures)
+	SetProvider(fake)
+	t.Cleanup(func() { SetProviders() })
+	return fake
+}
+
+func TestFakeNftBalance(t *testing.T) {
+	loadTestFixtures(t)
+	cases := []struct {
+		chain    string
+		contract string
+		wallet   string
+		tokenId  string
+		want     int64
+	}{
+		{"ethereum", "0xAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA", "0x1111111111111111111111111111111111111111", "", 1},
+		{"polygon", "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "0x1111111111111111111111111111111111111111", "7", 3},
+		{"polygon", "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "0x1111111111111111111111111111111111111111", "8", 0},
+		{"ethereum", "0xbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "0x1111111111111111111111111111111111111111", "", 0},
+		{"near", "example.mintbase1.near", "example.near", "", 1},
+	}
+	for _, c := range cases {
+		balance, err := NftBalance(c.chain, c.contract, c.wallet, c.tokenId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if balance.Int64() != c.want {
+			t.Errorf("NftBalance(%s, %s, %s, %q) = %s, want %d", c.chain, c.contract, c.wallet, c.tokenId, balance, c.want)
+		}
+	}
+}
+
+func TestFakeFail(t *testing.T) {
+	fake := loadTestFixtures(t)
+	fake.Fail = errors.New("provider down")
+	if _, err := NftBalance("ethereum", "0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "0x1111111111111111111111111111111111111111", ""); err == nil {
+		t.Error("Fail is not returned")
+	}
+	want := []string{"OwnedNfts(ethereum,0x1111111111111111111111111111111111111111,0xaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa)"}
+	if got := fake.CallLog(); !reflect.DeepEqual(got, want) {
+		t.Errorf("CallLog = %v, want %v", got, want)
+	}
+}
+
+func TestFakeNames(t *testing.T) {
+	loadTestFixtures(t)
+	if address, err := ResolveName("Example.eth"); err != nil || address != "0x1111111111111111111111111111111111111111" {
+		t.Errorf("ResolveName = %q, %v", address, err)
+	}
+	if _, err := ResolveName("missing.eth"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ResolveName of an unknown name = %v, want ErrNotFound", err)
+	}
+}
+
+func TestBuildChains(t *testing.T) {
+	names := []string{}
+	for _, chain := range buildChains("matic, ethereum,unknown,zora:7777777,ethereum") {
+		names = append(names, chain.Name)
+	}
+	want := []string{"polygon", "ethereum", "zora", ChainNear, ChainTezos}
+	if !reflect.DeepEqual(names, want) {
+		t.Errorf("buildChains = %v, want %v", names, want)
+	}
+}
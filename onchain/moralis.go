@@ -0,0 +1,284 @@
+package onchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	moralisApiUrl  = "https://deep-index.moralis.io/api/v2.2/"
+	maxOwnedPages  = 10 //100 NFTs per page
+	ownedPageLimit = 100
+)
+
+// chain ids Moralis expects
+var moralisChains = map[string]string{
+	ChainEthereum: "eth",
+	ChainPolygon:  "polygon",
+	"bsc":         "bsc",
+	"arbitrum":    "arbitrum",
+	"base":        "base",
+	"optimism":    "optimism",
+	"avalanche":   "avalanche",
+	"xdai":        "gnosis",
+}
+
+// MoralisProvider uses the Moralis Web3 Data API, it answers every kind of call
+type MoralisProvider struct {
+	apiKey string
+}
+
+// NewMoralisProviderFromEnv reads MORALIS_NFT_API_KEY
+func NewMoralisProviderFromEnv() (*MoralisProvider, error) {
+	apiKey := os.Getenv("MORALIS_NFT_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("MORALIS_NFT_API_KEY not set")
+	}
+	return &MoralisProvider{apiKey: apiKey}, nil
+}
+
+func (p *MoralisProvider) Name() string { return "moralis" }
+
+func (p *MoralisProvider) Supports(chain string) bool {
+	_, ok := moralisChains[chain]
+	return ok
+}
+
+func (p *MoralisProvider) get(path string, query url.Values, result interface{}) error {
+	req, _ := http.NewRequest("GET", moralisApiUrl+path+"?"+query.Encode(), nil)
+	req.Header.Add("accept", "application/json")
+	req.Header.Add("X-API-Key", p.apiKey)
+
+	resp, err := HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("moralis returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, result)
+}
+
+type moralisNftPage struct {
+	Cursor string `json:"cursor"`
+	Result []struct {
+		TokenAddress string `json:"token_address"`
+		TokenId      string `json:"token_id"`
+		Amount       string `json:"amount"`
+		ContractType string `json:"contract_type"`
+		Name         string `json:"name"`
+		Symbol       string `json:"symbol"`
+	} `json:"result"`
+}
+
+func (p *MoralisProvider) OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error) {
+	query := url.Values{}
+	query.Set("chain", moralisChains[chain])
+	query.Set("format", "decimal")
+	query.Set("normalizeMetadata", "false")
+	query.Set("limit", strconv.Itoa(ownedPageLimit))
+	for i, contract := range contracts {
+		query.Set("token_addresses["+strconv.Itoa(i)+"]", normalizeAddress(contract))
+	}
+
+	nfts := []Nft{}
+	for page := 0; page < maxOwnedPages; page++ {
+		var result moralisNftPage
+		if err := p.get(normalizeAddress(wallet)+"/nft", query, &result); err != nil {
+			return nil, err
+		}
+		for _, nft := range result.Result {
+			nfts = append(nfts, Nft{
+				Chain:    chain,
+				Contract: normalizeAddress(nft.TokenAddress),
+				Tokenid:  nft.TokenId,
+				Amount:   nft.Amount,
+				Standard: strings.ToLower(nft.ContractType),
+				Name:     nft.Name,
+				Symbol:   nft.Symbol,
+			})
+		}
+		if result.Cursor == "" {
+			break
+		}
+		query.Set("cursor", result.Cursor)
+	}
+	return nfts, nil
+}
+
+func (p *MoralisProvider) NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error) {
+	nfts, err := p.OwnedNfts(chain, wallet, []string{contract})
+	if err != nil {
+		return nil, err
+	}
+	return sumNfts(nfts, contract, tokenId), nil
+}
+
+func (p *MoralisProvider) TokenBalance(chain string, contract string, wallet string) (TokenBalance, error) {
+	query := url.Values{}
+	query.Set("chain", moralisChains[chain])
+	query.Set("token_addresses[0]", normalizeAddress(contract))
+	var result []struct {
+		TokenAddress string `json:"token_address"`
+		Balance      string `json:"balance"`
+		Decimals     int    `json:"decimals"`
+	}
+	if err := p.get(normalizeAddress(wallet)+"/erc20", query, &result); err != nil {
+		return TokenBalance{}, err
+	}
+
+	for _, token := range result {
+		if !strings.EqualFold(token.TokenAddress, contract) {
+			continue
+		}
+		balance, ok := new(big.Int).SetString(token.Balance, 10)
+		if !ok {
+			return TokenBalance{}, fmt.Errorf("moralis returned a bad balance %q", token.Balance)
+		}
+		return TokenBalance{Contract: normalizeAddress(contract), Balance: balance, Decimals: token.Decimals}, nil
+	}
+	return TokenBalance{Contract: normalizeAddress(contract), Balance: big.NewInt(0)}, nil
+}
+
+func (p *MoralisProvider) ResolveName(name string) (string, error) {
+	var result struct {
+		Address string `json:"address"`
+	}
+	if err := p.get("resolve/ens/"+url.PathEscape(name), url.Values{}, &result); err != nil {
+		return "", err
+	}
+	if result.Address == "" {
+		return "", ErrNotFound
+	}
+	return normalizeAddress(result.Address), nil
+}
+
+func (p *MoralisProvider) LookupAddress(address string) (string, error) {
+	var result struct {
+		Name string `json:"name"`
+	}
+	if err := p.get("resolve/"+normalizeAddress(address)+"/reverse", url.Values{}, &result); err != nil {
+		return "", err
+	}
+	if result.Name == "" {
+		return "", ErrNotFound
+	}
+	return result.Name, nil
+}
+
+func (p *MoralisProvider) Collection(chain string, contract string) (Collection, error) {
+	query := url.Values{}
+	query.Set("chain", moralisChains[chain])
+	var result struct {
+		TokenAddress string `json:"token_address"`
+		Name         string `json:"name"`
+		Symbol       string `json:"symbol"`
+		ContractType string `json:"contract_type"`
+	}
+	if err := p.get("nft/"+normalizeAddress(contract)+"/metadata", query, &result); err != nil {
+		return Collection{}, err
+	}
+	if result.TokenAddress == "" {
+		return Collection{}, ErrNotFound
+	}
+	return Collection{
+		Chain:    chain,
+		Contract: normalizeAddress(result.TokenAddress),
+		Name:     result.Name,
+		Symbol:   result.Symbol,
+		Standard: strings.ToLower(result.ContractType),
+	}, nil
+}
+
+func (p *MoralisProvider) TokenOwners(chain string, contract string, tokenId string) ([]string, error) {
+	query := url.Values{}
+	query.Set("chain", moralisChains[chain])
+	query.Set("format", "decimal")
+	var result struct {
+		Result []struct {
+			OwnerOf string `json:"owner_of"`
+		} `json:"result"`
+	}
+	if err := p.get("nft/"+normalizeAddress(contract)+"/"+url.PathEscape(tokenId)+"/owners", query, &result); err != nil {
+		return nil, err
+	}
+	owners := []string{}
+	for _, owner := range result.Result {
+		owners = append(owners, normalizeAddress(owner.OwnerOf))
+	}
+	return owners, nil
+}
+
+func (p *MoralisProvider) TokenTraits(chain string, contract string, tokenId string) (map[string]string, error) {
+	query := url.Values{}
+	query.Set("chain", moralisChains[chain])
+	query.Set("format", "decimal")
+	query.Set("normalizeMetadata", "true")
+	var result struct {
+		TokenAddress       string `json:"token_address"`
+		NormalizedMetadata struct {
+			Attributes []tokenAttribute `json:"attributes"`
+		} `json:"normalized_metadata"`
+	}
+	if err := p.get("nft/"+normalizeAddress(contract)+"/"+url.PathEscape(tokenId), query, &result); err != nil {
+		return nil, err
+	}
+	if result.TokenAddress == "" {
+		return nil, ErrNotFound
+	}
+	return traitsFromAttributes(result.NormalizedMetadata.Attributes), nil
+}
+
+// tokenAttribute is the OpenSea metadata standard attribute, values can be strings or numbers
+type tokenAttribute struct {
+	TraitType string      `json:"trait_type"`
+	Value     interface{} `json:"value"`
+}
+
+// traitsFromAttributes maps lower cased trait types to their values, numbers are formatted without exponent
+func traitsFromAttributes(attributes []tokenAttribute) map[string]string {
+	traits := map[string]string{}
+	for _, attribute := range attributes {
+		if attribute.TraitType == "" || attribute.Value == nil {
+			continue
+		}
+		value := fmt.Sprint(attribute.Value)
+		if number, ok := attribute.Value.(float64); ok {
+			value = strconv.FormatFloat(number, 'f', -1, 64)
+		}
+		traits[strings.ToLower(strings.TrimSpace(attribute.TraitType))] = value
+	}
+	return traits
+}
+
+// sumNfts adds up the amounts of one contract (and token id when set), amounts that don't parse count as 1
+func sumNfts(nfts []Nft, contract string, tokenId string) *big.Int {
+	total := big.NewInt(0)
+	for _, nft := range nfts {
+		if !strings.EqualFold(nft.Contract, contract) || (tokenId != "" && nft.Tokenid != tokenId) {
+			continue
+		}
+		amount, ok := new(big.Int).SetString(nft.Amount, 10)
+		if !ok || amount.Sign() < 0 {
+			amount = big.NewInt(1)
+		}
+		total.Add(total, amount)
+	}
+	return total
+}
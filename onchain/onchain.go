@@ -0,0 +1,128 @@
+// Package onchain reads chain data (NFT ownership, token holders and traits, token balances, names, collection metadata) through
+// interchangeable providers: Moralis, Alchemy, direct JSON-RPC, Pagoda (NEAR), TzKT (Tezos) and a fixture fake.
+// Callers use the package functions, which go through the configured providers in order and fail over on errors.
+package onchain
+
+import (
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnsupported is returned by a provider that can't answer this call or chain, the next provider is tried
+	ErrUnsupported = errors.New("not supported by this provider")
+	// ErrNotFound means the provider answered and the thing does not exist (unknown name, not a contract), no failover
+	ErrNotFound = errors.New("not found")
+	// ErrNoProvider is returned when no configured provider could answer
+	ErrNoProvider = errors.New("no chain data provider available")
+)
+
+// HttpClient is shared by the providers, the timeout keeps a slow provider from hanging chat requests
+var HttpClient = &http.Client{Timeout: 10 * time.Second}
+
+// chain names as stored in bookmarks and access conditions, each provider maps them to its own ids
+const (
+	ChainEthereum = "ethereum"
+	ChainPolygon  = "polygon"
+	ChainNear     = "near"
+	ChainTezos    = "tezos"
+)
+
+// token standards
+const (
+	StandardErc721  = "erc721"
+	StandardErc1155 = "erc1155"
+	StandardErc20   = "erc20"
+)
+
+// Nft is one token (or ERC-1155 amount) held by a wallet
+type Nft struct {
+	Chain    string `json:"chain"`
+	Contract string `json:"contract"` //lower case
+	Tokenid  string `json:"token_id"`
+	Amount   string `json:"amount"` //1 for ERC-721
+	Standard string `json:"standard,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Symbol   string `json:"symbol,omitempty"`
+}
+
+// TokenBalance is a fungible token balance in the token's smallest unit
+type TokenBalance struct {
+	Contract string   `json:"contract"`
+	Balance  *big.Int `json:"balance"`
+	Decimals int      `json:"decimals"`
+}
+
+// Units is the balance in whole tokens (decimals applied)
+func (b TokenBalance) Units() *big.Float {
+	if b.Balance == nil {
+		return big.NewFloat(0)
+	}
+	scale := new(big.Float).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(b.Decimals)), nil))
+	return new(big.Float).Quo(new(big.Float).SetInt(b.Balance), scale)
+}
+
+// Collection is contract level metadata
+type Collection struct {
+	Chain       string `json:"chain"`
+	Contract    string `json:"contract"`
+	Name        string `json:"name"`
+	Symbol      string `json:"symbol"`
+	Standard    string `json:"standard,omitempty"`
+	Totalsupply string `json:"total_supply,omitempty"`
+}
+
+// Provider is implemented by every chain data source, plus one or more of the interfaces below
+type Provider interface {
+	Name() string
+	Supports(chain string) bool
+}
+
+// OwnershipProvider answers NFT holder questions
+type OwnershipProvider interface {
+	Provider
+	// NftBalance is how many tokens of the contract the wallet holds (ERC-1155 amounts added up), only tokenId when set
+	NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error)
+	// OwnedNfts lists the wallet's NFTs, only of the given contracts when any are passed
+	OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error)
+}
+
+// BalanceProvider answers fungible token balance questions
+type BalanceProvider interface {
+	Provider
+	TokenBalance(chain string, contract string, wallet string) (TokenBalance, error)
+}
+
+// NameProvider resolves ENS style names, Supports("ethereum") is checked
+type NameProvider interface {
+	Provider
+	ResolveName(name string) (string, error)
+	LookupAddress(address string) (string, error)
+}
+
+// CollectionProvider returns contract metadata, ErrNotFound when the address is not an NFT contract on the chain
+type CollectionProvider interface {
+	Provider
+	Collection(chain string, contract string) (Collection, error)
+}
+
+// TokenProvider answers questions about one token id
+type TokenProvider interface {
+	Provider
+	// TokenOwners are the wallets holding the token id (one for ERC-721, any number for ERC-1155)
+	TokenOwners(chain string, contract string, tokenId string) ([]string, error)
+	// TokenTraits are the token's metadata attributes, trait type -> value
+	TokenTraits(chain string, contract string, tokenId string) (map[string]string, error)
+}
+
+// normalizeAddress lower cases EVM addresses, other chains (NEAR, Tezos) keep their case
+func normalizeAddress(address string) string {
+	address = strings.TrimSpace(address)
+	if strings.HasPrefix(address, "0x") || strings.HasPrefix(address, "0X") {
+		return strings.ToLower(address)
+	}
+	return address
+}
@@ -0,0 +1,102 @@
+package onchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+const pagodaApiUrl = "https://near-mainnet.api.pagoda.co/eapi/v1/"
+
+// PagodaProvider answers NEAR NFT ownership through the Pagoda Enhanced API
+type PagodaProvider struct {
+	apiKey string
+}
+
+// NewPagodaProviderFromEnv reads PAGODA_NFT_API_KEY
+func NewPagodaProviderFromEnv() (*PagodaProvider, error) {
+	apiKey := os.Getenv("PAGODA_NFT_API_KEY")
+	if apiKey == "" {
+		return nil, fmt.Errorf("PAGODA_NFT_API_KEY not set")
+	}
+	return &PagodaProvider{apiKey: apiKey}, nil
+}
+
+func (p *PagodaProvider) Name() string { return "pagoda" }
+
+func (p *PagodaProvider) Supports(chain string) bool { return chain == ChainNear }
+
+type pagodaNfts struct {
+	Nfts []struct {
+		TokenId        string `json:"token_id"`
+		OwnerAccountId string `json:"owner_account_id"`
+	} `json:"nfts"`
+	ContractMetadata struct {
+		Name   string `json:"name"`
+		Symbol string `json:"symbol"`
+	} `json:"contract_metadata"`
+}
+
+func (p *PagodaProvider) get(path string, result interface{}) error {
+	req, _ := http.NewRequest("GET", pagodaApiUrl+path, nil)
+	req.Header.Add("accept", "application/json")
+	req.Header.Add("X-API-Key", p.apiKey)
+
+	resp, err := HttpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pagoda returned %d", resp.StatusCode)
+	}
+	return json.Unmarshal(body, result)
+}
+
+func (p *PagodaProvider) OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error) {
+	if len(contracts) == 0 {
+		return nil, ErrUnsupported //the per contract endpoint is the only one we use
+	}
+	nfts := []Nft{}
+	for _, contract := range contracts {
+		var result pagodaNfts
+		err := p.get("accounts/"+url.PathEscape(wallet)+"/NFT/"+url.PathEscape(contract), &result)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, nft := range result.Nfts {
+			nfts = append(nfts, Nft{
+				Chain:    ChainNear,
+				Contract: contract,
+				Tokenid:  nft.TokenId,
+				Amount:   "1",
+				Name:     result.ContractMetadata.Name,
+				Symbol:   result.ContractMetadata.Symbol,
+			})
+		}
+	}
+	return nfts, nil
+}
+
+func (p *PagodaProvider) NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error) {
+	nfts, err := p.OwnedNfts(chain, wallet, []string{contract})
+	if err != nil {
+		return nil, err
+	}
+	return sumNfts(nfts, contract, tokenId), nil
+}
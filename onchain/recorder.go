@@ -0,0 +1,168 @@
+package onchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"strings"
+	"sync"
+)
+
+// Recorder passes every call through to its provider and saves the answers as Fixtures,
+// so a session against the real APIs can be replayed offline with the FakeProvider
+type Recorder struct {
+	inner AllProvider
+	path  string
+
+	mutex    sync.Mutex
+	fixtures Fixtures
+}
+
+// NewRecorder records into path, answers already in the file are kept
+func NewRecorder(inner AllProvider, path string) *Recorder {
+	fixtures, err := LoadFixtures(path)
+	if err != nil {
+		fixtures = Fixtures{Names: map[string]string{}, Reverse: map[string]string{}}
+	}
+	return &Recorder{inner: inner, path: path, fixtures: fixtures}
+}
+
+func (r *Recorder) Name() string { return "recorder(" + r.inner.Name() + ")" }
+
+func (r *Recorder) Supports(chain string) bool { return r.inner.Supports(chain) }
+
+// save writes the fixtures, called with the mutex held
+func (r *Recorder) save() {
+	data, err := json.MarshalIndent(r.fixtures, "", "  ")
+	if err != nil {
+		fmt.Println("onchain - could not encode fixtures: ", err)
+		return
+	}
+	if err := ioutil.WriteFile(r.path, data, 0644); err != nil {
+		fmt.Println("onchain - could not write fixtures: ", err)
+	}
+}
+
+func (r *Recorder) addNfts(wallet string, nfts []Nft) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, nft := range nfts {
+		known := false
+		for _, fixture := range r.fixtures.Nfts {
+			if strings.EqualFold(fixture.Wallet, wallet) && fixture.Chain == nft.Chain &&
+				strings.EqualFold(fixture.Contract, nft.Contract) && fixture.Tokenid == nft.Tokenid {
+				known = true
+				break
+			}
+		}
+		if !known {
+			r.fixtures.Nfts = append(r.fixtures.Nfts, FixtureNft{Wallet: normalizeAddress(wallet), Nft: nft})
+		}
+	}
+	r.save()
+}
+
+func (r *Recorder) OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error) {
+	nfts, err := r.inner.OwnedNfts(chain, wallet, contracts)
+	if err == nil {
+		r.addNfts(wallet, nfts)
+	}
+	return nfts, err
+}
+
+// NftBalance can only be replayed from token level data, so the wallet's NFTs of the contract are recorded instead
+func (r *Recorder) NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error) {
+	balance, err := r.inner.NftBalance(chain, contract, wallet, tokenId)
+	if err == nil && balance.Sign() > 0 {
+		if nfts, ownedErr := r.inner.OwnedNfts(chain, wallet, []string{contract}); ownedErr == nil {
+			r.addNfts(wallet, nfts)
+		}
+	}
+	return balance, err
+}
+
+func (r *Recorder) TokenBalance(chain string, contract string, wallet string) (TokenBalance, error) {
+	balance, err := r.inner.TokenBalance(chain, contract, wallet)
+	if err != nil || balance.Balance == nil || balance.Balance.Sign() == 0 {
+		return balance, err
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	fixture := FixtureToken{Chain: chain, Contract: normalizeAddress(contract), Wallet: normalizeAddress(wallet), Balance: balance.Balance.String(), Decimals: balance.Decimals}
+	for i, known := range r.fixtures.Tokens {
+		if known.Chain == chain && strings.EqualFold(known.Contract, contract) && strings.EqualFold(known.Wallet, wallet) {
+			r.fixtures.Tokens[i] = fixture
+			r.save()
+			return balance, err
+		}
+	}
+	r.fixtures.Tokens = append(r.fixtures.Tokens, fixture)
+	r.save()
+	return balance, err
+}
+
+func (r *Recorder) ResolveName(name string) (string, error) {
+	address, err := r.inner.ResolveName(name)
+	if err == nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.fixtures.Names[strings.ToLower(name)] = address
+		r.save()
+	}
+	return address, err
+}
+
+func (r *Recorder) LookupAddress(address string) (string, error) {
+	name, err := r.inner.LookupAddress(address)
+	if err == nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.fixtures.Reverse[normalizeAddress(address)] = name
+		r.save()
+	}
+	return name, err
+}
+
+func (r *Recorder) Collection(chain string, contract string) (Collection, error) {
+	collection, err := r.inner.Collection(chain, contract)
+	if err == nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		for _, known := range r.fixtures.Collections {
+			if known.Chain == chain && strings.EqualFold(known.Contract, contract) {
+				return collection, err
+			}
+		}
+		r.fixtures.Collections = append(r.fixtures.Collections, collection)
+		r.save()
+	}
+	return collection, err
+}
+
+// TokenOwners are recorded as the owners' NFTs, so the FakeProvider answers holder checks for them too
+func (r *Recorder) TokenOwners(chain string, contract string, tokenId string) ([]string, error) {
+	owners, err := r.inner.TokenOwners(chain, contract, tokenId)
+	if err == nil {
+		for _, owner := range owners {
+			r.addNfts(owner, []Nft{{Chain: chain, Contract: normalizeAddress(contract), Tokenid: tokenId, Amount: "1"}})
+		}
+	}
+	return owners, err
+}
+
+func (r *Recorder) TokenTraits(chain string, contract string, tokenId string) (map[string]string, error) {
+	traits, err := r.inner.TokenTraits(chain, contract, tokenId)
+	if err == nil {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		for _, known := range r.fixtures.Traits {
+			if known.Chain == chain && strings.EqualFold(known.Contract, contract) && known.Tokenid == tokenId {
+				return traits, err
+			}
+		}
+		r.fixtures.Traits = append(r.fixtures.Traits, FixtureTraits{Chain: chain, Contract: normalizeAddress(contract), Tokenid: tokenId, Traits: traits})
+		r.save()
+	}
+	return traits, err
+}
@@ -0,0 +1,332 @@
+package onchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	ens "github.com/wealdtech/go-ens/v3"
+)
+
+// the few ERC-20/721/1155/165 methods the RPC provider calls
+const tokenAbiJson = `[
+{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"owner","type":"address"}],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"ownerOf","stateMutability":"view","inputs":[{"name":"tokenId","type":"uint256"}],"outputs":[{"name":"","type":"address"}]},
+{"type":"function","name":"decimals","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint8"}]},
+{"type":"function","name":"name","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+{"type":"function","name":"symbol","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"string"}]},
+{"type":"function","name":"totalSupply","stateMutability":"view","inputs":[],"outputs":[{"name":"","type":"uint256"}]},
+{"type":"function","name":"supportsInterface","stateMutability":"view","inputs":[{"name":"interfaceId","type":"bytes4"}],"outputs":[{"name":"","type":"bool"}]}
+]`
+
+const erc1155AbiJson = `[
+{"type":"function","name":"balanceOf","stateMutability":"view","inputs":[{"name":"account","type":"address"},{"name":"id","type":"uint256"}],"outputs":[{"name":"","type":"uint256"}]}
+]`
+
+// ERC-165 interface ids
+var (
+	interfaceErc721  = [4]byte{0x80, 0xac, 0x58, 0xcd}
+	interfaceErc1155 = [4]byte{0xd9, 0xb6, 0x7a, 0x26}
+)
+
+var (
+	tokenAbi   abi.ABI
+	erc1155Abi abi.ABI
+)
+
+func init() {
+	var err error
+	if tokenAbi, err = abi.JSON(strings.NewReader(tokenAbiJson)); err != nil {
+		panic(err)
+	}
+	if erc1155Abi, err = abi.JSON(strings.NewReader(erc1155AbiJson)); err != nil {
+		panic(err)
+	}
+}
+
+// RpcProvider calls the token contracts directly over JSON-RPC, it needs no indexer so it is the
+// last resort when the APIs are down. It can't list a wallet's NFTs.
+type RpcProvider struct {
+	urls map[string]string //chain -> RPC url
+
+	mutex   sync.Mutex
+	clients map[string]*ethclient.Client
+}
+
+// rpcUrlsFromEnv reads ONCHAIN_RPC_<CHAIN> (ie ONCHAIN_RPC_POLYGON), Ethereum falls back to Infura with INFURA_V3
+func rpcUrlsFromEnv() map[string]string {
+	urls := map[string]string{}
+	for _, variable := range os.Environ() {
+		pair := strings.SplitN(variable, "=", 2)
+		if len(pair) == 2 && strings.HasPrefix(pair[0], "ONCHAIN_RPC_") && pair[1] != "" {
+			urls[strings.ToLower(strings.TrimPrefix(pair[0], "ONCHAIN_RPC_"))] = pair[1]
+		}
+	}
+	if _, ok := urls[ChainEthereum]; !ok && os.Getenv("INFURA_V3") != "" {
+		urls[ChainEthereum] = "https://mainnet.infura.io/v3/" + os.Getenv("INFURA_V3")
+	}
+	return urls
+}
+
+// RpcUrl is the JSON-RPC endpoint configured for the chain, for code that needs a raw client (ie the Transfer indexer)
+func RpcUrl(chain string) (string, bool) {
+	rpcUrl, ok := rpcUrlsFromEnv()[chain]
+	return rpcUrl, ok
+}
+
+// NewRpcProviderFromEnv uses the ONCHAIN_RPC_<CHAIN> endpoints, see rpcUrlsFromEnv
+func NewRpcProviderFromEnv() (*RpcProvider, error) {
+	urls := rpcUrlsFromEnv()
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no ONCHAIN_RPC_<CHAIN> or INFURA_V3 set")
+	}
+	return NewRpcProvider(urls), nil
+}
+
+func NewRpcProvider(urls map[string]string) *RpcProvider {
+	return &RpcProvider{urls: urls, clients: map[string]*ethclient.Client{}}
+}
+
+func (p *RpcProvider) Name() string { return "rpc" }
+
+func (p *RpcProvider) Supports(chain string) bool {
+	_, ok := p.urls[chain]
+	return ok
+}
+
+// client dials the chain on first use and keeps the connection
+func (p *RpcProvider) client(chain string) (*ethclient.Client, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if client, ok := p.clients[chain]; ok {
+		return client, nil
+	}
+	rpcUrl, ok := p.urls[chain]
+	if !ok {
+		return nil, ErrUnsupported
+	}
+	client, err := ethclient.Dial(rpcUrl)
+	if err != nil {
+		return nil, err
+	}
+	p.clients[chain] = client
+	return client, nil
+}
+
+// call runs a read only contract method and unpacks its single return value
+func (p *RpcProvider) call(chain string, contract string, contractAbi abi.ABI, method string, args ...interface{}) (interface{}, error) {
+	if !common.IsHexAddress(contract) {
+		return nil, ErrNotFound
+	}
+	client, err := p.client(chain)
+	if err != nil {
+		return nil, err
+	}
+	data, err := contractAbi.Pack(method, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), HttpClient.Timeout)
+	defer cancel()
+	to := common.HexToAddress(contract)
+	output, err := client.CallContract(ctx, ethereum.CallMsg{To: &to, Data: data}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(output) == 0 {
+		return nil, ErrNotFound //no code at the address or the method doesn't exist
+	}
+	values, err := contractAbi.Unpack(method, output)
+	if err != nil {
+		return nil, err
+	}
+	return values[0], nil
+}
+
+// supportsInterface is false for contracts without ERC-165, only connection errors are returned
+func (p *RpcProvider) supportsInterface(chain string, contract string, id [4]byte) (bool, error) {
+	value, err := p.call(chain, contract, tokenAbi, "supportsInterface", id)
+	if errors.Is(err, ErrNotFound) || (err != nil && strings.Contains(err.Error(), "execution reverted")) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	supported, _ := value.(bool)
+	return supported, nil
+}
+
+func (p *RpcProvider) NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error) {
+	if !common.IsHexAddress(wallet) {
+		return big.NewInt(0), nil
+	}
+	owner := common.HexToAddress(wallet)
+	isErc1155, err := p.supportsInterface(chain, contract, interfaceErc1155)
+	if err != nil {
+		return nil, err
+	}
+
+	if tokenId == "" {
+		if isErc1155 {
+			return nil, ErrUnsupported //needs an indexer to add up every id
+		}
+		value, err := p.call(chain, contract, tokenAbi, "balanceOf", owner)
+		if err != nil {
+			return nil, err
+		}
+		return value.(*big.Int), nil
+	}
+
+	id, ok := new(big.Int).SetString(tokenId, 10)
+	if !ok {
+		return nil, fmt.Errorf("bad token id %q", tokenId)
+	}
+	if isErc1155 {
+		value, err := p.call(chain, contract, erc1155Abi, "balanceOf", owner, id)
+		if err != nil {
+			return nil, err
+		}
+		return value.(*big.Int), nil
+	}
+	value, err := p.call(chain, contract, tokenAbi, "ownerOf", id)
+	if err != nil && strings.Contains(err.Error(), "execution reverted") {
+		return big.NewInt(0), nil //burnt or never minted
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value.(common.Address) == owner {
+		return big.NewInt(1), nil
+	}
+	return big.NewInt(0), nil
+}
+
+// TokenOwners uses ownerOf, ERC-1155 holders can't be listed without an indexer
+func (p *RpcProvider) TokenOwners(chain string, contract string, tokenId string) ([]string, error) {
+	isErc1155, err := p.supportsInterface(chain, contract, interfaceErc1155)
+	if err != nil {
+		return nil, err
+	}
+	if isErc1155 {
+		return nil, ErrUnsupported
+	}
+	id, ok := new(big.Int).SetString(tokenId, 10)
+	if !ok {
+		return nil, fmt.Errorf("bad token id %q", tokenId)
+	}
+	value, err := p.call(chain, contract, tokenAbi, "ownerOf", id)
+	if err != nil && strings.Contains(err.Error(), "execution reverted") {
+		return []string{}, nil //burnt or never minted
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []string{normalizeAddress(value.(common.Address).Hex())}, nil
+}
+
+// TokenTraits needs the token's metadata JSON, which is off chain
+func (p *RpcProvider) TokenTraits(chain string, contract string, tokenId string) (map[string]string, error) {
+	return nil, ErrUnsupported
+}
+
+func (p *RpcProvider) OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error) {
+	return nil, ErrUnsupported
+}
+
+func (p *RpcProvider) TokenBalance(chain string, contract string, wallet string) (TokenBalance, error) {
+	if !common.IsHexAddress(wallet) {
+		return TokenBalance{Contract: normalizeAddress(contract), Balance: big.NewInt(0)}, nil
+	}
+	value, err := p.call(chain, contract, tokenAbi, "balanceOf", common.HexToAddress(wallet))
+	if err != nil {
+		return TokenBalance{}, err
+	}
+	decimals, err := p.call(chain, contract, tokenAbi, "decimals")
+	if err != nil {
+		return TokenBalance{}, err
+	}
+	return TokenBalance{Contract: normalizeAddress(contract), Balance: value.(*big.Int), Decimals: int(decimals.(uint8))}, nil
+}
+
+// ens errors for names that don't resolve, anything else is a connection problem
+var ensNotFound = []string{"unregistered name", "no resolver", "no address", "no resolution", "bad name"}
+
+func ensError(err error) error {
+	for _, message := range ensNotFound {
+		if strings.Contains(err.Error(), message) {
+			return ErrNotFound
+		}
+	}
+	return err
+}
+
+func (p *RpcProvider) ResolveName(name string) (string, error) {
+	client, err := p.client(ChainEthereum)
+	if err != nil {
+		return "", err
+	}
+	address, err := ens.Resolve(client, name)
+	if err != nil {
+		return "", ensError(err)
+	}
+	return normalizeAddress(address.Hex()), nil
+}
+
+func (p *RpcProvider) LookupAddress(address string) (string, error) {
+	if !common.IsHexAddress(address) {
+		return "", ErrNotFound
+	}
+	client, err := p.client(ChainEthereum)
+	if err != nil {
+		return "", err
+	}
+	name, err := ens.ReverseResolve(client, common.HexToAddress(address))
+	if err != nil {
+		return "", ensError(err)
+	}
+	return name, nil
+}
+
+func (p *RpcProvider) Collection(chain string, contract string) (Collection, error) {
+	standard := ""
+	for _, candidate := range []struct {
+		id       [4]byte
+		standard string
+	}{{interfaceErc721, StandardErc721}, {interfaceErc1155, StandardErc1155}} {
+		supported, err := p.supportsInterface(chain, contract, candidate.id)
+		if err != nil {
+			return Collection{}, err
+		}
+		if supported {
+			standard = candidate.standard
+			break
+		}
+	}
+	if standard == "" {
+		return Collection{}, ErrNotFound
+	}
+
+	collection := Collection{Chain: chain, Contract: normalizeAddress(contract), Standard: standard}
+	//name, symbol and totalSupply are optional in both standards
+	if value, err := p.call(chain, contract, tokenAbi, "name"); err == nil {
+		collection.Name, _ = value.(string)
+	}
+	if value, err := p.call(chain, contract, tokenAbi, "symbol"); err == nil {
+		collection.Symbol, _ = value.(string)
+	}
+	if value, err := p.call(chain, contract, tokenAbi, "totalSupply"); err == nil {
+		if supply, ok := value.(*big.Int); ok {
+			collection.Totalsupply = supply.String()
+		}
+	}
+	return collection, nil
+}
@@ -0,0 +1,125 @@
+package onchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const tzktApiUrl = "https://api.tzkt.io/v1/"
+
+// TzktProvider answers Tezos token questions through the public TzKT API, it needs no key
+type TzktProvider struct{}
+
+func NewTzktProvider() *TzktProvider {
+	return &TzktProvider{}
+}
+
+func (p *TzktProvider) Name() string { return "tzkt" }
+
+func (p *TzktProvider) Supports(chain string) bool { return chain == ChainTezos }
+
+type tzktBalance struct {
+	Token struct {
+		Contract struct {
+			Address string `json:"address"`
+		} `json:"contract"`
+		TokenId  string `json:"tokenId"`
+		Standard string `json:"standard"`
+		Metadata struct {
+			Name     string `json:"name"`
+			Symbol   string `json:"symbol"`
+			Decimals string `json:"decimals"`
+		} `json:"metadata"`
+	} `json:"token"`
+	Balance string `json:"balance"`
+}
+
+func (p *TzktProvider) balances(query url.Values) ([]tzktBalance, error) {
+	req, _ := http.NewRequest("GET", tzktApiUrl+"tokens/balances?"+query.Encode(), nil)
+	req.Header.Add("accept", "application/json")
+
+	resp, err := HttpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("tzkt returned %d", resp.StatusCode)
+	}
+	var result []tzktBalance
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func (p *TzktProvider) OwnedNfts(chain string, wallet string, contracts []string) ([]Nft, error) {
+	query := url.Values{}
+	query.Set("account", wallet)
+	query.Set("balance.gt", "0")
+	query.Set("token.standard", "fa2")
+	query.Set("limit", strconv.Itoa(ownedPageLimit*maxOwnedPages))
+	if len(contracts) == 1 {
+		query.Set("token.contract", contracts[0])
+	} else if len(contracts) > 1 {
+		query.Set("token.contract.in", strings.Join(contracts, ","))
+	}
+
+	balances, err := p.balances(query)
+	if err != nil {
+		return nil, err
+	}
+	nfts := []Nft{}
+	for _, balance := range balances {
+		nfts = append(nfts, Nft{
+			Chain:    ChainTezos,
+			Contract: balance.Token.Contract.Address,
+			Tokenid:  balance.Token.TokenId,
+			Amount:   balance.Balance,
+			Standard: balance.Token.Standard,
+			Name:     balance.Token.Metadata.Name,
+			Symbol:   balance.Token.Metadata.Symbol,
+		})
+	}
+	return nfts, nil
+}
+
+func (p *TzktProvider) NftBalance(chain string, contract string, wallet string, tokenId string) (*big.Int, error) {
+	nfts, err := p.OwnedNfts(chain, wallet, []string{contract})
+	if err != nil {
+		return nil, err
+	}
+	return sumNfts(nfts, contract, tokenId), nil
+}
+
+func (p *TzktProvider) TokenBalance(chain string, contract string, wallet string) (TokenBalance, error) {
+	query := url.Values{}
+	query.Set("account", wallet)
+	query.Set("token.contract", contract)
+	balances, err := p.balances(query)
+	if err != nil {
+		return TokenBalance{}, err
+	}
+
+	total := TokenBalance{Contract: contract, Balance: big.NewInt(0)}
+	for _, balance := range balances {
+		amount, ok := new(big.Int).SetString(balance.Balance, 10)
+		if !ok {
+			return TokenBalance{}, fmt.Errorf("tzkt returned a bad balance %q", balance.Balance)
+		}
+		total.Balance.Add(total.Balance, amount)
+		total.Decimals, _ = strconv.Atoi(balance.Token.Metadata.Decimals)
+	}
+	return total, nil
+}
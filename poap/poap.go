@@ -0,0 +1,248 @@
+// Package poap is a typed client for the POAP API (https://documentation.poap.tech), POAP chats and gates are keyed
+// by event id ("poap_" + id in nftaddr).
+package poap
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"rest-go-demo/onchain"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultBaseUrl = "https://api.poap.tech"
+	// AddressPrefix is how POAP events are stored in nftaddr (bookmarks, group chats, access conditions)
+	AddressPrefix = "poap_"
+)
+
+var (
+	// ErrNotFound means the API answered and the event (or the wallet's POAP of it) doesn't exist
+	ErrNotFound = errors.New("not found")
+	// ErrUnsupported is returned for wallets that can't hold POAPs (Tezos, NEAR...)
+	ErrUnsupported = errors.New("wallet can't hold POAPs")
+)
+
+// Event is a POAP event (the drop), the same shape the API returns
+type Event struct {
+	Id          int    `json:"id"`
+	FancyId     string `json:"fancy_id"`
+	Name        string `json:"name"`
+	EventUrl    string `json:"event_url"`
+	ImageUrl    string `json:"image_url"`
+	Country     string `json:"country"`
+	City        string `json:"city"`
+	Description string `json:"description"`
+	Year        int    `json:"year"`
+	StartDate   string `json:"start_date"` //DD-Mon-YYYY, ie 05-Nov-2023
+	EndDate     string `json:"end_date"`
+	ExpiryDate  string `json:"expiry_date"`
+	Supply      int    `json:"supply"`
+}
+
+// Token is one POAP held by a wallet
+type Token struct {
+	Event   Event  `json:"event"`
+	TokenId string `json:"tokenId"`
+	Owner   string `json:"owner"`
+	Chain   string `json:"chain"` //xdai or mainnet
+	Created string `json:"created"`
+}
+
+// Client calls the POAP API with an API key, requests are made with the shared onchain.HttpClient
+type Client struct {
+	BaseUrl string
+	ApiKey  string
+	Http    *http.Client
+}
+
+var defaultClient = NewClientFromEnv()
+
+// NewClientFromEnv uses POAP_API_KEY (and POAP_API_URL, for tests)
+func NewClientFromEnv() *Client {
+	baseUrl := os.Getenv("POAP_API_URL")
+	if baseUrl == "" {
+		baseUrl = defaultBaseUrl
+	}
+	return &Client{BaseUrl: strings.TrimRight(baseUrl, "/"), ApiKey: os.Getenv("POAP_API_KEY"), Http: onchain.HttpClient}
+}
+
+// Init re-reads the environment, call it after the .env file is loaded
+func Init() {
+	defaultClient = NewClientFromEnv()
+}
+
+// SetClient replaces the client the package functions use, ie with one pointing at a test server
+func SetClient(client *Client) {
+	defaultClient = client
+}
+
+// ParseEventId returns the event id of "poap_123" or "123"
+func ParseEventId(address string) (int, bool) {
+	id, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(address), AddressPrefix))
+	if err != nil || id <= 0 {
+		return 0, false
+	}
+	return id, true
+}
+
+// ChatAddress is the nftaddr of an event's chat
+func ChatAddress(eventId int) string {
+	return AddressPrefix + strconv.Itoa(eventId)
+}
+
+// canHold - POAPs are minted to EVM addresses (and ENS names), other wallet formats never hold one
+func canHold(address string) bool {
+	return strings.HasPrefix(address, "0x") || strings.HasSuffix(address, ".eth")
+}
+
+// do sends the request, 404 is ErrNotFound and any other non 2xx status an error, out may be nil
+func (c *Client) do(method string, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(encoded)
+	}
+	req, err := http.NewRequest(method, c.BaseUrl+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("X-API-Key", c.ApiKey)
+	if body != nil {
+		req.Header.Add("Content-Type", "application/json")
+	}
+
+	resp, err := c.Http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("poap %s %s: status %d", method, path, resp.StatusCode)
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.Unmarshal(responseBody, out); err != nil {
+		return fmt.Errorf("poap %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// Scan lists every POAP the wallet holds
+func (c *Client) Scan(address string) ([]Token, error) {
+	if !canHold(address) {
+		return nil, ErrUnsupported
+	}
+	tokens := []Token{}
+	err := c.do("GET", "/actions/scan/"+strings.ToLower(address), nil, &tokens)
+	if errors.Is(err, ErrNotFound) {
+		return []Token{}, nil //unknown wallets have no POAPs
+	}
+	return tokens, err
+}
+
+// TokenOfEvent is the wallet's POAP of the event, ErrNotFound when it has none
+func (c *Client) TokenOfEvent(address string, eventId int) (Token, error) {
+	if !canHold(address) {
+		return Token{}, ErrNotFound
+	}
+	var token Token
+	if err := c.do("GET", "/actions/scan/"+strings.ToLower(address)+"/"+strconv.Itoa(eventId), nil, &token); err != nil {
+		return Token{}, err
+	}
+	//the API answers some unknown combinations with 200 and an empty body
+	if token.Event.Id != eventId || token.TokenId == "" {
+		return Token{}, ErrNotFound
+	}
+	return token, nil
+}
+
+// Event gets an event's metadata
+func (c *Client) Event(eventId int) (Event, error) {
+	var event Event
+	if err := c.do("GET", "/events/id/"+strconv.Itoa(eventId), nil, &event); err != nil {
+		return Event{}, err
+	}
+	if event.Id != eventId {
+		return Event{}, ErrNotFound
+	}
+	return event, nil
+}
+
+// ValidateSecret checks an event's secret (edit) code, only the event's organisers have it
+func (c *Client) ValidateSecret(eventId int, secretCode string) (bool, error) {
+	var result struct {
+		Valid bool `json:"valid"`
+	}
+	request := map[string]interface{}{"event_id": eventId, "secret_code": secretCode}
+	err := c.do("POST", "/event/validate", request, &result)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return result.Valid, err
+}
+
+// Scan lists every POAP the wallet holds
+func Scan(address string) ([]Token, error) {
+	return defaultClient.Scan(address)
+}
+
+// HoldsEvent is true when the wallet has the event's POAP, errors are API failures (not "doesn't hold it")
+func HoldsEvent(address string, eventId int) (bool, error) {
+	_, err := defaultClient.TokenOfEvent(address, eventId)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// HoldsAnyEvent is true when the wallet has a POAP of any of the events, one call for a single event, a scan otherwise
+func HoldsAnyEvent(address string, eventIds []int) (bool, error) {
+	if len(eventIds) == 1 {
+		return HoldsEvent(address, eventIds[0])
+	}
+	tokens, err := Scan(address)
+	if errors.Is(err, ErrUnsupported) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	for _, token := range tokens {
+		for _, eventId := range eventIds {
+			if token.Event.Id == eventId {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// GetEvent gets an event's metadata
+func GetEvent(eventId int) (Event, error) {
+	return defaultClient.Event(eventId)
+}
+
+// ValidateSecret checks an event's secret (edit) code
+func ValidateSecret(eventId int, secretCode string) (bool, error) {
+	return defaultClient.ValidateSecret(eventId, secretCode)
+}
@@ -0,0 +1,141 @@
+package push
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"rest-go-demo/entity"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Apple rejects provider tokens older than an hour and throttles refreshing more often than every 20 minutes
+const apnsTokenLifetime = 50 * time.Minute
+
+// ApnsProvider sends iOS notifications with token (.p8 key) based auth over HTTP/2
+type ApnsProvider struct {
+	key      *ecdsa.PrivateKey
+	keyId    string
+	teamId   string
+	bundleId string
+	host     string
+
+	tokenMutex sync.Mutex
+	token      string
+	tokenIat   time.Time
+}
+
+// NewApnsProviderFromEnv reads APNS_KEY (.p8 PEM contents) or APNS_KEY_FILE, APNS_KEY_ID, APNS_TEAM_ID, APNS_BUNDLE_ID
+// APNS_SANDBOX=true sends to the development environment
+func NewApnsProviderFromEnv() (*ApnsProvider, error) {
+	keyPem := []byte(os.Getenv("APNS_KEY"))
+	if len(keyPem) == 0 && os.Getenv("APNS_KEY_FILE") != "" {
+		var err error
+		keyPem, err = ioutil.ReadFile(os.Getenv("APNS_KEY_FILE"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(keyPem) == 0 {
+		return nil, fmt.Errorf("APNS_KEY/APNS_KEY_FILE not set")
+	}
+	return NewApnsProvider(keyPem, os.Getenv("APNS_KEY_ID"), os.Getenv("APNS_TEAM_ID"), os.Getenv("APNS_BUNDLE_ID"),
+		strings.EqualFold(os.Getenv("APNS_SANDBOX"), "true"))
+}
+
+func NewApnsProvider(keyPem []byte, keyId string, teamId string, bundleId string, sandbox bool) (*ApnsProvider, error) {
+	if keyId == "" || teamId == "" || bundleId == "" {
+		return nil, fmt.Errorf("APNS_KEY_ID, APNS_TEAM_ID and APNS_BUNDLE_ID are required")
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(keyPem)
+	if err != nil {
+		return nil, fmt.Errorf("invalid APNs key: %v", err)
+	}
+	host := "https://api.push.apple.com"
+	if sandbox {
+		host = "https://api.sandbox.push.apple.com"
+	}
+	return &ApnsProvider{key: key, keyId: keyId, teamId: teamId, bundleId: bundleId, host: host}, nil
+}
+
+func (p *ApnsProvider) Send(device entity.Pushdevice, notification Notification) error {
+	providerToken, err := p.getProviderToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": notification.Title,
+				"body":  notification.Body,
+			},
+			"sound": "default",
+		},
+	}
+	for key, value := range notification.Data {
+		if key != "aps" {
+			payload[key] = value
+		}
+	}
+	if notification.Url != "" {
+		payload["url"] = notification.Url
+	}
+	body, _ := json.Marshal(payload)
+
+	req, err := http.NewRequest("POST", p.host+"/3/device/"+device.Token, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "bearer "+providerToken)
+	req.Header.Set("apns-topic", p.bundleId)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+
+	//the default transport negotiates HTTP/2 over TLS, which APNs requires
+	res, err := pushClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	resBody, _ := ioutil.ReadAll(res.Body)
+
+	if res.StatusCode == http.StatusGone || strings.Contains(string(resBody), "BadDeviceToken") ||
+		strings.Contains(string(resBody), "Unregistered") {
+		return ErrDeviceGone
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("apns status %d: %s", res.StatusCode, string(resBody))
+	}
+	return nil
+}
+
+func (p *ApnsProvider) getProviderToken() (string, error) {
+	p.tokenMutex.Lock()
+	defer p.tokenMutex.Unlock()
+	if p.token != "" && time.Since(p.tokenIat) < apnsTokenLifetime {
+		return p.token, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": p.teamId,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = p.keyId
+	signed, err := token.SignedString(p.key)
+	if err != nil {
+		return "", err
+	}
+	p.token = signed
+	p.tokenIat = now
+	return p.token, nil
+}
@@ -0,0 +1,43 @@
+package push
+
+import (
+	"fmt"
+	"rest-go-demo/entity"
+	"sync"
+)
+
+// FakeDelivery is one notification captured by the FakeProvider
+type FakeDelivery struct {
+	Device       entity.Pushdevice
+	Notification Notification
+}
+
+// FakeProvider records notifications instead of sending them, for local development and tests
+// tokens added to Gone behave like uninstalled apps and return ErrDeviceGone
+type FakeProvider struct {
+	mutex sync.Mutex
+	Sent  []FakeDelivery
+	Gone  map[string]bool
+}
+
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{Gone: map[string]bool{}}
+}
+
+func (p *FakeProvider) Send(device entity.Pushdevice, notification Notification) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.Gone[device.Token] {
+		return ErrDeviceGone
+	}
+	p.Sent = append(p.Sent, FakeDelivery{Device: device, Notification: notification})
+	fmt.Println("push (fake) - ", device.Platform, device.Walletaddr, notification.Title, "-", notification.Body)
+	return nil
+}
+
+// Deliveries returns a copy of everything sent so far
+func (p *FakeProvider) Deliveries() []FakeDelivery {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return append([]FakeDelivery{}, p.Sent...)
+}
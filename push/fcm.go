@@ -0,0 +1,168 @@
+package push
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"rest-go-demo/entity"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// the parts of a Google service account key file we need
+type fcmServiceAccount struct {
+	ProjectId   string `json:"project_id"`
+	ClientEmail string `json:"client_email"`
+	PrivateKey  string `json:"private_key"`
+	TokenUri    string `json:"token_uri"`
+}
+
+// FcmProvider sends Android notifications with the FCM HTTP v1 API
+type FcmProvider struct {
+	account fcmServiceAccount
+
+	tokenMutex  sync.Mutex
+	accessToken string
+	tokenExpiry time.Time
+}
+
+// NewFcmProviderFromEnv reads the service account JSON from FCM_SERVICE_ACCOUNT (contents) or FCM_SERVICE_ACCOUNT_FILE (path)
+func NewFcmProviderFromEnv() (*FcmProvider, error) {
+	accountJson := []byte(os.Getenv("FCM_SERVICE_ACCOUNT"))
+	if len(accountJson) == 0 && os.Getenv("FCM_SERVICE_ACCOUNT_FILE") != "" {
+		var err error
+		accountJson, err = ioutil.ReadFile(os.Getenv("FCM_SERVICE_ACCOUNT_FILE"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(accountJson) == 0 {
+		return nil, fmt.Errorf("FCM_SERVICE_ACCOUNT/FCM_SERVICE_ACCOUNT_FILE not set")
+	}
+	return NewFcmProvider(accountJson)
+}
+
+func NewFcmProvider(serviceAccountJson []byte) (*FcmProvider, error) {
+	var account fcmServiceAccount
+	if err := json.Unmarshal(serviceAccountJson, &account); err != nil {
+		return nil, fmt.Errorf("invalid FCM service account: %v", err)
+	}
+	if account.ProjectId == "" || account.ClientEmail == "" || account.PrivateKey == "" {
+		return nil, fmt.Errorf("FCM service account is missing project_id, client_email or private_key")
+	}
+	if account.TokenUri == "" {
+		account.TokenUri = "https://oauth2.googleapis.com/token"
+	}
+	return &FcmProvider{account: account}, nil
+}
+
+func (p *FcmProvider) Send(device entity.Pushdevice, notification Notification) error {
+	accessToken, err := p.getAccessToken()
+	if err != nil {
+		return err
+	}
+
+	//FCM data values have to be strings
+	data := map[string]string{}
+	for key, value := range notification.Data {
+		data[key] = value
+	}
+	if notification.Url != "" {
+		data["url"] = notification.Url
+	}
+	message := map[string]interface{}{
+		"message": map[string]interface{}{
+			"token": device.Token,
+			"notification": map[string]string{
+				"title": notification.Title,
+				"body":  notification.Body,
+			},
+			"data":    data,
+			"android": map[string]string{"priority": "high"},
+		},
+	}
+	body, _ := json.Marshal(message)
+
+	sendUrl := "https://fcm.googleapis.com/v1/projects/" + p.account.ProjectId + "/messages:send"
+	req, err := http.NewRequest("POST", sendUrl, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+
+	res, err := pushClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	resBody, _ := ioutil.ReadAll(res.Body)
+
+	//404 UNREGISTERED - app was uninstalled or the token rotated
+	if res.StatusCode == http.StatusNotFound || strings.Contains(string(resBody), "UNREGISTERED") {
+		return ErrDeviceGone
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("fcm status %d: %s", res.StatusCode, string(resBody))
+	}
+	return nil
+}
+
+// getAccessToken exchanges a self-signed service account JWT for an OAuth token, cached until shortly before expiry
+func (p *FcmProvider) getAccessToken() (string, error) {
+	p.tokenMutex.Lock()
+	defer p.tokenMutex.Unlock()
+	if p.accessToken != "" && time.Now().Before(p.tokenExpiry) {
+		return p.accessToken, nil
+	}
+
+	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(p.account.PrivateKey))
+	if err != nil {
+		return "", fmt.Errorf("invalid FCM private key: %v", err)
+	}
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   p.account.ClientEmail,
+		"scope": fcmScope,
+		"aud":   p.account.TokenUri,
+		"iat":   now.Unix(),
+		"exp":   now.Add(time.Hour).Unix(),
+	}
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	res, err := pushClient.PostForm(p.account.TokenUri, form)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	resBody, _ := ioutil.ReadAll(res.Body)
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fcm token status %d: %s", res.StatusCode, string(resBody))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(resBody, &token); err != nil || token.AccessToken == "" {
+		return "", fmt.Errorf("fcm token response invalid: %s", string(resBody))
+	}
+	p.accessToken = token.AccessToken
+	p.tokenExpiry = now.Add(time.Duration(token.ExpiresIn)*time.Second - 5*time.Minute)
+	return p.accessToken, nil
+}
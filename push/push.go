@@ -0,0 +1,279 @@
+package push
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	_ "rest-go-demo/docs"
+	"rest-go-demo/entity"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// ErrDeviceGone is returned by a Provider when the token/subscription is no longer valid
+// (app uninstalled, browser unsubscribed), the device is removed when this is seen
+var ErrDeviceGone = errors.New("push device is no longer registered")
+
+// Notification is what gets shown on the device, Data is passed through to the app untouched
+type Notification struct {
+	Title string            `json:"title"`
+	Body  string            `json:"body"`
+	Url   string            `json:"url,omitempty"`
+	Data  map[string]string `json:"data,omitempty"`
+}
+
+// Provider delivers a notification to one device of its platform (Web Push, FCM, APNs or the fake)
+type Provider interface {
+	Send(device entity.Pushdevice, notification Notification) error
+}
+
+var (
+	providersMutex sync.RWMutex
+	providers      = map[string]Provider{}
+)
+
+var pushClient = &http.Client{Timeout: 10 * time.Second}
+
+// SetProvider registers (or replaces, ie with a FakeProvider) the provider for a platform
+func SetProvider(platform string, provider Provider) {
+	providersMutex.Lock()
+	defer providersMutex.Unlock()
+	if provider == nil {
+		delete(providers, platform)
+		return
+	}
+	providers[platform] = provider
+}
+
+func getProvider(platform string) Provider {
+	providersMutex.RLock()
+	defer providersMutex.RUnlock()
+	return providers[platform]
+}
+
+// InitProviders sets up the providers that have credentials in the environment
+// PUSH_PROVIDER=fake logs notifications instead of sending them (local development)
+func InitProviders() {
+	if strings.EqualFold(os.Getenv("PUSH_PROVIDER"), "fake") {
+		fake := NewFakeProvider()
+		SetProvider(entity.PushPlatformWeb, fake)
+		SetProvider(entity.PushPlatformFcm, fake)
+		SetProvider(entity.PushPlatformApns, fake)
+		fmt.Println("push - using fake provider for all platforms")
+		return
+	}
+
+	if webPush, err := NewWebPushProviderFromEnv(); err == nil {
+		SetProvider(entity.PushPlatformWeb, webPush)
+	} else {
+		fmt.Println("push - Web Push disabled: ", err)
+	}
+	if fcm, err := NewFcmProviderFromEnv(); err == nil {
+		SetProvider(entity.PushPlatformFcm, fcm)
+	} else {
+		fmt.Println("push - FCM disabled: ", err)
+	}
+	if apns, err := NewApnsProviderFromEnv(); err == nil {
+		SetProvider(entity.PushPlatformApns, apns)
+	} else {
+		fmt.Println("push - APNs disabled: ", err)
+	}
+}
+
+// NotifyWallet pushes to every registered device of the wallet, using the same
+// Notifydm preference as the email/Telegram new message notifications
+func NotifyWallet(walletaddr string, notification Notification) {
+	walletaddr = strings.ToLower(walletaddr)
+
+	var settings entity.Settings
+	dbQuery := database.Connector.Where("walletaddr = ?", walletaddr).Find(&settings)
+	if dbQuery.RowsAffected == 0 || !strings.EqualFold(settings.Notifydm, "true") {
+		return
+	}
+
+	var devices []entity.Pushdevice
+	database.Connector.Where("walletaddr = ?", walletaddr).Find(&devices)
+	for _, device := range devices {
+		provider := getProvider(device.Platform)
+		if provider == nil {
+			continue
+		}
+
+		err := provider.Send(device, notification)
+		if errors.Is(err, ErrDeviceGone) {
+			fmt.Println("push - removing expired device: ", device.Id, device.Platform)
+			database.Connector.Delete(&device)
+		} else if err != nil {
+			fmt.Println("push - send failed: ", device.Id, device.Platform, err)
+		} else {
+			database.Connector.Model(&device).Update("lastused", time.Now())
+		}
+	}
+}
+
+// ============================================================================
+
+// DeviceRegistration is the POST body for registering a device
+// Web Push clients can send PushSubscription.toJSON() as-is (endpoint + keys) along with platform=webpush
+type DeviceRegistration struct {
+	Platform string `json:"platform"`
+	Token    string `json:"token"`
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256dh string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+	Name string `json:"name"`
+}
+
+// VapidKey is returned to browsers as the applicationServerKey for pushManager.subscribe()
+type VapidKey struct {
+	Publickey string `json:"publickey"`
+}
+
+func renderJson(w http.ResponseWriter, statusCode int, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(res)
+}
+
+// RegisterPushDevice godoc
+// @Summary     Register a device/browser for push notifications
+// @Description platform is one of webpush, fcm, apns. FCM/APNs send the device token in "token",
+// @Description Web Push sends the PushSubscription JSON (endpoint + keys.p256dh + keys.auth).
+// @Description Web Push endpoints must be on a browser push service (FCM, Mozilla, Apple, WNS).
+// @Description Re-registering the same token updates it, 409 if the token is registered to another wallet (remove it there first).
+// @Tags        Push
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       message body    DeviceRegistration true "Device token or Web Push subscription"
+// @Success     201     {object} entity.Pushdevice
+// @Router      /v1/push/devices [post]
+func RegisterPushDevice(w http.ResponseWriter, r *http.Request) {
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var registration DeviceRegistration
+	if err := json.Unmarshal(requestBody, &registration); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	Authuser := auth.GetUserFromReqContext(r)
+	walletaddr := strings.ToLower(Authuser.Address)
+
+	var device entity.Pushdevice
+	device.Platform = strings.ToLower(strings.TrimSpace(registration.Platform))
+	switch device.Platform {
+	case entity.PushPlatformWeb:
+		device.Token = strings.TrimSpace(registration.Endpoint)
+		if device.Token == "" {
+			device.Token = strings.TrimSpace(registration.Token)
+		}
+		device.P256dh = registration.Keys.P256dh
+		device.Auth = registration.Keys.Auth
+		if !IsWebPushEndpoint(device.Token) || device.P256dh == "" || device.Auth == "" {
+			http.Error(w, "webpush requires a push service endpoint and keys.p256dh/keys.auth", http.StatusBadRequest)
+			return
+		}
+	case entity.PushPlatformFcm, entity.PushPlatformApns:
+		device.Token = strings.TrimSpace(registration.Token)
+		if device.Token == "" {
+			http.Error(w, "token is required", http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "platform must be webpush, fcm or apns", http.StatusBadRequest)
+		return
+	}
+
+	//same token registered again (ie new login on the same phone) - update it in place
+	var existing entity.Pushdevice
+	dbQuery := database.Connector.Where("platform = ?", device.Platform).Where("token = ?", device.Token).Find(&existing)
+	if dbQuery.RowsAffected > 0 {
+		//a token is only ever moved by the wallet that registered it, or anyone knowing it could take over the device
+		if existing.Walletaddr != walletaddr {
+			http.Error(w, "this device is registered to another wallet", http.StatusConflict)
+			return
+		}
+		device.Id = existing.Id
+		device.Lastused = existing.Lastused
+	}
+	device.Walletaddr = walletaddr
+	device.Name = registration.Name
+	device.Timestamp = time.Now()
+
+	var err error
+	if device.Id == 0 {
+		err = database.Connector.Create(&device).Error
+	} else {
+		err = database.Connector.Save(&device).Error
+	}
+	if err != nil {
+		fmt.Println("RegisterPushDevice error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	renderJson(w, http.StatusCreated, device)
+}
+
+// GetPushDevices godoc
+// @Summary     List the devices registered for push notifications by the calling wallet
+// @Tags        Push
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200     {array} entity.Pushdevice
+// @Router      /v1/push/devices [get]
+func GetPushDevices(w http.ResponseWriter, r *http.Request) {
+	Authuser := auth.GetUserFromReqContext(r)
+
+	var devices []entity.Pushdevice
+	database.Connector.Where("walletaddr = ?", strings.ToLower(Authuser.Address)).Order("timestamp desc").Find(&devices)
+	renderJson(w, http.StatusOK, devices)
+}
+
+// DeletePushDevice godoc
+// @Summary     Stop push notifications to a device
+// @Tags        Push
+// @Security    BearerAuth
+// @Param       id path string true "Device ID"
+// @Success     204
+// @Router      /v1/push/devices/{id} [delete]
+func DeletePushDevice(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	Authuser := auth.GetUserFromReqContext(r)
+
+	dbQuery := database.Connector.Where("id = ?", vars["id"]).
+		Where("walletaddr = ?", strings.ToLower(Authuser.Address)).
+		Delete(&entity.Pushdevice{})
+	if dbQuery.RowsAffected == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetVapidPublicKey godoc
+// @Summary     Web Push applicationServerKey (VAPID public key, base64url)
+// @Tags        Push
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200     {object} VapidKey
+// @Router      /v1/push/vapid_public_key [get]
+func GetVapidPublicKey(w http.ResponseWriter, r *http.Request) {
+	publicKey := os.Getenv("VAPID_PUBLIC_KEY")
+	if publicKey == "" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	renderJson(w, http.StatusOK, VapidKey{Publickey: publicKey})
+}
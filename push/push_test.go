@@ -0,0 +1,144 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	"rest-go-demo/entity"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/sqlite"
+)
+
+const (
+	alice = "0x1111111111111111111111111111111111111111"
+	bob   = "0x2222222222222222222222222222222222222222"
+)
+
+// useTestDB swaps the MySQL connector for an in-memory SQLite database with the push tables
+func useTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.DB().SetMaxOpenConns(1) //every connection to :memory: is a database of its own
+	db.AutoMigrate(&entity.Settings{}, &entity.Pushdevice{})
+	saved := database.Connector
+	database.Connector = db
+	t.Cleanup(func() {
+		database.Connector = saved
+		db.Close()
+	})
+}
+
+// useFakeProvider sends every platform to one FakeProvider
+func useFakeProvider(t *testing.T) *FakeProvider {
+	t.Helper()
+	fake := NewFakeProvider()
+	for _, platform := range []string{entity.PushPlatformWeb, entity.PushPlatformFcm, entity.PushPlatformApns} {
+		SetProvider(platform, fake)
+	}
+	t.Cleanup(func() {
+		for _, platform := range []string{entity.PushPlatformWeb, entity.PushPlatformFcm, entity.PushPlatformApns} {
+			SetProvider(platform, nil)
+		}
+	})
+	return fake
+}
+
+func register(t *testing.T, wallet string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/v1/push/devices", bytes.NewBufferString(body))
+	r = r.WithContext(context.WithValue(r.Context(), "Authuser", auth.Authuser{Address: wallet}))
+	w := httptest.NewRecorder()
+	RegisterPushDevice(w, r)
+	return w
+}
+
+func TestIsWebPushEndpoint(t *testing.T) {
+	cases := []struct {
+		endpoint string
+		want     bool
+	}{
+		{"https://fcm.googleapis.com/fcm/send/abc", true},
+		{"https://updates.push.services.mozilla.com/wpush/v2/abc", true},
+		{"https://web.push.apple.com/abc", true},
+		{"https://wns2-par02p.notify.windows.com/w/?token=abc", true},
+		{"http://fcm.googleapis.com/fcm/send/abc", false},          //not https
+		{"https://fcm.googleapis.com:8443/fcm/send/abc", false},    //custom port
+		{"https://user@fcm.googleapis.com/fcm/send/abc", false},    //credentials
+		{"https://fcm.googleapis.com.example.com/send/abc", false}, //not a subdomain
+		{"https://evilfcm.googleapis.com.attacker.io/abc", false},  //lookalike
+		{"https://169.254.169.254/latest/meta-data", false},        //internal address
+		{"https://localhost/push", false},
+		{"fcm.googleapis.com/fcm/send/abc", false},
+	}
+	for _, c := range cases {
+		if got := IsWebPushEndpoint(c.endpoint); got != c.want {
+			t.Errorf("IsWebPushEndpoint(%q) = %v, want %v", c.endpoint, got, c.want)
+		}
+	}
+}
+
+func TestRegisterPushDevice(t *testing.T) {
+	useTestDB(t)
+	cases := []struct {
+		name   string
+		wallet string
+		body   string
+		want   int
+	}{
+		{"fcm token", alice, `{"platform":"fcm","token":"fcm-token-1"}`, http.StatusCreated},
+		{"same token again", alice, `{"platform":"fcm","token":"fcm-token-1","name":"Pixel"}`, http.StatusCreated},
+		{"token of another wallet", bob, `{"platform":"fcm","token":"fcm-token-1"}`, http.StatusConflict},
+		{"same token on another platform", bob, `{"platform":"apns","token":"fcm-token-1"}`, http.StatusCreated},
+		{"web push subscription", alice, `{"platform":"webpush","endpoint":"https://fcm.googleapis.com/fcm/send/abc","keys":{"p256dh":"key","auth":"secret"}}`, http.StatusCreated},
+		{"web push outside the allow-list", alice, `{"platform":"webpush","endpoint":"https://attacker.io/push","keys":{"p256dh":"key","auth":"secret"}}`, http.StatusBadRequest},
+		{"web push without keys", alice, `{"platform":"webpush","endpoint":"https://fcm.googleapis.com/fcm/send/def"}`, http.StatusBadRequest},
+		{"missing token", alice, `{"platform":"apns"}`, http.StatusBadRequest},
+		{"unknown platform", alice, `{"platform":"sms","token":"123"}`, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if w := register(t, c.wallet, c.body); w.Code != c.want {
+			t.Errorf("%s: status %d, want %d (%s)", c.name, w.Code, c.want, w.Body.String())
+		}
+	}
+
+	var devices []entity.Pushdevice
+	database.Connector.Where("platform = ?", entity.PushPlatformFcm).Find(&devices)
+	if len(devices) != 1 || devices[0].Walletaddr != alice || devices[0].Name != "Pixel" {
+		t.Errorf("fcm devices = %+v, want alice's token updated in place", devices)
+	}
+}
+
+func TestNotifyWalletPrunesExpiredDevices(t *testing.T) {
+	useTestDB(t)
+	fake := useFakeProvider(t)
+	database.Connector.Create(&entity.Settings{Walletaddr: alice, Notifydm: "true"})
+	database.Connector.Create(&entity.Settings{Walletaddr: bob, Notifydm: "false"})
+	register(t, alice, `{"platform":"fcm","token":"phone"}`)
+	register(t, alice, `{"platform":"apns","token":"uninstalled"}`)
+	register(t, bob, `{"platform":"fcm","token":"bob-phone"}`)
+	fake.Gone["uninstalled"] = true
+
+	NotifyWallet(alice, Notification{Title: "New message", Body: "gm"})
+	NotifyWallet(bob, Notification{Title: "New message", Body: "gm"})
+
+	sent := fake.Deliveries()
+	if len(sent) != 1 || sent[0].Device.Token != "phone" || sent[0].Notification.Body != "gm" {
+		t.Errorf("sent = %+v, want only alice's phone (bob turned DM notifications off)", sent)
+	}
+	var remaining []entity.Pushdevice
+	database.Connector.Where("walletaddr = ?", alice).Find(&remaining)
+	if len(remaining) != 1 || remaining[0].Token != "phone" {
+		t.Errorf("alice's devices = %+v, want the expired one removed", remaining)
+	}
+	if remaining[0].Lastused.IsZero() {
+		t.Error("lastused isn't set after a successful push")
+	}
+}
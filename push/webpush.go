@@ -0,0 +1,242 @@
+package push
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"rest-go-demo/entity"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// record size advertised in the aes128gcm header, our payloads are always a single record
+const webPushRecordSize = 4096
+
+// push services browsers subscribe with (Chrome/Edge via FCM, Firefox, Safari, legacy Edge via WNS),
+// a host matches exactly or as a subdomain. Anything else is refused so endpoints can't point the server elsewhere.
+var webPushHosts = []string{
+	"fcm.googleapis.com",
+	"push.services.mozilla.com",
+	"push.apple.com",
+	"notify.windows.com",
+}
+
+// IsWebPushEndpoint is true for https URLs of a known push service
+func IsWebPushEndpoint(endpoint string) bool {
+	parsed, err := url.Parse(endpoint)
+	if err != nil || parsed.Scheme != "https" || parsed.User != nil || parsed.Port() != "" {
+		return false
+	}
+	host := strings.ToLower(parsed.Hostname())
+	for _, allowed := range webPushHosts {
+		if host == allowed || strings.HasSuffix(host, "."+allowed) {
+			return true
+		}
+	}
+	return false
+}
+
+// WebPushProvider sends browser notifications (RFC 8030) with VAPID auth (RFC 8292)
+// and aes128gcm payload encryption (RFC 8291)
+type WebPushProvider struct {
+	privateKey *ecdsa.PrivateKey
+	publicKey  string //base64url uncompressed P-256 point, same value browsers subscribe with
+	subject    string //mailto: or https: contact for the push service
+}
+
+// NewWebPushProviderFromEnv reads VAPID_PUBLIC_KEY/VAPID_PRIVATE_KEY (base64url) and VAPID_SUBJECT
+func NewWebPushProviderFromEnv() (*WebPushProvider, error) {
+	publicKey := os.Getenv("VAPID_PUBLIC_KEY")
+	privateKey := os.Getenv("VAPID_PRIVATE_KEY")
+	if publicKey == "" || privateKey == "" {
+		return nil, fmt.Errorf("VAPID_PUBLIC_KEY/VAPID_PRIVATE_KEY not set")
+	}
+	subject := os.Getenv("VAPID_SUBJECT")
+	if subject == "" {
+		subject = "mailto:contact@walletchat.fun"
+	}
+	return NewWebPushProvider(publicKey, privateKey, subject)
+}
+
+func NewWebPushProvider(publicKey string, privateKey string, subject string) (*WebPushProvider, error) {
+	publicBytes, err := decodeBase64Url(publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID public key: %v", err)
+	}
+	x, y := elliptic.Unmarshal(elliptic.P256(), publicBytes)
+	if x == nil {
+		return nil, fmt.Errorf("invalid VAPID public key: not an uncompressed P-256 point")
+	}
+	privateBytes, err := decodeBase64Url(privateKey)
+	if err != nil || len(privateBytes) != 32 {
+		return nil, fmt.Errorf("invalid VAPID private key")
+	}
+
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y},
+		D:         new(big.Int).SetBytes(privateBytes),
+	}
+	return &WebPushProvider{privateKey: key, publicKey: publicKey, subject: subject}, nil
+}
+
+func (p *WebPushProvider) Send(device entity.Pushdevice, notification Notification) error {
+	if !IsWebPushEndpoint(device.Token) {
+		return ErrDeviceGone //registered before endpoints were checked, drop it
+	}
+	payload, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+	body, err := encryptWebPushPayload(device.P256dh, device.Auth, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := p.vapidAuthorization(device.Token)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", device.Token, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("TTL", "86400")
+	req.Header.Set("Urgency", "high")
+	req.Header.Set("Authorization", authorization)
+
+	res, err := pushClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	resBody, _ := ioutil.ReadAll(res.Body)
+
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone {
+		return ErrDeviceGone
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("web push status %d: %s", res.StatusCode, string(resBody))
+	}
+	return nil
+}
+
+// vapidAuthorization signs a short lived JWT for the push service origin of the endpoint
+func (p *WebPushProvider) vapidAuthorization(endpoint string) (string, error) {
+	endpointUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	claims := jwt.MapClaims{
+		"aud": endpointUrl.Scheme + "://" + endpointUrl.Host,
+		"exp": time.Now().Add(12 * time.Hour).Unix(),
+		"sub": p.subject,
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, claims).SignedString(p.privateKey)
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + token + ", k=" + p.publicKey, nil
+}
+
+// encryptWebPushPayload implements the RFC 8291 aes128gcm content encoding for a single record
+func encryptWebPushPayload(p256dh string, authSecret string, payload []byte) ([]byte, error) {
+	uaPublic, err := decodeBase64Url(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid p256dh: %v", err)
+	}
+	auth, err := decodeBase64Url(authSecret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid auth secret: %v", err)
+	}
+	curve := elliptic.P256()
+	uaX, uaY := elliptic.Unmarshal(curve, uaPublic)
+	if uaX == nil {
+		return nil, fmt.Errorf("invalid p256dh: not an uncompressed P-256 point")
+	}
+
+	//ephemeral application server key pair, new for every message
+	asPrivate, err := ecdsa.GenerateKey(curve, rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	asPublic := elliptic.Marshal(curve, asPrivate.X, asPrivate.Y)
+	sharedX, _ := curve.ScalarMult(uaX, uaY, asPrivate.D.Bytes())
+	ecdhSecret := make([]byte, 32)
+	sharedX.FillBytes(ecdhSecret)
+
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+
+	keyInfo := append([]byte("WebPush: info\x00"), uaPublic...)
+	keyInfo = append(keyInfo, asPublic...)
+	ikm := hkdf(auth, ecdhSecret, keyInfo, 32)
+	contentKey := hkdf(salt, ikm, []byte("Content-Encoding: aes128gcm\x00"), 16)
+	nonce := hkdf(salt, ikm, []byte("Content-Encoding: nonce\x00"), 12)
+
+	block, err := aes.NewCipher(contentKey)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	//0x02 marks the last (and only) record
+	plaintext := append(payload, 0x02)
+	if len(plaintext)+gcm.Overhead() > webPushRecordSize {
+		return nil, fmt.Errorf("web push payload too large")
+	}
+
+	header := make([]byte, 0, 21+len(asPublic))
+	header = append(header, salt...)
+	recordSize := make([]byte, 4)
+	binary.BigEndian.PutUint32(recordSize, webPushRecordSize)
+	header = append(header, recordSize...)
+	header = append(header, byte(len(asPublic)))
+	header = append(header, asPublic...)
+
+	return gcm.Seal(header, nonce, plaintext, nil), nil
+}
+
+// hkdf is HKDF-SHA256 (RFC 5869) for outputs of up to one hash block, which is all Web Push needs
+func hkdf(salt []byte, secret []byte, info []byte, length int) []byte {
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	expand := hmac.New(sha256.New, prk)
+	expand.Write(info)
+	expand.Write([]byte{0x01})
+	return expand.Sum(nil)[:length]
+}
+
+// browsers hand out keys as unpadded base64url, but accept padded/standard too
+func decodeBase64Url(value string) ([]byte, error) {
+	if decoded, err := base64.RawURLEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	if decoded, err := base64.URLEncoding.DecodeString(value); err == nil {
+		return decoded, nil
+	}
+	return base64.StdEncoding.DecodeString(value)
+}
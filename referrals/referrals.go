@@ -11,6 +11,7 @@ import (
 	_ "rest-go-demo/docs"
 	"rest-go-demo/entity"
 	"rest-go-demo/wc_analytics"
+	"rest-go-demo/webhooks"
 	"strings"
 	"time"
 
@@ -192,6 +193,11 @@ func RedeemReferralCode(w http.ResponseWriter, r *http.Request) {
 			fmt.Printf("Redeemed referral code for wallet: %#v\n", code[0].Walletaddr)
 			if code[0].Walletaddr != "0xtest" {
 				wc_analytics.SendCustomEvent(Authuser.Address, "REFERRAL_CODE_REDEEMED")
+				webhooks.EmitForWallet(entity.WebhookReferralRedeemed, code[0].Walletaddr, map[string]string{
+					"code":       referral_code,
+					"referrer":   code[0].Walletaddr,
+					"walletaddr": walletaddr,
+				})
 			}
 		} else {
 			fmt.Printf("Redeemed referral failed!!!!")
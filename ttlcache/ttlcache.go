@@ -0,0 +1,105 @@
+// Package ttlcache is the bounded in-memory cache shared by the holder, access, name and link checks. Entries expire
+// after their TTL and the least recently used entry is evicted once the cache is full, so keys that come from user
+// input (contracts, links, names) can't grow it without limit.
+package ttlcache
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+)
+
+type entry[V any] struct {
+	key     string
+	value   V
+	expires time.Time
+}
+
+// Cache is safe for concurrent use, a zero capacity means 1
+type Cache[V any] struct {
+	mutex    sync.Mutex
+	capacity int
+	items    map[string]*list.Element
+	order    *list.List //front is the most recently used
+}
+
+func New[V any](capacity int) *Cache[V] {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &Cache[V]{capacity: capacity, items: map[string]*list.Element{}, order: list.New()}
+}
+
+// Get returns the value if it is cached and not expired, expired entries are removed
+func (c *Cache[V]) Get(key string) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var zero V
+	element, ok := c.items[key]
+	if !ok {
+		return zero, false
+	}
+	cached := element.Value.(*entry[V])
+	if time.Now().After(cached.expires) {
+		c.remove(element)
+		return zero, false
+	}
+	c.order.MoveToFront(element)
+	return cached.value, true
+}
+
+// Set caches the value for ttl, evicting the least recently used entry when the cache is full
+func (c *Cache[V]) Set(key string, value V, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	expires := time.Now().Add(ttl)
+	if element, ok := c.items[key]; ok {
+		cached := element.Value.(*entry[V])
+		cached.value, cached.expires = value, expires
+		c.order.MoveToFront(element)
+		return
+	}
+	c.items[key] = c.order.PushFront(&entry[V]{key: key, value: value, expires: expires})
+	for len(c.items) > c.capacity {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *Cache[V]) Delete(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if element, ok := c.items[key]; ok {
+		c.remove(element)
+	}
+}
+
+// DeletePrefix removes every key starting with prefix (ie all entries of a wallet or community)
+func (c *Cache[V]) DeletePrefix(prefix string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, element := range c.items {
+		if strings.HasPrefix(key, prefix) {
+			c.remove(element)
+		}
+	}
+}
+
+func (c *Cache[V]) Clear() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.items = map[string]*list.Element{}
+	c.order.Init()
+}
+
+// Len counts the entries, including expired ones not evicted yet
+func (c *Cache[V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return len(c.items)
+}
+
+func (c *Cache[V]) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.items, element.Value.(*entry[V]).key)
+}
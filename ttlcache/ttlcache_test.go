@@ -0,0 +1,74 @@
+package ttlcache
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGetSetExpiry(t *testing.T) {
+	cache := New[string](10)
+	cache.Set("a", "1", time.Hour)
+	cache.Set("b", "2", -time.Second) //already expired
+	if value, ok := cache.Get("a"); !ok || value != "1" {
+		t.Errorf("Get(a) = %q, %v", value, ok)
+	}
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expired entry returned")
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len = %d, the expired entry should be removed on Get", cache.Len())
+	}
+}
+
+func TestEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := New[int](3)
+	for i := 0; i < 3; i++ {
+		cache.Set(strconv.Itoa(i), i, time.Hour)
+	}
+	cache.Get("0") //1 is now the least recently used
+	cache.Set("3", 3, time.Hour)
+	if _, ok := cache.Get("1"); ok {
+		t.Error("least recently used entry was not evicted")
+	}
+	for _, key := range []string{"0", "2", "3"} {
+		if _, ok := cache.Get(key); !ok {
+			t.Errorf("%s was evicted", key)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		cache.Set("x"+strconv.Itoa(i), i, time.Hour)
+	}
+	if cache.Len() != 3 {
+		t.Errorf("Len = %d, want the capacity 3", cache.Len())
+	}
+}
+
+func TestSetReplacesValue(t *testing.T) {
+	cache := New[int](2)
+	cache.Set("a", 1, time.Hour)
+	cache.Set("a", 2, time.Hour)
+	if value, _ := cache.Get("a"); value != 2 || cache.Len() != 1 {
+		t.Errorf("Get(a) = %d with %d entries, want 2 with 1", value, cache.Len())
+	}
+}
+
+func TestDeletePrefix(t *testing.T) {
+	cache := New[bool](10)
+	cache.Set("0xabc|one", true, time.Hour)
+	cache.Set("0xabc|two", true, time.Hour)
+	cache.Set("0xdef|one", true, time.Hour)
+	cache.DeletePrefix("0xabc|")
+	if cache.Len() != 1 {
+		t.Errorf("Len = %d after DeletePrefix, want 1", cache.Len())
+	}
+	if _, ok := cache.Get("0xdef|one"); !ok {
+		t.Error("other prefix was deleted")
+	}
+	cache.Delete("0xdef|one")
+	cache.Set("new", true, time.Hour)
+	cache.Clear()
+	if cache.Len() != 0 {
+		t.Error("Clear left entries")
+	}
+}
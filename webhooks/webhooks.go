@@ -0,0 +1,490 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"rest-go-demo/auth"
+	"rest-go-demo/database"
+	_ "rest-go-demo/docs"
+	"rest-go-demo/entity"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	maxDeliveryAttempts = 8
+	initialRetryDelay   = 30 * time.Second
+	maxRetryDelay       = 6 * time.Hour
+)
+
+var errBlockedAddress = errors.New("webhook host resolves to a loopback, private or link-local address")
+
+// webhookClient checks the address it connects to, after DNS resolution, so a host can't be repointed at internal
+// services (or cloud metadata) once it is subscribed
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network string, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || blockedIP(ip) {
+					return errBlockedAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// blockedIP is true for addresses integrators may not deliver to
+func blockedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+var lookupIP = net.LookupIP
+
+// checkWebhookHost resolves the host, every address it resolves to has to be public
+func checkWebhookHost(host string) error {
+	ips, err := lookupIP(host)
+	if err != nil {
+		return err
+	}
+	if len(ips) == 0 {
+		return fmt.Errorf("%s has no addresses", host)
+	}
+	for _, ip := range ips {
+		if blockedIP(ip) {
+			return errBlockedAddress
+		}
+	}
+	return nil
+}
+
+// Envelope is the JSON body POSTed to integrators for every event
+type Envelope struct {
+	Id      string      `json:"id"`
+	Event   string      `json:"event"`
+	Created time.Time   `json:"created"`
+	Data    interface{} `json:"data"`
+}
+
+func randomHex(n int) string {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		fmt.Println("webhooks - random error: ", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Sign returns the X-WalletChat-Signature header value: t=<unix>,v1=<hex(HMAC-SHA256(secret, "<unix>.<body>"))>
+// integrators should recompute it and reject old timestamps to prevent replays
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "t=" + strconv.FormatInt(timestamp, 10) + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// retryDelay is exponential backoff per attempt: 30s, 1m, 2m, 4m... capped at 6h
+func retryDelay(attempts int) time.Duration {
+	delay := initialRetryDelay
+	for i := 1; i < attempts; i++ {
+		delay = delay * 2
+		if delay > maxRetryDelay {
+			return maxRetryDelay
+		}
+	}
+	return delay
+}
+
+func subscribedTo(subscription entity.Webhooksubscription, event string) bool {
+	if event == entity.WebhookPing || strings.TrimSpace(subscription.Events) == "" {
+		return true
+	}
+	for _, subscribed := range strings.Split(subscription.Events, ",") {
+		if strings.EqualFold(strings.TrimSpace(subscribed), event) {
+			return true
+		}
+	}
+	return false
+}
+
+// Emit queues an event for every active subscription of the given integration (signup site or domain)
+func Emit(event string, signupsite string, data interface{}) {
+	signupsite = normalizeSite(signupsite)
+	if signupsite == "" {
+		return
+	}
+
+	var subscriptions []entity.Webhooksubscription
+	database.Connector.Where("signupsite = ?", signupsite).Where("active = ?", true).Find(&subscriptions)
+	for _, subscription := range subscriptions {
+		if subscribedTo(subscription, event) {
+			queueDelivery(subscription, event, data)
+		}
+	}
+}
+
+// EmitForWallet sends the event to the integrations the wallet signed up from
+func EmitForWallet(event string, walletaddr string, data interface{}) {
+	var settings entity.Settings
+	dbQuery := database.Connector.Where("walletaddr = ?", walletaddr).Find(&settings)
+	if dbQuery.RowsAffected == 0 {
+		return
+	}
+
+	Emit(event, settings.Signupsite, data)
+	if normalizeSite(settings.Domain) != normalizeSite(settings.Signupsite) {
+		Emit(event, settings.Domain, data)
+	}
+}
+
+func queueDelivery(subscription entity.Webhooksubscription, event string, data interface{}) *entity.Webhookdelivery {
+	envelope := Envelope{
+		Id:      randomHex(12),
+		Event:   event,
+		Created: time.Now().UTC(),
+		Data:    data,
+	}
+	payload, err := json.Marshal(envelope)
+	if err != nil {
+		fmt.Println("webhooks - could not marshal event: ", event, err)
+		return nil
+	}
+
+	var delivery entity.Webhookdelivery
+	delivery.Subscriptionid = subscription.Id
+	delivery.Event = event
+	delivery.Payload = string(payload)
+	delivery.Status = entity.WebhookStatusPending
+	delivery.Nextattempt = time.Now()
+	delivery.Timestamp = time.Now()
+	if err := database.Connector.Create(&delivery).Error; err != nil {
+		fmt.Println("webhooks - could not queue delivery: ", event, err)
+		return nil
+	}
+
+	go attemptDelivery(delivery.Id)
+	return &delivery
+}
+
+// attemptDelivery claims a pending delivery (so only one replica sends it) and POSTs it
+func attemptDelivery(deliveryId int) {
+	claim := database.Connector.Model(&entity.Webhookdelivery{}).
+		Where("id = ?", deliveryId).
+		Where("status = ?", entity.WebhookStatusPending).
+		Updates(map[string]interface{}{"status": entity.WebhookStatusSending, "nextattempt": time.Now()})
+	if claim.RowsAffected == 0 {
+		return
+	}
+
+	var delivery entity.Webhookdelivery
+	var subscription entity.Webhooksubscription
+	database.Connector.Where("id = ?", deliveryId).Find(&delivery)
+	dbQuery := database.Connector.Where("id = ?", delivery.Subscriptionid).Find(&subscription)
+	if dbQuery.RowsAffected == 0 || !subscription.Active {
+		delivery.Status = entity.WebhookStatusFailed
+		delivery.Lasterror = "subscription removed or inactive"
+		database.Connector.Save(&delivery)
+		return
+	}
+
+	delivery.Attempts++
+	responseCode, err := post(subscription, delivery)
+	delivery.Responsecode = responseCode
+	if err == nil {
+		now := time.Now()
+		delivery.Status = entity.WebhookStatusDelivered
+		delivery.Lasterror = ""
+		delivery.Deliveredat = &now
+	} else {
+		delivery.Lasterror = err.Error()
+		if delivery.Attempts >= maxDeliveryAttempts {
+			delivery.Status = entity.WebhookStatusFailed
+		} else {
+			delivery.Status = entity.WebhookStatusPending
+			delivery.Nextattempt = time.Now().Add(retryDelay(delivery.Attempts))
+		}
+	}
+	database.Connector.Save(&delivery)
+}
+
+func post(subscription entity.Webhooksubscription, delivery entity.Webhookdelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest("POST", subscription.Url, bytes.NewBuffer(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "WalletChat-Webhooks/1.0")
+	req.Header.Set("X-WalletChat-Event", delivery.Event)
+	req.Header.Set("X-WalletChat-Delivery", strconv.Itoa(delivery.Id))
+	req.Header.Set("X-WalletChat-Signature", Sign(subscription.Secret, time.Now().Unix(), body))
+
+	res, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	ioutil.ReadAll(res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, fmt.Errorf("unexpected status code %d", res.StatusCode)
+	}
+	return res.StatusCode, nil
+}
+
+// RetryPendingDeliveries is run on a schedule and re-sends deliveries whose backoff has expired
+func RetryPendingDeliveries() {
+	//deliveries stuck in "sending" (process restarted mid-send) go back to pending
+	database.Connector.Model(&entity.Webhookdelivery{}).
+		Where("status = ?", entity.WebhookStatusSending).
+		Where("nextattempt < ?", time.Now().Add(-10*time.Minute)).
+		Update("status", entity.WebhookStatusPending)
+
+	var deliveries []entity.Webhookdelivery
+	database.Connector.Where("status = ?", entity.WebhookStatusPending).
+		Where("nextattempt <= ?", time.Now()).
+		Order("nextattempt asc").Limit(100).Find(&deliveries)
+	for _, delivery := range deliveries {
+		attemptDelivery(delivery.Id)
+	}
+}
+
+// ============================================================================
+
+func normalizeSite(site string) string {
+	site = strings.ToLower(strings.TrimSpace(site))
+	site = strings.TrimPrefix(site, "https://")
+	site = strings.TrimPrefix(site, "http://")
+	return strings.TrimSuffix(site, "/")
+}
+
+// integrations authenticate with their admin API key, returns the short key ID used as owner
+func integratorFromRequest(r *http.Request) (string, bool) {
+	apiKey := r.Header.Get("Authorization")
+	const prefix = "Bearer "
+	if !strings.HasPrefix(apiKey, prefix) || !auth.IsAdminApiKey(apiKey[len(prefix):]) {
+		return "", false
+	}
+	Authuser := auth.GetUserFromReqContext(r)
+	return Authuser.Address, true
+}
+
+func getOwnedSubscription(r *http.Request) (entity.Webhooksubscription, bool) {
+	var subscription entity.Webhooksubscription
+	owner, ok := integratorFromRequest(r)
+	if !ok {
+		return subscription, false
+	}
+	vars := mux.Vars(r)
+	dbQuery := database.Connector.Where("id = ?", vars["id"]).Where("owner = ?", owner).Find(&subscription)
+	return subscription, dbQuery.RowsAffected > 0
+}
+
+func renderJson(w http.ResponseWriter, statusCode int, res interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(res)
+}
+
+// CreateWebhook godoc
+// @Summary     Subscribe an integration to WalletChat events
+// @Description Requires an integrator API key. Events: message.created, community.member_joined, referral.redeemed, settings.email_verified, overlap.completed
+// @Description The secret is only returned once, use it to verify the X-WalletChat-Signature header (t=<unix>,v1=<hmac-sha256 of "<unix>.<body>">)
+// @Tags        Webhooks
+// @Accept      json
+// @Produce     json
+// @Security    BearerAuth
+// @Param       message body    entity.Webhooksubscription true "signupsite, url and optional comma separated events"
+// @Success     201     {object} entity.Webhooksubscription
+// @Router      /v1/webhooks [post]
+func CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	owner, ok := integratorFromRequest(r)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	requestBody, _ := ioutil.ReadAll(r.Body)
+	var subscription entity.Webhooksubscription
+	if err := json.Unmarshal(requestBody, &subscription); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	hookUrl, err := url.Parse(subscription.Url)
+	if err != nil || hookUrl.Scheme != "https" || hookUrl.Host == "" {
+		http.Error(w, "url must be a valid https URL", http.StatusBadRequest)
+		return
+	}
+	if err := checkWebhookHost(hookUrl.Hostname()); err != nil {
+		http.Error(w, "url must point to a public host: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	subscription.Signupsite = normalizeSite(subscription.Signupsite)
+	if subscription.Signupsite == "" {
+		http.Error(w, "signupsite is required", http.StatusBadRequest)
+		return
+	}
+
+	subscription.Id = 0
+	subscription.Owner = owner
+	subscription.Secret = "whsec_" + randomHex(24)
+	subscription.Active = true
+	subscription.Timestamp = time.Now()
+	if err := database.Connector.Create(&subscription).Error; err != nil {
+		fmt.Println("CreateWebhook error: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	renderJson(w, http.StatusCreated, subscription)
+}
+
+// GetWebhooks godoc
+// @Summary     List webhook subscriptions for the calling integration
+// @Tags        Webhooks
+// @Produce     json
+// @Security    BearerAuth
+// @Success     200     {array} entity.Webhooksubscription
+// @Router      /v1/webhooks [get]
+func GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	owner, ok := integratorFromRequest(r)
+	if !ok {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var subscriptions []entity.Webhooksubscription
+	database.Connector.Where("owner = ?", owner).Find(&subscriptions)
+	for i := range subscriptions {
+		subscriptions[i].Secret = ""
+	}
+	renderJson(w, http.StatusOK, subscriptions)
+}
+
+// DeleteWebhook godoc
+// @Summary     Remove a webhook subscription
+// @Tags        Webhooks
+// @Security    BearerAuth
+// @Param       id path string true "Subscription ID"
+// @Success     204
+// @Router      /v1/webhooks/{id} [delete]
+func DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := getOwnedSubscription(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	database.Connector.Delete(&subscription)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetWebhookDeliveries godoc
+// @Summary     Delivery log for a webhook subscription (latest first)
+// @Tags        Webhooks
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Subscription ID"
+// @Success     200     {array} entity.Webhookdelivery
+// @Router      /v1/webhooks/{id}/deliveries [get]
+func GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := getOwnedSubscription(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var deliveries []entity.Webhookdelivery
+	database.Connector.Where("subscriptionid = ?", subscription.Id).Order("id desc").Limit(100).Find(&deliveries)
+	renderJson(w, http.StatusOK, deliveries)
+}
+
+// ReplayWebhookDelivery godoc
+// @Summary     Re-send a previous delivery with the same payload (new delivery ID)
+// @Tags        Webhooks
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id       path string true "Subscription ID"
+// @Param       delivery path string true "Delivery ID"
+// @Success     202     {object} entity.Webhookdelivery
+// @Router      /v1/webhooks/{id}/deliveries/{delivery}/replay [post]
+func ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := getOwnedSubscription(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	vars := mux.Vars(r)
+	var original entity.Webhookdelivery
+	dbQuery := database.Connector.Where("id = ?", vars["delivery"]).Where("subscriptionid = ?", subscription.Id).Find(&original)
+	if dbQuery.RowsAffected == 0 {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	var replay entity.Webhookdelivery
+	replay.Subscriptionid = subscription.Id
+	replay.Event = original.Event
+	replay.Payload = original.Payload
+	replay.Status = entity.WebhookStatusPending
+	replay.Nextattempt = time.Now()
+	replay.Timestamp = time.Now()
+	if err := database.Connector.Create(&replay).Error; err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	go attemptDelivery(replay.Id)
+
+	renderJson(w, http.StatusAccepted, replay)
+}
+
+// PingWebhook godoc
+// @Summary     Send a test "ping" event to a webhook subscription
+// @Tags        Webhooks
+// @Produce     json
+// @Security    BearerAuth
+// @Param       id path string true "Subscription ID"
+// @Success     202     {object} entity.Webhookdelivery
+// @Router      /v1/webhooks/{id}/ping [post]
+func PingWebhook(w http.ResponseWriter, r *http.Request) {
+	subscription, ok := getOwnedSubscription(r)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	delivery := queueDelivery(subscription, entity.WebhookPing, map[string]string{
+		"signupsite": subscription.Signupsite,
+		"message":    "pong",
+	})
+	if delivery == nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	renderJson(w, http.StatusAccepted, delivery)
+}
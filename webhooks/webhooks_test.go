@@ -0,0 +1,63 @@
+package webhooks
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckWebhookHost(t *testing.T) {
+	cases := []struct {
+		host    string
+		blocked bool
+	}{
+		{"127.0.0.1", true},
+		{"::1", true},
+		{"10.1.2.3", true},
+		{"192.168.0.10", true},
+		{"172.16.5.5", true},
+		{"169.254.169.254", true}, //cloud metadata
+		{"fe80::1", true},
+		{"0.0.0.0", true},
+		{"93.184.216.34", false},
+		{"2606:4700::6810:84e5", false},
+	}
+	for _, c := range cases {
+		err := checkWebhookHost(c.host)
+		if blocked := err == errBlockedAddress; blocked != c.blocked {
+			t.Errorf("checkWebhookHost(%s) = %v, want blocked %v", c.host, err, c.blocked)
+		}
+	}
+
+	//a name is blocked when any of its addresses is
+	lookupIP = func(host string) ([]net.IP, error) {
+		return []net.IP{net.ParseIP("93.184.216.34"), net.ParseIP("10.0.0.1")}, nil
+	}
+	t.Cleanup(func() { lookupIP = net.LookupIP })
+	if err := checkWebhookHost("hooks.example.com"); err != errBlockedAddress {
+		t.Errorf("a name with a private address = %v", err)
+	}
+}
+
+func TestDeliveryRefusesLoopback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("a delivery reached a loopback server")
+	}))
+	defer server.Close()
+
+	if _, err := webhookClient.Post(server.URL, "application/json", nil); err == nil {
+		t.Error("the webhook client connected to a loopback address")
+	}
+}
+
+func TestIntegratorNeedsBearerKey(t *testing.T) {
+	t.Setenv("ADMIN_API_KEY_LIST", "key0123456789abcdef")
+	for _, header := range []string{"key0123456789abcdef", "Bearer key0123456789", "Bearer 0123456789abcdef"} {
+		r := httptest.NewRequest("GET", "/v1/webhooks", nil)
+		r.Header.Set("Authorization", header)
+		if _, ok := integratorFromRequest(r); ok {
+			t.Errorf("%q was accepted as an integrator key", header)
+		}
+	}
+}